@@ -165,6 +165,9 @@ var (
 		utils.MainPortFlag,
 		utils.MainUserFlag,
 		utils.MainPasswordFlag,
+		utils.MainCookieFileFlag,
+		utils.MainSidechainFlag,
+		utils.MainMinDepositFlag,
 		utils.AuthListenFlag,
 		utils.AuthPortFlag,
 		utils.AuthVirtualHostsFlag,
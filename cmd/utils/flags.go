@@ -730,6 +730,22 @@ var (
 		Value:    node.DefaultMainPassword,
 		Category: flags.MainCategory,
 	}
+	MainCookieFileFlag = &cli.StringFlag{
+		Name:     "main.cookiefile",
+		Usage:    "Mainchain node RPC cookie file, used instead of main.user and main.password.",
+		Category: flags.MainCategory,
+	}
+	MainSidechainFlag = &cli.IntFlag{
+		Name:     "main.sidechain",
+		Usage:    "Sidechain slot number on mainchain (0-255).",
+		Value:    node.DefaultMainSidechain,
+		Category: flags.MainCategory,
+	}
+	MainMinDepositFlag = &cli.Uint64Flag{
+		Name:     "main.mindeposit",
+		Usage:    "Smallest deposit in satoshis, e.g. 1 on regtest (0 = default).",
+		Category: flags.MainCategory,
+	}
 	GraphQLEnabledFlag = &cli.BoolFlag{
 		Name:     "graphql",
 		Usage:    "Enable GraphQL on the HTTP-RPC server. Note that GraphQL can only be started if an HTTP server is started as well.",
@@ -1196,6 +1212,15 @@ func setMain(ctx *cli.Context, cfg *node.Config) {
 	if cfg.MainPassword == "" {
 		cfg.MainPassword = ctx.String(MainPasswordFlag.Name)
 	}
+	if ctx.IsSet(MainCookieFileFlag.Name) {
+		cfg.MainCookieFile = ctx.String(MainCookieFileFlag.Name)
+	}
+	if ctx.IsSet(MainSidechainFlag.Name) {
+		cfg.MainSidechain = ctx.Int(MainSidechainFlag.Name)
+	}
+	if ctx.IsSet(MainMinDepositFlag.Name) {
+		cfg.MainMinDepositSats = ctx.Uint64(MainMinDepositFlag.Name)
+	}
 }
 
 // setHTTP creates the HTTP RPC listener interface string from the set
@@ -1,6 +1,7 @@
 package bmm
 
 import (
+	"context"
 	"crypto/ecdsa"
 	"errors"
 	"fmt"
@@ -14,7 +15,6 @@ import (
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/drivechain"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/rlp"
@@ -30,26 +30,29 @@ var (
 type Bmm struct {
 	treasuryPrivateKey *ecdsa.PrivateKey
 	treasuryAddress    common.Address
+	feePolicy          *drivechain.BmmFeePolicy
 }
 
-func New(dataDir, host string, port uint16, rpcuser, rpcpassword string) (Bmm, error) {
-	privKey, err := crypto.HexToECDSA(drivechain.TREASURY_PRIVATE_KEY)
-	if err != nil {
-		panic(fmt.Sprintf("can't get treasury private key: %s", err))
-	}
-	address := crypto.PubkeyToAddress(*privKey.Public().(*ecdsa.PublicKey))
-	if err := drivechain.Init(
-		filepath.Join(dataDir, "drivechain"), host, port, rpcuser, rpcpassword,
-	); err != nil {
+// New initializes the drivechain engine with config, storing its database in
+// the drivechain directory under dataDir, and creates a BMM consensus engine.
+func New(dataDir string, config drivechain.Config) (Bmm, error) {
+	config.DBPath = filepath.Join(dataDir, "drivechain")
+	if err := drivechain.InitWithContext(context.Background(), config); err != nil {
 		return Bmm{}, fmt.Errorf("not able to initialize drivechain: %w", err)
 	}
 
 	return Bmm{
-		treasuryPrivateKey: privKey,
-		treasuryAddress:    address,
+		treasuryPrivateKey: drivechain.TreasuryKey(),
+		treasuryAddress:    drivechain.TreasuryAddress(),
+		feePolicy:          drivechain.FixedBmmFee(drivechain.DefaultBmmBribe),
 	}, nil
 }
 
+// SetFeePolicy sets the policy deciding the bribe paid with BMM attempts.
+func (bmm *Bmm) SetFeePolicy(policy *drivechain.BmmFeePolicy) {
+	bmm.feePolicy = policy
+}
+
 func (bmm *Bmm) Author(header *types.Header) (common.Address, error) {
 	return header.Coinbase, nil
 }
@@ -58,7 +61,11 @@ func (bmm *Bmm) Author(header *types.Header) (common.Address, error) {
 // FIXME: Add non PoW checks from ethash consensus engine.
 func (bmm *Bmm) VerifyHeader(chain consensus.ChainHeaderReader, header *types.Header, seal bool) error {
 	log.Info(fmt.Sprintf("verifying %s", header.PrevMainBlockHash.Hex()))
-	if !drivechain.VerifyBmm(header.PrevMainBlockHash, header.Hash()) {
+	valid, err := drivechain.VerifyBmm(header.PrevMainBlockHash, header.Hash())
+	if err != nil {
+		return fmt.Errorf("failed to verify bmm: %w", err)
+	}
+	if !valid {
 		return errors.New("invalid bmm")
 	}
 	return nil
@@ -99,37 +106,106 @@ func (bmm *Bmm) FinalizeAndAssemble(chain consensus.ChainHeaderReader, header *t
 }
 
 func (bmm *Bmm) Seal(chain consensus.ChainHeaderReader, block *types.Block, results chan<- *types.Block, stop <-chan struct{}) error {
-	// FIXME: Make it possible for the miner to change the amount.
-	amount := uint64(10000)
+	amount := bmm.feePolicy.Bribe()
 	header := block.Header()
-	header.PrevMainBlockHash = drivechain.GetMainchainTip()
-	drivechain.AttemptBmm(header, amount)
-	log.Info("attempting to bmm block")
+	tip, err := drivechain.GetMainchainTip()
+	if err != nil {
+		log.Error("Failed to get mainchain tip, not attempting bmm", "err", err)
+		return err
+	}
+	header.PrevMainBlockHash = tip
+	// Abort any drivechain call that is blocked once sealing is stopped.
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	if err := drivechain.Ping(ctx); err != nil {
+		cancel()
+		log.Error("Mainchain unreachable, not attempting bmm", "err", err)
+		return err
+	}
+	txid, err := drivechain.AttemptBmm(ctx, header, amount)
+	if err != nil {
+		cancel()
+		if errors.Is(err, drivechain.ErrBmmNoFunds) {
+			log.Error("Mainchain wallet has no funds for bmm, fund it to seal blocks", "err", err)
+		} else {
+			log.Error("Failed to attempt bmm", "err", err)
+		}
+		return err
+	}
+	log.Info("attempting to bmm block", "txid", txid, "bribe", amount)
 
 	go func() {
+		defer cancel()
+		sealed := false
 		for true {
-			if !drivechain.AttemptBundleBroadcast() {
-				log.Error("failed to broadcast bundle")
+			result, err := drivechain.AttemptBundleBroadcast(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					break
+				}
+				log.Error("Failed to broadcast withdrawal bundle", "err", err)
+			} else if result.Outcome == drivechain.Broadcast {
+				log.Info("Broadcast withdrawal bundle", "hash", result.BundleHash)
 			}
 			// log.Info("checking if block was bmmed")
-			state := drivechain.ConfirmBmm()
-			if state == drivechain.Succeded {
+			state, mainBlockHash, err := drivechain.ConfirmBmm(ctx)
+			if err != nil {
+				break
+			}
+			if state == drivechain.Succeeded {
 				select {
 				case <-stop:
 					break
 				case results <- block.WithSeal(header):
 				default:
 				}
-				log.Info("block was bmmed")
+				sealed = true
+				log.Info("block was bmmed", "mainblock", mainBlockHash)
+				// Start the next block from the policy's base bribe again.
+				bmm.feePolicy.NextBribe(state)
 				break
 			} else if state == drivechain.Failed {
 				log.Info("bmm commitment wasn't inclued in a main:block")
 				log.Info("attempting new bmm request")
-				header.PrevMainBlockHash = drivechain.GetMainchainTip()
-				drivechain.AttemptBmm(header, amount)
+				if err := drivechain.Ping(ctx); err != nil {
+					if ctx.Err() != nil {
+						break
+					}
+					log.Error("Mainchain unreachable, not attempting bmm", "err", err)
+					time.Sleep(1 * time.Second)
+					continue
+				}
+				tip, err := drivechain.GetMainchainTip()
+				if err != nil {
+					log.Error("Failed to get mainchain tip", "err", err)
+					time.Sleep(1 * time.Second)
+					continue
+				}
+				header.PrevMainBlockHash = tip
+				amount = bmm.feePolicy.NextBribe(state)
+				txid, err := drivechain.AttemptBmm(ctx, header, amount)
+				if err != nil {
+					if ctx.Err() == nil {
+						log.Error("Failed to attempt bmm, giving up on block", "err", err)
+					}
+					break
+				}
+				log.Info("attempting to bmm block", "txid", txid, "bribe", amount)
 			}
 			time.Sleep(1 * time.Second)
 		}
+		// Sealing was stopped because the block went stale, stop paying for it.
+		if !sealed && ctx.Err() != nil {
+			if err := drivechain.CancelBmm(); err != nil {
+				log.Warn("Failed to cancel bmm attempt", "err", err)
+			}
+		}
 		log.Info("finished attempting to seal block")
 	}()
 	return nil
@@ -171,7 +247,7 @@ func (bmm *Bmm) APIs(chain consensus.ChainHeaderReader) []rpc.API {
 }
 
 func (bmm *Bmm) Close() error {
-	return nil
+	return drivechain.Shutdown()
 }
 
 // Deposit -- get
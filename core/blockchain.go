@@ -31,6 +31,7 @@ import (
 	"github.com/ethereum/go-ethereum/common/mclock"
 	"github.com/ethereum/go-ethereum/common/prque"
 	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/bmm"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/state/snapshot"
@@ -1291,26 +1292,39 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 	return nil
 }
 
+// UsesDrivechain reports whether the chain is blind merge mined. Only then are
+// blocks connected to and verified with the drivechain engine, other chains
+// never initialize it.
+func (bc *BlockChain) UsesDrivechain() bool {
+	_, ok := bc.engine.(*bmm.Bmm)
+	return ok
+}
+
 func (bc *BlockChain) ConnectBlock(block *types.Block) error {
+	if !bc.UsesDrivechain() {
+		return nil
+	}
 	withdrawals := make(map[common.Hash]drivechain.Withdrawal)
 	deposits := make([]drivechain.Deposit, 0)
 	refunds := make([]drivechain.Refund, 0)
 	refundedWithdrawals := make(map[common.Hash]bool)
 	refundAmounts := make(map[common.Address]*big.Int)
-	treasuryAddress := common.HexToAddress(drivechain.TREASURY_ACCOUNT)
 	blockNumber := big.NewInt(int64(*bc.hc.GetBlockNumber(block.ParentHash())))
+	signer := types.MakeSigner(bc.chainConfig, blockNumber)
+	rules := drivechain.WithdrawalRulesAt(bc.chainConfig, block.Number())
 	for _, tx := range block.Transactions() {
-		if tx.To() != nil && *tx.To() == treasuryAddress {
-			if withdrawal, err := drivechain.DecodeWithdrawal(tx.Value(), tx.Data()); err == nil {
-				withdrawals[tx.Hash()] = withdrawal
-			}
+		id, withdrawal, err := drivechain.ExtractWithdrawal(tx, signer, rules)
+		if withdrawal != nil {
+			withdrawals[id] = *withdrawal
+		} else if errors.Is(err, drivechain.ErrUnknownWithdrawalVersion) {
+			log.Warn("Burning withdrawal with unknown data version", "tx", tx.Hash(), "value", tx.Value(), "err", err)
 		}
-		message, err := tx.AsMessage(types.MakeSigner(bc.chainConfig, blockNumber), nil)
+		message, err := tx.AsMessage(signer, nil)
 		if err != nil {
 			log.Error(fmt.Sprintf("failed to convert tx to message: %s", err))
 		}
 		if tx.To() != nil {
-			if message.From() == treasuryAddress {
+			if drivechain.IsTreasury(message.From()) {
 				if len(message.Data()) == 0 {
 					// Handle. deposits.
 					var amount big.Int
@@ -1321,10 +1335,10 @@ func (bc *BlockChain) ConnectBlock(block *types.Block) error {
 					}
 					deposits = append(deposits, deposit)
 				}
-			} else if *tx.To() == treasuryAddress && len(message.Data()) == common.HashLength && message.Value().Cmp(common.Big0) == 0 {
-				hash := common.BytesToHash(message.Data())
+			} else if refund, err := drivechain.ExtractRefund(tx, signer); refund != nil && err == nil {
+				hash := refund.Id
 				withdrawalTx, _, _, _ := bc.GetTransaction(hash)
-				withdrawalMessage, err := withdrawalTx.AsMessage(types.MakeSigner(bc.chainConfig, blockNumber), nil)
+				withdrawalMessage, err := withdrawalTx.AsMessage(signer, nil)
 				if err != nil {
 					log.Error(fmt.Sprintf("failed to convert tx to message: %s", err))
 				}
@@ -1343,22 +1357,17 @@ func (bc *BlockChain) ConnectBlock(block *types.Block) error {
 					refundAmounts[address] = big.NewInt(0)
 				}
 				refundAmounts[address].Add(refundAmounts[address], withdrawalMessage.Value())
-				var satAmount big.Int
-				satAmount.Div(withdrawalTx.Value(), drivechain.Satoshi)
-				refund := drivechain.Refund{
-					Id:     withdrawalTx.Hash(),
-					Amount: &satAmount,
-				}
-				refunds = append(refunds, refund)
+				refund.Amount = new(big.Int).Div(withdrawalTx.Value(), drivechain.Satoshi)
+				refunds = append(refunds, *refund)
 			}
 		}
 	}
 	for _, tx := range block.Transactions() {
-		message, err := tx.AsMessage(types.MakeSigner(bc.chainConfig, blockNumber), nil)
+		message, err := tx.AsMessage(signer, nil)
 		if err != nil {
 			log.Error(fmt.Sprintf("failed to convert tx to message: %s", err))
 		}
-		if tx.To() != nil && message.From() == treasuryAddress && len(message.Data()) == 1 && message.Data()[0] == 1 {
+		if tx.To() != nil && drivechain.IsTreasury(message.From()) && len(message.Data()) == 1 && message.Data()[0] == 1 {
 			refundAmounts[*tx.To()].Sub(refundAmounts[*tx.To()], tx.Value())
 		}
 	}
@@ -1369,18 +1378,18 @@ func (bc *BlockChain) ConnectBlock(block *types.Block) error {
 	}
 	/////////// Drivechain update
 	// Update drivechain db with paid out deposits and with new withdrawals.
-	if !drivechain.ConnectBlock(deposits, withdrawals, refunds, false) {
-		log.Info("ConnectBlock")
-		log.Error("failed to connect block data for drivechain")
-		err := errors.New("failed to connect block data for drivechain")
-		return err
+	if err := drivechain.ConnectBlock(block.Hash(), deposits, withdrawals, refunds, false); err != nil {
+		log.Error("failed to connect block data for drivechain", "hash", block.Hash(), "err", err)
+		return fmt.Errorf("failed to connect block data for drivechain: %w", err)
 	}
 	return nil
 }
 
 func (bc *BlockChain) DisconnectBlock(block *types.Block) error {
+	if !bc.UsesDrivechain() {
+		return nil
+	}
 	log.Info(fmt.Sprintf("Disconnecting block: %s", block.Hash().Hex()))
-	treasuryAddress := common.HexToAddress(drivechain.TREASURY_ACCOUNT)
 	deposits := make([]drivechain.Deposit, 0)
 	withdrawals := make([]common.Hash, 0)
 	refunds := make(map[common.Hash]bool)
@@ -1388,18 +1397,18 @@ func (bc *BlockChain) DisconnectBlock(block *types.Block) error {
 	if block.NumberU64() > 0 {
 		blockNumber = big.NewInt(int64(*bc.hc.GetBlockNumber(block.ParentHash())))
 	}
+	signer := types.MakeSigner(bc.chainConfig, blockNumber)
+	rules := drivechain.WithdrawalRulesAt(bc.chainConfig, block.Number())
 	for _, tx := range block.Transactions() {
-		if tx.To() != nil && *tx.To() == treasuryAddress {
-			if _, err := drivechain.DecodeWithdrawal(tx.Value(), tx.Data()); err == nil {
-				withdrawals = append(withdrawals, tx.Hash())
-			}
+		if id, withdrawal, _ := drivechain.ExtractWithdrawal(tx, signer, rules); withdrawal != nil {
+			withdrawals = append(withdrawals, id)
 		}
-		message, err := tx.AsMessage(types.MakeSigner(bc.chainConfig, blockNumber), nil)
+		message, err := tx.AsMessage(signer, nil)
 		if err != nil {
 			log.Error(fmt.Sprintf("failed to convert tx to message: %s", err))
 		}
 		if tx.To() != nil {
-			if message.From() == treasuryAddress {
+			if drivechain.IsTreasury(message.From()) {
 				if len(message.Data()) == 0 {
 					// Handle. deposits.
 					var amount big.Int
@@ -1410,10 +1419,9 @@ func (bc *BlockChain) DisconnectBlock(block *types.Block) error {
 					}
 					deposits = append(deposits, deposit)
 				}
-			} else if *tx.To() == treasuryAddress && len(message.Data()) == common.HashLength && message.Value().Cmp(common.Big0) == 0 {
-				hash := common.BytesToHash(message.Data())
-				withdrawalTx, _, _, _ := bc.GetTransaction(hash)
-				withdrawalMessage, err := withdrawalTx.AsMessage(types.MakeSigner(bc.chainConfig, blockNumber), nil)
+			} else if refund, err := drivechain.ExtractRefund(tx, signer); refund != nil && err == nil {
+				withdrawalTx, _, _, _ := bc.GetTransaction(refund.Id)
+				withdrawalMessage, err := withdrawalTx.AsMessage(signer, nil)
 				if err != nil {
 					log.Error(fmt.Sprintf("failed to convert tx to message: %s", err))
 				}
@@ -1433,10 +1441,9 @@ func (bc *BlockChain) DisconnectBlock(block *types.Block) error {
 	}
 	/////////// Drivechain update
 	// Update drivechain db with paid out deposits and with new withdrawals.
-	if !drivechain.DisconnectBlock(deposits, withdrawals, refundsSlice, false) {
-		log.Error("failed to connect block data for drivechain")
-		err := errors.New("failed to connect block data for drivechain")
-		return err
+	if err := drivechain.DisconnectBlock(block.Hash(), deposits, withdrawals, refundsSlice, false); err != nil {
+		log.Error("failed to disconnect block data for drivechain", "hash", block.Hash(), "err", err)
+		return fmt.Errorf("failed to disconnect block data for drivechain: %w", err)
 	}
 	return nil
 }
@@ -1463,13 +1470,26 @@ func (bc *BlockChain) writeBlockAndSetHead(block *types.Block, receipts []*types
 	}
 	currentBlock := bc.CurrentBlock()
 	// Handle mainchain Reorg /////
-	if currentBlock.NumberU64() > 0 && !drivechain.VerifyBmm(currentBlock.PrevMainBlockHash(), currentBlock.Hash()) {
-		if err := bc.DisconnectBlock(block); err != nil {
+	if bc.UsesDrivechain() && currentBlock.NumberU64() > 0 {
+		valid, err := drivechain.VerifyBmm(currentBlock.PrevMainBlockHash(), currentBlock.Hash())
+		if err != nil {
 			return NonStatTy, err
 		}
-		block = currentBlock
+		if !valid {
+			if err := bc.DisconnectBlock(block); err != nil {
+				return NonStatTy, err
+			}
+			block = currentBlock
+		}
 	}
-	for currentBlock.NumberU64() > 0 && !drivechain.VerifyBmm(block.PrevMainBlockHash(), block.Hash()) {
+	for bc.UsesDrivechain() && currentBlock.NumberU64() > 0 {
+		valid, err := drivechain.VerifyBmm(block.PrevMainBlockHash(), block.Hash())
+		if err != nil {
+			return NonStatTy, err
+		}
+		if valid {
+			break
+		}
 		if err := bc.DisconnectBlock(block); err != nil {
 			return NonStatTy, err
 		}
@@ -96,4 +96,9 @@ var (
 
 	// ErrSenderNoEOA is returned if the sender of a transaction is a contract.
 	ErrSenderNoEOA = errors.New("sender not an eoa")
+
+	// ErrInvalidWithdrawal is returned if a transaction to the drivechain
+	// treasury is a withdrawal that can't be paid out, once strict withdrawals
+	// are active.
+	ErrInvalidWithdrawal = errors.New("invalid withdrawal")
 )
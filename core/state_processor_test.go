@@ -30,6 +30,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/drivechain"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/trie"
 	"golang.org/x/crypto/sha3"
@@ -42,19 +43,20 @@ import (
 func TestStateProcessorErrors(t *testing.T) {
 	var (
 		config = &params.ChainConfig{
-			ChainID:             big.NewInt(1),
-			HomesteadBlock:      big.NewInt(0),
-			EIP150Block:         big.NewInt(0),
-			EIP155Block:         big.NewInt(0),
-			EIP158Block:         big.NewInt(0),
-			ByzantiumBlock:      big.NewInt(0),
-			ConstantinopleBlock: big.NewInt(0),
-			PetersburgBlock:     big.NewInt(0),
-			IstanbulBlock:       big.NewInt(0),
-			MuirGlacierBlock:    big.NewInt(0),
-			BerlinBlock:         big.NewInt(0),
-			LondonBlock:         big.NewInt(0),
-			Ethash:              new(params.EthashConfig),
+			ChainID:               big.NewInt(1),
+			HomesteadBlock:        big.NewInt(0),
+			EIP150Block:           big.NewInt(0),
+			EIP155Block:           big.NewInt(0),
+			EIP158Block:           big.NewInt(0),
+			ByzantiumBlock:        big.NewInt(0),
+			ConstantinopleBlock:   big.NewInt(0),
+			PetersburgBlock:       big.NewInt(0),
+			IstanbulBlock:         big.NewInt(0),
+			MuirGlacierBlock:      big.NewInt(0),
+			BerlinBlock:           big.NewInt(0),
+			LondonBlock:           big.NewInt(0),
+			StrictWithdrawalBlock: big.NewInt(0),
+			Ethash:                new(params.EthashConfig),
 		}
 		signer  = types.LatestSigner(config)
 		key1, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
@@ -98,6 +100,8 @@ func TestStateProcessorErrors(t *testing.T) {
 		bigNumber := new(big.Int).SetBytes(common.FromHex("0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"))
 		tooBigNumber := new(big.Int).Set(bigNumber)
 		tooBigNumber.Add(tooBigNumber, common.Big1)
+		// A fee of 10 satoshis followed by a mainchain address.
+		withdrawalData := common.FromHex("000000000000000a62e907b15cbf27d5425399ebf6f0fb50ebb88f18")
 		for i, tt := range []struct {
 			txs  []*types.Transaction
 			want string
@@ -196,6 +200,12 @@ func TestStateProcessorErrors(t *testing.T) {
 				},
 				want: "could not apply tx 0 [0xd82a0c2519acfeac9a948258c47e784acd20651d9d80f9a1c67b4137651c3a24]: insufficient funds for gas * price + value: address 0x71562b71999873DB5b286dF957af199Ec94617F7 have 1000000000000000000 want 2431633873983640103894990685182446064918669677978451844828609264166175722438635000",
 			},
+			{ // ErrInvalidWithdrawal
+				txs: []*types.Transaction{
+					makeTx(key1, 0, drivechain.TreasuryAddress(), big.NewInt(1), 100000, big.NewInt(875000000), withdrawalData),
+				},
+				want: "could not apply tx 0 [0x82d0a9144381eef407ce7d384e35e73067a94929ed4bbaf1cd0983f4fd2f6bf3]: invalid withdrawal: address 0x71562b71999873DB5b286dF957af199Ec94617F7: withdrawal amount not a whole number of satoshis",
+			},
 		} {
 			block := GenerateBadBlock(genesis, ethash.NewFaker(), tt.txs, gspec.Config)
 			_, err := blockchain.InsertChain(types.Blocks{block})
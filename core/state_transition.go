@@ -26,6 +26,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/drivechain"
 	"github.com/ethereum/go-ethereum/params"
 )
 
@@ -232,6 +233,11 @@ func (st *StateTransition) preCheck() error {
 				st.msg.From().Hex(), codeHash)
 		}
 	}
+	// Make sure withdrawals can be paid out instead of burning their value
+	rules := drivechain.WithdrawalRulesAt(st.evm.ChainConfig(), st.evm.Context.BlockNumber)
+	if err := rules.ValidateWithdrawal(st.msg.From(), st.msg.To(), st.msg.Value(), st.msg.Data()); err != nil {
+		return fmt.Errorf("%w: address %v: %v", ErrInvalidWithdrawal, st.msg.From().Hex(), err)
+	}
 	// Make sure that transaction gasFeeCap is greater than the baseFee (post london)
 	if st.evm.ChainConfig().IsLondon(st.evm.Context.BlockNumber) {
 		// Skip the checks if gas fields are zero and baseFee was explicitly disabled (eth_call)
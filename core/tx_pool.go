@@ -18,6 +18,7 @@ package core
 
 import (
 	"errors"
+	"fmt"
 	"math"
 	"math/big"
 	"sort"
@@ -146,6 +147,7 @@ type blockChain interface {
 	CurrentBlock() *types.Block
 	GetBlock(hash common.Hash, number uint64) *types.Block
 	StateAt(root common.Hash) (*state.StateDB, error)
+	UsesDrivechain() bool
 
 	SubscribeChainHeadEvent(ch chan<- ChainHeadEvent) event.Subscription
 }
@@ -245,6 +247,8 @@ type TxPool struct {
 	eip2718  bool // Fork indicator whether we are using EIP-2718 type transactions.
 	eip1559  bool // Fork indicator whether we are using EIP-1559 type transactions.
 
+	withdrawalRules drivechain.WithdrawalRules // Withdrawal rules of the next block
+
 	currentState  *state.StateDB // Current state in the blockchain head
 	pendingNonces *txNoncer      // Pending state tracking virtual nonces
 	currentMaxGas uint64         // Current gas limit for transaction caps
@@ -585,11 +589,17 @@ func (pool *TxPool) local() map[common.Address]types.Transactions {
 // validateTx checks whether a transaction is valid according to the consensus
 // rules and adheres to some heuristic limits of the local node (price and size).
 func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
-	treasuryAddress := common.HexToAddress(drivechain.TREASURY_ACCOUNT)
-	if tx.To() != nil && *tx.To() == treasuryAddress && len(tx.Data()) == common.HashLength {
-		refund := common.BytesToHash(tx.Data())
-		if drivechain.IsWithdrawalSpent(refund) {
-			return types.ErrRefundSpent
+	// Invalid signatures are rejected below. Only chains running the
+	// drivechain engine have withdrawals to refund.
+	if pool.chain.UsesDrivechain() {
+		if refund, err := drivechain.ExtractRefund(tx, pool.signer); err == nil && refund != nil {
+			spent, err := drivechain.IsWithdrawalSpent(refund.Id)
+			if err != nil {
+				return err
+			}
+			if spent {
+				return types.ErrRefundSpent
+			}
 		}
 	}
 	// Accept only legacy transactions until EIP-2718/2930 activates.
@@ -629,6 +639,10 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	if err != nil {
 		return ErrInvalidSender
 	}
+	// Reject withdrawals that would burn their value
+	if err := pool.withdrawalRules.ValidateWithdrawal(from, tx.To(), tx.Value(), tx.Data()); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidWithdrawal, err)
+	}
 	// Drop non-local transactions under our own minimal accepted gas price or tip
 	if !local && tx.GasTipCapIntCmp(pool.gasPrice) < 0 {
 		return ErrUnderpriced
@@ -1312,6 +1326,7 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 	pool.istanbul = pool.chainconfig.IsIstanbul(next)
 	pool.eip2718 = pool.chainconfig.IsBerlin(next)
 	pool.eip1559 = pool.chainconfig.IsLondon(next)
+	pool.withdrawalRules = drivechain.WithdrawalRulesAt(pool.chainconfig, next)
 }
 
 // promoteExecutables moves transactions that have become processable from the
@@ -32,6 +32,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/drivechain"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/trie"
@@ -76,6 +77,10 @@ func (bc *testBlockChain) StateAt(common.Hash) (*state.StateDB, error) {
 	return bc.statedb, nil
 }
 
+func (bc *testBlockChain) UsesDrivechain() bool {
+	return false
+}
+
 func (bc *testBlockChain) SubscribeChainHeadEvent(ch chan<- ChainHeadEvent) event.Subscription {
 	return bc.chainHeadFeed.Subscribe(ch)
 }
@@ -380,6 +385,22 @@ func TestTransactionNegativeValue(t *testing.T) {
 	}
 }
 
+func TestTransactionInvalidWithdrawal(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	// A withdrawal of a single wei, with a fee of 10 satoshis.
+	data := common.FromHex("000000000000000a62e907b15cbf27d5425399ebf6f0fb50ebb88f18")
+	tx, _ := types.SignTx(types.NewTransaction(0, drivechain.TreasuryAddress(), big.NewInt(1), 100000, big.NewInt(1), data), types.HomesteadSigner{}, key)
+	from, _ := deriveSender(tx)
+	testAddBalance(pool, from, big.NewInt(1000000))
+	if err := pool.AddRemote(tx); !errors.Is(err, ErrInvalidWithdrawal) {
+		t.Error("expected", ErrInvalidWithdrawal, "got", err)
+	}
+}
+
 func TestTransactionTipAboveFeeCap(t *testing.T) {
 	t.Parallel()
 
@@ -0,0 +1,77 @@
+package drivechain
+
+/*
+#include "./bindings.h"
+*/
+import "C"
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ErrNotIndexed is returned by GetBlockSidechainActivity for blocks the engine
+// has no activity recorded for, e.g. blocks connected without their hash or
+// before the engine recorded activity.
+var ErrNotIndexed = errors.New("block activity not indexed")
+
+// BlockActivity is the drivechain related content of a sidechain block, as
+// recorded by ConnectBlock.
+type BlockActivity struct {
+	Deposits    []Deposit     // Deposits credited, amounts in satoshi
+	Withdrawals []common.Hash // Transaction hashes of the withdrawals created
+	Refunds     []common.Hash // Withdrawals refunded
+}
+
+// GetBlockSidechainActivity returns the deposits, withdrawals and refunds
+// applied by the sidechain block with the given hash, as recorded by
+// ConnectBlock. It fails with ErrNotIndexed if nothing was recorded for the
+// block.
+func (CGO) GetBlockSidechainActivity(sideBlockHash common.Hash) (BlockActivity, error) {
+	if err := rlockEngine(); err != nil {
+		return BlockActivity{}, err
+	}
+	defer engineMu.RUnlock()
+	var args cArgs
+	defer args.free()
+	cSideBlockHash := args.cString(sideBlockHash.Hex())
+	return blockActivity(sideBlockHash, C.get_block_activity(cSideBlockHash))
+}
+
+// blockActivity converts the activity the engine returned for a sidechain
+// block, freeing it.
+func blockActivity(sideBlockHash common.Hash, cActivity C.struct_BlockActivity) (BlockActivity, error) {
+	defer C.free_block_activity(cActivity)
+	if !bool(cActivity.valid) {
+		return BlockActivity{}, fmt.Errorf("%w: %s", ErrEngineFailure, getLastError())
+	}
+	if !bool(cActivity.indexed) {
+		return BlockActivity{}, fmt.Errorf("%w: %s", ErrNotIndexed, sideBlockHash.Hex())
+	}
+	rawDeposits := make([]RawDeposit, 0, cActivity.deposits.len)
+	for _, cDeposit := range unsafe.Slice(cActivity.deposits.ptr, cActivity.deposits.len) {
+		rawDeposit := RawDeposit{
+			address: goAddress(cDeposit.address).Hex(),
+			amount:  uint64(cDeposit.amount),
+			vout:    uint32(cDeposit.vout),
+		}
+		if cDeposit.txid != nil {
+			rawDeposit.txid = C.GoString(cDeposit.txid)
+		}
+		rawDeposits = append(rawDeposits, rawDeposit)
+	}
+	activity := BlockActivity{
+		Deposits:    newDepositsFromRaw(rawDeposits),
+		Withdrawals: make([]common.Hash, 0, cActivity.withdrawals.len),
+		Refunds:     make([]common.Hash, 0, cActivity.refunds.len),
+	}
+	for _, cWithdrawal := range unsafe.Slice(cActivity.withdrawals.ptr, cActivity.withdrawals.len) {
+		activity.Withdrawals = append(activity.Withdrawals, goHash(cWithdrawal.id))
+	}
+	for _, cRefund := range unsafe.Slice(cActivity.refunds.ptr, cActivity.refunds.len) {
+		activity.Refunds = append(activity.Refunds, goHash(cRefund.id))
+	}
+	return activity, nil
+}
@@ -0,0 +1,45 @@
+package drivechain
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Tests that the activity of a block is recorded when it's connected and
+// dropped when it's disconnected, but not when it's only checked.
+func TestBlockSidechainActivity(t *testing.T) {
+	initTestEngine(t, nil)
+
+	hash := common.HexToHash("0xb10c")
+	deposits := []Deposit{{Address: TreasuryAddress(), Amount: big.NewInt(5000)}}
+	if _, err := GetBlockSidechainActivity(hash); !errors.Is(err, ErrNotIndexed) {
+		t.Fatalf("unknown block: error mismatch: have %v, want %v", err, ErrNotIndexed)
+	}
+	if err := ConnectBlock(hash, deposits, nil, nil, true); err != nil {
+		t.Fatalf("failed to check block: %v", err)
+	}
+	if _, err := GetBlockSidechainActivity(hash); !errors.Is(err, ErrNotIndexed) {
+		t.Fatalf("checked block: error mismatch: have %v, want %v", err, ErrNotIndexed)
+	}
+
+	if err := ConnectBlock(hash, deposits, nil, nil, false); err != nil {
+		t.Fatalf("failed to connect block: %v", err)
+	}
+	activity, err := GetBlockSidechainActivity(hash)
+	if err != nil {
+		t.Fatalf("failed to get activity: %v", err)
+	}
+	if len(activity.Deposits) != 1 || activity.Deposits[0].Address != deposits[0].Address || activity.Deposits[0].Amount.Cmp(deposits[0].Amount) != 0 {
+		t.Errorf("deposits mismatch: have %v, want %v", activity.Deposits, deposits)
+	}
+
+	if err := DisconnectBlock(hash, deposits, nil, nil, false); err != nil {
+		t.Fatalf("failed to disconnect block: %v", err)
+	}
+	if _, err := GetBlockSidechainActivity(hash); !errors.Is(err, ErrNotIndexed) {
+		t.Errorf("disconnected block: error mismatch: have %v, want %v", err, ErrNotIndexed)
+	}
+}
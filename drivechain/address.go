@@ -0,0 +1,359 @@
+package drivechain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+var (
+	// ErrInvalidMainchainAddress is returned when parsing a malformed
+	// mainchain address, and by FormatMainchainAddress if the engine
+	// formatted an address wrongly.
+	ErrInvalidMainchainAddress = errors.New("invalid mainchain address")
+
+	// ErrUnknownMainchainAddressType is returned for addresses tagged with a
+	// type this package doesn't know.
+	ErrUnknownMainchainAddressType = errors.New("unknown mainchain address type")
+)
+
+// MainchainAddressType is the kind of mainchain output a withdrawal pays to. In
+// tagged withdrawal data it's encoded as a single byte.
+type MainchainAddressType uint8
+
+const (
+	P2PKH  MainchainAddressType = iota // Pay to the hash160 of a key
+	P2WPKH                             // Pay to the hash160 of a key, segwit v0
+	P2WSH                              // Pay to the sha256 of a script, segwit v0
+	P2TR                               // Pay to a taproot output key, segwit v1
+)
+
+var mainchainAddressTypeNames = [...]string{
+	P2PKH:  "p2pkh",
+	P2WPKH: "p2wpkh",
+	P2WSH:  "p2wsh",
+	P2TR:   "p2tr",
+}
+
+// String implements fmt.Stringer.
+func (t MainchainAddressType) String() string {
+	if int(t) < len(mainchainAddressTypeNames) {
+		return mainchainAddressTypeNames[t]
+	}
+	return fmt.Sprintf("MainchainAddressType(%d)", uint8(t))
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (t MainchainAddressType) MarshalText() ([]byte, error) {
+	if int(t) >= len(mainchainAddressTypeNames) {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownMainchainAddressType, uint8(t))
+	}
+	return []byte(mainchainAddressTypeNames[t]), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. Names are matched case
+// insensitively.
+func (t *MainchainAddressType) UnmarshalText(text []byte) error {
+	for typ, name := range mainchainAddressTypeNames {
+		if strings.EqualFold(name, string(text)) {
+			*t = MainchainAddressType(typ)
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %q", ErrUnknownMainchainAddressType, text)
+}
+
+// Length returns the length of the payload of addresses of type t, or 0 if t
+// isn't a known type.
+func (t MainchainAddressType) Length() int {
+	switch t {
+	case P2PKH, P2WPKH:
+		return MainchainAddressLength
+	case P2WSH, P2TR:
+		return MaxMainchainAddressLength
+	}
+	return 0
+}
+
+// MainchainAddress is a mainchain destination withdrawn funds are paid out to:
+// the hash or key of the output script, tagged with its type. The zero value is
+// the zero P2PKH address. MainchainAddresses are comparable with ==.
+type MainchainAddress struct {
+	Type    MainchainAddressType
+	payload [MaxMainchainAddressLength]byte // Zero padded past Type.Length()
+}
+
+// NewMainchainAddress returns the address of type typ with the given payload.
+func NewMainchainAddress(typ MainchainAddressType, payload []byte) (MainchainAddress, error) {
+	n := typ.Length()
+	if n == 0 {
+		return MainchainAddress{}, fmt.Errorf("%w: %v", ErrUnknownMainchainAddressType, typ)
+	}
+	if len(payload) != n {
+		return MainchainAddress{}, fmt.Errorf("%w: have %d bytes for %v, want %d", ErrInvalidMainchainAddress, len(payload), typ, n)
+	}
+	address := MainchainAddress{Type: typ}
+	copy(address.payload[:], payload)
+	return address, nil
+}
+
+// MainchainAddressFromBytes returns the P2PKH address with the hash160 b. It
+// fails unless b is exactly MainchainAddressLength bytes long. Use
+// NewMainchainAddress for other address types.
+func MainchainAddressFromBytes(b []byte) (MainchainAddress, error) {
+	return NewMainchainAddress(P2PKH, b)
+}
+
+// Bytes returns a copy of the address' payload, Type.Length() bytes long.
+func (a MainchainAddress) Bytes() []byte {
+	return append([]byte(nil), a.payload[:a.Type.Length()]...)
+}
+
+// IsZero reports whether the address' payload is all zeros.
+func (a MainchainAddress) IsZero() bool {
+	return a.payload == [MaxMainchainAddressLength]byte{}
+}
+
+// encode appends the address in the withdrawal data format: the bare hash160
+// for P2PKH addresses unless tagged is set, the type byte followed by the
+// payload otherwise.
+func (a MainchainAddress) encode(b []byte, tagged bool) []byte {
+	if tagged || a.Type != P2PKH {
+		b = append(b, byte(a.Type))
+	}
+	return append(b, a.payload[:a.Type.Length()]...)
+}
+
+// String returns the address formatted by the engine, see
+// FormatMainchainAddress. It is empty if the engine isn't running.
+func (a MainchainAddress) String() string {
+	s, _ := FormatMainchainAddress(a)
+	return s
+}
+
+// mainchainAddressJSON is the JSON encoding of a MainchainAddress, which
+// doesn't need the engine unlike String.
+type mainchainAddressJSON struct {
+	Type    MainchainAddressType `json:"type"`
+	Payload hexutil.Bytes        `json:"payload"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (a MainchainAddress) MarshalJSON() ([]byte, error) {
+	return json.Marshal(mainchainAddressJSON{Type: a.Type, Payload: a.Bytes()})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (a *MainchainAddress) UnmarshalJSON(input []byte) error {
+	var dec mainchainAddressJSON
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	address, err := NewMainchainAddress(dec.Type, dec.Payload)
+	if err != nil {
+		return err
+	}
+	*a = address
+	return nil
+}
+
+// ParseMainchainAddress decodes a base58check encoded P2PKH mainchain address,
+// as returned by FormatMainchainAddress. Other base58check addresses, like
+// P2SH ones, are rejected by their version byte, as their hash160 taken for a
+// P2PKH one would pay an output nobody can spend.
+func ParseMainchainAddress(s string) (MainchainAddress, error) {
+	decoded, ok := decodeBase58(s)
+	// One version byte, the address and a four byte checksum.
+	if !ok || len(decoded) != 1+MainchainAddressLength+4 {
+		return MainchainAddress{}, ErrInvalidMainchainAddress
+	}
+	payload, checksum := decoded[:len(decoded)-4], decoded[len(decoded)-4:]
+	first := sha256.Sum256(payload)
+	second := sha256.Sum256(first[:])
+	if !bytes.Equal(second[:4], checksum) {
+		return MainchainAddress{}, ErrInvalidMainchainAddress
+	}
+	if version := payload[0]; version != p2pkhVersion && version != testnetP2PKHVersion {
+		return MainchainAddress{}, fmt.Errorf("%w: unsupported version %d", ErrInvalidMainchainAddress, version)
+	}
+	return MainchainAddressFromBytes(payload[1:])
+}
+
+// Version bytes of base58check encoded P2PKH addresses on mainnet and on
+// testnet, signet and regtest.
+const (
+	p2pkhVersion        = 0x00
+	testnetP2PKHVersion = 0x6f
+)
+
+// ParseWithdrawalAddress decodes a mainchain address in the text form a user
+// would give it: base58check for P2PKH addresses, bech32 for segwit v0 ones
+// and bech32m for taproot ones. Other kinds of addresses, like P2SH, are
+// rejected, as withdrawals can't pay to them. It doesn't check which network
+// the address is for.
+func ParseWithdrawalAddress(s string) (MainchainAddress, error) {
+	if version, program, ok := decodeSegwitAddress(s); ok {
+		switch {
+		case version == 0 && len(program) == MainchainAddressLength:
+			return NewMainchainAddress(P2WPKH, program)
+		case version == 0 && len(program) == MaxMainchainAddressLength:
+			return NewMainchainAddress(P2WSH, program)
+		case version == 1 && len(program) == MaxMainchainAddressLength:
+			return NewMainchainAddress(P2TR, program)
+		}
+		return MainchainAddress{}, fmt.Errorf("%w: %q has unsupported witness version %d with a %d byte program", ErrInvalidMainchainAddress, s, version, len(program))
+	}
+	address, err := ParseMainchainAddress(s)
+	if err != nil {
+		return MainchainAddress{}, fmt.Errorf("%w: %q", err, s)
+	}
+	return address, nil
+}
+
+// FormatWithdrawalAddress returns the address a withdrawal pays to in text
+// form, see FormatMainchainAddress.
+func FormatWithdrawalAddress(w Withdrawal) (string, error) {
+	return FormatMainchainAddress(w.Address)
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+var bigRadix = big.NewInt(58)
+
+func decodeBase58(s string) ([]byte, bool) {
+	n := new(big.Int)
+	for _, r := range s {
+		digit := bytes.IndexRune([]byte(base58Alphabet), r)
+		if digit < 0 {
+			return nil, false
+		}
+		n.Mul(n, bigRadix)
+		n.Add(n, big.NewInt(int64(digit)))
+	}
+	// Every leading '1' encodes a leading zero byte.
+	zeros := 0
+	for zeros < len(s) && s[zeros] == base58Alphabet[0] {
+		zeros++
+	}
+	return append(make([]byte, zeros), n.Bytes()...), true
+}
+
+// checkFormattedAddress verifies that s is the text form of address: a
+// base58check encoded P2PKH address or a bech32 (witness version 0) or bech32m
+// (witness version 1) encoded segwit address with the same payload.
+func checkFormattedAddress(address MainchainAddress, s string) error {
+	var payload []byte
+	switch address.Type {
+	case P2PKH:
+		decoded, ok := decodeBase58(s)
+		if !ok || len(decoded) != 1+MainchainAddressLength+4 {
+			return fmt.Errorf("%w: %q not a base58check address", ErrInvalidMainchainAddress, s)
+		}
+		sum := sha256.Sum256(decoded[:len(decoded)-4])
+		sum = sha256.Sum256(sum[:])
+		if !bytes.Equal(sum[:4], decoded[len(decoded)-4:]) {
+			return fmt.Errorf("%w: %q has a bad checksum", ErrInvalidMainchainAddress, s)
+		}
+		payload = decoded[1 : len(decoded)-4]
+	case P2WPKH, P2WSH, P2TR:
+		version, program, ok := decodeSegwitAddress(s)
+		if !ok {
+			return fmt.Errorf("%w: %q not a segwit address", ErrInvalidMainchainAddress, s)
+		}
+		want := byte(0)
+		if address.Type == P2TR {
+			want = 1
+		}
+		if version != want {
+			return fmt.Errorf("%w: %q has witness version %d, want %d", ErrInvalidMainchainAddress, s, version, want)
+		}
+		payload = program
+	default:
+		return fmt.Errorf("%w: %v", ErrUnknownMainchainAddressType, address.Type)
+	}
+	if !bytes.Equal(payload, address.Bytes()) {
+		return fmt.Errorf("%w: %q doesn't encode %v address %x", ErrInvalidMainchainAddress, s, address.Type, address.Bytes())
+	}
+	return nil
+}
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// Checksum constants of bech32 (BIP 173) and bech32m (BIP 350).
+const (
+	bech32Const  = 1
+	bech32mConst = 0x2bc830a3
+)
+
+// decodeSegwitAddress decodes a bech32 or bech32m encoded segwit address,
+// checking that witness version 0 uses bech32 and later versions bech32m.
+func decodeSegwitAddress(s string) (version byte, program []byte, ok bool) {
+	lower := strings.ToLower(s)
+	if len(s) > 90 || (s != lower && s != strings.ToUpper(s)) {
+		return 0, nil, false
+	}
+	sep := strings.LastIndexByte(lower, '1')
+	// At least one hrp character, the witness version and a six character
+	// checksum.
+	if sep < 1 || len(lower)-sep-1 < 1+6 {
+		return 0, nil, false
+	}
+	hrp, data := lower[:sep], make([]byte, 0, len(lower)-sep-1)
+	for _, c := range lower[sep+1:] {
+		digit := strings.IndexRune(bech32Charset, c)
+		if digit < 0 {
+			return 0, nil, false
+		}
+		data = append(data, byte(digit))
+	}
+	values := make([]byte, 0, 2*len(hrp)+1+len(data))
+	for _, c := range []byte(hrp) {
+		values = append(values, c>>5)
+	}
+	values = append(values, 0)
+	for _, c := range []byte(hrp) {
+		values = append(values, c&31)
+	}
+	checksum := bech32Polymod(append(values, data...))
+
+	version = data[0]
+	if version > 16 || (version == 0 && checksum != bech32Const) || (version != 0 && checksum != bech32mConst) {
+		return 0, nil, false
+	}
+	// Regroup the 5 bit groups between the version and the checksum into
+	// bytes, the padding must be zero.
+	var acc, bits uint
+	for _, d := range data[1 : len(data)-6] {
+		acc = acc<<5 | uint(d)
+		bits += 5
+		if bits >= 8 {
+			bits -= 8
+			program = append(program, byte(acc>>bits))
+		}
+	}
+	if bits >= 5 || acc&(1<<bits-1) != 0 || len(program) < 2 || len(program) > 40 {
+		return 0, nil, false
+	}
+	return version, program, true
+}
+
+func bech32Polymod(values []byte) uint32 {
+	gen := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>i)&1 == 1 {
+				chk ^= gen[i]
+			}
+		}
+	}
+	return chk
+}
@@ -0,0 +1,230 @@
+package drivechain
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestParseMainchainAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		want    string // hex encoded hash160, empty if invalid
+	}{
+		// Mainnet and testnet P2PKH addresses.
+		{"1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa", "62e907b15cbf27d5425399ebf6f0fb50ebb88f18"},
+		{"mipcBbFg9gMiCh81Kj8tqqdgoZub1ZJRfn", "243f1394f44554f4ce3fd68649c19adc483ce924"},
+		{"1111111111111111111114oLvT2", "0000000000000000000000000000000000000000"},
+		// Broken checksum.
+		{"1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNb", ""},
+		// Not base58.
+		{"0A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa", ""},
+		// Valid base58check, but a private key rather than an address.
+		{"5HueCGU8rMjxEXxiPuD5BDku4MkFqeZyd4dZ1jvhTVqvbTLvyTJ", ""},
+		// P2SH addresses on mainnet and testnet, of the same length as P2PKH
+		// ones.
+		{"3J98t1WpEZ73CNmQviecrnyiWrnqRhWNLy", ""},
+		{"2MzQwSSnBHWHqSAqtTVQ6v47XtaisrJa1Vc", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		address, err := ParseMainchainAddress(tt.address)
+		if tt.want == "" {
+			if !errors.Is(err, ErrInvalidMainchainAddress) {
+				t.Errorf("%q: error mismatch: have %v, want %v", tt.address, err, ErrInvalidMainchainAddress)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.address, err)
+			continue
+		}
+		if want := common.FromHex(tt.want); !bytes.Equal(address.Bytes(), want) {
+			t.Errorf("%q: address mismatch: have %x, want %x", tt.address, address, want)
+		}
+	}
+}
+
+func TestMainchainAddressFromBytes(t *testing.T) {
+	raw := common.FromHex("62e907b15cbf27d5425399ebf6f0fb50ebb88f18")
+	address, err := MainchainAddressFromBytes(raw)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(address.Bytes(), raw) {
+		t.Errorf("round trip mismatch: have %x, want %x", address.Bytes(), raw)
+	}
+	// Bytes must not alias the address.
+	address.Bytes()[0] = 0
+	if address.Bytes()[0] != raw[0] {
+		t.Error("modifying Bytes changed the address")
+	}
+	for _, n := range []int{0, MainchainAddressLength - 1, MainchainAddressLength + 1} {
+		if _, err := MainchainAddressFromBytes(make([]byte, n)); !errors.Is(err, ErrInvalidMainchainAddress) {
+			t.Errorf("%d bytes: error mismatch: have %v, want %v", n, err, ErrInvalidMainchainAddress)
+		}
+	}
+}
+
+func TestNewMainchainAddress(t *testing.T) {
+	tests := []struct {
+		typ MainchainAddressType
+		n   int
+		err error
+	}{
+		{P2PKH, 20, nil},
+		{P2WPKH, 20, nil},
+		{P2WSH, 32, nil},
+		{P2TR, 32, nil},
+		{P2WSH, 20, ErrInvalidMainchainAddress},
+		{P2PKH, 32, ErrInvalidMainchainAddress},
+		{P2TR + 1, 32, ErrUnknownMainchainAddressType},
+	}
+	for _, tt := range tests {
+		payload := bytes.Repeat([]byte{0xab}, tt.n)
+		address, err := NewMainchainAddress(tt.typ, payload)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%v/%d: error mismatch: have %v, want %v", tt.typ, tt.n, err, tt.err)
+			continue
+		}
+		if err == nil && (address.Type != tt.typ || !bytes.Equal(address.Bytes(), payload)) {
+			t.Errorf("%v/%d: address mismatch: have %v %x", tt.typ, tt.n, address.Type, address.Bytes())
+		}
+	}
+	// Addresses with the same payload but different types must differ.
+	pkh, _ := NewMainchainAddress(P2PKH, make([]byte, 20))
+	wpkh, _ := NewMainchainAddress(P2WPKH, make([]byte, 20))
+	if pkh == wpkh {
+		t.Error("P2PKH and P2WPKH addresses compare equal")
+	}
+}
+
+func TestCheckFormattedAddress(t *testing.T) {
+	zero := func(typ MainchainAddressType) MainchainAddress {
+		address, _ := NewMainchainAddress(typ, make([]byte, typ.Length()))
+		return address
+	}
+	max := func(typ MainchainAddressType) MainchainAddress {
+		address, _ := NewMainchainAddress(typ, bytes.Repeat([]byte{0xff}, typ.Length()))
+		return address
+	}
+	tests := []struct {
+		address   MainchainAddress
+		formatted string
+		valid     bool
+	}{
+		{zero(P2PKH), "1111111111111111111114oLvT2", true},
+		{max(P2PKH), "1QLbz7JHiBTspS962RLKV8GndWFwi5j6Qr", true},
+		{zero(P2WPKH), "bc1qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqq9e75rs", true},
+		{max(P2WPKH), "bc1qllllllllllllllllllllllllllllllllfglmy6", true},
+		{max(P2WPKH), "BC1QLLLLLLLLLLLLLLLLLLLLLLLLLLLLLLLLFGLMY6", true},
+		{zero(P2WSH), "bc1qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqthqst8", true},
+		{max(P2WSH), "bc1qlllllllllllllllllllllllllllllllllllllllllllllllllllsffrpzs", true},
+		{zero(P2TR), "bc1pqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqpqqenm", true},
+		{max(P2TR), "bc1plllllllllllllllllllllllllllllllllllllllllllllllllllsr7rg6v", true},
+
+		// Empty or garbage output.
+		{zero(P2PKH), "", false},
+		{zero(P2WPKH), "", false},
+		{zero(P2PKH), "1stub", false},
+		// Valid addresses of another payload or type.
+		{zero(P2PKH), "1QLbz7JHiBTspS962RLKV8GndWFwi5j6Qr", false},
+		{zero(P2WPKH), "bc1qllllllllllllllllllllllllllllllllfglmy6", false},
+		{zero(P2WPKH), "1111111111111111111114oLvT2", false},
+		{zero(P2WSH), "bc1pqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqpqqenm", false},
+		// Broken checksum and mixed case.
+		{max(P2WPKH), "bc1qllllllllllllllllllllllllllllllllfglmy7", false},
+		{max(P2WPKH), "bc1qllllllllllllllllllllllllllllllllFGLMY6", false},
+	}
+	for _, tt := range tests {
+		err := checkFormattedAddress(tt.address, tt.formatted)
+		if tt.valid && err != nil {
+			t.Errorf("%v %q: unexpected error: %v", tt.address.Type, tt.formatted, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidMainchainAddress) {
+			t.Errorf("%v %q: error mismatch: have %v, want %v", tt.address.Type, tt.formatted, err, ErrInvalidMainchainAddress)
+		}
+	}
+}
+
+func TestParseWithdrawalAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		typ     MainchainAddressType
+		want    string // hex encoded payload, empty if invalid
+	}{
+		{"1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa", P2PKH, "62e907b15cbf27d5425399ebf6f0fb50ebb88f18"},
+		{"mipcBbFg9gMiCh81Kj8tqqdgoZub1ZJRfn", P2PKH, "243f1394f44554f4ce3fd68649c19adc483ce924"},
+		// BIP 173 and BIP 350 test vectors.
+		{"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4", P2WPKH, "751e76e8199196d454941c45d1b3a323f1433bd6"},
+		{"BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4", P2WPKH, "751e76e8199196d454941c45d1b3a323f1433bd6"},
+		{"tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7", P2WSH, "1863143c14c5166804bd19203356da136c985678cd4d27a1b8c6329604903262"},
+		{"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0", P2TR, "79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"},
+		// P2SH, which withdrawals can't pay to.
+		{"3J98t1WpEZ73CNmQviecrnyiWrnqRhWNLy", 0, ""},
+		// Witness version 2.
+		{"bc1zw508d6qejxtdg4y5r3zarvaryvaqyzf3du", 0, ""},
+		// Segwit v0 with a bech32m checksum.
+		{"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kemeawh", 0, ""},
+		// Broken checksums.
+		{"1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNb", 0, ""},
+		{"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t5", 0, ""},
+		{"", 0, ""},
+	}
+	for _, tt := range tests {
+		address, err := ParseWithdrawalAddress(tt.address)
+		if tt.want == "" {
+			if !errors.Is(err, ErrInvalidMainchainAddress) {
+				t.Errorf("%q: error mismatch: have %v, want %v", tt.address, err, ErrInvalidMainchainAddress)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.address, err)
+			continue
+		}
+		if address.Type != tt.typ || !bytes.Equal(address.Bytes(), common.FromHex(tt.want)) {
+			t.Errorf("%q: address mismatch: have %v %x, want %v %s", tt.address, address.Type, address.Bytes(), tt.typ, tt.want)
+		}
+	}
+}
+
+// addressFormatter is a Drivechain formatting mainchain addresses from a
+// fixed table.
+type addressFormatter struct {
+	CGO
+	formatted map[MainchainAddress]string
+}
+
+func (f addressFormatter) FormatMainchainAddress(dest MainchainAddress) (string, error) {
+	s, ok := f.formatted[dest]
+	if !ok {
+		return "", ErrInvalidMainchainAddress
+	}
+	return s, checkFormattedAddress(dest, s)
+}
+
+func TestFormatWithdrawalAddress(t *testing.T) {
+	f := addressFormatter{formatted: make(map[MainchainAddress]string)}
+	for _, s := range []string{
+		"1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa",
+		"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4",
+		"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0",
+	} {
+		address, err := ParseWithdrawalAddress(s)
+		if err != nil {
+			t.Fatalf("%q: failed to parse: %v", s, err)
+		}
+		f.formatted[address] = s
+	}
+	defer SetDefault(SetDefault(f))
+
+	for address, want := range f.formatted {
+		have, err := FormatWithdrawalAddress(Withdrawal{Address: address})
+		if err != nil || have != want {
+			t.Errorf("%v: formatted %q, %v, want %q", address.Type, have, err, want)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package drivechain
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Error codes of the errors returned by API. Errors without a code of their
+// own get the default server error code of the rpc package.
+const (
+	apiErrInvalidParams        = -32602 // The arguments were rejected before reaching the engine
+	apiErrNotInitialized       = -32001 // The engine isn't running
+	apiErrMainchainUnreachable = -32002 // The engine can't reach the mainchain node
+)
+
+// apiError is an error with a JSON-RPC error code.
+type apiError struct {
+	code int
+	err  error
+}
+
+// Error implements error.
+func (e *apiError) Error() string { return e.err.Error() }
+
+// ErrorCode implements rpc.Error.
+func (e *apiError) ErrorCode() int { return e.code }
+
+// Unwrap returns the error from the package.
+func (e *apiError) Unwrap() error { return e.err }
+
+// newAPIError attaches the error code matching err, if there is one.
+func newAPIError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, ErrNotInitialized):
+		return &apiError{apiErrNotInitialized, err}
+	case errors.Is(err, ErrMainchainUnreachable):
+		return &apiError{apiErrMainchainUnreachable, err}
+	case errors.Is(err, ErrZeroDepositAmount), errors.Is(err, ErrZeroDepositFee),
+		errors.Is(err, ErrDepositFeeExceedsAmount), errors.Is(err, ErrUnknownMainchainAddressType):
+		return &apiError{apiErrInvalidParams, err}
+	}
+	return err
+}
+
+// API exposes the engine over JSON-RPC in the "sidechain" namespace, for
+// wallets and operator scripts. Amounts are in satoshi.
+type API struct {
+	backend apiBackend
+}
+
+// apiBackend is the engine served by an API, the one started by Init or a
+// Client.
+type apiBackend interface {
+	FormatDepositAddress(address common.Address) (string, error)
+	GetUnspentWithdrawals(ctx context.Context) (map[common.Hash]WithdrawalInfo, error)
+	FormatMainchainAddress(dest MainchainAddress) (string, error)
+	GetWithdrawalData(fee uint64) ([]byte, error)
+	ValidateDeposit(amount uint64, fee uint64) error
+	CreateDeposit(address common.Address, amount uint64, fee uint64) (common.Hash, error)
+	GetMainchainTipInfo() (MainchainTipInfo, error)
+	VerifyBmm(prevMainBlockHash common.Hash, criticalHash common.Hash) (bool, error)
+}
+
+// packageBackend is the apiBackend of the engine started by Init, going
+// through the package level functions.
+type packageBackend struct{}
+
+func (packageBackend) FormatDepositAddress(address common.Address) (string, error) {
+	return FormatDepositAddress(address)
+}
+
+func (packageBackend) GetUnspentWithdrawals(ctx context.Context) (map[common.Hash]WithdrawalInfo, error) {
+	return GetUnspentWithdrawals(ctx)
+}
+
+func (packageBackend) FormatMainchainAddress(dest MainchainAddress) (string, error) {
+	return FormatMainchainAddress(dest)
+}
+
+func (packageBackend) GetWithdrawalData(fee uint64) ([]byte, error) {
+	return GetWithdrawalData(fee)
+}
+
+func (packageBackend) ValidateDeposit(amount uint64, fee uint64) error {
+	return ValidateDeposit(amount, fee)
+}
+
+func (packageBackend) CreateDeposit(address common.Address, amount uint64, fee uint64) (common.Hash, error) {
+	return CreateDeposit(address, amount, fee)
+}
+
+func (packageBackend) GetMainchainTipInfo() (MainchainTipInfo, error) {
+	return GetMainchainTipInfo()
+}
+
+func (packageBackend) VerifyBmm(prevMainBlockHash common.Hash, criticalHash common.Hash) (bool, error) {
+	return VerifyBmm(prevMainBlockHash, criticalHash)
+}
+
+// APIs returns the RPC APIs of the package, to be registered by the node
+// next to its own.
+func APIs() []rpc.API {
+	return newAPIs(packageBackend{})
+}
+
+func newAPIs(backend apiBackend) []rpc.API {
+	return []rpc.API{{
+		Namespace: "sidechain",
+		Version:   "1.0",
+		Service:   &API{backend: backend},
+	}}
+}
+
+// RPCWithdrawal is an unspent withdrawal as returned by
+// sidechain_listUnspentWithdrawals.
+type RPCWithdrawal struct {
+	ID      common.Hash      `json:"id"`
+	Address string           `json:"address"` // Mainchain address in the engine's text format
+	Amount  hexutil.Uint64   `json:"amount"`
+	Fee     hexutil.Uint64   `json:"fee"`
+	Status  WithdrawalStatus `json:"status"`
+}
+
+// RPCMainchainTip is the mainchain tip as returned by sidechain_mainchainTip.
+type RPCMainchainTip struct {
+	Hash     common.Hash    `json:"hash"`
+	Height   hexutil.Uint64 `json:"height"`
+	Time     hexutil.Uint64 `json:"time"` // Block header timestamp in seconds
+	PrevHash common.Hash    `json:"prevHash"`
+}
+
+// GetDepositAddress returns the address mainchain users deposit to in order
+// to credit address on the sidechain.
+func (api *API) GetDepositAddress(address common.Address) (string, error) {
+	depositAddress, err := api.backend.FormatDepositAddress(address)
+	return depositAddress, newAPIError(err)
+}
+
+// ListUnspentWithdrawals returns the withdrawals that weren't paid out or
+// refunded yet.
+func (api *API) ListUnspentWithdrawals(ctx context.Context) ([]RPCWithdrawal, error) {
+	withdrawals, err := api.backend.GetUnspentWithdrawals(ctx)
+	if err != nil {
+		return nil, newAPIError(err)
+	}
+	result := make([]RPCWithdrawal, 0, len(withdrawals))
+	for id, w := range withdrawals {
+		address, err := api.backend.FormatMainchainAddress(w.Address)
+		if err != nil {
+			return nil, newAPIError(err)
+		}
+		// The engine reports the amounts in Wei, but only ever as whole
+		// satoshis.
+		amount, err := WeiToSatoshi(w.Amount)
+		if err != nil {
+			return nil, err
+		}
+		fee, err := WeiToSatoshi(w.Fee)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, RPCWithdrawal{
+			ID:      id,
+			Address: address,
+			Amount:  hexutil.Uint64(amount),
+			Fee:     hexutil.Uint64(fee),
+			Status:  w.Status,
+		})
+	}
+	return result, nil
+}
+
+// GetWithdrawalData returns the data of a transaction to the treasury
+// withdrawing to a new mainchain address of the engine's wallet, paying fee
+// to mainchain miners.
+func (api *API) GetWithdrawalData(fee hexutil.Uint64) (hexutil.Bytes, error) {
+	data, err := api.backend.GetWithdrawalData(uint64(fee))
+	return data, newAPIError(err)
+}
+
+// CreateDeposit makes a mainchain deposit of amount to address, paying fee to
+// mainchain miners, and returns the mainchain txid of the deposit.
+func (api *API) CreateDeposit(address common.Address, amount hexutil.Uint64, fee hexutil.Uint64) (common.Hash, error) {
+	if err := api.backend.ValidateDeposit(uint64(amount), uint64(fee)); err != nil {
+		return common.Hash{}, newAPIError(err)
+	}
+	txid, err := api.backend.CreateDeposit(address, uint64(amount), uint64(fee))
+	return txid, newAPIError(err)
+}
+
+// MainchainTip returns the mainchain block the engine is following.
+func (api *API) MainchainTip() (*RPCMainchainTip, error) {
+	tip, err := api.backend.GetMainchainTipInfo()
+	if err != nil {
+		return nil, newAPIError(err)
+	}
+	return &RPCMainchainTip{
+		Hash:     tip.Hash,
+		Height:   hexutil.Uint64(tip.Height),
+		Time:     hexutil.Uint64(tip.Time.Unix()),
+		PrevHash: tip.PrevHash,
+	}, nil
+}
+
+// VerifyBmm reports whether the sidechain block with hash criticalHash was
+// merge mined in the mainchain block following prevMainBlockHash.
+func (api *API) VerifyBmm(prevMainBlockHash common.Hash, criticalHash common.Hash) (bool, error) {
+	verified, err := api.backend.VerifyBmm(prevMainBlockHash, criticalHash)
+	return verified, newAPIError(err)
+}
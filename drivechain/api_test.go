@@ -0,0 +1,71 @@
+package drivechain
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// newTestRPCClient serves the package's APIs on an in-process RPC server.
+func newTestRPCClient(t *testing.T) *rpc.Client {
+	t.Helper()
+	server := rpc.NewServer()
+	for _, api := range APIs() {
+		if err := server.RegisterName(api.Namespace, api.Service); err != nil {
+			t.Fatalf("failed to register %s API: %v", api.Namespace, err)
+		}
+	}
+	t.Cleanup(server.Stop)
+	client := rpc.DialInProc(server)
+	t.Cleanup(client.Close)
+	return client
+}
+
+// Tests that errors of the package get the matching JSON-RPC error codes.
+func TestAPIErrorCodes(t *testing.T) {
+	client := newTestRPCClient(t)
+
+	var tip RPCMainchainTip
+	err := client.Call(&tip, "sidechain_mainchainTip")
+	var rpcErr rpc.Error
+	if !errors.As(err, &rpcErr) || rpcErr.ErrorCode() != apiErrNotInitialized {
+		t.Errorf("engine not running: have %v, want error code %d", err, apiErrNotInitialized)
+	}
+
+	initTestEngine(t, nil)
+
+	if err := client.Call(&tip, "sidechain_mainchainTip"); err != nil {
+		t.Fatalf("failed to get tip: %v", err)
+	}
+	if want := common.HexToHash("01"); tip.Hash != want || tip.Height != 1 {
+		t.Errorf("tip mismatch: have %x at %d, want %x at 1", tip.Hash, tip.Height, want)
+	}
+
+	var txid common.Hash
+	err = client.Call(&txid, "sidechain_createDeposit", common.Address{1}, hexutil.Uint64(0), hexutil.Uint64(1))
+	if !errors.As(err, &rpcErr) || rpcErr.ErrorCode() != apiErrInvalidParams {
+		t.Errorf("zero deposit: have %v, want error code %d", err, apiErrInvalidParams)
+	}
+	if err := client.Call(&txid, "sidechain_createDeposit", common.Address{1}, hexutil.Uint64(DefaultMinDepositSats), hexutil.Uint64(1)); err != nil {
+		t.Errorf("failed to create deposit: %v", err)
+	}
+
+	forked := *params.MainnetChainConfig
+	forked.TaggedWithdrawalBlock, forked.VersionedWithdrawalBlock = common.Big0, common.Big0
+	setTestChain(t, &forked, 0)
+	var data hexutil.Bytes
+	if err := client.Call(&data, "sidechain_getWithdrawalData", hexutil.Uint64(1)); err != nil {
+		t.Fatalf("failed to get withdrawal data: %v", err)
+	}
+	if err := ValidateWithdrawalData(data); err != nil || data[0] != WithdrawalDataVersion {
+		t.Errorf("invalid withdrawal data %x: %v", data, err)
+	}
+	var withdrawals []RPCWithdrawal
+	if err := client.Call(&withdrawals, "sidechain_listUnspentWithdrawals"); err != nil || len(withdrawals) != 0 {
+		t.Errorf("unspent withdrawals: have %v, %v, want none", withdrawals, err)
+	}
+}
@@ -3,25 +3,159 @@
 #include <stdint.h>
 #include <stdlib.h>
 
+enum BlockError {
+  BlockError_None = 0,
+  BlockError_DepositMismatch = 1,
+  BlockError_InvalidWithdrawal = 2,
+  BlockError_EngineFailure = 3,
+  BlockError_WithdrawalSpent = 4,
+  BlockError_RefundNotFound = 5,
+};
+typedef uint32_t BlockError;
+
+enum WithdrawalStatus {
+  WithdrawalStatus_Unknown = 0,
+  WithdrawalStatus_Pending = 1,
+  WithdrawalStatus_InBundle = 2,
+  WithdrawalStatus_BundleBroadcast = 3,
+  WithdrawalStatus_Spent = 4,
+  WithdrawalStatus_Failed = 5,
+  WithdrawalStatus_Refunded = 6,
+};
+typedef uint32_t WithdrawalStatus;
+
+enum BmmError {
+  BmmError_None = 0,
+  BmmError_InsufficientFunds = 1,
+  BmmError_AlreadyPending = 2,
+  BmmError_RpcFailure = 3,
+  BmmError_EngineFailure = 4,
+  BmmError_NoFunds = 5,
+};
+typedef uint32_t BmmError;
+
+enum DepositError {
+  DepositError_None = 0,
+  DepositError_InsufficientFunds = 1,
+  DepositError_FeeTooLow = 2,
+  DepositError_WalletLocked = 3,
+  DepositError_RpcFailure = 4,
+  DepositError_EngineFailure = 5,
+};
+typedef uint32_t DepositError;
+
+enum BmmState {
+  BmmState_Succeeded = 0,
+  BmmState_Failed = 1,
+  BmmState_Pending = 2,
+  BmmState_Cancelled = 3,
+  BmmState_Idle = 4,
+};
+typedef uint32_t BmmState;
+
+enum SpentStatus {
+  SpentStatus_Unknown = 0,
+  SpentStatus_Unspent = 1,
+  SpentStatus_Spent = 2,
+};
+typedef uint32_t SpentStatus;
+
+enum BundleStatus {
+  BundleStatus_Unknown = 0,
+  BundleStatus_Building = 1,
+  BundleStatus_Broadcast = 2,
+  BundleStatus_Acked = 3,
+  BundleStatus_Failed = 4,
+  BundleStatus_Paid = 5,
+};
+typedef uint32_t BundleStatus;
+
+enum BroadcastStatus {
+  BroadcastStatus_NothingToDo = 0,
+  BroadcastStatus_AlreadyPending = 1,
+  BroadcastStatus_Broadcast = 2,
+  BroadcastStatus_RpcFailure = 3,
+  BroadcastStatus_EngineFailure = 4,
+};
+typedef uint32_t BroadcastStatus;
+
+enum WithdrawalEventKind {
+  WithdrawalEventKind_Created = 1,
+  WithdrawalEventKind_InBundle = 2,
+  WithdrawalEventKind_Broadcast = 3,
+  WithdrawalEventKind_Spent = 4,
+  WithdrawalEventKind_Failed = 5,
+};
+typedef uint32_t WithdrawalEventKind;
+
+enum AddressType {
+  AddressType_P2pkh = 0,
+  AddressType_P2wpkh = 1,
+  AddressType_P2wsh = 2,
+  AddressType_P2tr = 3,
+};
+typedef uint8_t AddressType;
+
+typedef struct BmmEngine BmmEngine;
+
 typedef struct WithdrawalAddress {
-  uint8_t address[20];
+  AddressType address_type;
+  uint8_t address[32];
 } WithdrawalAddress;
 
 typedef struct Withdrawal {
-  const char *id;
-  uint8_t address[20];
+  uint8_t id[32];
+  struct WithdrawalAddress address;
   uint64_t amount;
   uint64_t fee;
 } Withdrawal;
 
+typedef struct WithdrawalStatusInfo {
+  WithdrawalStatus status;
+  const char *bundle_hash;
+  uint32_t acks;
+} WithdrawalStatusInfo;
+
+typedef struct WithdrawalEvent {
+  WithdrawalEventKind kind;
+  uint64_t mainchain_height;
+  uint64_t time;
+} WithdrawalEvent;
+
+typedef struct WithdrawalEvents {
+  bool valid;
+  struct WithdrawalEvent *ptr;
+  uintptr_t len;
+} WithdrawalEvents;
+
+typedef struct SpentWithdrawal {
+  const char *id;
+  const char *bundle_txid;
+  const char *mainchain_block_hash;
+  uint64_t mainchain_height;
+  uint64_t amount;
+  uint64_t fee;
+} SpentWithdrawal;
+
+typedef struct SpentWithdrawals {
+  bool valid;
+  struct SpentWithdrawal *ptr;
+  uintptr_t len;
+} SpentWithdrawals;
+
 typedef struct Withdrawals {
+  bool valid;
   struct Withdrawal *ptr;
   uintptr_t len;
 } Withdrawals;
 
 typedef struct Deposit {
-  const char *address;
+  uint8_t address[20];
   uint64_t amount;
+  const char *txid;
+  uint32_t vout;
+  uint64_t index;
+  uint64_t height;
 } Deposit;
 
 typedef struct Deposits {
@@ -30,8 +164,26 @@ typedef struct Deposits {
   uintptr_t len;
 } Deposits;
 
+typedef struct BmmAttempt {
+  BmmError error;
+  const char *txid;
+} BmmAttempt;
+
+typedef struct BmmConfirmation {
+  BmmState state;
+  const char *main_block_hash;
+} BmmConfirmation;
+
+typedef struct MainchainTip {
+  bool valid;
+  const char *hash;
+  uint64_t height;
+  uint64_t time;
+  const char *prev_hash;
+} MainchainTip;
+
 typedef struct Refund {
-  const char *id;
+  uint8_t id[32];
   uint64_t amount;
 } Refund;
 
@@ -40,6 +192,86 @@ typedef struct Refunds {
   uintptr_t len;
 } Refunds;
 
+typedef struct WithdrawalBundle {
+  bool valid;
+  const char *bundle_hash;
+  const char *const *members;
+  uintptr_t len;
+  uint64_t total_amount;
+  uint64_t total_fee;
+  BundleStatus status;
+  uint32_t acks;
+  const char *main_txid;
+} WithdrawalBundle;
+
+typedef struct BundleBroadcast {
+  BroadcastStatus status;
+  const char *bundle_hash;
+} BundleBroadcast;
+
+typedef struct DepositResult {
+  DepositError error;
+  const char *txid;
+} DepositResult;
+
+typedef struct StateBlob {
+  bool valid;
+  uint8_t *ptr;
+  uintptr_t len;
+} StateBlob;
+
+typedef struct BmmCommitment {
+  const char *prev_main_block_hash;
+  const char *main_block_hash;
+  const char *critical_hash;
+} BmmCommitment;
+
+typedef struct BmmCommitments {
+  bool valid;
+  struct BmmCommitment *ptr;
+  uintptr_t len;
+} BmmCommitments;
+
+typedef struct BmmRecord {
+  const char *critical_hash;
+  const char *main_block_hash;
+  uint64_t amount;
+  BmmState state;
+  uint64_t time;
+} BmmRecord;
+
+typedef struct BmmRecords {
+  bool valid;
+  struct BmmRecord *ptr;
+  uintptr_t len;
+} BmmRecords;
+
+typedef struct BlockActivity {
+  bool valid;
+  bool indexed;
+  struct Deposits deposits;
+  struct Withdrawals withdrawals;
+  struct Refunds refunds;
+} BlockActivity;
+
+typedef struct BlockData {
+  struct Deposits deposits;
+  struct Withdrawals withdrawals;
+  struct Refunds refunds;
+} BlockData;
+
+typedef struct CheckResult {
+  const char *name;
+  BlockError error;
+  const char *message;
+} CheckResult;
+
+typedef struct CheckResults {
+  bool valid;
+  struct CheckResult *ptr;
+  uintptr_t len;
+} CheckResults;
+
 bool init(const char *db_path,
           uintptr_t this_sidechain,
           const char *host,
@@ -49,44 +281,297 @@ bool init(const char *db_path,
 
 uintptr_t flush(void);
 
-void attempt_bmm(const char *critical_hash, const char *prev_main_block_hash, uint64_t amount);
+bool deinit(void);
+
+bool reconnect(const char *host, uint16_t port, const char *rpcuser, const char *rpcpassword);
+
+bool set_mainchain_transport(const char *url,
+                             const char *ca_file,
+                             const char *cert_file,
+                             const char *key_file,
+                             bool insecure_skip_verify);
+
+bool ping(void);
+
+struct BmmAttempt attempt_bmm(const char *critical_hash,
+                              const char *prev_main_block_hash,
+                              uint64_t amount);
+
+struct BmmConfirmation confirm_bmm(void);
+
+bool cancel_bmm(void);
 
-uint32_t confirm_bmm(void);
+bool get_bmm_amount(uint64_t *amount);
+
+bool set_bmm_amount(uint64_t amount);
+
+struct BmmRecords get_bmm_history(uintptr_t limit);
+
+bool set_dust_thresholds(uint64_t min_deposit, uint64_t min_withdrawal);
 
 bool verify_bmm(const char *main_block_hash, const char *critical_hash);
 
+struct BmmCommitments get_bmm_commitments(const char *from_main_block_hash,
+                                          const char *to_main_block_hash);
+
 const char *get_prev_main_block_hash(const char *main_block_hash);
 
 const char *get_mainchain_tip(void);
 
+struct MainchainTip get_mainchain_tip_info(void);
+
 const char *format_deposit_address(const char *address);
 
-bool create_deposit(const char *address, uint64_t amount, uint64_t fee);
+struct DepositResult create_deposit(const char *address, uint64_t amount, uint64_t fee);
 
 struct WithdrawalAddress get_new_mainchain_address(void);
 
 const char *format_mainchain_address(struct WithdrawalAddress dest);
 
-bool attempt_bundle_broadcast(void);
+struct BundleBroadcast attempt_bundle_broadcast(void);
+
+const char *get_pending_bundle_hash(void);
+
+struct WithdrawalBundle get_current_bundle(void);
 
 struct Withdrawals get_unspent_withdrawals(void);
 
+bool get_refundable_withdrawals(struct Refunds *refunds);
+
 struct Deposits get_deposit_outputs(void);
 
-bool connect_block(struct Deposits deposits,
-                   struct Withdrawals withdrawals,
-                   struct Refunds refunds,
-                   bool just_check);
+struct Deposits get_deposit_outputs_since(const char *block_hash);
 
-bool disconnect_block(struct Deposits deposits,
-                      struct Withdrawals withdrawals,
-                      struct Refunds refunds,
-                      bool just_check);
+struct Deposits get_deposit_outputs_in_range(uint64_t from_height, uint64_t to_height);
+
+BlockError connect_block(struct Deposits deposits,
+                         struct Withdrawals withdrawals,
+                         struct Refunds refunds,
+                         bool just_check);
+
+BlockError disconnect_block(struct Deposits deposits,
+                            struct Withdrawals withdrawals,
+                            struct Refunds refunds,
+                            bool just_check);
+
+BlockError connect_side_block(const char *side_block_hash, struct BlockData block, bool just_check);
+
+BlockError disconnect_side_block(const char *side_block_hash,
+                                 struct Deposits deposits,
+                                 struct Withdrawals withdrawals,
+                                 struct Refunds refunds,
+                                 bool just_check);
+
+struct BlockActivity get_block_activity(const char *side_block_hash);
+
+BlockError connect_blocks(const struct BlockData *blocks,
+                          uintptr_t len,
+                          bool just_check,
+                          uintptr_t *failed);
+
+BlockError disconnect_blocks(const struct BlockData *blocks,
+                             uintptr_t len,
+                             bool just_check,
+                             uintptr_t *failed);
+
+struct CheckResults validate_block(struct BlockData block);
+
+void free_check_results(struct CheckResults results);
+
+const char *get_last_error(void);
 
 bool is_outpoint_spent(const char *outpoint);
 
+bool are_outpoints_spent(const char *const *outpoints, uintptr_t len, SpentStatus *results);
+
+WithdrawalStatus get_withdrawal_status(const char *id);
+
+struct WithdrawalStatusInfo get_withdrawal_status_info(const char *id);
+
+struct WithdrawalEvents get_withdrawal_history(const char *id);
+
+struct SpentWithdrawals get_spent_withdrawals(const char *after, uintptr_t limit);
+
+bool get_treasury_totals(uint64_t *deposited, uint64_t *withdrawn);
+
 void free_string(const char *string);
 
 void free_deposits(struct Deposits deposits);
 
 void free_withdrawals(struct Withdrawals withdrawals);
+
+void free_refunds(struct Refunds refunds);
+
+void free_bundle(struct WithdrawalBundle bundle);
+
+void free_bmm_commitments(struct BmmCommitments commitments);
+
+void free_bmm_records(struct BmmRecords records);
+
+void free_withdrawal_events(struct WithdrawalEvents events);
+
+void free_spent_withdrawals(struct SpentWithdrawals withdrawals);
+
+void free_block_activity(struct BlockActivity activity);
+
+bool state_format_versions(uint32_t *min, uint32_t *max);
+
+struct StateBlob export_state(uint32_t version);
+
+bool import_state(const uint8_t *data, uintptr_t len, uint32_t version);
+
+void free_state(struct StateBlob state);
+
+struct BmmEngine *create_bmm_engine(const char *db_path,
+                                    uintptr_t this_sidechain,
+                                    const char *host,
+                                    uint16_t port,
+                                    const char *rpcuser,
+                                    const char *rpcpassword);
+
+void destroy_bmm_engine(struct BmmEngine *engine);
+
+bool bmm_engine_reconnect(struct BmmEngine *engine,
+                          const char *host,
+                          uint16_t port,
+                          const char *rpcuser,
+                          const char *rpcpassword);
+
+const char *bmm_engine_get_mainchain_tip(const struct BmmEngine *engine);
+
+struct MainchainTip bmm_engine_get_mainchain_tip_info(const struct BmmEngine *engine);
+
+struct Deposits bmm_engine_get_deposit_outputs(const struct BmmEngine *engine);
+
+struct DepositResult bmm_engine_create_deposit(struct BmmEngine *engine,
+                                               const char *address,
+                                               uint64_t amount,
+                                               uint64_t fee);
+
+BlockError bmm_engine_connect_block(struct BmmEngine *engine,
+                                    struct Deposits deposits,
+                                    struct Withdrawals withdrawals,
+                                    struct Refunds refunds,
+                                    bool just_check);
+
+BlockError bmm_engine_disconnect_block(struct BmmEngine *engine,
+                                       struct Deposits deposits,
+                                       struct Withdrawals withdrawals,
+                                       struct Refunds refunds,
+                                       bool just_check);
+
+BlockError bmm_engine_connect_side_block(struct BmmEngine *engine,
+                                         const char *side_block_hash,
+                                         struct BlockData block,
+                                         bool just_check);
+
+BlockError bmm_engine_disconnect_side_block(struct BmmEngine *engine,
+                                            const char *side_block_hash,
+                                            struct Deposits deposits,
+                                            struct Withdrawals withdrawals,
+                                            struct Refunds refunds,
+                                            bool just_check);
+
+struct BlockActivity bmm_engine_get_block_activity(const struct BmmEngine *engine,
+                                                   const char *side_block_hash);
+
+struct WithdrawalAddress bmm_engine_get_new_mainchain_address(const struct BmmEngine *engine);
+
+struct Withdrawals bmm_engine_get_unspent_withdrawals(const struct BmmEngine *engine);
+
+WithdrawalStatus bmm_engine_get_withdrawal_status(const struct BmmEngine *engine, const char *id);
+
+struct WithdrawalStatusInfo bmm_engine_get_withdrawal_status_info(const struct BmmEngine *engine,
+                                                                  const char *id);
+
+bool bmm_engine_is_outpoint_spent(const struct BmmEngine *engine, const char *outpoint);
+
+struct BundleBroadcast bmm_engine_attempt_bundle_broadcast(struct BmmEngine *engine);
+
+struct BmmAttempt bmm_engine_attempt_bmm(struct BmmEngine *engine,
+                                         const char *critical_hash,
+                                         const char *prev_main_block_hash,
+                                         uint64_t amount);
+
+struct BmmConfirmation bmm_engine_confirm_bmm(struct BmmEngine *engine);
+
+bool bmm_engine_set_dust_thresholds(struct BmmEngine *engine,
+                                    uint64_t min_deposit,
+                                    uint64_t min_withdrawal);
+
+bool bmm_engine_cancel_bmm(struct BmmEngine *engine);
+
+bool bmm_engine_verify_bmm(const struct BmmEngine *engine,
+                           const char *main_block_hash,
+                           const char *critical_hash);
+
+bool bmm_engine_set_mainchain_transport(struct BmmEngine *engine,
+                                        const char *url,
+                                        const char *ca_file,
+                                        const char *cert_file,
+                                        const char *key_file,
+                                        bool insecure_skip_verify);
+
+bool bmm_engine_ping(const struct BmmEngine *engine);
+
+bool bmm_engine_get_bmm_amount(const struct BmmEngine *engine, uint64_t *amount);
+
+bool bmm_engine_set_bmm_amount(struct BmmEngine *engine, uint64_t amount);
+
+struct BmmRecords bmm_engine_get_bmm_history(const struct BmmEngine *engine, uintptr_t limit);
+
+struct BmmCommitments bmm_engine_get_bmm_commitments(const struct BmmEngine *engine,
+                                                     const char *from_main_block_hash,
+                                                     const char *to_main_block_hash);
+
+const char *bmm_engine_format_deposit_address(const struct BmmEngine *engine, const char *address);
+
+const char *bmm_engine_get_pending_bundle_hash(const struct BmmEngine *engine);
+
+struct WithdrawalBundle bmm_engine_get_current_bundle(const struct BmmEngine *engine);
+
+bool bmm_engine_get_refundable_withdrawals(const struct BmmEngine *engine, struct Refunds *refunds);
+
+struct Deposits bmm_engine_get_deposit_outputs_since(const struct BmmEngine *engine,
+                                                     const char *block_hash);
+
+struct Deposits bmm_engine_get_deposit_outputs_in_range(const struct BmmEngine *engine,
+                                                        uint64_t from_height,
+                                                        uint64_t to_height);
+
+BlockError bmm_engine_connect_blocks(struct BmmEngine *engine,
+                                     const struct BlockData *blocks,
+                                     uintptr_t len,
+                                     bool just_check,
+                                     uintptr_t *failed);
+
+BlockError bmm_engine_disconnect_blocks(struct BmmEngine *engine,
+                                        const struct BlockData *blocks,
+                                        uintptr_t len,
+                                        bool just_check,
+                                        uintptr_t *failed);
+
+struct CheckResults bmm_engine_validate_block(const struct BmmEngine *engine, struct BlockData block);
+
+bool bmm_engine_are_outpoints_spent(const struct BmmEngine *engine,
+                                    const char *const *outpoints,
+                                    uintptr_t len,
+                                    SpentStatus *results);
+
+struct WithdrawalEvents bmm_engine_get_withdrawal_history(const struct BmmEngine *engine,
+                                                          const char *id);
+
+struct SpentWithdrawals bmm_engine_get_spent_withdrawals(const struct BmmEngine *engine,
+                                                         const char *after,
+                                                         uintptr_t limit);
+
+bool bmm_engine_get_treasury_totals(const struct BmmEngine *engine,
+                                    uint64_t *deposited,
+                                    uint64_t *withdrawn);
+
+struct StateBlob bmm_engine_export_state(const struct BmmEngine *engine, uint32_t version);
+
+bool bmm_engine_import_state(struct BmmEngine *engine,
+                             const uint8_t *data,
+                             uintptr_t len,
+                             uint32_t version);
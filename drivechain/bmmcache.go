@@ -0,0 +1,93 @@
+package drivechain
+
+/*
+#include "./bindings.h"
+*/
+import "C"
+import (
+	"fmt"
+	"unsafe"
+
+	"github.com/ethereum/go-ethereum/common"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// DefaultBmmCacheSize is the number of verified BMM commitments kept when
+// Config.BmmCacheSize isn't set.
+const DefaultBmmCacheSize = 16384
+
+// bmmCache holds the BMM commitments VerifyBmm found in mainchain blocks below
+// the tip, which can't change anymore. Failed verifications aren't cached, as
+// the engine also fails them for blocks it hasn't seen yet or couldn't look
+// up. It's nil while the engine isn't running, or if caching is disabled, and
+// is guarded by engineMu.
+var bmmCache *lru.Cache
+
+// bmmCacheKey identifies a VerifyBmm call. Only exact matches are cache hits.
+type bmmCacheKey struct {
+	prevMainBlockHash common.Hash
+	criticalHash      common.Hash
+}
+
+// newBmmCache returns the VerifyBmm cache for cfg, or nil if it's disabled.
+func newBmmCache(cfg *Config) *lru.Cache {
+	size := cfg.bmmCacheSize()
+	if size < 0 {
+		return nil
+	}
+	cache, _ := lru.New(size)
+	return cache
+}
+
+// cacheBmmCommitment stores the verified BMM commitment in the mainchain block
+// following prevMainBlockHash in cache, unless that block is the mainchain tip
+// or not mined yet according to tip.
+func cacheBmmCommitment(cache *lru.Cache, tip MainchainTipInfo, prevMainBlockHash, criticalHash common.Hash) {
+	if cache == nil || prevMainBlockHash == tip.Hash || prevMainBlockHash == tip.PrevHash {
+		return
+	}
+	cache.Add(bmmCacheKey{prevMainBlockHash, criticalHash}, true)
+}
+
+// PrefetchBmmCommitments loads the BMM commitments of this sidechain in the
+// mainchain blocks from fromMainHash to toMainHash, inclusive, with a single
+// call to the engine and caches them for VerifyBmm. It's meant for syncing
+// historical sidechain blocks, which would otherwise be verified one mainchain
+// round trip at a time. Commitments in the mainchain tip aren't cached.
+func PrefetchBmmCommitments(fromMainHash, toMainHash common.Hash) error {
+	if err := rlockEngine(); err != nil {
+		return err
+	}
+	defer engineMu.RUnlock()
+	if bmmCache == nil {
+		return nil
+	}
+	tip, err := mainchainTipInfo()
+	if err != nil {
+		return err
+	}
+	var args cArgs
+	defer args.free()
+	cFrom := args.cString(fromMainHash.Hex()[2:])
+	cTo := args.cString(toMainHash.Hex()[2:])
+	waitRateLimit()
+	return cacheBmmCommitments(bmmCache, tip, C.get_bmm_commitments(cFrom, cTo))
+}
+
+// cacheBmmCommitments stores the commitments returned by the engine in cache
+// like cacheBmmCommitment, freeing them.
+func cacheBmmCommitments(cache *lru.Cache, tip MainchainTipInfo, cCommitments C.BmmCommitments) error {
+	defer C.free_bmm_commitments(cCommitments)
+	if !bool(cCommitments.valid) {
+		return fmt.Errorf("failed to get bmm commitments: %w: %s", ErrMainchainUnreachable, getLastError())
+	}
+	for _, cCommitment := range unsafe.Slice(cCommitments.ptr, cCommitments.len) {
+		cacheBmmCommitment(
+			cache,
+			tip,
+			common.HexToHash(C.GoString(cCommitment.prev_main_block_hash)),
+			common.HexToHash(C.GoString(cCommitment.critical_hash)),
+		)
+	}
+	return nil
+}
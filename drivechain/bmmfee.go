@@ -0,0 +1,64 @@
+package drivechain
+
+import "sync"
+
+// DefaultBmmBribe is the bribe in satoshi paid to mainchain miners for
+// including a BMM commitment when no other policy is configured.
+const DefaultBmmBribe = 10000
+
+// BmmFeePolicy decides the bribe paid with each BMM attempt. A fixed policy
+// always pays the same amount, an escalating one raises the bribe every time
+// an attempt fails, so commitments still get included when mainchain fees
+// spike. It is safe for concurrent use.
+type BmmFeePolicy struct {
+	mu      sync.Mutex
+	start   uint64
+	step    uint64
+	max     uint64
+	current uint64
+}
+
+// FixedBmmFee returns a policy that always bribes amount satoshi.
+func FixedBmmFee(amount uint64) *BmmFeePolicy {
+	return EscalatingBmmFee(amount, 0, amount)
+}
+
+// EscalatingBmmFee returns a policy that starts bribing start satoshi and adds
+// step after every failed attempt, up to max. The bribe drops back to start
+// once an attempt succeeds. A max below start is treated as start.
+func EscalatingBmmFee(start, step, max uint64) *BmmFeePolicy {
+	if max < start {
+		max = start
+	}
+	return &BmmFeePolicy{start: start, step: step, max: max, current: start}
+}
+
+// Bribe returns the bribe for the next attempt without changing the policy's
+// state.
+func (p *BmmFeePolicy) Bribe() uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.current
+}
+
+// NextBribe returns the bribe for the next attempt, given the state
+// ConfirmBmm reported for the previous one. Failed raises the bribe by the
+// policy's step, Succeeded resets it to the starting amount and any other
+// state leaves it unchanged.
+func (p *BmmFeePolicy) NextBribe(previousState BmmState) uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch previousState {
+	case Succeeded:
+		p.current = p.start
+	case Failed:
+		if p.max-p.current < p.step {
+			p.current = p.max
+		} else {
+			p.current += p.step
+		}
+	}
+	return p.current
+}
@@ -0,0 +1,49 @@
+package drivechain
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFixedBmmFee(t *testing.T) {
+	p := FixedBmmFee(5000)
+	for _, state := range []BmmState{Failed, Failed, Pending, Succeeded, Unknown} {
+		if bribe := p.NextBribe(state); bribe != 5000 {
+			t.Fatalf("bribe after %v: have %d, want %d", state, bribe, 5000)
+		}
+	}
+}
+
+func TestEscalatingBmmFeeCap(t *testing.T) {
+	p := EscalatingBmmFee(1000, 400, 2000)
+	if bribe := p.Bribe(); bribe != 1000 {
+		t.Fatalf("initial bribe: have %d, want %d", bribe, 1000)
+	}
+	want := []uint64{1400, 1800, 2000, 2000}
+	for i, w := range want {
+		if bribe := p.NextBribe(Failed); bribe != w {
+			t.Errorf("bribe after %d failures: have %d, want %d", i+1, bribe, w)
+		}
+	}
+	if bribe := p.NextBribe(Pending); bribe != 2000 {
+		t.Errorf("bribe after pending: have %d, want %d", bribe, 2000)
+	}
+
+	// The step must not overflow past the cap.
+	p = EscalatingBmmFee(math.MaxUint64-1, 10, math.MaxUint64)
+	if bribe := p.NextBribe(Failed); bribe != math.MaxUint64 {
+		t.Errorf("bribe near overflow: have %d, want %d", bribe, uint64(math.MaxUint64))
+	}
+}
+
+func TestEscalatingBmmFeeReset(t *testing.T) {
+	p := EscalatingBmmFee(1000, 500, 10000)
+	p.NextBribe(Failed)
+	p.NextBribe(Failed)
+	if bribe := p.NextBribe(Succeeded); bribe != 1000 {
+		t.Errorf("bribe after success: have %d, want %d", bribe, 1000)
+	}
+	if bribe := p.NextBribe(Failed); bribe != 1500 {
+		t.Errorf("bribe after reset and failure: have %d, want %d", bribe, 1500)
+	}
+}
@@ -0,0 +1,1210 @@
+package drivechain
+
+/*
+#include "./bindings.h"
+*/
+import "C"
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+	lru "github.com/hashicorp/golang-lru"
+	"golang.org/x/time/rate"
+)
+
+// Client is a drivechain engine of its own, with its own database and
+// mainchain slot, for processes running more than one sidechain. Unlike CGO it
+// doesn't use the engine started by Init, so any number of clients can run
+// next to it as long as their sidechain numbers differ.
+//
+// The methods of a Client are the package level functions serving the engine
+// started by Init, for the engine of the client and with the settings of its
+// Config. Its caches, pollers, subscriptions and withdrawal notifiers are its
+// own as well, and so are the withdrawal rules, see SetChain. Only the metrics
+// are shared with the engine started by Init.
+type Client struct {
+	// mu guards engine like engineMu guards the engine started by Init:
+	// calls changing the engine's state take the write lock, other calls the
+	// read lock.
+	mu      sync.RWMutex
+	engine  *C.BmmEngine // nil once closed
+	cfg     Config
+	limiter *rate.Limiter
+
+	bmmCache    *lru.Cache   // Nil if caching is disabled, guarded by mu
+	zmq         *zmqListener // Nil without Config.ZMQEndpoint, guarded by mu
+	deposits    *depositOutputsCache
+	depositSubs *depositSubscriptions
+	tipSubs     *tipSubscriptions
+	notifiers   *withdrawalNotifiers
+
+	chainMu sync.RWMutex
+	chain   ChainHeadReader // Set by SetChain, nil until then
+}
+
+var _ Drivechain = (*Client)(nil)
+
+var (
+	clientsMu   sync.Mutex
+	clientSlots = make(map[uint8]bool) // Sidechain numbers used by open clients, guarded by clientsMu
+)
+
+// clientsInUse reports whether an open client uses the sidechain number.
+func clientsInUse(sidechain uint8) bool {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	return clientSlots[sidechain]
+}
+
+// NewClient verifies the mainchain RPC credentials of cfg and starts an engine
+// for cfg.SidechainNumber. It fails with ErrSidechainInUse if the process
+// already runs an engine for that sidechain. The client must be closed with
+// Close.
+func NewClient(cfg Config) (*Client, error) {
+	if cfg.SidechainNumber < 0 || cfg.SidechainNumber > math.MaxUint8 {
+		return nil, fmt.Errorf("invalid sidechain number %d, must be in range 0-%d", cfg.SidechainNumber, math.MaxUint8)
+	}
+	sidechain := uint8(cfg.SidechainNumber)
+	if cfg.ZMQEndpoint != "" && !strings.HasPrefix(cfg.ZMQEndpoint, "tcp://") {
+		return nil, fmt.Errorf("unsupported zmq endpoint %q, must start with tcp://", cfg.ZMQEndpoint)
+	}
+	if err := cfg.setupTransport(); err != nil {
+		return nil, err
+	}
+	limiter := newRPCLimiter(&cfg)
+	if limiter != nil {
+		cfg.HTTPClient = rateLimitedClient(cfg.httpClient(), limiter)
+	}
+
+	probeCtx, cancel := context.WithTimeout(context.Background(), cfg.rpcTimeout())
+	defer cancel()
+	if err := probeMainchain(probeCtx, &cfg); err != nil {
+		return nil, err
+	}
+
+	engineMu.RLock()
+	clientsMu.Lock()
+	inUse := clientSlots[sidechain] || (initialized && sidechainNumber == sidechain)
+	if !inUse {
+		clientSlots[sidechain] = true
+	}
+	clientsMu.Unlock()
+	engineMu.RUnlock()
+	if inUse {
+		return nil, fmt.Errorf("%w: %d", ErrSidechainInUse, sidechain)
+	}
+
+	engine := createBmmEngine(cfg.DBPath, sidechain, cfg.Host, cfg.RPCUser, cfg.RPCPassword, cfg.Port)
+	if engine == nil {
+		releaseClientSlot(sidechain)
+		if msg := getLastError(); msg != "" {
+			return nil, fmt.Errorf("failed to create drivechain engine: %s", msg)
+		}
+		return nil, errors.New("failed to create drivechain engine")
+	}
+	if err := setMainchainTransport(&cfg, func(url, caFile, certFile, keyFile *C.char, insecureSkipVerify C.bool) C.bool {
+		return C.bmm_engine_set_mainchain_transport(engine, url, caFile, certFile, keyFile, insecureSkipVerify)
+	}); err != nil {
+		C.destroy_bmm_engine(engine)
+		releaseClientSlot(sidechain)
+		return nil, err
+	}
+	if !bool(C.bmm_engine_set_dust_thresholds(engine, C.uint64_t(cfg.minDepositSats()), C.uint64_t(cfg.minWithdrawalSats()))) {
+		C.destroy_bmm_engine(engine)
+		releaseClientSlot(sidechain)
+		return nil, fmt.Errorf("failed to set dust thresholds of the engine: %s", getLastError())
+	}
+	c := &Client{
+		engine:      engine,
+		cfg:         cfg,
+		limiter:     limiter,
+		bmmCache:    newBmmCache(&cfg),
+		deposits:    new(depositOutputsCache),
+		depositSubs: newDepositSubscriptions(),
+		tipSubs:     newTipSubscriptions(),
+		notifiers:   new(withdrawalNotifiers),
+	}
+	if cfg.ZMQEndpoint != "" {
+		c.zmq = startZMQ(cfg.ZMQEndpoint)
+	}
+	return c, nil
+}
+
+func releaseClientSlot(sidechain uint8) {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	delete(clientSlots, sidechain)
+}
+
+func createBmmEngine(dbPath string, sidechain uint8, host, rpcUser, rpcPassword string, port uint16) *C.BmmEngine {
+	var args cArgs
+	defer args.free()
+	cDbPath := args.cString(dbPath)
+	cHost := args.cString(host)
+	cRpcUser := args.cString(rpcUser)
+	cRpcPassword := args.cString(rpcPassword)
+	return C.create_bmm_engine(cDbPath, C.uintptr_t(sidechain), cHost, C.uint16_t(port), cRpcUser, cRpcPassword)
+}
+
+// Close flushes and destroys the engine, freeing its sidechain number. It ends
+// the subscriptions and stops the pollers of c. Afterwards the methods of c
+// return ErrNotInitialized.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.engine == nil {
+		return ErrNotInitialized
+	}
+	c.depositSubs.stop()
+	c.tipSubs.stop()
+	c.notifiers.stop()
+	if c.zmq != nil {
+		c.zmq.stop()
+		c.zmq = nil
+	}
+	c.deposits.invalidate()
+	C.destroy_bmm_engine(c.engine)
+	c.engine = nil
+	releaseClientSlot(c.SidechainNumber())
+	return nil
+}
+
+// SidechainNumber returns the mainchain slot of the client.
+func (c *Client) SidechainNumber() uint8 {
+	return uint8(c.cfg.SidechainNumber)
+}
+
+// rlock takes the read lock on the engine if it's still open. The caller must
+// release it with c.mu.RUnlock unless an error is returned.
+func (c *Client) rlock() error {
+	c.mu.RLock()
+	if c.engine == nil {
+		c.mu.RUnlock()
+		return ErrNotInitialized
+	}
+	return nil
+}
+
+// lock is like rlock, but takes the write lock.
+func (c *Client) lock() error {
+	c.mu.Lock()
+	if c.engine == nil {
+		c.mu.Unlock()
+		return ErrNotInitialized
+	}
+	return nil
+}
+
+// call is like callEngine for the engine of c.
+func (c *Client) call(ctx context.Context, exclusive bool, fn func()) error {
+	var err error
+	if ctxErr := runWithContext(ctx, func() {
+		if exclusive {
+			if err = c.lock(); err != nil {
+				return
+			}
+			defer c.mu.Unlock()
+		} else {
+			if err = c.rlock(); err != nil {
+				return
+			}
+			defer c.mu.RUnlock()
+		}
+		if err = checkAborted(ctx); err != nil {
+			return
+		}
+		fn()
+	}); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+// config returns a copy of the Config of c, for the calls to the mainchain
+// node made without going through the engine.
+func (c *Client) config() (Config, error) {
+	if err := c.rlock(); err != nil {
+		return Config{}, err
+	}
+	defer c.mu.RUnlock()
+	return c.cfg, nil
+}
+
+// waitRateLimit is like the package's waitRateLimit for the limiter of c.
+func (c *Client) waitRateLimit() {
+	if c.limiter != nil {
+		c.limiter.Wait(context.Background())
+	}
+}
+
+// RPCLimiterStats is like the package's RPCLimiterStats for the limiter of c.
+func (c *Client) RPCLimiterStats() rate.Reservation {
+	if err := c.rlock(); err != nil {
+		return rate.Reservation{}
+	}
+	defer c.mu.RUnlock()
+	if c.limiter == nil {
+		return rate.Reservation{}
+	}
+	return *c.limiter.ReserveN(time.Now(), 0)
+}
+
+// WithRetry is like the package's WithRetry with the retry policy of c.
+func (c *Client) WithRetry(ctx context.Context, fn func() error) error {
+	return withRetry(ctx, c.cfg.retryPolicy(), fn)
+}
+
+// APIs is like the package's APIs for the engine of c.
+func (c *Client) APIs() []rpc.API {
+	return newAPIs(c)
+}
+
+// SetChain is like the package's SetChain, for the withdrawal data built by
+// c.
+func (c *Client) SetChain(chain ChainHeadReader) {
+	c.chainMu.Lock()
+	defer c.chainMu.Unlock()
+	c.chain = chain
+}
+
+// CurrentWithdrawalRules is like the package's CurrentWithdrawalRules for the
+// chain set with c.SetChain.
+func (c *Client) CurrentWithdrawalRules() WithdrawalRules {
+	c.chainMu.RLock()
+	defer c.chainMu.RUnlock()
+	return nextWithdrawalRules(c.chain)
+}
+
+// Ping is like CGO.Ping for the engine of c.
+func (c *Client) Ping(ctx context.Context) error {
+	cfg, err := c.config()
+	if err != nil {
+		return err
+	}
+	if err := probeMainchain(ctx, &cfg); err != nil {
+		return err
+	}
+	if cfg.CookieFile == "" {
+		return nil
+	}
+
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.mu.Unlock()
+	if cfg.RPCUser == c.cfg.RPCUser && cfg.RPCPassword == c.cfg.RPCPassword {
+		return nil
+	}
+	log.Info("Mainchain RPC cookie changed, reconnecting", "sidechain", c.SidechainNumber())
+	return c.reconnect(&cfg)
+}
+
+// HealthCheck is like the package's HealthCheck for the engine of c.
+func (c *Client) HealthCheck() error {
+	if err := c.rlock(); err != nil {
+		return &HealthError{Component: "engine", Cause: err}
+	}
+	var pingErr error
+	c.waitRateLimit()
+	if !bool(C.bmm_engine_ping(c.engine)) {
+		pingErr = fmt.Errorf("%w: %s", ErrEngineFailure, getLastError())
+	}
+	cfg := c.cfg
+	c.mu.RUnlock()
+	if pingErr != nil {
+		return &HealthError{Component: "engine", Cause: pingErr}
+	}
+	return checkMainchainHealth(&cfg)
+}
+
+// Reconnect is like the package's Reconnect for the engine of c.
+func (c *Client) Reconnect(host string, port uint16, rpcUser, rpcPassword string) error {
+	cfg, err := c.config()
+	if err != nil {
+		return err
+	}
+	if err := probeReconnect(&cfg, host, port, rpcUser, rpcPassword); err != nil {
+		return err
+	}
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.mu.Unlock()
+	return c.reconnect(&cfg)
+}
+
+// reconnect switches the engine of c over to the mainchain node and
+// credentials in cfg. The caller must hold the write lock of c.
+func (c *Client) reconnect(cfg *Config) error {
+	var args cArgs
+	defer args.free()
+	cHost := args.cString(cfg.Host)
+	cRpcUser := args.cString(cfg.RPCUser)
+	cRpcPassword := args.cString(cfg.RPCPassword)
+	if !bool(C.bmm_engine_reconnect(c.engine, cHost, C.uint16_t(cfg.Port), cRpcUser, cRpcPassword)) {
+		return fmt.Errorf("failed to reconnect drivechain engine: %s", getLastError())
+	}
+	// Only the connection settings change, the others are read without the
+	// lock.
+	c.cfg.Host, c.cfg.Port, c.cfg.URL = cfg.Host, cfg.Port, cfg.URL
+	c.cfg.RPCUser, c.cfg.RPCPassword, c.cfg.CookieFile = cfg.RPCUser, cfg.RPCPassword, cfg.CookieFile
+	return nil
+}
+
+// GetMainchainTip is like CGO.GetMainchainTip for the engine of c.
+func (c *Client) GetMainchainTip() (common.Hash, error) {
+	var tip common.Hash
+	err := withRetry(context.Background(), c.cfg.retryPolicy(), func() error {
+		if err := c.rlock(); err != nil {
+			return err
+		}
+		defer c.mu.RUnlock()
+		c.waitRateLimit()
+		var err error
+		tip, err = newMainchainTip(C.bmm_engine_get_mainchain_tip(c.engine))
+		return err
+	})
+	return tip, err
+}
+
+// GetMainchainTipInfo is like the package's GetMainchainTipInfo for the
+// mainchain node of c.
+func (c *Client) GetMainchainTipInfo() (MainchainTipInfo, error) {
+	cfg, err := c.config()
+	if err != nil {
+		return MainchainTipInfo{}, err
+	}
+	return fetchMainchainTipInfo(&cfg)
+}
+
+// mainchainTipInfo is like the package's mainchainTipInfo for the engine of
+// c. The caller must hold the lock of c.
+func (c *Client) mainchainTipInfo() (MainchainTipInfo, error) {
+	c.waitRateLimit()
+	return newMainchainTipInfo(C.bmm_engine_get_mainchain_tip_info(c.engine))
+}
+
+// GetMainchainTipHeight is like the package's GetMainchainTipHeight for the
+// mainchain node of c.
+func (c *Client) GetMainchainTipHeight() (uint64, error) {
+	tip, err := c.GetMainchainTipInfo()
+	if err != nil {
+		return 0, err
+	}
+	return tip.Height, nil
+}
+
+// GetMainchainBlock is like the package's GetMainchainBlock for the mainchain
+// node of c.
+func (c *Client) GetMainchainBlock(hash common.Hash) (MainchainBlock, error) {
+	cfg, err := c.config()
+	if err != nil {
+		return MainchainBlock{}, err
+	}
+	return fetchMainchainBlock(&cfg, hash)
+}
+
+// EstimateWithdrawalFee is like the package's EstimateWithdrawalFee for the
+// mainchain node of c.
+func (c *Client) EstimateWithdrawalFee(amount *big.Int) (uint64, error) {
+	if amount == nil || amount.Sign() <= 0 {
+		return 0, ErrZeroWithdrawalAmount
+	}
+	cfg, err := c.config()
+	if err != nil {
+		return 0, err
+	}
+	return recommendWithdrawalFee(&cfg, amount)
+}
+
+// GetMainchainConfirmations is like the package's GetMainchainConfirmations
+// for the mainchain node of c.
+func (c *Client) GetMainchainConfirmations(txHash common.Hash) (uint64, error) {
+	cfg, err := c.config()
+	if err != nil {
+		return 0, err
+	}
+	return fetchMainchainConfirmations(&cfg, txHash)
+}
+
+// SubscribeMainchainTip is like the package's SubscribeMainchainTip for the
+// mainchain node of c. Closing c ends the subscriptions.
+func (c *Client) SubscribeMainchainTip(ch chan<- MainchainTipEvent) event.Subscription {
+	if err := c.rlock(); err != nil {
+		return event.NewSubscription(func(<-chan struct{}) error { return err })
+	}
+	defer c.mu.RUnlock()
+	return c.tipSubs.subscribe(ch, c.cfg.tipPollInterval(), c.GetMainchainTipInfo, c.mainchainHeader)
+}
+
+// mainchainHeader is like the package's mainchainHeader for the mainchain
+// node of c.
+func (c *Client) mainchainHeader(hash common.Hash) (MainchainTipInfo, error) {
+	cfg, err := c.config()
+	if err != nil {
+		return MainchainTipInfo{}, err
+	}
+	return fetchMainchainHeader(&cfg, hash)
+}
+
+// depositOutputs returns the deposits the engine of c returns from get,
+// retrying as the retry policy of c allows.
+func (c *Client) depositOutputs(get func() C.Deposits) ([]RawDeposit, error) {
+	var rawDeposits []RawDeposit
+	err := withRetry(context.Background(), c.cfg.retryPolicy(), func() error {
+		if err := c.rlock(); err != nil {
+			return err
+		}
+		defer c.mu.RUnlock()
+		c.waitRateLimit()
+		var err error
+		rawDeposits, err = newRawDeposits(get())
+		return err
+	})
+	return rawDeposits, err
+}
+
+// GetDepositOutputs is like CGO.GetDepositOutputs for the engine of c.
+func (c *Client) GetDepositOutputs() ([]Deposit, error) {
+	rawDeposits, err := c.GetDepositOutputsRaw()
+	if err != nil {
+		return make([]Deposit, 0), err
+	}
+	confirmed, _, err := c.splitDepositOutputs(dedupeRawDeposits(rawDeposits))
+	if err != nil {
+		return make([]Deposit, 0), err
+	}
+	return newDepositsFromRaw(confirmed), nil
+}
+
+// GetPendingDeposits is like the package's GetPendingDeposits for the engine
+// of c.
+func (c *Client) GetPendingDeposits() ([]PendingDeposit, error) {
+	rawDeposits, err := c.GetDepositOutputsRaw()
+	if err != nil {
+		return nil, err
+	}
+	_, pending, err := c.splitDepositOutputs(dedupeRawDeposits(rawDeposits))
+	return pending, err
+}
+
+// splitDepositOutputs is like the package's splitDepositOutputs with the
+// confirmations required by c.
+func (c *Client) splitDepositOutputs(rawDeposits []RawDeposit) ([]RawDeposit, []PendingDeposit, error) {
+	return splitDepositsAtTip(rawDeposits, c.cfg.MinDepositConfirmations, c.GetMainchainTipInfo)
+}
+
+// GetDepositOutputsRaw is like the package's GetDepositOutputsRaw for the
+// engine of c.
+func (c *Client) GetDepositOutputsRaw() ([]RawDeposit, error) {
+	rawDeposits, err := c.depositOutputs(func() C.Deposits {
+		return C.bmm_engine_get_deposit_outputs(c.engine)
+	})
+	if err != nil {
+		return make([]RawDeposit, 0), fmt.Errorf("failed to get deposits: %w", err)
+	}
+	return rawDeposits, nil
+}
+
+// GetDepositOutputsSince is like the package's GetDepositOutputsSince for the
+// engine of c.
+func (c *Client) GetDepositOutputsSince(blockHash common.Hash) ([]Deposit, error) {
+	rawDeposits, err := c.depositOutputs(func() C.Deposits {
+		var args cArgs
+		defer args.free()
+		return C.bmm_engine_get_deposit_outputs_since(c.engine, args.cString(blockHash.Hex()[2:]))
+	})
+	if err != nil {
+		return make([]Deposit, 0), fmt.Errorf("failed to get deposits since %s: %w", blockHash.Hex(), err)
+	}
+	confirmed, _, err := c.splitDepositOutputs(dedupeRawDeposits(rawDeposits))
+	if err != nil {
+		return make([]Deposit, 0), err
+	}
+	return newDepositsFromRaw(confirmed), nil
+}
+
+// ReplayDeposits is like the package's ReplayDeposits for the engine of c.
+func (c *Client) ReplayDeposits(fromMainchainHeight, toMainchainHeight uint64) ([]Deposit, error) {
+	if fromMainchainHeight > toMainchainHeight {
+		return nil, fmt.Errorf("%w: %d-%d", ErrInvalidHeightRange, fromMainchainHeight, toMainchainHeight)
+	}
+	rawDeposits, err := c.depositOutputs(func() C.Deposits {
+		return C.bmm_engine_get_deposit_outputs_in_range(c.engine, C.uint64_t(fromMainchainHeight), C.uint64_t(toMainchainHeight))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to replay deposits of mainchain blocks %d-%d: %w", fromMainchainHeight, toMainchainHeight, err)
+	}
+	return newDepositsFromRaw(dedupeRawDeposits(rawDeposits)), nil
+}
+
+// VerifyDeposit is like the package's VerifyDeposit for the engine of c,
+// caching the deposit outputs for the Config.DepositCacheTTL of c.
+func (c *Client) VerifyDeposit(d Deposit) error {
+	if d.Amount == nil {
+		return fmt.Errorf("%w: no amount", ErrUnknownDeposit)
+	}
+	if err := c.rlock(); err != nil {
+		return err
+	}
+	defer c.mu.RUnlock()
+	return c.deposits.verify(d, c.cfg.depositCacheTTL(), func() ([]RawDeposit, error) {
+		c.waitRateLimit()
+		return newRawDeposits(C.bmm_engine_get_deposit_outputs(c.engine))
+	})
+}
+
+// SubscribeDeposits is like the package's SubscribeDeposits for the engine of
+// c. Closing c ends the subscriptions.
+func (c *Client) SubscribeDeposits(ch chan<- Deposit) event.Subscription {
+	if err := c.rlock(); err != nil {
+		return event.NewSubscription(func(<-chan struct{}) error { return err })
+	}
+	defer c.mu.RUnlock()
+	return c.depositSubs.subscribe(ch, c.cfg.depositPollInterval(), c.GetDepositOutputs)
+}
+
+// DepositStream is like the package's DepositStream for the engine of c. The
+// returned channel is closed once ctx is done or c is closed.
+func (c *Client) DepositStream(ctx context.Context) (<-chan Deposit, error) {
+	if err := c.rlock(); err != nil {
+		return nil, err
+	}
+	c.mu.RUnlock()
+	return depositStream(ctx, c.SubscribeDeposits), nil
+}
+
+// FormatDepositAddress is like the package's FormatDepositAddress for the
+// engine of c.
+func (c *Client) FormatDepositAddress(address common.Address) (string, error) {
+	if err := c.rlock(); err != nil {
+		return "", err
+	}
+	defer c.mu.RUnlock()
+	var args cArgs
+	defer args.free()
+	return goString(C.bmm_engine_format_deposit_address(c.engine, args.cString(address.Hex()))), nil
+}
+
+// ValidateDeposit is like the package's ValidateDeposit with the dust
+// threshold of c.
+func (c *Client) ValidateDeposit(amount uint64, fee uint64) error {
+	return validateDeposit(amount, fee, c.cfg.minDepositSats())
+}
+
+// CreateDeposit is like CGO.CreateDeposit for the engine of c.
+func (c *Client) CreateDeposit(address common.Address, amount uint64, fee uint64) (common.Hash, error) {
+	if err := c.ValidateDeposit(amount, fee); err != nil {
+		return common.Hash{}, err
+	}
+	if err := c.lock(); err != nil {
+		return common.Hash{}, err
+	}
+	defer c.mu.Unlock()
+	c.waitRateLimit()
+	var args cArgs
+	defer args.free()
+	cAddress := args.cString(strings.ToLower(address.Hex()))
+	return depositResult(C.bmm_engine_create_deposit(c.engine, cAddress, C.uint64_t(amount), C.uint64_t(fee)))
+}
+
+// ConnectBlock is like CGO.ConnectBlock for the engine of c.
+func (c *Client) ConnectBlock(sideBlockHash common.Hash, deposits []Deposit, withdrawals map[common.Hash]Withdrawal, refunds []Refund, just_checking bool) error {
+	return c.connectBlock(sideBlockHash, deposits, withdrawals, refunds, just_checking, true)
+}
+
+// ConnectBlockUnchecked is like the package's ConnectBlockUnchecked for the
+// engine of c.
+func (c *Client) ConnectBlockUnchecked(deposits []Deposit, withdrawals map[common.Hash]Withdrawal, refunds []Refund, just_checking bool) error {
+	return c.connectBlock(common.Hash{}, deposits, withdrawals, refunds, just_checking, false)
+}
+
+// connectBlock is like the package's connectBlock for the engine of c.
+func (c *Client) connectBlock(sideBlockHash common.Hash, deposits []Deposit, withdrawals map[common.Hash]Withdrawal, refunds []Refund, just_checking, checkRefunds bool) error {
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.mu.Unlock()
+	if checkRefunds && len(refunds) > 0 {
+		c.waitRateLimit()
+		if err := checkUnspentRefunds(refunds, C.bmm_engine_get_unspent_withdrawals(c.engine)); err != nil {
+			return err
+		}
+	}
+	if !just_checking {
+		defer c.deposits.invalidate()
+	}
+	args := marshalArgs()
+	defer args.free()
+	cBlock := newBlockData(&args, &BlockData{Deposits: deposits, Withdrawals: withdrawals, Refunds: refunds})
+	if sideBlockHash == (common.Hash{}) {
+		return blockError(C.bmm_engine_connect_block(c.engine, cBlock.deposits, cBlock.withdrawals, cBlock.refunds, C.bool(just_checking)))
+	}
+	cSideBlockHash := args.cString(sideBlockHash.Hex())
+	return blockError(C.bmm_engine_connect_side_block(c.engine, cSideBlockHash, cBlock, C.bool(just_checking)))
+}
+
+// ConnectBlocks is like the package's ConnectBlocks for the engine of c.
+func (c *Client) ConnectBlocks(blocks []BlockConnectData, justChecking bool) (int, error) {
+	if len(blocks) == 0 {
+		return 0, nil
+	}
+	if err := c.lock(); err != nil {
+		return 0, err
+	}
+	defer c.mu.Unlock()
+	if !justChecking {
+		defer c.deposits.invalidate()
+	}
+	args := marshalArgs()
+	defer args.free()
+	cBlocks := newBlockDataBatch(&args, blocks)
+	var failed C.uintptr_t
+	if err := blockError(C.bmm_engine_connect_blocks(c.engine, &cBlocks[0], C.uintptr_t(len(blocks)), C.bool(justChecking), &failed)); err != nil {
+		return int(failed), &BatchBlockError{Index: int(failed), Err: err}
+	}
+	return len(blocks), nil
+}
+
+// DisconnectBlocks is like the package's DisconnectBlocks for the engine of c.
+func (c *Client) DisconnectBlocks(blocks []BlockDisconnectData, justChecking bool) (int, error) {
+	if len(blocks) == 0 {
+		return 0, nil
+	}
+	if err := c.lock(); err != nil {
+		return 0, err
+	}
+	defer c.mu.Unlock()
+	if !justChecking {
+		defer c.deposits.invalidate()
+	}
+	args := marshalArgs()
+	defer args.free()
+	cBlocks := newBlockDisconnectDataBatch(&args, blocks)
+	var failed C.uintptr_t
+	if err := blockError(C.bmm_engine_disconnect_blocks(c.engine, &cBlocks[0], C.uintptr_t(len(blocks)), C.bool(justChecking), &failed)); err != nil {
+		return int(failed), &BatchBlockError{Index: int(failed), Err: err}
+	}
+	return len(blocks), nil
+}
+
+// DisconnectBlock is like CGO.DisconnectBlock for the engine of c.
+func (c *Client) DisconnectBlock(sideBlockHash common.Hash, deposits []Deposit, withdrawals []common.Hash, refunds []common.Hash, just_checking bool) error {
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.mu.Unlock()
+	if !just_checking {
+		defer c.deposits.invalidate()
+	}
+	args := marshalArgs()
+	defer args.free()
+	cDeposits := newDeposits(&args, deposits)
+	cWithdrawals := newWithdrawalsFromHash(&args, withdrawals)
+	cRefunds := newRefundsFromHash(&args, refunds)
+	if sideBlockHash == (common.Hash{}) {
+		return blockError(C.bmm_engine_disconnect_block(c.engine, cDeposits, cWithdrawals, cRefunds, C.bool(just_checking)))
+	}
+	cSideBlockHash := args.cString(sideBlockHash.Hex())
+	return blockError(C.bmm_engine_disconnect_side_block(c.engine, cSideBlockHash, cDeposits, cWithdrawals, cRefunds, C.bool(just_checking)))
+}
+
+// GetBlockSidechainActivity is like CGO.GetBlockSidechainActivity for the
+// engine of c.
+func (c *Client) GetBlockSidechainActivity(sideBlockHash common.Hash) (BlockActivity, error) {
+	if err := c.rlock(); err != nil {
+		return BlockActivity{}, err
+	}
+	defer c.mu.RUnlock()
+	var args cArgs
+	defer args.free()
+	cSideBlockHash := args.cString(sideBlockHash.Hex())
+	return blockActivity(sideBlockHash, C.bmm_engine_get_block_activity(c.engine, cSideBlockHash))
+}
+
+// ValidateBlock is like the package's ValidateBlock for the engine of c.
+func (c *Client) ValidateBlock(deposits []Deposit, withdrawals map[common.Hash]Withdrawal, refunds []Refund) error {
+	if err := c.rlock(); err != nil {
+		return err
+	}
+	defer c.mu.RUnlock()
+	args := marshalArgs()
+	defer args.free()
+	cBlock := newBlockData(&args, &BlockData{Deposits: deposits, Withdrawals: withdrawals, Refunds: refunds})
+	return checkResultsError(C.bmm_engine_validate_block(c.engine, cBlock))
+}
+
+// ExportState is like the package's ExportState for the engine of c.
+func (c *Client) ExportState() ([]byte, error) {
+	if err := c.rlock(); err != nil {
+		return nil, err
+	}
+	defer c.mu.RUnlock()
+	_, version, err := stateFormatVersions()
+	if err != nil {
+		return nil, err
+	}
+	return newStateSnapshot(version, C.bmm_engine_export_state(c.engine, C.uint32_t(version)))
+}
+
+// ImportState is like the package's ImportState for the engine of c.
+func (c *Client) ImportState(snapshot []byte) error {
+	version, payload, err := splitStateSnapshot(snapshot)
+	if err != nil {
+		return err
+	}
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.mu.Unlock()
+	if err := checkStateVersion(version); err != nil {
+		return err
+	}
+	defer c.deposits.invalidate()
+	var args cArgs
+	defer args.free()
+	if !bool(C.bmm_engine_import_state(c.engine, args.cBytes(payload), C.uintptr_t(len(payload)), C.uint32_t(version))) {
+		return fmt.Errorf("failed to import state: %w: %s", ErrEngineFailure, getLastError())
+	}
+	return nil
+}
+
+// GetWithdrawalData is like CGO.GetWithdrawalData for the engine of c, in the
+// format of c.CurrentWithdrawalRules.
+func (c *Client) GetWithdrawalData(fee uint64) ([]byte, error) {
+	if err := c.rlock(); err != nil {
+		return nil, err
+	}
+	defer c.mu.RUnlock()
+	c.waitRateLimit()
+	address := newMainchainAddress(C.bmm_engine_get_new_mainchain_address(c.engine))
+	return encodeWithdrawalData(fee, address, c.CurrentWithdrawalRules())
+}
+
+// ValidateWithdrawalData is like the package's ValidateWithdrawalData under
+// c.CurrentWithdrawalRules.
+func (c *Client) ValidateWithdrawalData(data []byte) error {
+	return c.CurrentWithdrawalRules().ValidateWithdrawalData(data)
+}
+
+// CreateWithdrawal is like the package's CreateWithdrawal for the engine of c,
+// with the dust threshold of c and in the format of c.CurrentWithdrawalRules.
+func (c *Client) CreateWithdrawal(ctx context.Context, signer types.Signer, key *ecdsa.PrivateKey, amount *big.Int, fee uint64, nonce uint64) (*types.Transaction, error) {
+	if err := checkWithdrawal(amount, fee, c.cfg.minWithdrawalSats()); err != nil {
+		return nil, err
+	}
+	return c.createWithdrawal(ctx, signer, key, amount, fee, nonce, nil)
+}
+
+// createWithdrawal is like the package's createWithdrawal for the engine of c.
+func (c *Client) createWithdrawal(ctx context.Context, signer types.Signer, key *ecdsa.PrivateKey, amount *big.Int, fee uint64, nonce uint64, gasPrice *big.Int) (*types.Transaction, error) {
+	var address MainchainAddress
+	if err := c.call(ctx, false, func() {
+		c.waitRateLimit()
+		address = newMainchainAddress(C.bmm_engine_get_new_mainchain_address(c.engine))
+	}); err != nil {
+		return nil, err
+	}
+	return signWithdrawal(signer, key, amount, fee, nonce, gasPrice, address, c.CurrentWithdrawalRules())
+}
+
+// Withdraw is like the package's Withdraw for the engine of c, with the dust
+// threshold of c and in the format of c.CurrentWithdrawalRules.
+func (c *Client) Withdraw(ctx context.Context, client EthClient, signer types.Signer, key *ecdsa.PrivateKey, weiAmount *big.Int, fee uint64) (common.Hash, error) {
+	amount, err := withdrawalSatoshis(weiAmount)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if err := checkWithdrawal(amount, fee, c.cfg.minWithdrawalSats()); err != nil {
+		return common.Hash{}, err
+	}
+	return sendWithdrawal(ctx, client, key, func(nonce uint64, gasPrice *big.Int) (*types.Transaction, error) {
+		return c.createWithdrawal(ctx, signer, key, amount, fee, nonce, gasPrice)
+	})
+}
+
+// GetUnspentWithdrawals is like CGO.GetUnspentWithdrawals for the engine of c.
+func (c *Client) GetUnspentWithdrawals(ctx context.Context) (map[common.Hash]WithdrawalInfo, error) {
+	var (
+		withdrawals map[common.Hash]WithdrawalInfo
+		err         error
+	)
+	if callErr := c.call(ctx, false, func() {
+		withdrawals, err = newWithdrawalInfos(C.bmm_engine_get_unspent_withdrawals(c.engine), func(id common.Hash) C.WithdrawalStatus {
+			var args cArgs
+			defer args.free()
+			return C.bmm_engine_get_withdrawal_status(c.engine, args.cString(id.Hex()))
+		})
+	}); callErr != nil {
+		return nil, callErr
+	}
+	return withdrawals, err
+}
+
+// GetWithdrawalStatusInfo is like CGO.GetWithdrawalStatusInfo for the engine
+// of c.
+func (c *Client) GetWithdrawalStatusInfo(id common.Hash) (WithdrawalStatusInfo, error) {
+	if err := c.rlock(); err != nil {
+		return WithdrawalStatusInfo{}, err
+	}
+	defer c.mu.RUnlock()
+	var args cArgs
+	defer args.free()
+	cId := args.cString(id.Hex())
+	return newWithdrawalStatusInfo(id, C.bmm_engine_get_withdrawal_status_info(c.engine, cId))
+}
+
+// GetWithdrawalStatus is like the package's GetWithdrawalStatus for the
+// engine of c.
+func (c *Client) GetWithdrawalStatus(id common.Hash) (WithdrawalStatus, error) {
+	if err := c.rlock(); err != nil {
+		return 0, err
+	}
+	defer c.mu.RUnlock()
+	var args cArgs
+	defer args.free()
+	cId := args.cString(id.Hex())
+	return withdrawalStatus(id, C.bmm_engine_get_withdrawal_status(c.engine, cId))
+}
+
+// GetWithdrawalHistory is like the package's GetWithdrawalHistory for the
+// engine of c.
+func (c *Client) GetWithdrawalHistory(id common.Hash) ([]WithdrawalEvent, error) {
+	if err := c.rlock(); err != nil {
+		return nil, err
+	}
+	defer c.mu.RUnlock()
+	var args cArgs
+	defer args.free()
+	cId := args.cString(id.Hex())
+	return newWithdrawalEvents(id, C.bmm_engine_get_withdrawal_history(c.engine, cId))
+}
+
+// RegisterWithdrawalNotifier is like the package's RegisterWithdrawalNotifier
+// for the withdrawals of the engine of c. Closing c stops the polling.
+func (c *Client) RegisterWithdrawalNotifier(n WithdrawalNotifier) {
+	running := c.rlock() == nil
+	if running {
+		defer c.mu.RUnlock()
+	}
+	c.notifiers.register(n)
+	if running {
+		c.notifiers.start(c.cfg.withdrawalPollInterval(), c.GetUnspentWithdrawals, c.GetWithdrawalStatus)
+	}
+}
+
+// GetSpentWithdrawals is like the package's GetSpentWithdrawals for the
+// engine of c.
+func (c *Client) GetSpentWithdrawals() (map[common.Hash]SpentWithdrawal, error) {
+	return getSpentWithdrawals(c.GetSpentWithdrawalsPage)
+}
+
+// GetSpentWithdrawalsPage is like the package's GetSpentWithdrawalsPage for
+// the engine of c.
+func (c *Client) GetSpentWithdrawalsPage(after common.Hash, limit int) ([]SpentWithdrawal, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidPageLimit, limit)
+	}
+	if err := c.rlock(); err != nil {
+		return nil, err
+	}
+	defer c.mu.RUnlock()
+	var args cArgs
+	defer args.free()
+	var cAfter *C.char
+	if after != (common.Hash{}) {
+		cAfter = args.cString(after.Hex())
+	}
+	return newSpentWithdrawals(C.bmm_engine_get_spent_withdrawals(c.engine, cAfter, C.uintptr_t(limit)))
+}
+
+// IsWithdrawalSpent is like CGO.IsWithdrawalSpent for the engine of c.
+func (c *Client) IsWithdrawalSpent(id common.Hash) (bool, error) {
+	if err := c.rlock(); err != nil {
+		return false, err
+	}
+	defer c.mu.RUnlock()
+	var args cArgs
+	defer args.free()
+	cId := args.cString(id.Hex())
+	return bool(C.bmm_engine_is_outpoint_spent(c.engine, cId)), nil
+}
+
+// AreWithdrawalsSpent is like the package's AreWithdrawalsSpent for the engine
+// of c.
+func (c *Client) AreWithdrawalsSpent(ids []common.Hash) (map[common.Hash]bool, error) {
+	if err := c.rlock(); err != nil {
+		return nil, err
+	}
+	defer c.mu.RUnlock()
+	if len(ids) == 0 {
+		return make(map[common.Hash]bool), nil
+	}
+	var args cArgs
+	defer args.free()
+	results := make([]C.SpentStatus, len(ids))
+	if !bool(C.bmm_engine_are_outpoints_spent(c.engine, newOutpoints(&args, ids), C.uintptr_t(len(ids)), &results[0])) {
+		return nil, fmt.Errorf("%w: %s", ErrEngineFailure, getLastError())
+	}
+	return newSpentStatuses(ids, results), nil
+}
+
+// AttemptBundleBroadcast is like CGO.AttemptBundleBroadcast for the engine of
+// c.
+func (c *Client) AttemptBundleBroadcast(ctx context.Context) (BroadcastResult, error) {
+	var (
+		result BroadcastResult
+		err    error
+	)
+	if callErr := c.call(ctx, true, func() {
+		c.waitRateLimit()
+		result, err = newBroadcastResult(C.bmm_engine_attempt_bundle_broadcast(c.engine))
+	}); callErr != nil {
+		return BroadcastResult{}, callErr
+	}
+	return result, err
+}
+
+// GetPendingBundleHash is like the package's GetPendingBundleHash for the
+// engine of c. The bool is also false once c is closed.
+func (c *Client) GetPendingBundleHash() (common.Hash, bool) {
+	if err := c.rlock(); err != nil {
+		return common.Hash{}, false
+	}
+	defer c.mu.RUnlock()
+	return pendingBundleHash(C.bmm_engine_get_pending_bundle_hash(c.engine))
+}
+
+// GetCurrentBundle is like the package's GetCurrentBundle for the engine of c.
+func (c *Client) GetCurrentBundle() (WithdrawalBundle, error) {
+	bundle, err := c.GetPendingBundle()
+	if err != nil {
+		return WithdrawalBundle{}, err
+	}
+	return bundle.WithdrawalBundle, nil
+}
+
+// GetPendingBundle is like the package's GetPendingBundle for the engine of c.
+func (c *Client) GetPendingBundle() (*Bundle, error) {
+	if err := c.rlock(); err != nil {
+		return nil, err
+	}
+	defer c.mu.RUnlock()
+	return newBundle(C.bmm_engine_get_current_bundle(c.engine))
+}
+
+// GetRefundableWithdrawals is like the package's GetRefundableWithdrawals for
+// the engine of c.
+func (c *Client) GetRefundableWithdrawals() ([]Refund, error) {
+	if err := c.rlock(); err != nil {
+		return nil, err
+	}
+	defer c.mu.RUnlock()
+	var cRefunds C.Refunds
+	if !bool(C.bmm_engine_get_refundable_withdrawals(c.engine, &cRefunds)) {
+		return nil, fmt.Errorf("%w: %s", ErrEngineFailure, getLastError())
+	}
+	return newRefundableWithdrawals(cRefunds), nil
+}
+
+// ExpectedTreasuryBalance is like the package's ExpectedTreasuryBalance for
+// the database of the engine of c.
+func (c *Client) ExpectedTreasuryBalance() (*big.Int, error) {
+	deposited, withdrawn, err := c.treasuryTotals()
+	if err != nil {
+		return nil, err
+	}
+	return expectedTreasuryBalance(deposited, withdrawn), nil
+}
+
+// CheckTreasuryInvariant is like the package's CheckTreasuryInvariant for the
+// database of the engine of c.
+func (c *Client) CheckTreasuryInvariant(actual *big.Int) error {
+	return checkTreasuryInvariant(actual, c.treasuryTotals)
+}
+
+// treasuryTotals is like the package's getTreasuryTotals for the engine of c.
+func (c *Client) treasuryTotals() (deposited, withdrawn uint64, err error) {
+	if err := c.rlock(); err != nil {
+		return 0, 0, err
+	}
+	defer c.mu.RUnlock()
+	var cDeposited, cWithdrawn C.uint64_t
+	if !bool(C.bmm_engine_get_treasury_totals(c.engine, &cDeposited, &cWithdrawn)) {
+		return 0, 0, fmt.Errorf("failed to get treasury totals: %w: %s", ErrEngineFailure, getLastError())
+	}
+	return uint64(cDeposited), uint64(cWithdrawn), nil
+}
+
+// FormatMainchainAddress is like CGO.FormatMainchainAddress. The format
+// doesn't depend on the engine, it only needs c to be open.
+func (c *Client) FormatMainchainAddress(dest MainchainAddress) (string, error) {
+	if dest.Type.Length() == 0 {
+		return "", fmt.Errorf("%w: %v", ErrUnknownMainchainAddressType, dest.Type)
+	}
+	if err := c.rlock(); err != nil {
+		return "", err
+	}
+	defer c.mu.RUnlock()
+	return formatMainchainAddress(dest)
+}
+
+// AttemptBmm is like CGO.AttemptBmm for the engine of c.
+func (c *Client) AttemptBmm(ctx context.Context, header *types.Header, amount uint64) (common.Hash, error) {
+	if header.PrevMainBlockHash == (common.Hash{}) {
+		return common.Hash{}, ErrNoPrevMainBlockHash
+	}
+	criticalHash := header.Hash().Hex()[2:]
+	prevMainBlockHash := header.PrevMainBlockHash.Hex()[2:]
+	var (
+		txid       common.Hash
+		attemptErr error
+	)
+	err := c.call(ctx, true, func() {
+		var args cArgs
+		defer args.free()
+		cCriticalHash := args.cString(criticalHash)
+		cPrevMainBlockHash := args.cString(prevMainBlockHash)
+		c.waitRateLimit()
+		txid, attemptErr = newBmmAttempt(C.bmm_engine_attempt_bmm(c.engine, cCriticalHash, cPrevMainBlockHash, C.uint64_t(amount)))
+	})
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return txid, attemptErr
+}
+
+// GetBmmAmount is like the package's GetBmmAmount for the engine of c.
+func (c *Client) GetBmmAmount() (uint64, error) {
+	if err := c.rlock(); err != nil {
+		return 0, err
+	}
+	defer c.mu.RUnlock()
+	var amount C.uint64_t
+	if !bool(C.bmm_engine_get_bmm_amount(c.engine, &amount)) {
+		return 0, fmt.Errorf("failed to get bmm amount: %w: %s", ErrEngineFailure, getLastError())
+	}
+	return uint64(amount), nil
+}
+
+// SetBmmAmount is like the package's SetBmmAmount for the engine of c.
+func (c *Client) SetBmmAmount(amount uint64) error {
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.mu.Unlock()
+	if !bool(C.bmm_engine_set_bmm_amount(c.engine, C.uint64_t(amount))) {
+		return fmt.Errorf("failed to set bmm amount: %w: %s", ErrEngineFailure, getLastError())
+	}
+	return nil
+}
+
+// GetBmmHistory is like the package's GetBmmHistory for the engine of c.
+func (c *Client) GetBmmHistory(limit int) ([]BmmRecord, error) {
+	if limit < 0 {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidPageLimit, limit)
+	}
+	if err := c.rlock(); err != nil {
+		return nil, err
+	}
+	defer c.mu.RUnlock()
+	return newBmmRecords(C.bmm_engine_get_bmm_history(c.engine, C.uintptr_t(limit)))
+}
+
+// ConfirmBmm is like CGO.ConfirmBmm for the engine of c.
+func (c *Client) ConfirmBmm(ctx context.Context) (BmmState, common.Hash, error) {
+	var (
+		state         BmmState
+		mainBlockHash common.Hash
+	)
+	if err := c.call(ctx, true, func() {
+		c.waitRateLimit()
+		state, mainBlockHash = newBmmConfirmation(C.bmm_engine_confirm_bmm(c.engine))
+	}); err != nil {
+		return Pending, common.Hash{}, err
+	}
+	return state, mainBlockHash, nil
+}
+
+// ListenForBmmConfirmation is like the package's ListenForBmmConfirmation for
+// the engine of c.
+func (c *Client) ListenForBmmConfirmation(ctx context.Context, pollInterval time.Duration) (<-chan BmmResult, error) {
+	return listenForBmmConfirmation(ctx, pollInterval, c.ConfirmBmm)
+}
+
+// WaitForBmm is like the package's WaitForBmm for the engine of c.
+func (c *Client) WaitForBmm(ctx context.Context) (BmmState, common.Hash, error) {
+	return waitForBmm(ctx, c.ConfirmBmm)
+}
+
+// CancelBmm is like CGO.CancelBmm for the engine of c.
+func (c *Client) CancelBmm() error {
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.mu.Unlock()
+	if !bool(C.bmm_engine_cancel_bmm(c.engine)) {
+		return fmt.Errorf("failed to cancel bmm: %w: %s", ErrEngineFailure, getLastError())
+	}
+	return nil
+}
+
+// VerifyBmm is like CGO.VerifyBmm for the engine of c, caching the verified
+// commitments for the Config.BmmCacheSize of c.
+func (c *Client) VerifyBmm(prevMainBlockHash common.Hash, criticalHash common.Hash) (bool, error) {
+	if err := c.rlock(); err != nil {
+		return false, err
+	}
+	defer c.mu.RUnlock()
+	if c.bmmCache != nil {
+		if _, ok := c.bmmCache.Get(bmmCacheKey{prevMainBlockHash, criticalHash}); ok {
+			return true, nil
+		}
+	}
+	var args cArgs
+	defer args.free()
+	cPrevMainBlockHash := args.cString(prevMainBlockHash.Hex()[2:])
+	cCriticalHash := args.cString(criticalHash.Hex()[2:])
+	c.waitRateLimit()
+	verified := bool(C.bmm_engine_verify_bmm(c.engine, cPrevMainBlockHash, cCriticalHash))
+	if verified && c.bmmCache != nil {
+		if tip, err := c.mainchainTipInfo(); err == nil {
+			cacheBmmCommitment(c.bmmCache, tip, prevMainBlockHash, criticalHash)
+		}
+	}
+	return verified, nil
+}
+
+// PrefetchBmmCommitments is like the package's PrefetchBmmCommitments for the
+// engine of c and its cache.
+func (c *Client) PrefetchBmmCommitments(fromMainHash, toMainHash common.Hash) error {
+	if err := c.rlock(); err != nil {
+		return err
+	}
+	defer c.mu.RUnlock()
+	if c.bmmCache == nil {
+		return nil
+	}
+	tip, err := c.mainchainTipInfo()
+	if err != nil {
+		return err
+	}
+	var args cArgs
+	defer args.free()
+	cFrom := args.cString(fromMainHash.Hex()[2:])
+	cTo := args.cString(toMainHash.Hex()[2:])
+	c.waitRateLimit()
+	return cacheBmmCommitments(c.bmmCache, tip, C.bmm_engine_get_bmm_commitments(c.engine, cFrom, cTo))
+}
@@ -0,0 +1,160 @@
+package drivechain
+
+import (
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// newTestClientConfig returns the config of a client for sidechain using a
+// test mainchain node.
+func newTestClientConfig(t *testing.T, sidechain int) Config {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(testMainchainHandler))
+	t.Cleanup(srv.Close)
+	u, _ := url.Parse(srv.URL)
+	port, _ := strconv.Atoi(u.Port())
+	return Config{DBPath: t.TempDir(), Host: u.Hostname(), Port: uint16(port), SidechainNumber: sidechain}
+}
+
+// Tests that clients for different sidechains run next to each other and the
+// engine started by Init, and that a sidechain number is only used once.
+func TestClientSidechainNumbers(t *testing.T) {
+	initTestEngine(t, nil)
+
+	if _, err := NewClient(newTestClientConfig(t, 0)); !errors.Is(err, ErrSidechainInUse) {
+		t.Fatalf("client for the sidechain of Init: got %v, want %v", err, ErrSidechainInUse)
+	}
+	first, err := NewClient(newTestClientConfig(t, 1))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	second, err := NewClient(newTestClientConfig(t, 2))
+	if err != nil {
+		t.Fatalf("failed to create second client: %v", err)
+	}
+	defer second.Close()
+	if _, err := NewClient(newTestClientConfig(t, 1)); !errors.Is(err, ErrSidechainInUse) {
+		t.Fatalf("second client for sidechain 1: got %v, want %v", err, ErrSidechainInUse)
+	}
+
+	want := common.HexToHash("01")
+	for _, c := range []*Client{first, second} {
+		if tip, err := c.GetMainchainTip(); err != nil || tip != want {
+			t.Errorf("sidechain %d: tip %x, %v, want %x", c.SidechainNumber(), tip, err, want)
+		}
+	}
+
+	if err := first.Close(); err != nil {
+		t.Fatalf("failed to close client: %v", err)
+	}
+	if _, err := first.GetMainchainTip(); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("closed client: got %v, want %v", err, ErrNotInitialized)
+	}
+	if err := first.Close(); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("closing twice: got %v, want %v", err, ErrNotInitialized)
+	}
+	reopened, err := NewClient(newTestClientConfig(t, 1))
+	if err != nil {
+		t.Fatalf("failed to reuse sidechain number of closed client: %v", err)
+	}
+	reopened.Close()
+}
+
+// Tests that a client builds withdrawal data for the chain set on it, not for
+// the chain of the engine started by Init.
+func TestClientWithdrawalRules(t *testing.T) {
+	initTestEngine(t, nil)
+	c, err := NewClient(newTestClientConfig(t, 1))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	defer c.Close()
+
+	forked := *params.MainnetChainConfig
+	forked.TaggedWithdrawalBlock, forked.VersionedWithdrawalBlock = big.NewInt(10), big.NewInt(20)
+	setTestChain(t, params.MainnetChainConfig, 100)
+	c.SetChain(testChain{config: &forked, head: &types.Header{Number: big.NewInt(19)}})
+
+	data, err := c.GetWithdrawalData(10)
+	if err != nil {
+		t.Fatalf("failed to get withdrawal data: %v", err)
+	}
+	if want := 1 + legacyWithdrawalDataLength + 1; len(data) != want {
+		t.Errorf("client data length mismatch: have %d, want %d", len(data), want)
+	}
+	if err := c.ValidateWithdrawalData(data); err != nil {
+		t.Errorf("client rejects its own data: %v", err)
+	}
+	if err := ValidateWithdrawalData(data); err == nil {
+		t.Error("versioned data accepted under the rules of the engine started by Init")
+	}
+	if data, err := GetWithdrawalData(10); err != nil || len(data) != legacyWithdrawalDataLength {
+		t.Errorf("engine data length mismatch: have %d, %v, want %d", len(data), err, legacyWithdrawalDataLength)
+	}
+}
+
+// Tests that the bundle state of a client is read from its own engine.
+func TestClientPendingBundleHash(t *testing.T) {
+	c, err := NewClient(newTestClientConfig(t, 1))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	if hash, ok := c.GetPendingBundleHash(); ok {
+		t.Fatalf("nothing broadcast, but bundle %x reported", hash)
+	}
+	if _, err := c.GetPendingBundle(); !errors.Is(err, ErrNoBundle) {
+		t.Errorf("pending bundle: have %v, want %v", err, ErrNoBundle)
+	}
+	if refunds, err := c.GetRefundableWithdrawals(); err != nil || len(refunds) != 0 {
+		t.Errorf("refundable withdrawals: have %v, %v, want none", refunds, err)
+	}
+	c.Close()
+	if hash, ok := c.GetPendingBundleHash(); ok {
+		t.Errorf("client closed, but bundle %x reported", hash)
+	}
+	if _, err := c.GetRefundableWithdrawals(); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("closed client: have %v, want %v", err, ErrNotInitialized)
+	}
+}
+
+// Tests that closing a client ends its subscriptions, leaving those of the
+// engine started by Init running.
+func TestClientCloseEndsSubscriptions(t *testing.T) {
+	initTestEngine(t, nil)
+	c, err := NewClient(newTestClientConfig(t, 1))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	engineSub := SubscribeDeposits(make(chan Deposit))
+	defer engineSub.Unsubscribe()
+	depositSub := c.SubscribeDeposits(make(chan Deposit))
+	tipSub := c.SubscribeMainchainTip(make(chan MainchainTipEvent))
+
+	c.Close()
+	for name, sub := range map[string]event.Subscription{"deposit": depositSub, "tip": tipSub} {
+		select {
+		case <-sub.Err():
+		case <-time.After(time.Second):
+			t.Errorf("%s subscription not ended by Close", name)
+		}
+	}
+	select {
+	case err := <-engineSub.Err():
+		t.Errorf("subscription of the engine started by Init ended: %v", err)
+	default:
+	}
+	if err := <-c.SubscribeDeposits(make(chan Deposit)).Err(); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("subscribing to a closed client: have %v, want %v", err, ErrNotInitialized)
+	}
+}
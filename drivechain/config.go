@@ -0,0 +1,253 @@
+package drivechain
+
+import (
+	"net/http"
+	"time"
+)
+
+// DefaultRPCTimeout is the time the mainchain RPC probe done on startup is
+// allowed to take when Config.RPCTimeout isn't set.
+const DefaultRPCTimeout = 5 * time.Second
+
+// Config contains the settings the drivechain engine is started with.
+type Config struct {
+	DBPath      string // Directory of the engine's database
+	Host        string // Mainchain node hostname
+	Port        uint16 // Mainchain node RPC port
+	RPCUser     string // Mainchain node rpcuser
+	RPCPassword string // Mainchain node rpcpassword
+
+	// URL is the mainchain node's RPC URL including the scheme, e.g.
+	// "https://mainchain.example.com:8332". If set it's used instead of Host
+	// and Port.
+	URL string
+
+	// TLS configures https connections to the mainchain node, both from Go
+	// and from the engine.
+	TLS TLSConfig
+
+	// Transport, if set, carries the mainchain RPC requests made from Go,
+	// e.g. to tunnel them or dial a unix socket. It isn't used if HTTPClient
+	// is set. The engine's own requests can't go through it, they only follow
+	// URL and TLS.
+	Transport http.RoundTripper
+
+	// CookieFile is the path of the mainchain node's RPC cookie file, e.g.
+	// ~/.drivechain/regtest/.cookie. If set, the credentials are read from it
+	// instead of RPCUser and RPCPassword.
+	CookieFile string
+
+	// SidechainNumber is the slot the sidechain was activated in on mainchain,
+	// it must be in the range 0-255.
+	SidechainNumber int
+
+	// RPCTimeout bounds the getblockchaininfo probe used to verify the RPC
+	// credentials. Zero means DefaultRPCTimeout.
+	RPCTimeout time.Duration
+
+	// HTTPClient is used for mainchain RPC requests made from Go, so TLS,
+	// proxies and connection pooling can be set up independently of the rest
+	// of the application. Nil means http.DefaultClient.
+	HTTPClient *http.Client
+
+	// DepositPollInterval is how often the engine is asked for new deposit
+	// outputs while there are deposit subscribers. Zero means DefaultDepositPollInterval.
+	DepositPollInterval time.Duration
+
+	// ZMQEndpoint is the mainchain node's zmqpubhashblock endpoint, e.g.
+	// "tcp://127.0.0.1:28332". If set, new mainchain blocks wake the tip and
+	// BMM pollers right away, which otherwise only poll every few seconds.
+	// While the endpoint can't be reached the pollers keep their intervals.
+	ZMQEndpoint string
+
+	// TipPollInterval is how often the mainchain tip is checked while there
+	// are tip subscribers. Zero means DefaultTipPollInterval.
+	TipPollInterval time.Duration
+
+	// WithdrawalPollInterval is how often the withdrawals are checked for
+	// status changes while there are withdrawal notifiers. Zero means
+	// DefaultWithdrawalPollInterval.
+	WithdrawalPollInterval time.Duration
+
+	// MinDepositConfirmations is the number of mainchain confirmations a
+	// deposit needs before GetDepositOutputs returns it. It's a local policy,
+	// blocks crediting shallower deposits are still valid. Zero returns
+	// deposits as soon as the engine sees them.
+	MinDepositConfirmations uint64
+
+	// MinDepositSats is the smallest deposit in satoshis CreateDeposit makes.
+	// Zero means DefaultMinDepositSats.
+	MinDepositSats uint64
+
+	// MinWithdrawalSats is the smallest withdrawal in satoshis the engine puts
+	// into a bundle and CreateWithdrawal makes, as smaller ones would be
+	// unspendable mainchain dust. Zero means DefaultMinWithdrawalSats. It's a
+	// local policy, the smallest valid withdrawal is set by the chain config,
+	// see params.ChainConfig.MinWithdrawalSats, which the node passes here.
+	MinWithdrawalSats uint64
+
+	// DepositCacheTTL is how long VerifyDeposit reuses the deposit outputs it
+	// fetched from the engine. Zero means DefaultDepositCacheTTL.
+	DepositCacheTTL time.Duration
+
+	// FeeEstimateConf is the confirmation target, in mainchain blocks, that
+	// EstimateWithdrawalFee asks fee estimates for. Zero means
+	// DefaultFeeEstimateConf.
+	FeeEstimateConf int
+
+	// RateLimit is the maximum number of mainchain RPC calls per second, made
+	// either by the engine or from Go. Zero means no limit.
+	RateLimit float64
+
+	// BmmCacheSize is the number of BMM commitments verified by VerifyBmm
+	// that are cached. Zero means DefaultBmmCacheSize, a negative size
+	// disables the cache.
+	BmmCacheSize int
+
+	// Retry is the policy read-only engine calls and WithRetry follow when the
+	// mainchain node can't be reached. The zero value means DefaultRetryPolicy,
+	// otherwise only zero Attempts, BaseDelay and MaxDelay are defaulted.
+	Retry RetryPolicy
+}
+
+// Option modifies the Config used by Init.
+type Option func(*Config)
+
+// WithHTTPClient makes Init use c for mainchain RPC requests.
+func WithHTTPClient(c *http.Client) Option {
+	return func(cfg *Config) {
+		cfg.HTTPClient = c
+	}
+}
+
+// WithURL makes Init connect to the mainchain node at u, e.g.
+// "https://mainchain.example.com:8332", instead of host and port.
+func WithURL(u string) Option {
+	return func(cfg *Config) {
+		cfg.URL = u
+	}
+}
+
+// WithTLS makes Init use t for https connections to the mainchain node.
+func WithTLS(t TLSConfig) Option {
+	return func(cfg *Config) {
+		cfg.TLS = t
+	}
+}
+
+// WithTransport makes Init send the mainchain RPC requests made from Go through
+// rt.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(cfg *Config) {
+		cfg.Transport = rt
+	}
+}
+
+// WithMinDepositConfirmations makes GetDepositOutputs hold back deposits with
+// less than n mainchain confirmations.
+func WithMinDepositConfirmations(n uint64) Option {
+	return func(cfg *Config) {
+		cfg.MinDepositConfirmations = n
+	}
+}
+
+// WithCookieFile makes Init authenticate to the mainchain node with the
+// credentials in its RPC cookie file.
+func WithCookieFile(path string) Option {
+	return func(cfg *Config) {
+		cfg.CookieFile = path
+	}
+}
+
+// WithRetryPolicy makes Init use p for retrying transient mainchain failures.
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(cfg *Config) {
+		cfg.Retry = p
+	}
+}
+
+func (c *Config) rpcTimeout() time.Duration {
+	if c.RPCTimeout <= 0 {
+		return DefaultRPCTimeout
+	}
+	return c.RPCTimeout
+}
+
+func (c *Config) depositPollInterval() time.Duration {
+	if c.DepositPollInterval <= 0 {
+		return DefaultDepositPollInterval
+	}
+	return c.DepositPollInterval
+}
+
+func (c *Config) tipPollInterval() time.Duration {
+	if c.TipPollInterval <= 0 {
+		return DefaultTipPollInterval
+	}
+	return c.TipPollInterval
+}
+
+func (c *Config) withdrawalPollInterval() time.Duration {
+	if c.WithdrawalPollInterval <= 0 {
+		return DefaultWithdrawalPollInterval
+	}
+	return c.WithdrawalPollInterval
+}
+
+func (c *Config) depositCacheTTL() time.Duration {
+	if c.DepositCacheTTL <= 0 {
+		return DefaultDepositCacheTTL
+	}
+	return c.DepositCacheTTL
+}
+
+// WithFeeEstimateConf makes EstimateWithdrawalFee aim for confirmation within
+// conf mainchain blocks.
+func WithFeeEstimateConf(conf int) Option {
+	return func(cfg *Config) {
+		cfg.FeeEstimateConf = conf
+	}
+}
+
+func (c *Config) feeEstimateConf() int {
+	if c.FeeEstimateConf <= 0 {
+		return DefaultFeeEstimateConf
+	}
+	return c.FeeEstimateConf
+}
+
+func (c *Config) bmmCacheSize() int {
+	if c.BmmCacheSize == 0 {
+		return DefaultBmmCacheSize
+	}
+	return c.BmmCacheSize
+}
+
+func (c *Config) retryPolicy() RetryPolicy {
+	p := c.Retry
+	if p == (RetryPolicy{}) {
+		return DefaultRetryPolicy
+	}
+	if p.Attempts <= 0 {
+		p.Attempts = DefaultRetryPolicy.Attempts
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = DefaultRetryPolicy.BaseDelay
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = DefaultRetryPolicy.MaxDelay
+	}
+	if p.Jitter < 0 {
+		p.Jitter = 0
+	} else if p.Jitter > 1 {
+		p.Jitter = 1
+	}
+	return p
+}
+
+func (c *Config) httpClient() *http.Client {
+	if c.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return c.HTTPClient
+}
@@ -0,0 +1,23 @@
+package drivechain
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// readCookieFile reads the user and password from a mainchain node's RPC cookie
+// file. The node rewrites the file with a fresh password on every start, so it
+// has to be read again whenever the node rejects the credentials.
+func readCookieFile(path string) (string, string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", fmt.Errorf("can't read mainchain RPC cookie: %w", err)
+	}
+	cookie := strings.TrimSpace(string(data))
+	i := strings.IndexByte(cookie, ':')
+	if i <= 0 {
+		return "", "", fmt.Errorf("malformed mainchain RPC cookie %s", path)
+	}
+	return cookie[:i], cookie[i+1:], nil
+}
@@ -0,0 +1,150 @@
+package drivechain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// DefaultDepositCacheTTL is how long VerifyDeposit reuses the deposit outputs
+// fetched from the engine when Config.DepositCacheTTL isn't set.
+const DefaultDepositCacheTTL = 5 * time.Second
+
+// depositOutputsCache holds the deposit outputs last fetched by
+// VerifyDeposit.
+type depositOutputsCache struct {
+	lock     sync.Mutex
+	deposits []Deposit
+	expires  time.Time
+}
+
+// depositCache is the deposit outputs cache of the engine started by Init.
+var depositCache = new(depositOutputsCache)
+
+// VerifyDeposit checks that the engine knows about a deposit output to
+// d.Address of d.Amount, returning ErrUnknownDeposit if it doesn't. The
+// deposit outputs are cached for Config.DepositCacheTTL, so validating the
+// deposits of many blocks doesn't query the engine for each of them.
+func VerifyDeposit(d Deposit) error {
+	if d.Amount == nil {
+		return fmt.Errorf("%w: no amount", ErrUnknownDeposit)
+	}
+	if err := rlockEngine(); err != nil {
+		return err
+	}
+	defer engineMu.RUnlock()
+	return depositCache.verify(d, engineConfig.depositCacheTTL(), getDepositOutputs)
+}
+
+// verify looks d up in the cached deposit outputs, refreshing them with fetch
+// once they are older than ttl. The caller must hold the engine's lock.
+func (c *depositOutputsCache) verify(d Deposit, ttl time.Duration, fetch func() ([]RawDeposit, error)) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.deposits == nil || time.Now().After(c.expires) {
+		rawDeposits, err := fetch()
+		if err != nil {
+			return fmt.Errorf("failed to get deposits: %w", err)
+		}
+		c.deposits = newDepositsFromRaw(rawDeposits)
+		c.expires = time.Now().Add(ttl)
+	}
+	for _, deposit := range c.deposits {
+		if deposit.Address == d.Address && deposit.Amount.Cmp(d.Amount) == 0 {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %v to %s", ErrUnknownDeposit, d.Amount, d.Address)
+}
+
+// invalidate drops the cached deposit outputs, which is needed whenever the
+// engine's deposit outputs change.
+func (c *depositOutputsCache) invalidate() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.deposits = nil
+}
+
+// depositAddressChecksumLength is the number of hex digits of the checksum
+// ending deposit addresses.
+const depositAddressChecksumLength = 6
+
+// DepositAddress is a sidechain address together with the number of the
+// sidechain it's on, which together make up the address mainchain users
+// deposit to.
+type DepositAddress struct {
+	Address   common.Address
+	Sidechain uint8
+}
+
+// Encode returns the deposit address in the format parsed by
+// ParseDepositAddress, s<sidechain number>_<hex address>_<checksum>, where the
+// checksum is the first six hex digits of the sha256 of everything before it.
+// The address is written in lower case without 0x prefix. Unlike
+// FormatDepositAddress it works for any sidechain and doesn't need the engine.
+func (d DepositAddress) Encode() string {
+	prefix := fmt.Sprintf("s%d_%x_", d.Sidechain, d.Address)
+	hash := sha256.Sum256([]byte(prefix))
+	return prefix + hex.EncodeToString(hash[:])[:depositAddressChecksumLength]
+}
+
+// ParseDepositAddress decodes a deposit address as returned by Encode or
+// FormatDepositAddress. Malformed addresses, sidechain numbers outside of
+// 0-255 and checksum mismatches are rejected with ErrInvalidDepositAddress.
+func ParseDepositAddress(s string) (DepositAddress, error) {
+	parts := strings.Split(s, "_")
+	if len(parts) != 3 || !strings.HasPrefix(parts[0], "s") {
+		return DepositAddress{}, fmt.Errorf("%w: %q", ErrInvalidDepositAddress, s)
+	}
+	number, err := strconv.ParseUint(parts[0][1:], 10, 8)
+	if err != nil {
+		return DepositAddress{}, fmt.Errorf("%w: bad sidechain number in %q", ErrInvalidDepositAddress, s)
+	}
+	if !common.IsHexAddress(parts[1]) {
+		return DepositAddress{}, fmt.Errorf("%w: bad sidechain address in %q", ErrInvalidDepositAddress, s)
+	}
+	hash := sha256.Sum256([]byte(s[:len(s)-len(parts[2])]))
+	if want := hex.EncodeToString(hash[:])[:depositAddressChecksumLength]; !strings.EqualFold(parts[2], want) {
+		return DepositAddress{}, fmt.Errorf("%w: checksum mismatch in %q", ErrInvalidDepositAddress, s)
+	}
+	return DepositAddress{Address: common.HexToAddress(parts[1]), Sidechain: uint8(number)}, nil
+}
+
+// PendingDeposit is a deposit without enough mainchain confirmations to be
+// credited yet, as returned by GetPendingDeposits.
+type PendingDeposit struct {
+	Deposit
+	Confirmations uint64 // Confirmations of the deposit transaction
+	Required      uint64 // Confirmations needed, Config.MinDepositConfirmations
+}
+
+// splitDepositsByDepth separates the deposits with at least minConfirmations
+// confirmations at a mainchain tip of height tipHeight from the others.
+func splitDepositsByDepth(rawDeposits []RawDeposit, tipHeight, minConfirmations uint64) ([]RawDeposit, []PendingDeposit) {
+	var (
+		confirmed = make([]RawDeposit, 0, len(rawDeposits))
+		pending   []PendingDeposit
+	)
+	for _, rawDeposit := range rawDeposits {
+		var confirmations uint64
+		if rawDeposit.height != 0 && rawDeposit.height <= tipHeight {
+			confirmations = tipHeight - rawDeposit.height + 1
+		}
+		if confirmations >= minConfirmations {
+			confirmed = append(confirmed, rawDeposit)
+			continue
+		}
+		pending = append(pending, PendingDeposit{
+			Deposit:       newDepositsFromRaw([]RawDeposit{rawDeposit})[0],
+			Confirmations: confirmations,
+			Required:      minConfirmations,
+		})
+	}
+	return confirmed, pending
+}
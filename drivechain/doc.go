@@ -0,0 +1,22 @@
+/*
+Package drivechain implements the sidechain side of the drivechain protocol on
+top of the drivechain engine, a Rust library called through cgo.
+
+The engine is a single global instance that has to be started with Init or
+InitWithContext before any other function of the package is used. Processes
+running more than one sidechain can start further engines with NewClient, each
+for its own sidechain number. The methods of a Client mirror the package level
+functions for its engine.
+
+The engine's API is described by the Drivechain interface, which CGO
+implements. The package level functions call the Drivechain returned by
+Default, so tests can replace the engine with SetDefault, e.g. with the
+in-memory fake of the fakes package.
+
+All functions of the package are safe for concurrent use. Initializing the
+engine and calls changing its state are exclusive, while every other call
+into the engine holds a shared lock for as long as the C layer is executing.
+Functions taking a context may return before the engine call finished, in
+which case the lock is held until the call actually returns.
+*/
+package drivechain
@@ -5,16 +5,18 @@ package drivechain
 */
 import "C"
 import (
-	"bytes"
 	"context"
 	"crypto/ecdsa"
 	"encoding/binary"
+	"encoding/hex"
 	"errors"
 	"fmt"
-	"io"
+	"math"
 	"math/big"
 	"net/http"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 	"unsafe"
 
@@ -22,9 +24,33 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
 )
 
-const THIS_SIDECHAIN = 7
+// THIS_SIDECHAIN is the mainchain slot used by Init unless overridden.
+//
+// Deprecated: the slot is configurable through Config.SidechainNumber, use
+// GetSidechainID to get the slot the engine is running with.
+const THIS_SIDECHAIN = defaultSidechainNumber
+
+// defaultSidechainNumber is the mainchain slot used by Init and InitWithRetry.
+// InitWithContext takes the slot from Config.SidechainNumber instead.
+const defaultSidechainNumber = 7
+
+var (
+	// engineMu guards the engine state held by the C layer, as the engine
+	// isn't safe for concurrent mutation. Initializing and shutting down the
+	// engine and calls changing its state (connecting and disconnecting
+	// blocks, creating deposits, BMM requests and bundle broadcasts) take the
+	// write lock, every other call into the C layer holds the read lock for
+	// its duration.
+	engineMu     sync.RWMutex
+	initialized  bool   // Whether the engine is running, guarded by engineMu
+	engineConfig Config // Config the engine is running with, guarded by engineMu
+
+	// sidechainNumber is the mainchain slot the engine was initialized with.
+	sidechainNumber uint8
+)
 
 // A publicly known "private key" to the treasury account, that holds 21M BTC.
 // There are special consensus rules for this account.
@@ -37,8 +63,9 @@ const THIS_SIDECHAIN = 7
 // Transfering funds to this account without the special withdrawal data will
 // burn the coins. They will never show up on mainchain and there will be no way
 // to refund them.
+//
+// Use TreasuryKey and TreasuryAddress rather than parsing it.
 const TREASURY_PRIVATE_KEY = "deadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeef"
-const TREASURY_ACCOUNT = "0xc96aaa54e2d44c299564da76e1cd3184a2386b8d"
 
 // There are 10,000,000,000 Wei in one Satoshi
 var Satoshi = big.NewInt(10_000_000_000)
@@ -50,94 +77,597 @@ var Satoshi = big.NewInt(10_000_000_000)
 //
 // So there should be 21 * 10 ^ 6 * 10 ^ 18 = 21 * 10^24 "Wei" in the treasury account.
 
-func Init(dbPath, host string, port uint16, rpcUser, rpcPassword string) error {
-	privKey, err := crypto.HexToECDSA(TREASURY_PRIVATE_KEY)
-	if err != nil {
-		panic(fmt.Sprintf("can't get treasury private key: %s", err))
+// Init starts the drivechain engine, giving the mainchain RPC probe
+// DefaultRPCTimeout to succeed. The settings not covered by the arguments can
+// be changed with opts.
+func Init(dbPath, host string, port uint16, rpcUser, rpcPassword string, opts ...Option) error {
+	cfg := Config{
+		DBPath:      dbPath,
+		Host:        host,
+		Port:        port,
+		RPCUser:     rpcUser,
+		RPCPassword: rpcPassword,
+
+		SidechainNumber: defaultSidechainNumber,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return InitWithContext(context.Background(), cfg)
+}
+
+const (
+	initRetryDelay    = 500 * time.Millisecond // Delay before the first retry of InitWithRetry
+	maxInitRetryDelay = 30 * time.Second       // Cap of the exponentially growing retry delay
+)
+
+// InitWithRetry is like Init, but if the mainchain node can't be reached it
+// keeps retrying with an exponential backoff, for mainchain nodes that are
+// still starting up. It gives up after maxAttempts attempts, or never if
+// maxAttempts isn't positive, and as soon as ctx is done.
+func InitWithRetry(ctx context.Context, dbPath, host string, port uint16, rpcUser, rpcPassword string, maxAttempts int) error {
+	cfg := Config{
+		DBPath:      dbPath,
+		Host:        host,
+		Port:        port,
+		RPCUser:     rpcUser,
+		RPCPassword: rpcPassword,
+
+		SidechainNumber: defaultSidechainNumber,
 	}
-	address := crypto.PubkeyToAddress(*privKey.Public().(*ecdsa.PublicKey))
-	actualTreasuryAccount := strings.ToLower(address.Hex())
-	if TREASURY_ACCOUNT != actualTreasuryAccount {
-		panic(fmt.Sprintf("treasury account: %s != actual treasury account: %s", TREASURY_ACCOUNT, actualTreasuryAccount))
+	delay := initRetryDelay
+	for attempt := 1; ; attempt++ {
+		probeCtx, cancel := context.WithTimeout(ctx, cfg.rpcTimeout())
+		err := probeMainchain(probeCtx, &cfg)
+		cancel()
+		if err == nil {
+			break
+		}
+		if maxAttempts > 0 && attempt >= maxAttempts {
+			return fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+		log.Info("Mainchain not reachable, retrying", "attempt", attempt, "delay", delay, "err", err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+		if delay *= 2; delay > maxInitRetryDelay {
+			delay = maxInitRetryDelay
+		}
+	}
+	return InitWithContext(ctx, cfg)
+}
+
+// InitWithContext verifies the mainchain RPC credentials and starts the
+// drivechain engine. The whole startup is bounded by ctx. If ctx is done while
+// the engine is still initializing inside the C layer an error is returned,
+// but the initialization itself runs to completion in the background.
+func InitWithContext(ctx context.Context, cfg Config) error {
+	if cfg.SidechainNumber < 0 || cfg.SidechainNumber > math.MaxUint8 {
+		return fmt.Errorf("invalid sidechain number %d, must be in range 0-%d", cfg.SidechainNumber, math.MaxUint8)
+	}
+	if cfg.ZMQEndpoint != "" && !strings.HasPrefix(cfg.ZMQEndpoint, "tcp://") {
+		return fmt.Errorf("unsupported zmq endpoint %q, must start with tcp://", cfg.ZMQEndpoint)
+	}
+	if err := cfg.setupTransport(); err != nil {
+		return err
+	}
+	limiter := newRPCLimiter(&cfg)
+	if limiter != nil {
+		cfg.HTTPClient = rateLimitedClient(cfg.httpClient(), limiter)
 	}
 
 	// Verify we're able to use the RPC credentials
+	probeCtx, cancel := context.WithTimeout(ctx, cfg.rpcTimeout())
+	defer cancel()
+	if err := probeMainchain(probeCtx, &cfg); err != nil {
+		return err
+	}
+
+	var initErr error
+	if err := runWithContext(ctx, func() {
+		engineMu.Lock()
+		defer engineMu.Unlock()
+		if initialized {
+			initErr = ErrAlreadyInitialized
+			return
+		}
+		if clientsInUse(uint8(cfg.SidechainNumber)) {
+			initErr = fmt.Errorf("%w: %d", ErrSidechainInUse, cfg.SidechainNumber)
+			return
+		}
+		if initErr = setEngineTransport(&cfg); initErr != nil {
+			return
+		}
+		if initErr = setDustThresholds(&cfg); initErr != nil {
+			return
+		}
+		if !initBmmEngine(cfg.DBPath, uint8(cfg.SidechainNumber), cfg.Host, cfg.RPCUser, cfg.RPCPassword, cfg.Port) {
+			initErr = errors.New("failed to initialize drivechain engine")
+			return
+		}
+		initialized = true
+		engineConfig = cfg
+		bmmCache = newBmmCache(&cfg)
+		rpcLimiter = limiter
+		if cfg.ZMQEndpoint != "" {
+			zmqSub = startZMQ(cfg.ZMQEndpoint)
+		}
+		sidechainNumber = uint8(cfg.SidechainNumber)
+		startWithdrawalPoller()
+	}); err != nil {
+		return err
+	}
+	if initErr != nil {
+		return initErr
+	}
+	registerDefaultMetrics()
+	return nil
+}
+
+// Shutdown flushes and closes the engine. Afterwards the package functions
+// return ErrNotInitialized until the engine is initialized again.
+func Shutdown() error {
+	engineMu.Lock()
+	defer engineMu.Unlock()
+	if !initialized {
+		return ErrNotInitialized
+	}
+	depositSubs.stop()
+	tipSubs.stop()
+	notifiers.stop()
+	if zmqSub != nil {
+		zmqSub.stop()
+		zmqSub = nil
+	}
+	depositCache.invalidate()
+	C.flush()
+	if !bool(C.deinit()) {
+		return errors.New("failed to shut down drivechain engine")
+	}
+	initialized = false
+	bmmCache = nil
+	rpcLimiter = nil
+	resetDustThresholds()
+	return nil
+}
+
+// Ping checks that the mainchain node is reachable with the credentials the
+// engine is using, by doing the same getblockchaininfo call as Init. If the
+// engine authenticates with a cookie file that was rotated since, the engine is
+// switched over to the new credentials.
+func (CGO) Ping(ctx context.Context) error {
+	if err := rlockEngine(); err != nil {
+		return err
+	}
+	cfg := engineConfig
+	engineMu.RUnlock()
+	if err := probeMainchain(ctx, &cfg); err != nil {
+		return err
+	}
+	if cfg.CookieFile == "" {
+		return nil
+	}
+
+	engineMu.Lock()
+	defer engineMu.Unlock()
+	if !initialized {
+		return ErrNotInitialized
+	}
+	if cfg.RPCUser == engineConfig.RPCUser && cfg.RPCPassword == engineConfig.RPCPassword {
+		return nil
+	}
+	log.Info("Mainchain RPC cookie changed, reconnecting")
+	return reconnectEngine(cfg)
+}
+
+// HealthCheck is a cheap liveness check. It verifies that the engine is
+// initialized and responsive and that the mainchain node answers a
+// getblockcount call. Failures are reported as a *HealthError.
+func HealthCheck() error {
+	if err := rlockEngine(); err != nil {
+		return &HealthError{Component: "engine", Cause: err}
+	}
+	var pingErr error
+	waitRateLimit()
+	if !bool(C.ping()) {
+		pingErr = fmt.Errorf("%w: %s", ErrEngineFailure, getLastError())
+	}
+	cfg := engineConfig
+	engineMu.RUnlock()
+	if pingErr != nil {
+		return &HealthError{Component: "engine", Cause: pingErr}
+	}
+	return checkMainchainHealth(&cfg)
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+// checkMainchainHealth is the mainchain part of HealthCheck, a getblockcount
+// call to the node of cfg.
+func checkMainchainHealth(cfg *Config) error {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.rpcTimeout())
 	defer cancel()
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
-		fmt.Sprintf("http://%s:%d", host, port),
-		bytes.NewBuffer([]byte(
-			`{"jsonrpc": "2.0", "method": "getblockchaininfo", "params": [], "id": 1}`,
-		)),
-	)
-	if err != nil {
+	var height uint64
+	if err := newMainchainClient(cfg).call(ctx, &height, "getblockcount"); err != nil {
+		return &HealthError{Component: "mainchain", Cause: err}
+	}
+	return nil
+}
+
+// Reconnect points the running engine at a mainchain node with new
+// credentials, e.g. after the node restarted or rotated them. The new
+// credentials are verified before the engine is switched over. If the engine
+// was started with a cookie file, passing an empty user and password keeps
+// using it. A URL the engine was started with is replaced by host and port,
+// over plain http.
+func Reconnect(host string, port uint16, rpcUser, rpcPassword string) error {
+	if err := rlockEngine(); err != nil {
+		return err
+	}
+	cfg := engineConfig
+	engineMu.RUnlock()
+
+	if err := probeReconnect(&cfg, host, port, rpcUser, rpcPassword); err != nil {
 		return err
 	}
 
-	req.SetBasicAuth(rpcUser, rpcPassword)
-	req.Header.Set("Content-Type", "application/json")
+	engineMu.Lock()
+	defer engineMu.Unlock()
+	if !initialized {
+		return ErrNotInitialized
+	}
+	return reconnectEngine(cfg)
+}
 
-	res, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("unable to establish RPC connection with mainchain: %w", err)
+// probeReconnect changes cfg over to the mainchain node and credentials passed
+// to Reconnect and verifies them.
+func probeReconnect(cfg *Config, host string, port uint16, rpcUser, rpcPassword string) error {
+	cfg.Host, cfg.Port, cfg.URL = host, port, ""
+	if rpcUser != "" || rpcPassword != "" || cfg.CookieFile == "" {
+		cfg.RPCUser, cfg.RPCPassword, cfg.CookieFile = rpcUser, rpcPassword, ""
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.rpcTimeout())
+	defer cancel()
+	return probeMainchain(ctx, cfg)
+}
+
+// reconnectEngine switches the engine over to the mainchain node and
+// credentials in cfg. The caller must hold the exclusive engine lock.
+func reconnectEngine(cfg Config) error {
+	var args cArgs
+	defer args.free()
+	cHost := args.cString(cfg.Host)
+	cRpcUser := args.cString(cfg.RPCUser)
+	cRpcPassword := args.cString(cfg.RPCPassword)
+	if !bool(C.reconnect(cHost, C.ushort(cfg.Port), cRpcUser, cRpcPassword)) {
+		return errors.New("failed to reconnect drivechain engine to mainchain")
 	}
+	engineConfig = cfg
+	return nil
+}
+
+// rlockEngine takes the read lock on the engine if it's initialized. The
+// caller must release it with engineMu.RUnlock unless an error is returned.
+func rlockEngine() error {
+	engineMu.RLock()
+	if !initialized {
+		engineMu.RUnlock()
+		return ErrNotInitialized
+	}
+	return nil
+}
+
+// lockEngine is like rlockEngine, but takes the write lock for calls that
+// change the engine's state. The caller must release it with engineMu.Unlock
+// unless an error is returned.
+func lockEngine() error {
+	engineMu.Lock()
+	if !initialized {
+		engineMu.Unlock()
+		return ErrNotInitialized
+	}
+	return nil
+}
+
+// IsInitialized reports whether the engine is running. Until it is, every call
+// into the engine fails with ErrNotInitialized.
+func IsInitialized() bool {
+	engineMu.RLock()
+	defer engineMu.RUnlock()
+	return initialized
+}
+
+// GetSidechainID returns the mainchain slot the engine is running with. Until
+// the engine is initialized it returns the slot Init would use.
+func GetSidechainID() uint8 {
+	engineMu.RLock()
+	defer engineMu.RUnlock()
+	if !initialized {
+		return defaultSidechainNumber
+	}
+	return sidechainNumber
+}
+
+// GetSidechainNumber returns the mainchain slot the engine was initialized
+// with.
+//
+// Deprecated: use GetSidechainID.
+func GetSidechainNumber() uint8 {
+	return GetSidechainID()
+}
 
-	if res.StatusCode != http.StatusOK {
-		body, err := io.ReadAll(res.Body)
+// probeMainchain calls getblockchaininfo on the mainchain node to check that
+// it's reachable and accepts the RPC credentials. If cfg uses a cookie file,
+// the credentials read from it are stored in cfg.RPCUser and cfg.RPCPassword.
+func probeMainchain(ctx context.Context, cfg *Config) error {
+	if cfg.CookieFile != "" {
+		user, password, err := readCookieFile(cfg.CookieFile)
 		if err != nil {
-			body = []byte("<empty body>")
+			return err
+		}
+		cfg.RPCUser, cfg.RPCPassword = user, password
+	}
+	_, err := newMainchainClient(cfg).getBlockchainInfo(ctx)
+
+	// The cookie may have been rotated by a restart of the mainchain node
+	// while the request was in flight, retry once with the new one.
+	var httpErr *mainchainHTTPError
+	if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusUnauthorized && cfg.CookieFile != "" {
+		user, password, err2 := readCookieFile(cfg.CookieFile)
+		if err2 != nil {
+			return err2
+		}
+		if user != cfg.RPCUser || password != cfg.RPCPassword {
+			cfg.RPCUser, cfg.RPCPassword = user, password
+			_, err = newMainchainClient(cfg).getBlockchainInfo(ctx)
+		}
+	}
+	if errors.As(err, &httpErr) {
+		return fmt.Errorf("unable to establish RPC connection with mainchain: %s: %s", httpErr.Status, httpErr.Body)
+	}
+	return err
+}
+
+// callEngine calls fn through runWithContext while holding the read lock on the
+// engine. It fails with ErrNotInitialized if the engine isn't running.
+func callEngine(ctx context.Context, fn func()) error {
+	var err error
+	if ctxErr := runWithContext(ctx, func() {
+		if err = rlockEngine(); err != nil {
+			return
+		}
+		defer engineMu.RUnlock()
+		if err = checkAborted(ctx); err != nil {
+			return
 		}
+		fn()
+	}); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
 
-		return fmt.Errorf(
-			"unable to establish RPC connection with mainchain: %s: %s",
-			res.Status, string(body),
-		)
+// callEngineExclusive is like callEngine, but holds the write lock.
+func callEngineExclusive(ctx context.Context, fn func()) error {
+	var err error
+	if ctxErr := runWithContext(ctx, func() {
+		if err = lockEngine(); err != nil {
+			return
+		}
+		defer engineMu.Unlock()
+		if err = checkAborted(ctx); err != nil {
+			return
+		}
+		fn()
+	}); ctxErr != nil {
+		return ctxErr
 	}
+	return err
+}
 
-	initBmmEngine(dbPath, host, rpcUser, rpcPassword, port)
+// runWithContext runs fn, which is expected to block inside the C layer, on its
+// own goroutine and waits until it either returns or ctx is done. A C call
+// can't be interrupted, so if ctx expires first fn keeps running in the
+// background and whatever it produces must be discarded by the caller.
+func runWithContext(ctx context.Context, fn func()) error {
+	if err := checkAborted(ctx); err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("drivechain call aborted: %w", ctx.Err())
+	}
+}
 
+// checkAborted returns the error of an aborted call if ctx is done. Checked
+// again once the engine lock is held, it keeps a call whose caller gave up
+// while waiting for the lock from running after all.
+func checkAborted(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("drivechain call aborted: %w", err)
+	}
 	return nil
 }
 
-func GetMainchainTip() common.Hash {
-	var cMainchainTip = C.get_mainchain_tip()
-	var mainchainTip = C.GoString(cMainchainTip)
-	C.free_string(cMainchainTip)
-	return common.HexToHash(mainchainTip)
+// GetMainchainTip returns the hash of the mainchain tip. It fails with
+// ErrMainchainUnreachable if the engine doesn't know the tip, after retrying
+// as the retry policy allows.
+func (CGO) GetMainchainTip() (common.Hash, error) {
+	var tip common.Hash
+	err := WithRetry(context.Background(), func() (err error) {
+		tip, err = getMainchainTip()
+		return err
+	})
+	return tip, err
+}
+
+func getMainchainTip() (common.Hash, error) {
+	if err := rlockEngine(); err != nil {
+		return common.Hash{}, err
+	}
+	defer engineMu.RUnlock()
+	waitRateLimit()
+	return newMainchainTip(C.get_mainchain_tip())
+}
+
+// newMainchainTip converts and frees a tip hash returned by the engine, which
+// is empty if the engine doesn't know the tip.
+func newMainchainTip(cMainchainTip *C.char) (common.Hash, error) {
+	tip := common.HexToHash(goString(cMainchainTip))
+	if tip == (common.Hash{}) {
+		return common.Hash{}, fmt.Errorf("%w: %s", ErrMainchainUnreachable, getLastError())
+	}
+	return tip, nil
+}
+
+// MainchainTipInfo describes the mainchain block the engine is following.
+type MainchainTipInfo struct {
+	Hash     common.Hash
+	Height   uint64
+	Time     time.Time // Block header timestamp
+	PrevHash common.Hash
+}
+
+// TipInfo is the former name of MainchainTipInfo.
+//
+// Deprecated: use MainchainTipInfo.
+type TipInfo = MainchainTipInfo
+
+// GetMainchainTipInfo returns the header fields of the mainchain tip. They
+// are read from the mainchain node with getbestblockhash and getblockheader,
+// without going through the engine. Failures are retried as the retry policy
+// allows.
+func GetMainchainTipInfo() (MainchainTipInfo, error) {
+	if err := rlockEngine(); err != nil {
+		return MainchainTipInfo{}, err
+	}
+	cfg := engineConfig
+	engineMu.RUnlock()
+	return fetchMainchainTipInfo(&cfg)
+}
+
+// fetchMainchainTipInfo reads the header fields of the mainchain tip from the
+// node of cfg, retrying as its retry policy allows.
+func fetchMainchainTipInfo(cfg *Config) (MainchainTipInfo, error) {
+	var tip MainchainTipInfo
+	err := withRetry(context.Background(), cfg.retryPolicy(), func() (err error) {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.rpcTimeout())
+		defer cancel()
+		tip, err = newMainchainClient(cfg).getTipInfo(ctx)
+		return err
+	})
+	return tip, err
+}
+
+// mainchainTipInfo asks the engine for the mainchain tip it's following, which
+// may lag behind the tip of the node. The caller must hold engineMu.
+func mainchainTipInfo() (MainchainTipInfo, error) {
+	waitRateLimit()
+	return newMainchainTipInfo(C.get_mainchain_tip_info())
+}
+
+// newMainchainTipInfo converts and frees tip info returned by the engine.
+func newMainchainTipInfo(cTip C.MainchainTip) (MainchainTipInfo, error) {
+	hash, prevHash := goString(cTip.hash), goString(cTip.prev_hash)
+	if !bool(cTip.valid) {
+		return MainchainTipInfo{}, fmt.Errorf("%w: %s", ErrMainchainUnreachable, getLastError())
+	}
+	return MainchainTipInfo{
+		Hash:     common.HexToHash(hash),
+		Height:   uint64(cTip.height),
+		Time:     time.Unix(int64(cTip.time), 0),
+		PrevHash: common.HexToHash(prevHash),
+	}, nil
+}
+
+// GetMainchainTipHeight returns the height of the mainchain tip.
+func GetMainchainTipHeight() (uint64, error) {
+	tip, err := GetMainchainTipInfo()
+	if err != nil {
+		return 0, err
+	}
+	return tip.Height, nil
 }
 
 type RawDeposit struct {
 	address string
 	amount  uint64
+	txid    string
+	vout    uint32
+
+	// Index is the position of the deposit in the engine's deposit history.
+	// A deposit reported twice, e.g. during a mainchain reorg, keeps its index.
+	Index uint64
+
+	height uint64 // Height of the mainchain block the deposit is in, 0 if unknown
 }
 
 func getDepositOutputs() ([]RawDeposit, error) {
-	ptrDeposits := C.get_deposit_outputs()
+	waitRateLimit()
+	return newRawDeposits(C.get_deposit_outputs())
+}
+
+func getDepositOutputsInRange(fromHeight, toHeight uint64) ([]RawDeposit, error) {
+	waitRateLimit()
+	return newRawDeposits(C.get_deposit_outputs_in_range(C.uint64_t(fromHeight), C.uint64_t(toHeight)))
+}
+
+func getDepositOutputsSince(blockHash string) ([]RawDeposit, error) {
+	var args cArgs
+	defer args.free()
+	cBlockHash := args.cString(blockHash)
+	waitRateLimit()
+	return newRawDeposits(C.get_deposit_outputs_since(cBlockHash))
+}
+
+// newRawDeposits copies deposits returned by the engine into Go memory and
+// frees them.
+func newRawDeposits(ptrDeposits C.Deposits) ([]RawDeposit, error) {
+	defer C.free_deposits(ptrDeposits)
 	if !ptrDeposits.valid {
-		C.free_deposits(ptrDeposits)
-		return make([]RawDeposit, 0), fmt.Errorf("can't get deposit outputs")
+		return make([]RawDeposit, 0), fmt.Errorf("%w: %s", ErrMainchainUnreachable, getLastError())
 	}
 	cDeposits := unsafe.Slice(ptrDeposits.ptr, ptrDeposits.len)
 	deposits := make([]RawDeposit, 0, ptrDeposits.len)
 	for _, cDeposit := range cDeposits {
 		deposit := RawDeposit{
-			address: C.GoString(cDeposit.address),
+			address: goAddress(cDeposit.address).Hex(),
 			amount:  uint64(cDeposit.amount),
+			vout:    uint32(cDeposit.vout),
+			Index:   uint64(cDeposit.index),
+			height:  uint64(cDeposit.height),
+		}
+		if cDeposit.txid != nil {
+			deposit.txid = C.GoString(cDeposit.txid)
 		}
 		deposits = append(deposits, deposit)
 	}
-	C.free_deposits(ptrDeposits)
 	return deposits, nil
 }
 
 type Deposit struct {
 	Address common.Address
 	Amount  *big.Int
+
+	// Outpoint of the deposit output on mainchain. It's only known for
+	// deposits returned by the engine, deposits read back from sidechain
+	// blocks leave it empty.
+	MainTxid common.Hash
+	Vout     uint32
 }
 
 type Withdrawal struct {
-	Address [MainchainAddressLength]C.uchar
+	Address MainchainAddress
 	Amount  *big.Int
 	Fee     *big.Int
 }
@@ -147,202 +677,1677 @@ type Refund struct {
 	Amount *big.Int
 }
 
-func GetDepositOutputs() ([]Deposit, error) {
-	rawDeposits, err := getDepositOutputs()
+// GetDepositOutputs returns the deposits made to the sidechain on mainchain
+// with at least Config.MinDepositConfirmations confirmations, see
+// GetPendingDeposits for the others. Deposits the engine reports more than
+// once are only returned once. Failures to reach the mainchain node are
+// retried as the retry policy allows.
+func (CGO) GetDepositOutputs() ([]Deposit, error) {
+	rawDeposits, err := GetDepositOutputsRaw()
 	if err != nil {
-		return make([]Deposit, 0), fmt.Errorf("failed to get deposits")
+		return make([]Deposit, 0), err
 	}
-	deposits := make([]Deposit, 0, len(rawDeposits))
-	for _, rawDeposit := range rawDeposits {
-		deposits = append(deposits, Deposit{
-			Address: common.HexToAddress(rawDeposit.address),
-			Amount:  big.NewInt(int64(rawDeposit.amount)),
-		})
+	confirmed, _, err := splitDepositOutputs(dedupeRawDeposits(rawDeposits))
+	if err != nil {
+		return make([]Deposit, 0), err
 	}
-	return deposits, nil
+	return newDepositsFromRaw(confirmed), nil
+}
+
+// GetPendingDeposits returns the deposits GetDepositOutputs leaves out because
+// they don't have Config.MinDepositConfirmations confirmations yet. The
+// confirmations are counted from the current mainchain tip on every call.
+func GetPendingDeposits() ([]PendingDeposit, error) {
+	rawDeposits, err := GetDepositOutputsRaw()
+	if err != nil {
+		return nil, err
+	}
+	_, pending, err := splitDepositOutputs(dedupeRawDeposits(rawDeposits))
+	return pending, err
+}
+
+// splitDepositOutputs separates the deposits deep enough to be credited from
+// the pending ones.
+func splitDepositOutputs(rawDeposits []RawDeposit) ([]RawDeposit, []PendingDeposit, error) {
+	if err := rlockEngine(); err != nil {
+		return nil, nil, err
+	}
+	minConfirmations := engineConfig.MinDepositConfirmations
+	engineMu.RUnlock()
+	return splitDepositsAtTip(rawDeposits, minConfirmations, GetMainchainTipInfo)
+}
+
+// splitDepositsAtTip is splitDepositsByDepth at the mainchain tip returned by
+// tipInfo, which is only called if minConfirmations is set.
+func splitDepositsAtTip(rawDeposits []RawDeposit, minConfirmations uint64, tipInfo func() (MainchainTipInfo, error)) ([]RawDeposit, []PendingDeposit, error) {
+	if minConfirmations == 0 {
+		return rawDeposits, nil, nil
+	}
+	tip, err := tipInfo()
+	if err != nil {
+		return nil, nil, err
+	}
+	confirmed, pending := splitDepositsByDepth(rawDeposits, tip.Height, minConfirmations)
+	return confirmed, pending, nil
+}
+
+// GetDepositOutputsRaw is like GetDepositOutputs, but returns the deposits
+// exactly as reported by the engine, duplicates included. It's meant for
+// debugging.
+func GetDepositOutputsRaw() ([]RawDeposit, error) {
+	var rawDeposits []RawDeposit
+	if err := WithRetry(context.Background(), func() error {
+		if err := rlockEngine(); err != nil {
+			return err
+		}
+		defer engineMu.RUnlock()
+		defer depositOutputsTimer.UpdateSince(time.Now())
+		var err error
+		rawDeposits, err = getDepositOutputs()
+		return err
+	}); err != nil {
+		return make([]RawDeposit, 0), fmt.Errorf("failed to get deposits: %w", err)
+	}
+	return rawDeposits, nil
 }
 
-// common.Hash here is for transaction hashes.
-func ConnectBlock(deposits []Deposit, withdrawals map[common.Hash]Withdrawal, refunds []Refund, just_checking bool) bool {
-	depositsMemory := C.malloc(C.size_t(len(deposits)) * C.size_t(unsafe.Sizeof(C.Deposit{})))
-	depositsSlice := (*[1<<30 - 1]C.Deposit)(depositsMemory)
-	for i, deposit := range deposits {
-		cDeposit := C.Deposit{
-			address: C.CString(strings.ToLower(deposit.Address.String())),
-			amount:  newUlong(deposit.Amount.Uint64()),
+// GetDepositOutputsSince returns only the deposits made after the mainchain
+// block blockHash, so that a node catching up doesn't have to go through the
+// whole deposit history again. Like GetDepositOutputs it leaves out deposits
+// with less than Config.MinDepositConfirmations confirmations.
+func GetDepositOutputsSince(blockHash common.Hash) ([]Deposit, error) {
+	var rawDeposits []RawDeposit
+	if err := WithRetry(context.Background(), func() error {
+		if err := rlockEngine(); err != nil {
+			return err
 		}
-		depositsSlice[i] = cDeposit
+		defer engineMu.RUnlock()
+		defer depositOutputsTimer.UpdateSince(time.Now())
+		var err error
+		rawDeposits, err = getDepositOutputsSince(blockHash.Hex()[2:])
+		return err
+	}); err != nil {
+		return make([]Deposit, 0), fmt.Errorf("failed to get deposits since %s: %w", blockHash.Hex(), err)
 	}
-	cDeposits := C.Deposits{
-		ptr: &depositsSlice[0],
-		len: C.ulong(len(deposits)),
+	confirmed, _, err := splitDepositOutputs(dedupeRawDeposits(rawDeposits))
+	if err != nil {
+		return make([]Deposit, 0), err
+	}
+	return newDepositsFromRaw(confirmed), nil
+}
+
+// ReplayDeposits returns the deposits of the mainchain blocks from height
+// fromMainchainHeight to toMainchainHeight, both included, in a single call
+// to the engine, for catching up after the node was offline. Unlike
+// GetDepositOutputs it doesn't leave out deposits without enough
+// confirmations, the caller picks the range. Deposits are only returned once.
+func ReplayDeposits(fromMainchainHeight, toMainchainHeight uint64) ([]Deposit, error) {
+	if fromMainchainHeight > toMainchainHeight {
+		return nil, fmt.Errorf("%w: %d-%d", ErrInvalidHeightRange, fromMainchainHeight, toMainchainHeight)
 	}
-	withdrawalsMemory := C.malloc(C.size_t(len(withdrawals)) * C.size_t(unsafe.Sizeof(C.Withdrawal{})))
-	withdrawalsSlice := (*[1<<30 - 1]C.Withdrawal)(withdrawalsMemory)
-	{
-		i := 0
-		for id, w := range withdrawals {
-			log.Info(fmt.Sprintf("wtid = %s", id.Hex()))
-			cWithdrawal := C.Withdrawal{
-				id:      C.CString(id.Hex()),
-				address: w.Address,
-				amount:  newUlong(w.Amount.Uint64()),
-				fee:     newUlong(w.Fee.Uint64()),
-			}
-			withdrawalsSlice[i] = cWithdrawal
-			i += 1
+	var rawDeposits []RawDeposit
+	if err := WithRetry(context.Background(), func() error {
+		if err := rlockEngine(); err != nil {
+			return err
 		}
+		defer engineMu.RUnlock()
+		defer depositOutputsTimer.UpdateSince(time.Now())
+		var err error
+		rawDeposits, err = getDepositOutputsInRange(fromMainchainHeight, toMainchainHeight)
+		return err
+	}); err != nil {
+		return nil, fmt.Errorf("failed to replay deposits of mainchain blocks %d-%d: %w", fromMainchainHeight, toMainchainHeight, err)
 	}
-	cWithdrawals := C.Withdrawals{
-		ptr: &withdrawalsSlice[0],
-		len: C.ulong(len(withdrawals)),
+	return newDepositsFromRaw(dedupeRawDeposits(rawDeposits)), nil
+}
+
+// dedupeRawDeposits drops the deposits reported more than once, keeping the
+// first of each address, amount and index.
+func dedupeRawDeposits(rawDeposits []RawDeposit) []RawDeposit {
+	type key struct {
+		address common.Address
+		amount  uint64
+		index   uint64
 	}
-	refundsMemory := C.malloc(C.size_t(len(refunds)) * C.size_t(unsafe.Sizeof(C.Refund{})))
-	refundsSlice := (*[1<<30 - 1]C.Refund)(refundsMemory)
-	for i, r := range refunds {
-		cRefund := C.Refund{
-			id:     C.CString(r.Id.Hex()),
-			amount: newUlong(r.Amount.Uint64()),
+	seen := make(map[key]struct{}, len(rawDeposits))
+	deduped := make([]RawDeposit, 0, len(rawDeposits))
+	for _, rawDeposit := range rawDeposits {
+		k := key{common.HexToAddress(rawDeposit.address), rawDeposit.amount, rawDeposit.Index}
+		if _, ok := seen[k]; ok {
+			continue
 		}
-		refundsSlice[i] = cRefund
+		seen[k] = struct{}{}
+		deduped = append(deduped, rawDeposit)
 	}
-	cRefunds := C.Refunds{
-		ptr: &refundsSlice[0],
-		len: C.ulong(len(refunds)),
+	if dropped := len(rawDeposits) - len(deduped); dropped > 0 {
+		log.Warn("Engine reported duplicate deposits", "dropped", dropped)
 	}
-	return bool(C.connect_block(cDeposits, cWithdrawals, cRefunds, C.bool(just_checking)))
+	return deduped
 }
 
-func DisconnectBlock(deposits []Deposit, withdrawals []common.Hash, refunds []common.Hash, just_checking bool) bool {
+func newDepositsFromRaw(rawDeposits []RawDeposit) []Deposit {
+	deposits := make([]Deposit, 0, len(rawDeposits))
+	for _, rawDeposit := range rawDeposits {
+		deposits = append(deposits, Deposit{
+			Address: common.HexToAddress(rawDeposit.address),
+			Amount:  new(big.Int).SetUint64(rawDeposit.amount),
+
+			MainTxid: common.HexToHash(rawDeposit.txid),
+			Vout:     rawDeposit.vout,
+		})
+	}
+	return deposits
+}
 
-	cDeposits := newDeposits(deposits)
-	cWithdrawals := newWithdrawalsFromHash(withdrawals)
-	cRefunds := newRefundsFromHash(refunds)
-	return bool(C.disconnect_block(cDeposits, cWithdrawals, cRefunds, C.bool(just_checking)))
+// blockError converts an error code returned by connect_block or
+// disconnect_block into a *BlockError carrying the engine's last error message.
+func blockError(code C.BlockError) error {
+	if code == C.BlockError_None {
+		return nil
+	}
+	kind, ok := errorKind(code)
+	if !ok {
+		return &BlockError{Kind: EngineFailure, Msg: fmt.Sprintf("unknown error code %d", code)}
+	}
+	return &BlockError{Kind: kind, Msg: getLastError()}
 }
 
-func FormatDepositAddress(address string) string {
-	cAddress := C.CString(address)
-	cDepositAddress := C.format_deposit_address(cAddress)
-	depositAddress := C.GoString(cDepositAddress)
-	C.free(unsafe.Pointer(cAddress))
-	C.free_string(cDepositAddress)
-	return depositAddress
+// errorKind returns the ErrorKind of an error code of the engine, reporting
+// false for codes it doesn't know.
+func errorKind(code C.BlockError) (ErrorKind, bool) {
+	switch code {
+	case C.BlockError_DepositMismatch:
+		return DepositMismatch, true
+	case C.BlockError_InvalidWithdrawal:
+		return InvalidWithdrawal, true
+	case C.BlockError_WithdrawalSpent:
+		return WithdrawalSpent, true
+	case C.BlockError_RefundNotFound:
+		return RefundNotFound, true
+	case C.BlockError_EngineFailure:
+		return EngineFailure, true
+	}
+	return EngineFailure, false
 }
 
-func CreateDeposit(address common.Address, amount uint64, fee uint64) bool {
-	return createDeposit(address, amount, fee)
+func getLastError() string {
+	return goString(C.get_last_error())
 }
 
-const (
-	FeeLength              = 8
-	MainchainAddressLength = 20
-)
+// ConnectBlock applies the deposits, withdrawals and refunds of a block to
+// the engine. With just_checking set the block is only validated, the error
+// returned is the same either way. Unless just_checking is set or
+// sideBlockHash is zero, the engine also records the deposits, withdrawals
+// and refunds under the hash of the sidechain block, see
+// GetBlockSidechainActivity. Errors are of type *BlockError: an
+// EngineFailure means the block couldn't be checked, any other kind means the
+// block is invalid.
+//
+// common.Hash here is for transaction hashes. The engine gets the
+// withdrawals in ascending order of their hashes, so that every node
+// validates a block the same way whatever the map's iteration order.
+// Deposits and refunds are passed in the order given, which is their order
+// in the block.
+//
+// Before calling the engine the refunds are checked to be of unspent
+// withdrawals, a refund of any other id fails with a RefundNotFound
+// *BlockError listing the unknown ids. If the unspent withdrawals can't be
+// looked up the check is left to the engine, which rejects unknown refunds
+// without listing them.
+func (CGO) ConnectBlock(sideBlockHash common.Hash, deposits []Deposit, withdrawals map[common.Hash]Withdrawal, refunds []Refund, just_checking bool) error {
+	return connectBlock(sideBlockHash, deposits, withdrawals, refunds, just_checking, true)
+}
 
-func GetWithdrawalData(fee uint64) []byte {
-	feeBytes := make([]byte, FeeLength)
-	binary.BigEndian.PutUint64(feeBytes, fee)
-	addressBytes := make([]byte, MainchainAddressLength)
-	cAddress := C.get_new_mainchain_address()
-	for i, uchar := range cAddress.address {
-		addressBytes[i] = byte(uchar)
-	}
-	return append(feeBytes, addressBytes...)
+// ConnectBlockUnchecked is like ConnectBlock without checking the refunds
+// against the unspent withdrawals first, for replaying blocks that are known
+// to be valid. The engine still rejects refunds it can't find. No activity
+// is recorded for the block.
+func ConnectBlockUnchecked(deposits []Deposit, withdrawals map[common.Hash]Withdrawal, refunds []Refund, just_checking bool) error {
+	return connectBlock(common.Hash{}, deposits, withdrawals, refunds, just_checking, false)
 }
 
-func DecodeWithdrawal(value *big.Int, data []byte) (Withdrawal, error) {
-	if len(data) != FeeLength+MainchainAddressLength {
-		return Withdrawal{}, errors.New("wrong withdrawal data length")
+// connectBlock connects a block, recording its activity unless sideBlockHash
+// is zero. With checkRefunds set the refunds are checked to be of unspent
+// withdrawals first.
+func connectBlock(sideBlockHash common.Hash, deposits []Deposit, withdrawals map[common.Hash]Withdrawal, refunds []Refund, just_checking, checkRefunds bool) error {
+	if err := lockEngine(); err != nil {
+		return err
 	}
-	feeBytes := data[:FeeLength]
-	if len(feeBytes) != FeeLength {
-		panic("off by one error")
+	defer engineMu.Unlock()
+	defer connectBlockTimer.UpdateSince(time.Now())
+	if checkRefunds && len(refunds) > 0 {
+		waitRateLimit()
+		if err := checkUnspentRefunds(refunds, C.get_unspent_withdrawals()); err != nil {
+			connectBlockFailureCounter.Inc(1)
+			return err
+		}
 	}
-	addressBytes := data[FeeLength : FeeLength+MainchainAddressLength]
-	if len(addressBytes) != MainchainAddressLength {
-		panic("off by one error")
+	if !just_checking {
+		defer depositCache.invalidate()
 	}
-	var address [MainchainAddressLength]C.uchar
-	for i, byte := range addressBytes {
-		address[i] = C.uchar(byte)
+	args := marshalArgs()
+	defer args.free()
+	cBlock := newBlockData(&args, &BlockData{Deposits: deposits, Withdrawals: withdrawals, Refunds: refunds})
+	var code C.BlockError
+	if sideBlockHash == (common.Hash{}) {
+		code = C.connect_block(cBlock.deposits, cBlock.withdrawals, cBlock.refunds, C.bool(just_checking))
+	} else {
+		cSideBlockHash := args.cString(sideBlockHash.Hex())
+		code = C.connect_side_block(cSideBlockHash, cBlock, C.bool(just_checking))
 	}
-	// Convert Wei to Satoshi.
-	var amount big.Int
-	amount.Div(value, Satoshi)
-	fee := big.NewInt(int64(binary.BigEndian.Uint64(feeBytes)))
-	return Withdrawal{
-		Address: address,
-		Amount:  &amount,
-		Fee:     fee,
-	}, nil
+	if err := blockError(code); err != nil {
+		connectBlockFailureCounter.Inc(1)
+		return err
+	}
+	if !just_checking {
+		depositsCounter.Inc(int64(len(deposits)))
+		withdrawalsCounter.Inc(int64(len(withdrawals)))
+	}
+	return nil
 }
 
-func AttemptBundleBroadcast() bool {
-	return bool(C.attempt_bundle_broadcast())
+// BlockData is the drivechain related content of a block, as passed to
+// ConnectBlock.
+type BlockData struct {
+	Deposits    []Deposit
+	Withdrawals map[common.Hash]Withdrawal // Keyed by transaction hash, passed to the engine sorted by it
+	Refunds     []Refund
 }
 
-func GetUnspentWithdrawals() map[common.Hash]Withdrawal {
-	ptrWithdrawals := C.get_unspent_withdrawals()
-	cWithdrawals := unsafe.Slice(ptrWithdrawals.ptr, ptrWithdrawals.len)
-	withdrawals := make(map[common.Hash]Withdrawal)
-	for _, cWithdrawal := range cWithdrawals {
-		var amount big.Int
-		var fee big.Int
-		amount.Mul(big.NewInt(int64(cWithdrawal.amount)), Satoshi)
-		fee.Mul(big.NewInt(int64(cWithdrawal.fee)), Satoshi)
-		withdrawal := Withdrawal{
-			Address: cWithdrawal.address,
-			Amount:  &amount,
-			Fee:     &fee,
-		}
-		strId := C.GoString(cWithdrawal.id)
-		id := common.HexToHash(strId)
-		withdrawals[id] = withdrawal
-	}
-	C.free_withdrawals(ptrWithdrawals)
-	return withdrawals
+// BlockConnectData is the content of a block passed to ConnectBlocks.
+type BlockConnectData = BlockData
+
+// BlockDisconnectData is the content of a block passed to DisconnectBlocks,
+// with the arguments of DisconnectBlock.
+type BlockDisconnectData struct {
+	Deposits    []Deposit
+	Withdrawals []common.Hash // Transaction hashes of the withdrawals
+	Refunds     []common.Hash // Ids of the refunded withdrawals
 }
 
-func FormatMainchainAddress(dest [MainchainAddressLength]C.uchar) string {
-	withdrawalAddress := C.WithdrawalAddress{address: dest}
-	cAddress := C.format_mainchain_address(withdrawalAddress)
-	address := C.GoString(cAddress)
-	C.free_string(cAddress)
-	return address
+// BatchBlockError is returned by ConnectBlocks and DisconnectBlocks for the
+// block of a batch that failed. It unwraps to the *BlockError of the block.
+type BatchBlockError struct {
+	Index int   // Index of the failing block in the batch
+	Err   error // Error of the block, as returned by ConnectBlock
 }
 
-func AttemptBmm(header *types.Header, amount uint64) {
-	attemptBmm(header.Hash().Hex()[2:], header.PrevMainBlockHash.Hex()[2:], amount)
+// Error implements error.
+func (e *BatchBlockError) Error() string {
+	return fmt.Sprintf("block %d of batch: %v", e.Index, e.Err)
 }
 
-type BmmState uint
+// Unwrap returns the error of the failing block.
+func (e *BatchBlockError) Unwrap() error { return e.Err }
 
-const (
-	Succeded BmmState = iota
-	Failed
-	Pending
-)
+// ConnectBlocks is like ConnectBlock for a sequence of blocks, but marshals
+// all of them into a single call to the engine, for initial sync. Blocks are
+// connected in order until one fails. Each block is applied all or nothing,
+// the blocks before the failing one stay connected. It returns the number of
+// blocks connected, and for a failing block a *BatchBlockError carrying its
+// index.
+func ConnectBlocks(blocks []BlockConnectData, justChecking bool) (int, error) {
+	connected, err := connectBlocks(blocks, justChecking)
+	if _, ok := err.(*BlockError); ok {
+		err = &BatchBlockError{Index: connected, Err: err}
+	}
+	return connected, err
+}
 
-func ConfirmBmm() BmmState {
-	return BmmState(C.confirm_bmm())
+// BatchConnectBlocks is like ConnectBlocks, but returns the *BlockError of
+// the failing block unwrapped.
+//
+// Deprecated: use ConnectBlocks.
+func BatchConnectBlocks(blocks []BlockData, just_checking bool) (int, error) {
+	return connectBlocks(blocks, just_checking)
 }
 
-func verifyBmm(prevMainBlockHash string, criticalHash string) bool {
-	cPrevMainBlockHash := C.CString(prevMainBlockHash)
-	cCriticalHash := C.CString(criticalHash)
-	result := bool(C.verify_bmm(cPrevMainBlockHash, cCriticalHash))
-	C.free(unsafe.Pointer(cPrevMainBlockHash))
-	C.free(unsafe.Pointer(cCriticalHash))
-	return result
+func connectBlocks(blocks []BlockData, justChecking bool) (int, error) {
+	if len(blocks) == 0 {
+		return 0, nil
+	}
+	if err := lockEngine(); err != nil {
+		return 0, err
+	}
+	defer engineMu.Unlock()
+	defer connectBlockTimer.UpdateSince(time.Now())
+	if !justChecking {
+		defer depositCache.invalidate()
+	}
+	args := marshalArgs()
+	defer args.free()
+	cBlocks := newBlockDataBatch(&args, blocks)
+	var failed C.uintptr_t
+	err := blockError(C.connect_blocks(&cBlocks[0], C.uintptr_t(len(blocks)), C.bool(justChecking), &failed))
+	connected := len(blocks)
+	if err != nil {
+		connectBlockFailureCounter.Inc(1)
+		connected = int(failed)
+	}
+	if !justChecking {
+		for _, block := range blocks[:connected] {
+			depositsCounter.Inc(int64(len(block.Deposits)))
+			withdrawalsCounter.Inc(int64(len(block.Withdrawals)))
+		}
+	}
+	return connected, err
+}
+
+// DisconnectBlocks is like DisconnectBlock for a sequence of blocks, tip
+// first, in a single call to the engine, for deep reorgs. Blocks are
+// disconnected in order until one fails, with the same semantics as
+// ConnectBlocks.
+func DisconnectBlocks(blocks []BlockDisconnectData, justChecking bool) (int, error) {
+	if len(blocks) == 0 {
+		return 0, nil
+	}
+	if err := lockEngine(); err != nil {
+		return 0, err
+	}
+	defer engineMu.Unlock()
+	if !justChecking {
+		defer depositCache.invalidate()
+	}
+	args := marshalArgs()
+	defer args.free()
+	cBlocks := newBlockDisconnectDataBatch(&args, blocks)
+	var failed C.uintptr_t
+	if err := blockError(C.disconnect_blocks(&cBlocks[0], C.uintptr_t(len(blocks)), C.bool(justChecking), &failed)); err != nil {
+		return int(failed), &BatchBlockError{Index: int(failed), Err: err}
+	}
+	return len(blocks), nil
+}
+
+// DisconnectBlock reverts the deposits, withdrawals and refunds of a block.
+// Errors are reported the same way as by ConnectBlock. Unless just_checking is
+// set or sideBlockHash is zero, the engine also drops the activity recorded
+// for the sidechain block.
+func (CGO) DisconnectBlock(sideBlockHash common.Hash, deposits []Deposit, withdrawals []common.Hash, refunds []common.Hash, just_checking bool) error {
+	return disconnectBlock(sideBlockHash, deposits, withdrawals, refunds, just_checking)
 }
 
-func VerifyBmm(prevMainBlockHash common.Hash, criticalHash common.Hash) bool {
-	return verifyBmm(prevMainBlockHash.Hex()[2:], criticalHash.Hex()[2:])
+func disconnectBlock(sideBlockHash common.Hash, deposits []Deposit, withdrawals []common.Hash, refunds []common.Hash, just_checking bool) error {
+	if err := lockEngine(); err != nil {
+		return err
+	}
+	defer engineMu.Unlock()
+	if !just_checking {
+		defer depositCache.invalidate()
+	}
+	args := marshalArgs()
+	defer args.free()
+	cDeposits := newDeposits(&args, deposits)
+	cWithdrawals := newWithdrawalsFromHash(&args, withdrawals)
+	cRefunds := newRefundsFromHash(&args, refunds)
+	if sideBlockHash == (common.Hash{}) {
+		return blockError(C.disconnect_block(cDeposits, cWithdrawals, cRefunds, C.bool(just_checking)))
+	}
+	cSideBlockHash := args.cString(sideBlockHash.Hex())
+	return blockError(C.disconnect_side_block(cSideBlockHash, cDeposits, cWithdrawals, cRefunds, C.bool(just_checking)))
 }
 
-func IsWithdrawalSpent(id common.Hash) bool {
-	cId := C.CString(id.Hex())
-	result := bool(C.is_outpoint_spent(cId))
-	C.free(unsafe.Pointer(cId))
-	return result
+// FormatDepositAddress formats the sidechain address into the address
+// mainchain users deposit to, see ParseDepositAddress.
+func FormatDepositAddress(address common.Address) (string, error) {
+	if err := rlockEngine(); err != nil {
+		return "", err
+	}
+	defer engineMu.RUnlock()
+	var args cArgs
+	defer args.free()
+	return goString(C.format_deposit_address(args.cString(address.Hex()))), nil
+}
+
+// ValidateDeposit checks that a deposit of amount satoshis paying fee
+// satoshis to mainchain miners is sane: both must be non-zero, the amount
+// can't be below the dust threshold, see Config.MinDepositSats, and the fee
+// can't exceed the amount.
+func ValidateDeposit(amount uint64, fee uint64) error {
+	return validateDeposit(amount, fee, atomic.LoadUint64(&minDepositSats))
+}
+
+// validateDeposit is ValidateDeposit with the dust threshold minAmount.
+func validateDeposit(amount, fee, minAmount uint64) error {
+	if amount == 0 {
+		return ErrZeroDepositAmount
+	}
+	if fee == 0 {
+		return ErrZeroDepositFee
+	}
+	if fee > amount {
+		return fmt.Errorf("%w: fee %d, amount %d", ErrDepositFeeExceedsAmount, fee, amount)
+	}
+	return checkDust("deposit", amount, minAmount)
+}
+
+// CreateDeposit makes a mainchain deposit of amount satoshis to address,
+// paying fee satoshis to mainchain miners, and returns the mainchain txid of
+// the deposit. Insane amounts are rejected before reaching the engine, see
+// ValidateDeposit. If the mainchain wallet refuses, the error is
+// ErrDepositInsufficientFunds, ErrDepositFeeTooLow, ErrMainchainWalletLocked
+// or ErrMainchainUnreachable.
+func (CGO) CreateDeposit(address common.Address, amount uint64, fee uint64) (common.Hash, error) {
+	if err := ValidateDeposit(amount, fee); err != nil {
+		return common.Hash{}, err
+	}
+	if err := lockEngine(); err != nil {
+		return common.Hash{}, err
+	}
+	defer engineMu.Unlock()
+	waitRateLimit()
+	return depositResult(createDeposit(address, amount, fee))
+}
+
+// depositResult converts the result of create_deposit into the txid of the
+// deposit or an error, freeing the txid.
+func depositResult(result C.DepositResult) (common.Hash, error) {
+	txid := goString(result.txid)
+	var err error
+	switch result.error {
+	case C.DepositError_None:
+		if txid == "" {
+			return common.Hash{}, fmt.Errorf("failed to create deposit: %w: no txid", ErrEngineFailure)
+		}
+		return common.HexToHash(txid), nil
+	case C.DepositError_InsufficientFunds:
+		err = ErrDepositInsufficientFunds
+	case C.DepositError_FeeTooLow:
+		err = ErrDepositFeeTooLow
+	case C.DepositError_WalletLocked:
+		err = ErrMainchainWalletLocked
+	case C.DepositError_RpcFailure:
+		err = ErrMainchainUnreachable
+	default:
+		err = ErrEngineFailure
+	}
+	if msg := getLastError(); msg != "" {
+		return common.Hash{}, fmt.Errorf("failed to create deposit: %w: %s", err, msg)
+	}
+	return common.Hash{}, fmt.Errorf("failed to create deposit: %w", err)
+}
+
+const (
+	FeeLength                 = 8
+	MainchainAddressLength    = 20 // Length of hash160 addresses, the only kind in legacy withdrawal data
+	MaxMainchainAddressLength = 32 // Length of the longest addresses, e.g. P2WSH
+
+	// MaxWithdrawalDataLength is the length of the longest withdrawal data:
+	// the version, the fee, an address type tag and the longest address.
+	MaxWithdrawalDataLength = 1 + FeeLength + 1 + MaxMainchainAddressLength
+
+	// WithdrawalDataVersion is the version of the withdrawal data written by
+	// GetWithdrawalData.
+	WithdrawalDataVersion = 1
+
+	// legacyWithdrawalDataLength is the length of the unversioned legacy
+	// withdrawal data, the fee followed by a hash160. It's read as version 0.
+	legacyWithdrawalDataLength = FeeLength + MainchainAddressLength
+)
+
+// GetWithdrawalData returns the data of a transaction to the treasury
+// withdrawing to a new mainchain address of the engine's wallet, paying fee
+// satoshis to mainchain miners. The data is in the format of
+// CurrentWithdrawalRules, see encodeWithdrawalData.
+func (CGO) GetWithdrawalData(fee uint64) ([]byte, error) {
+	if err := rlockEngine(); err != nil {
+		return nil, err
+	}
+	defer engineMu.RUnlock()
+	waitRateLimit()
+	address := newMainchainAddress(C.get_new_mainchain_address())
+	return encodeWithdrawalData(fee, address, CurrentWithdrawalRules())
+}
+
+// encodeWithdrawalData encodes withdrawal data in the newest format valid
+// under rules: the versioned format once rules.Versioned is set, the
+// unversioned format otherwise. Before rules.Tagged only P2PKH addresses can
+// be encoded, others fail with ErrWithdrawalAddressInactive.
+func encodeWithdrawalData(fee uint64, address MainchainAddress, rules WithdrawalRules) ([]byte, error) {
+	switch {
+	case rules.Versioned:
+		return encodeVersionedWithdrawalData(fee, address), nil
+	case !rules.Tagged && address.Type != P2PKH:
+		return nil, fmt.Errorf("%w: %v", ErrWithdrawalAddressInactive, address.Type)
+	}
+	return encodeUnversionedWithdrawalData(fee, address), nil
+}
+
+// encodeVersionedWithdrawalData encodes withdrawal data in the versioned
+// format of WithdrawalDataVersion: the version byte, the fee in satoshis as an
+// 8 byte big endian integer, the address type byte and the 20 or 32 byte
+// address. The versioned format is only valid from
+// params.ChainConfig.VersionedWithdrawalBlock on.
+func encodeVersionedWithdrawalData(fee uint64, address MainchainAddress) []byte {
+	data := make([]byte, 1+FeeLength, MaxWithdrawalDataLength)
+	data[0] = WithdrawalDataVersion
+	binary.BigEndian.PutUint64(data[1:], fee)
+	return address.encode(data, true)
+}
+
+// encodeUnversionedWithdrawalData encodes withdrawal data in the format used
+// before params.ChainConfig.VersionedWithdrawalBlock: the fee in satoshis as
+// an 8 byte big endian integer, followed by the address. P2PKH addresses are
+// encoded in the legacy format as the bare 20 byte hash160, other addresses in
+// the tagged format as their type byte followed by the 20 or 32 byte payload.
+// The tagged format is only valid from params.ChainConfig.TaggedWithdrawalBlock
+// on.
+func encodeUnversionedWithdrawalData(fee uint64, address MainchainAddress) []byte {
+	data := make([]byte, FeeLength, MaxWithdrawalDataLength)
+	binary.BigEndian.PutUint64(data, fee)
+	return address.encode(data, false)
+}
+
+// WithdrawalGas is the gas limit of withdrawal transactions made by
+// CreateWithdrawal, enough for the longest withdrawal data even if it has no
+// zero bytes.
+const WithdrawalGas = params.TxGas + MaxWithdrawalDataLength*params.TxDataNonZeroGasEIP2028
+
+// CreateWithdrawal creates and signs a transaction withdrawing amount satoshis
+// to a new mainchain address of the engine's wallet, paying fee satoshis to
+// mainchain miners. Amounts below the dust threshold, see
+// Config.MinWithdrawalSats, are rejected.
+func CreateWithdrawal(ctx context.Context, signer types.Signer, key *ecdsa.PrivateKey, amount *big.Int, fee uint64, nonce uint64) (*types.Transaction, error) {
+	if err := validateWithdrawal(amount, fee); err != nil {
+		return nil, err
+	}
+	return createWithdrawal(ctx, signer, key, amount, fee, nonce, nil)
+}
+
+// validateWithdrawal checks the amount in satoshis and the fee of a withdrawal
+// about to be made.
+func validateWithdrawal(amount *big.Int, fee uint64) error {
+	return checkWithdrawal(amount, fee, atomic.LoadUint64(&minWithdrawalSats))
+}
+
+// checkWithdrawal is validateWithdrawal with the dust threshold minAmount.
+func checkWithdrawal(amount *big.Int, fee, minAmount uint64) error {
+	if amount == nil || amount.Sign() <= 0 {
+		return ErrZeroWithdrawalAmount
+	}
+	if amount.IsUint64() {
+		if err := checkDust("withdrawal", amount.Uint64(), minAmount); err != nil {
+			return err
+		}
+	}
+	if fee == 0 {
+		return ErrZeroWithdrawalFee
+	}
+	return nil
+}
+
+// createWithdrawal is CreateWithdrawal without the validation, paying gasPrice
+// for the transaction.
+func createWithdrawal(ctx context.Context, signer types.Signer, key *ecdsa.PrivateKey, amount *big.Int, fee uint64, nonce uint64, gasPrice *big.Int) (*types.Transaction, error) {
+	var address MainchainAddress
+	if err := callEngine(ctx, func() {
+		waitRateLimit()
+		address = newMainchainAddress(C.get_new_mainchain_address())
+	}); err != nil {
+		return nil, err
+	}
+	return signWithdrawal(signer, key, amount, fee, nonce, gasPrice, address, CurrentWithdrawalRules())
+}
+
+// signWithdrawal signs a withdrawal transaction to address, with the
+// withdrawal data in the format of rules.
+func signWithdrawal(signer types.Signer, key *ecdsa.PrivateKey, amount *big.Int, fee uint64, nonce uint64, gasPrice *big.Int, address MainchainAddress, rules WithdrawalRules) (*types.Transaction, error) {
+	data, err := encodeWithdrawalData(fee, address, rules)
+	if err != nil {
+		return nil, err
+	}
+	value := new(big.Int).Mul(amount, Satoshi)
+	tx := types.NewTransaction(nonce, TreasuryAddress(), value, WithdrawalGas, gasPrice, data)
+	signed, err := types.SignTx(tx, signer, key)
+	if err != nil {
+		return nil, err
+	}
+	withdrawalsCreatedCounter.Inc(1)
+	return signed, nil
+}
+
+// EthClient is the part of the sidechain node's RPC API used by Withdraw. It's
+// implemented by ethclient.Client.
+type EthClient interface {
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+	SuggestGasPrice(ctx context.Context) (*big.Int, error)
+	SendTransaction(ctx context.Context, tx *types.Transaction) error
+}
+
+// withdrawMu serializes Withdraw, so that concurrent withdrawals from the
+// same key don't pick the same nonce.
+var withdrawMu sync.Mutex
+
+// Withdraw withdraws weiAmount from the account of key to a new mainchain
+// address of the engine's wallet, paying fee satoshis to mainchain miners. It
+// creates, signs and submits the withdrawal transaction through client and
+// returns its hash. The amount must be a whole number of satoshis, it's
+// checked like every other argument before anything is sent over the network.
+func Withdraw(ctx context.Context, client EthClient, signer types.Signer, key *ecdsa.PrivateKey, weiAmount *big.Int, fee uint64) (common.Hash, error) {
+	amount, err := withdrawalSatoshis(weiAmount)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if err := validateWithdrawal(amount, fee); err != nil {
+		return common.Hash{}, err
+	}
+	return sendWithdrawal(ctx, client, key, func(nonce uint64, gasPrice *big.Int) (*types.Transaction, error) {
+		return createWithdrawal(ctx, signer, key, amount, fee, nonce, gasPrice)
+	})
+}
+
+// withdrawalSatoshis converts the amount passed to Withdraw to satoshis.
+func withdrawalSatoshis(weiAmount *big.Int) (*big.Int, error) {
+	if weiAmount == nil || weiAmount.Sign() <= 0 {
+		return nil, ErrZeroWithdrawalAmount
+	}
+	sats, err := WeiToSatoshi(weiAmount)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetUint64(sats), nil
+}
+
+// sendWithdrawal submits the withdrawal transaction from the account of key
+// made by create through client, picking its nonce and gas price.
+func sendWithdrawal(ctx context.Context, client EthClient, key *ecdsa.PrivateKey, create func(nonce uint64, gasPrice *big.Int) (*types.Transaction, error)) (common.Hash, error) {
+	withdrawMu.Lock()
+	defer withdrawMu.Unlock()
+	nonce, err := client.PendingNonceAt(ctx, crypto.PubkeyToAddress(key.PublicKey))
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("failed to get nonce: %w", err)
+	}
+	gasPrice, err := client.SuggestGasPrice(ctx)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("failed to get gas price: %w", err)
+	}
+	tx, err := create(nonce, gasPrice)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if err := client.SendTransaction(ctx, tx); err != nil {
+		return common.Hash{}, fmt.Errorf("failed to send withdrawal: %w", err)
+	}
+	return tx.Hash(), nil
+}
+
+// ValidateWithdrawalData checks that data is well formed withdrawal data, as
+// produced by GetWithdrawalData, under CurrentWithdrawalRules.
+func ValidateWithdrawalData(data []byte) error {
+	return CurrentWithdrawalRules().ValidateWithdrawalData(data)
+}
+
+// ValidateWithdrawalData checks that data is well formed withdrawal data under
+// the rules. Data in the tagged format, see encodeUnversionedWithdrawalData,
+// is only accepted if r.Tagged is set, data in the versioned format, see
+// encodeVersionedWithdrawalData, only if r.Versioned is set. Legacy data is
+// always accepted, as version 0.
+func (r WithdrawalRules) ValidateWithdrawalData(data []byte) error {
+	_, _, err := splitWithdrawalData(data, r)
+	return err
+}
+
+// splitWithdrawalData validates withdrawal data and returns its address and
+// the 8 bytes of its fee.
+func splitWithdrawalData(data []byte, rules WithdrawalRules) (MainchainAddress, []byte, error) {
+	var (
+		address MainchainAddress
+		fee     []byte
+		err     error
+	)
+	switch {
+	case len(data) == legacyWithdrawalDataLength:
+		address, fee, err = splitTaggedWithdrawalData(data, false)
+	case rules.Versioned:
+		if len(data) == 0 {
+			return MainchainAddress{}, nil, fmt.Errorf("%w: empty", ErrWithdrawalDataLength)
+		}
+		switch data[0] {
+		case 0:
+			return MainchainAddress{}, nil, fmt.Errorf("%w: have %d bytes, want %d for version 0", ErrWithdrawalDataLength, len(data), legacyWithdrawalDataLength)
+		case 1:
+			address, fee, err = splitTaggedWithdrawalData(data[1:], true)
+		default:
+			return MainchainAddress{}, nil, fmt.Errorf("%w: %d", ErrUnknownWithdrawalVersion, data[0])
+		}
+	case rules.Tagged && len(data) > FeeLength:
+		address, fee, err = splitTaggedWithdrawalData(data, true)
+	default:
+		return MainchainAddress{}, nil, fmt.Errorf("%w: have %d bytes, want %d", ErrWithdrawalDataLength, len(data), legacyWithdrawalDataLength)
+	}
+	if err != nil {
+		return MainchainAddress{}, nil, err
+	}
+	if binary.BigEndian.Uint64(fee) == 0 {
+		return MainchainAddress{}, nil, ErrZeroWithdrawalFee
+	}
+	if address.IsZero() {
+		return MainchainAddress{}, nil, ErrZeroMainchainAddress
+	}
+	return address, fee, nil
+}
+
+// splitTaggedWithdrawalData splits the fee followed by a type tagged address,
+// or by a bare hash160 unless tagged is set.
+func splitTaggedWithdrawalData(data []byte, tagged bool) (MainchainAddress, []byte, error) {
+	if !tagged {
+		address, err := NewMainchainAddress(P2PKH, data[FeeLength:])
+		return address, data[:FeeLength], err
+	}
+	if len(data) <= FeeLength {
+		return MainchainAddress{}, nil, fmt.Errorf("%w: have %d bytes, want more than %d", ErrWithdrawalDataLength, len(data), FeeLength)
+	}
+	typ := MainchainAddressType(data[FeeLength])
+	if typ.Length() == 0 {
+		return MainchainAddress{}, nil, fmt.Errorf("%w: %v", ErrUnknownMainchainAddressType, typ)
+	}
+	if want := FeeLength + 1 + typ.Length(); len(data) != want {
+		return MainchainAddress{}, nil, fmt.Errorf("%w: have %d bytes, want %d for %v", ErrWithdrawalDataLength, len(data), want, typ)
+	}
+	address, err := NewMainchainAddress(typ, data[FeeLength+1:])
+	return address, data[:FeeLength], err
+}
+
+// DecodeWithdrawal decodes a withdrawal of value Wei sent to the treasury with
+// data as produced by GetWithdrawalData. Value is rounded down to whole
+// satoshis, unless rules.Strict is set. In strict mode the withdrawal must
+// also be economically sane: a whole, non-zero number of satoshis with a fee
+// that fits an int64 and doesn't exceed the amount. Amounts below
+// rules.MinWithdrawalSats fail with ErrBelowDustThreshold in either mode.
+// Data in the tagged and versioned formats is only accepted under the
+// matching rules, see ValidateWithdrawalData. Versioned data of an unknown
+// version fails with ErrUnknownWithdrawalVersion. All rules are consensus
+// relevant, see WithdrawalRulesAt.
+func DecodeWithdrawal(value *big.Int, data []byte, rules WithdrawalRules) (Withdrawal, error) {
+	return decodeWithdrawal(value, data, rules, binary.BigEndian)
+}
+
+// DecodeWithdrawalLE is like DecodeWithdrawal in lenient mode, but reads the
+// fee as a little endian integer, as written by some older sidechain nodes.
+// Those never wrote the tagged or versioned formats, so they aren't accepted.
+func DecodeWithdrawalLE(value *big.Int, data []byte) (Withdrawal, error) {
+	return decodeWithdrawal(value, data, WithdrawalRules{}, binary.LittleEndian)
+}
+
+// AutoDecodeWithdrawal decodes a withdrawal whose fee may be encoded in either
+// byte order. It decodes in strict mode, trying the canonical big endian
+// encoding first. If the fee doesn't pass the strict checks that way but does
+// as a little endian integer, the little endian decoding is returned. Data in
+// the tagged and versioned formats is only accepted under the matching rules,
+// rules.Strict is ignored.
+func AutoDecodeWithdrawal(value *big.Int, data []byte, rules WithdrawalRules) (Withdrawal, error) {
+	rules.Strict = true
+	withdrawal, err := decodeWithdrawal(value, data, rules, binary.BigEndian)
+	if errors.Is(err, ErrWithdrawalFeeOverflow) || errors.Is(err, ErrWithdrawalFeeExceedsAmount) {
+		if le, leErr := decodeWithdrawal(value, data, rules, binary.LittleEndian); leErr == nil {
+			return le, nil
+		}
+	}
+	return withdrawal, err
+}
+
+func decodeWithdrawal(value *big.Int, data []byte, rules WithdrawalRules, order binary.ByteOrder) (Withdrawal, error) {
+	address, feeBytes, err := splitWithdrawalData(data, rules)
+	if err != nil {
+		return Withdrawal{}, err
+	}
+	// Convert Wei to Satoshi.
+	var amount, rem big.Int
+	amount.DivMod(value, Satoshi, &rem)
+	if rules.Strict {
+		if rem.Sign() != 0 {
+			return Withdrawal{}, ErrWithdrawalAmountFraction
+		}
+		if amount.Sign() == 0 {
+			return Withdrawal{}, ErrZeroWithdrawalAmount
+		}
+	}
+	fee := new(big.Int).SetUint64(order.Uint64(feeBytes))
+	if rules.Strict {
+		if !fee.IsInt64() {
+			return Withdrawal{}, ErrWithdrawalFeeOverflow
+		}
+		if fee.Cmp(&amount) > 0 {
+			return Withdrawal{}, ErrWithdrawalFeeExceedsAmount
+		}
+	}
+	// Amounts beyond uint64 are above any threshold.
+	if rules.MinWithdrawalSats != 0 && amount.IsUint64() {
+		if err := checkDust("withdrawal", amount.Uint64(), rules.MinWithdrawalSats); err != nil {
+			return Withdrawal{}, err
+		}
+	}
+	return Withdrawal{
+		Address: address,
+		Amount:  &amount,
+		Fee:     fee,
+	}, nil
+}
+
+// AttemptBundleBroadcast bundles the unspent withdrawals and broadcasts the
+// bundle to mainchain, unless there are none or the previous bundle is still
+// pending. Failing to reach mainchain or the engine is reported as an error.
+func (CGO) AttemptBundleBroadcast(ctx context.Context) (BroadcastResult, error) {
+	var (
+		result BroadcastResult
+		err    error
+	)
+	if callErr := callEngineExclusive(ctx, func() {
+		result, err = attemptBundleBroadcast()
+	}); callErr != nil {
+		return BroadcastResult{}, callErr
+	}
+	if err != nil {
+		return BroadcastResult{}, err
+	}
+	if result.Outcome == Broadcast {
+		bundleBroadcastsCounter.Inc(1)
+	}
+	return result, nil
+}
+
+// attemptBundleBroadcast calls into the engine, the caller must hold the
+// engine lock.
+func attemptBundleBroadcast() (BroadcastResult, error) {
+	waitRateLimit()
+	return newBroadcastResult(C.attempt_bundle_broadcast())
+}
+
+// newBroadcastResult converts the result of attempt_bundle_broadcast, freeing
+// the bundle hash.
+func newBroadcastResult(cBroadcast C.BundleBroadcast) (BroadcastResult, error) {
+	bundleHash := goString(cBroadcast.bundle_hash)
+	switch cBroadcast.status {
+	case C.BroadcastStatus_NothingToDo:
+		return BroadcastResult{Outcome: NothingToDo}, nil
+	case C.BroadcastStatus_AlreadyPending:
+		return BroadcastResult{Outcome: AlreadyPending}, nil
+	case C.BroadcastStatus_Broadcast:
+		result := BroadcastResult{Outcome: Broadcast}
+		if bundleHash != "" {
+			result.BundleHash = common.HexToHash(bundleHash)
+		}
+		return result, nil
+	case C.BroadcastStatus_RpcFailure:
+		return BroadcastResult{}, fmt.Errorf("%w: %s", ErrMainchainUnreachable, getLastError())
+	default:
+		return BroadcastResult{}, fmt.Errorf("%w: %s", ErrEngineFailure, getLastError())
+	}
+}
+
+// GetPendingBundleHash returns the hash of the withdrawal bundle broadcast to
+// mainchain that isn't paid out or failed yet. It only reads the engine's
+// state, without contacting mainchain. The bool is false if there is no such
+// bundle, including while the engine isn't running.
+func GetPendingBundleHash() (common.Hash, bool) {
+	if err := rlockEngine(); err != nil {
+		return common.Hash{}, false
+	}
+	defer engineMu.RUnlock()
+	return pendingBundleHash(C.get_pending_bundle_hash())
+}
+
+// pendingBundleHash converts and frees the bundle hash returned by
+// get_pending_bundle_hash.
+func pendingBundleHash(cBundleHash *C.char) (common.Hash, bool) {
+	if cBundleHash == nil {
+		return common.Hash{}, false
+	}
+	return common.HexToHash(goString(cBundleHash)), true
+}
+
+// GetCurrentBundle returns the withdrawal bundle the engine is currently
+// trying to get paid out on mainchain, with the withdrawals it contains. It
+// returns ErrNoBundle if there is none. Use GetPendingBundle to also learn
+// how far along the payout is.
+func GetCurrentBundle() (WithdrawalBundle, error) {
+	bundle, err := GetPendingBundle()
+	if err != nil {
+		return WithdrawalBundle{}, err
+	}
+	return bundle.WithdrawalBundle, nil
+}
+
+// GetPendingBundle returns the withdrawal bundle the engine is currently
+// trying to get paid out on mainchain, with the withdrawals it contains, its
+// status and the mainchain transaction paying it out once it's broadcast. It
+// returns ErrNoBundle if there is none.
+func GetPendingBundle() (*Bundle, error) {
+	if err := rlockEngine(); err != nil {
+		return nil, err
+	}
+	defer engineMu.RUnlock()
+	return newBundle(C.get_current_bundle())
+}
+
+// newBundle converts and frees a bundle returned by the engine.
+func newBundle(cBundle C.WithdrawalBundle) (*Bundle, error) {
+	defer C.free_bundle(cBundle)
+	if !bool(cBundle.valid) {
+		return nil, fmt.Errorf("%w: %s", ErrEngineFailure, getLastError())
+	}
+	if cBundle.bundle_hash == nil {
+		return nil, ErrNoBundle
+	}
+	bundle := &Bundle{
+		WithdrawalBundle: WithdrawalBundle{
+			BundleHash:  common.HexToHash(C.GoString(cBundle.bundle_hash)),
+			Members:     make([]common.Hash, 0, cBundle.len),
+			TotalAmount: uint64(cBundle.total_amount),
+			TotalFee:    uint64(cBundle.total_fee),
+		},
+		Status: BundleStatus(cBundle.status),
+		Acks:   uint32(cBundle.acks),
+	}
+	for _, cId := range unsafe.Slice(cBundle.members, cBundle.len) {
+		bundle.Members = append(bundle.Members, common.HexToHash(C.GoString(cId)))
+	}
+	if cBundle.main_txid != nil {
+		txid := common.HexToHash(C.GoString(cBundle.main_txid))
+		bundle.MainchainTxid = &txid
+	}
+	return bundle, nil
+}
+
+func (CGO) GetUnspentWithdrawals(ctx context.Context) (map[common.Hash]WithdrawalInfo, error) {
+	var (
+		withdrawals map[common.Hash]WithdrawalInfo
+		err         error
+	)
+	if callErr := callEngine(ctx, func() {
+		withdrawals, err = getUnspentWithdrawals()
+	}); callErr != nil {
+		return nil, callErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	withdrawalsPendingGauge.Update(int64(len(withdrawals)))
+	return withdrawals, nil
+}
+
+func getUnspentWithdrawals() (map[common.Hash]WithdrawalInfo, error) {
+	return newWithdrawalInfos(C.get_unspent_withdrawals(), func(id common.Hash) C.WithdrawalStatus {
+		var args cArgs
+		defer args.free()
+		return C.get_withdrawal_status(args.cString(id.Hex()))
+	})
+}
+
+// newWithdrawalInfos converts and frees withdrawals returned by the engine,
+// looking up the status of each with status.
+func newWithdrawalInfos(ptrWithdrawals C.Withdrawals, status func(id common.Hash) C.WithdrawalStatus) (map[common.Hash]WithdrawalInfo, error) {
+	defer C.free_withdrawals(ptrWithdrawals)
+	if !bool(ptrWithdrawals.valid) {
+		return nil, fmt.Errorf("failed to get withdrawals: %w: %s", ErrEngineFailure, getLastError())
+	}
+	cWithdrawals := unsafe.Slice(ptrWithdrawals.ptr, ptrWithdrawals.len)
+	withdrawals := make(map[common.Hash]WithdrawalInfo)
+	for _, cWithdrawal := range cWithdrawals {
+		var amount big.Int
+		var fee big.Int
+		amount.Mul(new(big.Int).SetUint64(uint64(cWithdrawal.amount)), Satoshi)
+		fee.Mul(new(big.Int).SetUint64(uint64(cWithdrawal.fee)), Satoshi)
+		withdrawal := Withdrawal{
+			Address: newMainchainAddress(cWithdrawal.address),
+			Amount:  &amount,
+			Fee:     &fee,
+		}
+		id := goHash(cWithdrawal.id)
+		withdrawals[id] = WithdrawalInfo{
+			Withdrawal: withdrawal,
+			Status:     WithdrawalStatus(status(id)),
+		}
+	}
+	return withdrawals, nil
+}
+
+// unspentWithdrawalIds returns the ids of withdrawals returned by the engine,
+// freeing them.
+func unspentWithdrawalIds(ptrWithdrawals C.Withdrawals) (map[common.Hash]struct{}, error) {
+	defer C.free_withdrawals(ptrWithdrawals)
+	if !bool(ptrWithdrawals.valid) {
+		return nil, fmt.Errorf("failed to get withdrawals: %w: %s", ErrEngineFailure, getLastError())
+	}
+	ids := make(map[common.Hash]struct{}, ptrWithdrawals.len)
+	for _, cWithdrawal := range unsafe.Slice(ptrWithdrawals.ptr, ptrWithdrawals.len) {
+		ids[goHash(cWithdrawal.id)] = struct{}{}
+	}
+	return ids, nil
+}
+
+// checkUnspentRefunds checks refunds against the unspent withdrawals returned
+// by the engine, freeing them, see checkRefundIds. If the engine failed to
+// return them the check is skipped, leaving it to the engine's own when the
+// block is connected, so that a transient failure doesn't reject a valid
+// block.
+func checkUnspentRefunds(refunds []Refund, ptrWithdrawals C.Withdrawals) error {
+	unspent, err := unspentWithdrawalIds(ptrWithdrawals)
+	if err != nil {
+		log.Warn("Failed to get unspent withdrawals, leaving the refund check to the engine", "err", err)
+		return nil
+	}
+	return checkRefundIds(refunds, unspent)
+}
+
+// checkRefundIds returns a RefundNotFound *BlockError listing the refunds
+// whose id isn't in unspent.
+func checkRefundIds(refunds []Refund, unspent map[common.Hash]struct{}) error {
+	var unknown []string
+	for _, r := range refunds {
+		if _, ok := unspent[r.Id]; !ok {
+			unknown = append(unknown, r.Id.Hex())
+		}
+	}
+	if len(unknown) > 0 {
+		return &BlockError{Kind: RefundNotFound, Msg: "unknown withdrawal ids " + strings.Join(unknown, ", ")}
+	}
+	return nil
+}
+
+// GetRefundableWithdrawals returns the refunds that can be claimed for
+// withdrawals whose bundle failed enough voting rounds on mainchain. Unlike
+// GetUnspentWithdrawals it only includes withdrawals eligible for a refund.
+// The refund amounts are in satoshi.
+func GetRefundableWithdrawals() ([]Refund, error) {
+	if err := rlockEngine(); err != nil {
+		return nil, err
+	}
+	defer engineMu.RUnlock()
+	var cRefunds C.Refunds
+	if !bool(C.get_refundable_withdrawals(&cRefunds)) {
+		return nil, fmt.Errorf("%w: %s", ErrEngineFailure, getLastError())
+	}
+	return newRefundableWithdrawals(cRefunds), nil
+}
+
+// newRefundableWithdrawals converts and frees the refunds returned by
+// get_refundable_withdrawals.
+func newRefundableWithdrawals(cRefunds C.Refunds) []Refund {
+	defer C.free_refunds(cRefunds)
+	refunds := make([]Refund, 0, cRefunds.len)
+	for _, cRefund := range unsafe.Slice(cRefunds.ptr, cRefunds.len) {
+		refunds = append(refunds, Refund{
+			Id:     goHash(cRefund.id),
+			Amount: new(big.Int).SetUint64(uint64(cRefund.amount)),
+		})
+	}
+	return refunds
+}
+
+// newMainchainAddress converts an address returned by the engine.
+func newMainchainAddress(cAddress C.WithdrawalAddress) MainchainAddress {
+	address := MainchainAddress{Type: MainchainAddressType(cAddress.address_type)}
+	for i, uchar := range cAddress.address[:address.Type.Length()] {
+		address.payload[i] = byte(uchar)
+	}
+	return address
+}
+
+// cMainchainAddress converts an address to be passed to the engine.
+func cMainchainAddress(address MainchainAddress) C.WithdrawalAddress {
+	cAddress := C.WithdrawalAddress{address_type: C.AddressType(address.Type)}
+	for i, b := range address.payload {
+		cAddress.address[i] = C.uchar(b)
+	}
+	return cAddress
+}
+
+// FormatMainchainAddress returns the mainchain address dest in the engine's
+// text format: base58check for P2PKH addresses, bech32 or bech32m for segwit
+// ones. The engine's output is checked to encode dest, otherwise
+// ErrInvalidMainchainAddress is returned.
+func (CGO) FormatMainchainAddress(dest MainchainAddress) (string, error) {
+	if dest.Type.Length() == 0 {
+		return "", fmt.Errorf("%w: %v", ErrUnknownMainchainAddressType, dest.Type)
+	}
+	if err := rlockEngine(); err != nil {
+		return "", err
+	}
+	defer engineMu.RUnlock()
+	return formatMainchainAddress(dest)
+}
+
+func formatMainchainAddress(dest MainchainAddress) (string, error) {
+	cAddress := C.format_mainchain_address(cMainchainAddress(dest))
+	if cAddress == nil {
+		return "", fmt.Errorf("failed to format mainchain address: %w: %s", ErrEngineFailure, getLastError())
+	}
+	address := goString(cAddress)
+	if address == "" {
+		return "", fmt.Errorf("%w: engine returned an empty address", ErrInvalidMainchainAddress)
+	}
+	if err := checkFormattedAddress(dest, address); err != nil {
+		return "", err
+	}
+	return address, nil
+}
+
+// AttemptBmm sends a BMM request for header to mainchain, bribing mainchain
+// miners with amount satoshis, and returns the mainchain txid of the request.
+// It fails with ErrBmmNoFunds if the mainchain wallet has nothing to fund the
+// request with, ErrBmmInsufficientFunds if it can't pay the bribe,
+// ErrBmmAlreadyPending if an earlier request is still pending and
+// ErrMainchainUnreachable if the mainchain node can't be reached. The engine's
+// error message is included in the returned error.
+//
+// The header's PrevMainBlockHash must be set to the mainchain tip the block
+// builds on, otherwise AttemptBmm fails with ErrNoPrevMainBlockHash without
+// calling the engine.
+func (CGO) AttemptBmm(ctx context.Context, header *types.Header, amount uint64) (common.Hash, error) {
+	if header.PrevMainBlockHash == (common.Hash{}) {
+		return common.Hash{}, ErrNoPrevMainBlockHash
+	}
+	criticalHash := header.Hash().Hex()[2:]
+	prevMainBlockHash := header.PrevMainBlockHash.Hex()[2:]
+	bmmAttemptsCounter.Inc(1)
+	defer bmmAttemptTimer.UpdateSince(time.Now())
+	var (
+		txid       common.Hash
+		attemptErr error
+	)
+	if err := callEngineExclusive(ctx, func() {
+		waitRateLimit()
+		txid, attemptErr = newBmmAttempt(attemptBmm(criticalHash, prevMainBlockHash, amount))
+	}); err != nil {
+		return common.Hash{}, err
+	}
+	return txid, attemptErr
+}
+
+// newBmmAttempt converts the result of attempt_bmm into the txid of the BMM
+// request or an error, freeing the txid.
+func newBmmAttempt(attempt C.BmmAttempt) (common.Hash, error) {
+	txid := goString(attempt.txid)
+	if err := bmmError(attempt.error); err != nil {
+		return common.Hash{}, err
+	}
+	return common.HexToHash(txid), nil
+}
+
+// bmmError converts an error code returned by attempt_bmm into an error.
+func bmmError(code C.BmmError) error {
+	var err error
+	switch code {
+	case C.BmmError_None:
+		return nil
+	case C.BmmError_InsufficientFunds:
+		err = ErrBmmInsufficientFunds
+	case C.BmmError_NoFunds:
+		err = ErrBmmNoFunds
+	case C.BmmError_AlreadyPending:
+		err = ErrBmmAlreadyPending
+	case C.BmmError_RpcFailure:
+		err = ErrMainchainUnreachable
+	default:
+		err = ErrEngineFailure
+	}
+	if msg := getLastError(); msg != "" {
+		return fmt.Errorf("bmm attempt failed: %w: %s", err, msg)
+	}
+	return fmt.Errorf("bmm attempt failed: %w", err)
+}
+
+// BmmState is the outcome of a BMM attempt, as reported by ConfirmBmm.
+type BmmState uint
+
+const (
+	Succeeded BmmState = iota
+	Failed
+	Pending
+	Unknown   // The engine reported a state this package doesn't know
+	Cancelled // The attempt was abandoned with CancelBmm
+	Idle      // There is no BMM attempt, e.g. because the last one was decided
+)
+
+// Succeded is the former, misspelled name of Succeeded.
+//
+// Deprecated: use Succeeded.
+const Succeded = Succeeded
+
+var bmmStateNames = [...]string{
+	Succeeded: "succeeded",
+	Failed:    "failed",
+	Pending:   "pending",
+	Unknown:   "unknown",
+	Cancelled: "cancelled",
+	Idle:      "idle",
+}
+
+// String implements fmt.Stringer.
+func (s BmmState) String() string {
+	if s < BmmState(len(bmmStateNames)) {
+		return bmmStateNames[s]
+	}
+	return fmt.Sprintf("BmmState(%d)", uint(s))
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (s BmmState) MarshalText() ([]byte, error) {
+	if s >= BmmState(len(bmmStateNames)) {
+		return nil, fmt.Errorf("invalid bmm state %d", uint(s))
+	}
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. Names are matched case
+// insensitively.
+func (s *BmmState) UnmarshalText(text []byte) error {
+	for state, name := range bmmStateNames {
+		if strings.EqualFold(name, string(text)) {
+			*s = BmmState(state)
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid bmm state %q", text)
+}
+
+// ConfirmBmm checks whether the last BMM attempt made it into a mainchain
+// block. If it did the state is Succeeded and the returned hash is the hash of
+// that mainchain block, otherwise the hash is zero. Confirming settles the
+// attempt in the engine, so it holds the engine exclusively like AttemptBmm.
+func (CGO) ConfirmBmm(ctx context.Context) (BmmState, common.Hash, error) {
+	var (
+		state         BmmState
+		mainBlockHash common.Hash
+	)
+	if err := callEngineExclusive(ctx, func() {
+		waitRateLimit()
+		state, mainBlockHash = newBmmConfirmation(C.confirm_bmm())
+	}); err != nil {
+		return Pending, common.Hash{}, err
+	}
+	switch state {
+	case Succeeded:
+		bmmSucceededCounter.Inc(1)
+	case Failed:
+		bmmFailedCounter.Inc(1)
+	}
+	return state, mainBlockHash, nil
+}
+
+// newBmmConfirmation converts the result of confirm_bmm, freeing the mainchain
+// block hash. The hash is only kept if the state is Succeeded.
+func newBmmConfirmation(confirmation C.BmmConfirmation) (BmmState, common.Hash) {
+	mainBlockHash := common.HexToHash(goString(confirmation.main_block_hash))
+	state := bmmState(confirmation.state)
+	if state != Succeeded {
+		mainBlockHash = common.Hash{}
+	}
+	return state, mainBlockHash
+}
+
+// bmmState converts a state returned by confirm_bmm into a BmmState.
+func bmmState(state C.BmmState) BmmState {
+	switch state {
+	case C.BmmState_Succeeded:
+		return Succeeded
+	case C.BmmState_Failed:
+		return Failed
+	case C.BmmState_Pending:
+		return Pending
+	case C.BmmState_Cancelled:
+		return Cancelled
+	case C.BmmState_Idle:
+		return Idle
+	default:
+		return Unknown
+	}
+}
+
+// CancelBmm abandons the pending BMM attempt, e.g. because the block it was
+// for went stale. Where possible the engine replaces the bribe transaction
+// with one paying back to the mainchain wallet. Afterwards ConfirmBmm reports
+// Cancelled and AttemptBmm accepts a request for a new header. Cancelling
+// when there is no pending attempt does nothing.
+func (CGO) CancelBmm() error {
+	if err := lockEngine(); err != nil {
+		return err
+	}
+	defer engineMu.Unlock()
+	if !bool(C.cancel_bmm()) {
+		return fmt.Errorf("failed to cancel bmm: %w: %s", ErrEngineFailure, getLastError())
+	}
+	return nil
+}
+
+// GetBmmAmount returns the bribe in satoshis the engine recommends paying
+// with the next BMM attempt, based on recent mainchain fee rates, or the
+// amount set with SetBmmAmount. It can be used as the amount passed to
+// AttemptBmm, or as the start of a BmmFeePolicy.
+func GetBmmAmount() (uint64, error) {
+	if err := rlockEngine(); err != nil {
+		return 0, err
+	}
+	defer engineMu.RUnlock()
+	var amount C.uint64_t
+	if !bool(C.get_bmm_amount(&amount)) {
+		return 0, fmt.Errorf("failed to get bmm amount: %w: %s", ErrEngineFailure, getLastError())
+	}
+	return uint64(amount), nil
+}
+
+// SetBmmAmount overrides the bribe in satoshis returned by GetBmmAmount. An
+// amount of zero drops the override, going back to the engine's
+// recommendation.
+func SetBmmAmount(amount uint64) error {
+	if err := lockEngine(); err != nil {
+		return err
+	}
+	defer engineMu.Unlock()
+	if !bool(C.set_bmm_amount(C.uint64_t(amount))) {
+		return fmt.Errorf("failed to set bmm amount: %w: %s", ErrEngineFailure, getLastError())
+	}
+	return nil
+}
+
+// BmmRecord is a BMM attempt recorded by the engine, as returned by
+// GetBmmHistory.
+type BmmRecord struct {
+	SidechainBlockHash common.Hash `json:"sidechainBlockHash"` // Critical hash the attempt committed to
+	MainchainBlockHash common.Hash `json:"mainchainBlockHash"` // Mainchain block including the commitment, zero unless State is Succeeded
+	Amount             uint64      `json:"amount"`             // Bribe in satoshis
+	State              BmmState    `json:"state"`              // Last known state of the attempt
+	Timestamp          time.Time   `json:"timestamp"`          // When the attempt was made
+}
+
+// GetBmmHistory returns the most recent limit BMM attempts recorded by the
+// engine, oldest first, for debugging failed merge mining. A limit of zero
+// returns all of them.
+func GetBmmHistory(limit int) ([]BmmRecord, error) {
+	if limit < 0 {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidPageLimit, limit)
+	}
+	if err := rlockEngine(); err != nil {
+		return nil, err
+	}
+	defer engineMu.RUnlock()
+	return newBmmRecords(C.get_bmm_history(C.uintptr_t(limit)))
+}
+
+// newBmmRecords converts and frees BMM records returned by the engine.
+func newBmmRecords(cRecords C.BmmRecords) ([]BmmRecord, error) {
+	defer C.free_bmm_records(cRecords)
+	if !bool(cRecords.valid) {
+		return nil, fmt.Errorf("failed to get bmm history: %w: %s", ErrEngineFailure, getLastError())
+	}
+	records := make([]BmmRecord, 0, cRecords.len)
+	for _, cRecord := range unsafe.Slice(cRecords.ptr, cRecords.len) {
+		record := BmmRecord{
+			SidechainBlockHash: common.HexToHash(C.GoString(cRecord.critical_hash)),
+			Amount:             uint64(cRecord.amount),
+			State:              bmmState(cRecord.state),
+			Timestamp:          time.Unix(int64(cRecord.time), 0),
+		}
+		if record.State == Succeeded {
+			record.MainchainBlockHash = common.HexToHash(C.GoString(cRecord.main_block_hash))
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func verifyBmm(prevMainBlockHash string, criticalHash string) bool {
+	var args cArgs
+	defer args.free()
+	cPrevMainBlockHash := args.cString(prevMainBlockHash)
+	cCriticalHash := args.cString(criticalHash)
+	waitRateLimit()
+	return bool(C.verify_bmm(cPrevMainBlockHash, cCriticalHash))
+}
+
+// VerifyBmm reports whether the sidechain block with hash criticalHash was
+// merge mined in the mainchain block following prevMainBlockHash. Commitments
+// verified in mainchain blocks below the tip are cached, see
+// Config.BmmCacheSize. A block failing verification is checked again by the
+// next call, as it may only have been unknown to the engine so far.
+func (CGO) VerifyBmm(prevMainBlockHash common.Hash, criticalHash common.Hash) (bool, error) {
+	if err := rlockEngine(); err != nil {
+		return false, err
+	}
+	defer engineMu.RUnlock()
+	if bmmCache != nil {
+		if _, ok := bmmCache.Get(bmmCacheKey{prevMainBlockHash, criticalHash}); ok {
+			bmmVerifyCacheHitsCounter.Inc(1)
+			return true, nil
+		}
+	}
+	defer bmmVerifyTimer.UpdateSince(time.Now())
+	verified := verifyBmm(prevMainBlockHash.Hex()[2:], criticalHash.Hex()[2:])
+	if verified && bmmCache != nil {
+		if tip, err := mainchainTipInfo(); err == nil {
+			cacheBmmCommitment(bmmCache, tip, prevMainBlockHash, criticalHash)
+		}
+	}
+	return verified, nil
+}
+
+// GetWithdrawalStatus returns the stage of the withdrawal process the
+// withdrawal with the given id is in, or ErrUnknownWithdrawal.
+func GetWithdrawalStatus(id common.Hash) (WithdrawalStatus, error) {
+	if err := rlockEngine(); err != nil {
+		return 0, err
+	}
+	defer engineMu.RUnlock()
+	var args cArgs
+	defer args.free()
+	cId := args.cString(id.Hex())
+	return withdrawalStatus(id, C.get_withdrawal_status(cId))
+}
+
+// withdrawalStatus converts the status of the withdrawal with the given id
+// returned by the engine.
+func withdrawalStatus(id common.Hash, status C.WithdrawalStatus) (WithdrawalStatus, error) {
+	if status == C.WithdrawalStatus_Unknown {
+		return 0, fmt.Errorf("%w: %s", ErrUnknownWithdrawal, id.Hex())
+	}
+	return WithdrawalStatus(status), nil
+}
+
+// GetWithdrawalStatusInfo is like GetWithdrawalStatus, but also reports the
+// bundle the withdrawal is in and the ACKs it got on mainchain.
+func (CGO) GetWithdrawalStatusInfo(id common.Hash) (WithdrawalStatusInfo, error) {
+	if err := rlockEngine(); err != nil {
+		return WithdrawalStatusInfo{}, err
+	}
+	defer engineMu.RUnlock()
+	var args cArgs
+	defer args.free()
+	cId := args.cString(id.Hex())
+	return newWithdrawalStatusInfo(id, C.get_withdrawal_status_info(cId))
+}
+
+// newWithdrawalStatusInfo converts the status of the withdrawal with the given
+// id returned by the engine, freeing the bundle hash.
+func newWithdrawalStatusInfo(id common.Hash, cInfo C.WithdrawalStatusInfo) (WithdrawalStatusInfo, error) {
+	bundleHash := goString(cInfo.bundle_hash)
+	if cInfo.status == C.WithdrawalStatus_Unknown {
+		return WithdrawalStatusInfo{}, fmt.Errorf("%w: %s", ErrUnknownWithdrawal, id.Hex())
+	}
+	info := WithdrawalStatusInfo{
+		Status: WithdrawalStatus(cInfo.status),
+		Acks:   uint32(cInfo.acks),
+	}
+	if bundleHash != "" {
+		hash := common.HexToHash(bundleHash)
+		info.BundleHash = &hash
+	}
+	return info, nil
+}
+
+// GetWithdrawalHistory returns the state transitions of the withdrawal with
+// the given id recorded by the engine, oldest first. It fails with
+// ErrUnknownWithdrawal if the engine doesn't know the withdrawal.
+func GetWithdrawalHistory(id common.Hash) ([]WithdrawalEvent, error) {
+	if err := rlockEngine(); err != nil {
+		return nil, err
+	}
+	defer engineMu.RUnlock()
+	var args cArgs
+	defer args.free()
+	cId := args.cString(id.Hex())
+	return newWithdrawalEvents(id, C.get_withdrawal_history(cId))
+}
+
+// newWithdrawalEvents converts and frees the history of the withdrawal with
+// the given id returned by the engine.
+func newWithdrawalEvents(id common.Hash, cEvents C.WithdrawalEvents) ([]WithdrawalEvent, error) {
+	defer C.free_withdrawal_events(cEvents)
+	if !bool(cEvents.valid) {
+		return nil, fmt.Errorf("%w: %s", ErrEngineFailure, getLastError())
+	}
+	// Every withdrawal known to the engine has at least its creation event.
+	if cEvents.len == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownWithdrawal, id.Hex())
+	}
+	events := make([]WithdrawalEvent, 0, cEvents.len)
+	for _, cEvent := range unsafe.Slice(cEvents.ptr, cEvents.len) {
+		events = append(events, WithdrawalEvent{
+			Kind:            WithdrawalEventKind(cEvent.kind),
+			MainchainHeight: uint64(cEvent.mainchain_height),
+			Timestamp:       time.Unix(int64(cEvent.time), 0),
+		})
+	}
+	return events, nil
+}
+
+// spentWithdrawalsPageSize is the number of spent withdrawals
+// GetSpentWithdrawals asks the engine for at once.
+const spentWithdrawalsPageSize = 1000
+
+// GetSpentWithdrawals returns every withdrawal the engine considers paid out
+// on mainchain, by id. The set grows without bound, it's fetched in pages of
+// spentWithdrawalsPageSize so the engine isn't locked for the whole walk.
+// Callers that don't need all of it at once should use
+// GetSpentWithdrawalsPage instead.
+func GetSpentWithdrawals() (map[common.Hash]SpentWithdrawal, error) {
+	return getSpentWithdrawals(GetSpentWithdrawalsPage)
+}
+
+// getSpentWithdrawals walks all pages of spent withdrawals returned by
+// getPage.
+func getSpentWithdrawals(getPage func(after common.Hash, limit int) ([]SpentWithdrawal, error)) (map[common.Hash]SpentWithdrawal, error) {
+	spent := make(map[common.Hash]SpentWithdrawal)
+	var after common.Hash
+	for {
+		page, err := getPage(after, spentWithdrawalsPageSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, w := range page {
+			spent[w.Id] = w
+		}
+		if len(page) < spentWithdrawalsPageSize {
+			return spent, nil
+		}
+		after = page[len(page)-1].Id
+	}
+}
+
+// GetSpentWithdrawalsPage returns up to limit withdrawals the engine
+// considers paid out on mainchain, in ascending order of their ids, starting
+// after the id after. The zero hash starts at the beginning. The id of the
+// last withdrawal returned is the cursor of the next page, a page shorter
+// than limit is the last one.
+func GetSpentWithdrawalsPage(after common.Hash, limit int) ([]SpentWithdrawal, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidPageLimit, limit)
+	}
+	if err := rlockEngine(); err != nil {
+		return nil, err
+	}
+	defer engineMu.RUnlock()
+	var args cArgs
+	defer args.free()
+	var cAfter *C.char
+	if after != (common.Hash{}) {
+		cAfter = args.cString(after.Hex())
+	}
+	return newSpentWithdrawals(C.get_spent_withdrawals(cAfter, C.uintptr_t(limit)))
+}
+
+// newSpentWithdrawals converts and frees spent withdrawals returned by the
+// engine.
+func newSpentWithdrawals(cSpent C.SpentWithdrawals) ([]SpentWithdrawal, error) {
+	defer C.free_spent_withdrawals(cSpent)
+	if !bool(cSpent.valid) {
+		return nil, fmt.Errorf("%w: %s", ErrEngineFailure, getLastError())
+	}
+	spent := make([]SpentWithdrawal, 0, cSpent.len)
+	for _, cWithdrawal := range unsafe.Slice(cSpent.ptr, cSpent.len) {
+		spent = append(spent, SpentWithdrawal{
+			Id:                 common.HexToHash(C.GoString(cWithdrawal.id)),
+			BundleTxid:         common.HexToHash(C.GoString(cWithdrawal.bundle_txid)),
+			MainchainBlockHash: common.HexToHash(C.GoString(cWithdrawal.mainchain_block_hash)),
+			MainchainHeight:    uint64(cWithdrawal.mainchain_height),
+			Amount:             uint64(cWithdrawal.amount),
+			Fee:                uint64(cWithdrawal.fee),
+		})
+	}
+	return spent, nil
+}
+
+// IsWithdrawalSpent reports whether the withdrawal with the given id was
+// already paid out or refunded.
+func (CGO) IsWithdrawalSpent(id common.Hash) (bool, error) {
+	if err := rlockEngine(); err != nil {
+		return false, err
+	}
+	defer engineMu.RUnlock()
+	var args cArgs
+	defer args.free()
+	return bool(C.is_outpoint_spent(args.cString(id.Hex()))), nil
+}
+
+// AreWithdrawalsSpent is like IsWithdrawalSpent for many withdrawals at once,
+// asking the engine in a single call. Withdrawals the engine doesn't know
+// about are left out of the result.
+func AreWithdrawalsSpent(ids []common.Hash) (map[common.Hash]bool, error) {
+	if err := rlockEngine(); err != nil {
+		return nil, err
+	}
+	defer engineMu.RUnlock()
+	if len(ids) == 0 {
+		return make(map[common.Hash]bool), nil
+	}
+	var args cArgs
+	defer args.free()
+	results := make([]C.SpentStatus, len(ids))
+	if !bool(C.are_outpoints_spent(newOutpoints(&args, ids), C.uintptr_t(len(ids)), &results[0])) {
+		return nil, fmt.Errorf("%w: %s", ErrEngineFailure, getLastError())
+	}
+	return newSpentStatuses(ids, results), nil
+}
+
+// newOutpoints passes the ids of withdrawals as NUL terminated 0x prefixed
+// hex strings, all in one buffer instead of a C string each. There must be at
+// least one id.
+func newOutpoints(args *cArgs, ids []common.Hash) **C.char {
+	const idLength = 2 + 2*common.HashLength + 1
+	idsMemory := args.malloc(C.size_t(len(ids) * idLength))
+	idsSlice := unsafe.Slice((*byte)(idsMemory), len(ids)*idLength)
+	ptrsMemory := args.malloc(C.size_t(len(ids)) * C.size_t(unsafe.Sizeof((*C.char)(nil))))
+	ptrsSlice := unsafe.Slice((**C.char)(ptrsMemory), len(ids))
+	for i, id := range ids {
+		cId := idsSlice[i*idLength : (i+1)*idLength]
+		copy(cId, "0x")
+		hex.Encode(cId[2:], id[:])
+		cId[idLength-1] = 0
+		ptrsSlice[i] = (*C.char)(unsafe.Pointer(&cId[0]))
+	}
+	return &ptrsSlice[0]
+}
+
+// newSpentStatuses converts the results of are_outpoints_spent for ids,
+// leaving out the withdrawals the engine doesn't know about.
+func newSpentStatuses(ids []common.Hash, results []C.SpentStatus) map[common.Hash]bool {
+	spent := make(map[common.Hash]bool, len(ids))
+	for i, id := range ids {
+		switch results[i] {
+		case C.SpentStatus_Spent:
+			spent[id] = true
+		case C.SpentStatus_Unspent:
+			spent[id] = false
+		}
+	}
+	return spent
 }
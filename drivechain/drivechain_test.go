@@ -0,0 +1,1328 @@
+package drivechain
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"math"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// Tests that satoshi amounts above math.MaxInt64 are lifted into big.Ints
+// without wrapping around to negative values.
+func TestLargeDepositAmounts(t *testing.T) {
+	amounts := []uint64{math.MaxInt64 - 1, math.MaxInt64, math.MaxInt64 + 1, math.MaxUint64 - 1, math.MaxUint64}
+
+	raw := make([]RawDeposit, len(amounts))
+	for i, amount := range amounts {
+		raw[i] = RawDeposit{address: "0xc96aaa54e2d44c299564da76e1cd3184a2386b8d", amount: amount}
+	}
+	deposits := newDepositsFromRaw(raw)
+	for i, deposit := range deposits {
+		if deposit.Amount.Sign() < 0 {
+			t.Errorf("deposit %d: negative amount %v", i, deposit.Amount)
+		}
+		if want := new(big.Int).SetUint64(amounts[i]); deposit.Amount.Cmp(want) != 0 {
+			t.Errorf("deposit %d: amount mismatch: have %v, want %v", i, deposit.Amount, want)
+		}
+	}
+}
+
+func TestLargeWithdrawalFees(t *testing.T) {
+	fees := []uint64{math.MaxInt64 - 1, math.MaxInt64, math.MaxInt64 + 1, math.MaxUint64 - 1, math.MaxUint64}
+
+	for _, fee := range fees {
+		data := make([]byte, FeeLength+MainchainAddressLength)
+		binary.BigEndian.PutUint64(data, fee)
+		data[FeeLength] = 1
+
+		// Strict mode rejects fees above the amount, check that lenient mode
+		// decodes them correctly.
+		withdrawal, err := DecodeWithdrawal(new(big.Int).Mul(big.NewInt(1), Satoshi), data, WithdrawalRules{})
+		if err != nil {
+			t.Fatalf("fee %d: failed to decode withdrawal: %v", fee, err)
+		}
+		if withdrawal.Fee.Sign() < 0 {
+			t.Errorf("fee %d: negative fee %v", fee, withdrawal.Fee)
+		}
+		if want := new(big.Int).SetUint64(fee); withdrawal.Fee.Cmp(want) != 0 {
+			t.Errorf("fee %d: fee mismatch: have %v, want %v", fee, withdrawal.Fee, want)
+		}
+	}
+}
+
+func TestValidateWithdrawalData(t *testing.T) {
+	valid := make([]byte, FeeLength+MainchainAddressLength)
+	binary.BigEndian.PutUint64(valid, 1000)
+	copy(valid[FeeLength:], common.FromHex("62e907b15cbf27d5425399ebf6f0fb50ebb88f18"))
+
+	zeroFee := common.CopyBytes(valid)
+	binary.BigEndian.PutUint64(zeroFee, 0)
+
+	zeroAddress := common.CopyBytes(valid)
+	copy(zeroAddress[FeeLength:], make([]byte, MainchainAddressLength))
+
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"valid", valid, nil},
+		{"empty", nil, ErrWithdrawalDataLength},
+		{"short", valid[:len(valid)-1], ErrWithdrawalDataLength},
+		{"long", append(common.CopyBytes(valid), 0), ErrWithdrawalDataLength},
+		{"fee only", valid[:FeeLength], ErrWithdrawalDataLength},
+		{"zero fee", zeroFee, ErrZeroWithdrawalFee},
+		{"zero address", zeroAddress, ErrZeroMainchainAddress},
+	}
+	for _, tt := range tests {
+		if err := (WithdrawalRules{}).ValidateWithdrawalData(tt.data); !errors.Is(err, tt.err) {
+			t.Errorf("%s: error mismatch: have %v, want %v", tt.name, err, tt.err)
+		}
+		if _, err := DecodeWithdrawal(new(big.Int).Mul(big.NewInt(1e6), Satoshi), tt.data, WithdrawalRules{Strict: true}); !errors.Is(err, tt.err) {
+			t.Errorf("%s: decode error mismatch: have %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestDecodeWithdrawalStrict(t *testing.T) {
+	data := make([]byte, FeeLength+MainchainAddressLength)
+	binary.BigEndian.PutUint64(data, 1)
+	data[FeeLength] = 1
+
+	satoshis := func(n int64) *big.Int { return new(big.Int).Mul(big.NewInt(n), Satoshi) }
+	tests := []struct {
+		name   string
+		value  *big.Int
+		err    error // In strict mode
+		amount int64 // In lenient mode
+	}{
+		{"one satoshi", satoshis(1), ErrBelowDustThreshold, 1},
+		{"dust threshold", satoshis(DefaultMinWithdrawalSats), nil, DefaultMinWithdrawalSats},
+		{"many satoshis", satoshis(21e14), nil, 21e14},
+		{"one wei more", new(big.Int).Add(satoshis(2), common.Big1), ErrWithdrawalAmountFraction, 2},
+		{"one wei less", new(big.Int).Sub(satoshis(2), common.Big1), ErrWithdrawalAmountFraction, 1},
+		{"half satoshi", new(big.Int).Div(satoshis(3), common.Big2), ErrWithdrawalAmountFraction, 1},
+		{"dust", common.Big1, ErrWithdrawalAmountFraction, 0},
+		{"zero", new(big.Int), ErrZeroWithdrawalAmount, 0},
+	}
+	for _, tt := range tests {
+		withdrawal, err := DecodeWithdrawal(tt.value, data, WithdrawalRules{Strict: true, MinWithdrawalSats: DefaultMinWithdrawalSats})
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: strict error mismatch: have %v, want %v", tt.name, err, tt.err)
+		}
+		if err == nil && withdrawal.Amount.Int64() != tt.amount {
+			t.Errorf("%s: strict amount mismatch: have %v, want %d", tt.name, withdrawal.Amount, tt.amount)
+		}
+		withdrawal, err = DecodeWithdrawal(tt.value, data, WithdrawalRules{})
+		if err != nil {
+			t.Errorf("%s: lenient decode failed: %v", tt.name, err)
+			continue
+		}
+		if withdrawal.Amount.Int64() != tt.amount {
+			t.Errorf("%s: lenient amount mismatch: have %v, want %d", tt.name, withdrawal.Amount, tt.amount)
+		}
+	}
+}
+
+func TestDecodeWithdrawalFeeSanity(t *testing.T) {
+	tests := []struct {
+		name   string
+		amount int64 // In satoshis
+		fee    uint64
+		err    error
+	}{
+		{"fee below amount", 10000, 9999, nil},
+		{"fee equals amount", 10000, 10000, nil},
+		{"fee above amount", 10000, 10001, ErrWithdrawalFeeExceedsAmount},
+		{"zero amount", 0, 1, ErrZeroWithdrawalAmount},
+		{"zero amount huge fee", 0, math.MaxUint64, ErrZeroWithdrawalAmount},
+		{"max int64 fee", math.MaxInt64, math.MaxInt64, nil},
+		{"int64 overflowing fee", math.MaxInt64, math.MaxInt64 + 1, ErrWithdrawalFeeOverflow},
+		{"max uint64 fee", math.MaxInt64, math.MaxUint64, ErrWithdrawalFeeOverflow},
+	}
+	for _, tt := range tests {
+		data := make([]byte, FeeLength+MainchainAddressLength)
+		binary.BigEndian.PutUint64(data, tt.fee)
+		data[FeeLength] = 1
+
+		value := new(big.Int).Mul(big.NewInt(tt.amount), Satoshi)
+		withdrawal, err := DecodeWithdrawal(value, data, WithdrawalRules{Strict: true})
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: error mismatch: have %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		if err == nil && withdrawal.Fee.Cmp(new(big.Int).SetUint64(tt.fee)) != 0 {
+			t.Errorf("%s: fee mismatch: have %v, want %d", tt.name, withdrawal.Fee, tt.fee)
+		}
+	}
+}
+
+// Tests decoding withdrawal data with the fee in either byte order. The vector
+// withdraws 0.5 BTC paying a 10000 satoshi fee.
+func TestDecodeWithdrawalByteOrder(t *testing.T) {
+	var (
+		value   = new(big.Int).Mul(big.NewInt(50000000), Satoshi)
+		address = common.FromHex("0x4d6c3e9a58f1b0e2c7a3d5f6e8b9a0c1d2e3f4a5")
+		beData  = common.FromHex("0x00000000000027104d6c3e9a58f1b0e2c7a3d5f6e8b9a0c1d2e3f4a5")
+		leData  = common.FromHex("0x10270000000000004d6c3e9a58f1b0e2c7a3d5f6e8b9a0c1d2e3f4a5")
+	)
+	check := func(name string, withdrawal Withdrawal, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("%s: failed to decode: %v", name, err)
+		}
+		if withdrawal.Fee.Uint64() != 10000 {
+			t.Errorf("%s: fee mismatch: have %v, want %d", name, withdrawal.Fee, 10000)
+		}
+		if withdrawal.Amount.Uint64() != 50000000 {
+			t.Errorf("%s: amount mismatch: have %v, want %d", name, withdrawal.Amount, 50000000)
+		}
+		if !bytes.Equal(withdrawal.Address.Bytes(), address) {
+			t.Errorf("%s: address mismatch: have %x, want %x", name, withdrawal.Address.Bytes(), address)
+		}
+	}
+	addr, _ := MainchainAddressFromBytes(address)
+	if data := encodeUnversionedWithdrawalData(10000, addr); !bytes.Equal(data, beData) {
+		t.Errorf("canonical encoding mismatch: have %x, want %x", data, beData)
+	}
+	withdrawal, err := DecodeWithdrawal(value, beData, WithdrawalRules{Strict: true})
+	check("big endian", withdrawal, err)
+	withdrawal, err = DecodeWithdrawalLE(value, leData)
+	check("little endian", withdrawal, err)
+	withdrawal, err = AutoDecodeWithdrawal(value, beData, WithdrawalRules{})
+	check("auto big endian", withdrawal, err)
+	withdrawal, err = AutoDecodeWithdrawal(value, leData, WithdrawalRules{})
+	check("auto little endian", withdrawal, err)
+
+	// A fee that is insane in both byte orders is still rejected.
+	bad := common.CopyBytes(beData)
+	binary.BigEndian.PutUint64(bad, 0xff000000000000ff)
+	if _, err := AutoDecodeWithdrawal(value, bad, WithdrawalRules{}); !errors.Is(err, ErrWithdrawalFeeOverflow) {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrWithdrawalFeeOverflow)
+	}
+}
+
+// Tests the tagged withdrawal data format, which is only accepted after its
+// activation, and that legacy data keeps decoding either way.
+func TestDecodeTaggedWithdrawal(t *testing.T) {
+	var (
+		value   = new(big.Int).Mul(big.NewInt(50000000), Satoshi)
+		legacy  = common.FromHex("0x00000000000027104d6c3e9a58f1b0e2c7a3d5f6e8b9a0c1d2e3f4a5")
+		wsh     = common.FromHex("0x00000000000027100211223344556677889900aabbccddeeff00112233445566778899aabbccddeeff")
+		wpkh    = common.FromHex("0x0000000000002710014d6c3e9a58f1b0e2c7a3d5f6e8b9a0c1d2e3f4a5")
+		unknown = common.FromHex("0x0000000000002710094d6c3e9a58f1b0e2c7a3d5f6e8b9a0c1d2e3f4a5")
+	)
+	tests := []struct {
+		name   string
+		data   []byte
+		tagged bool
+		typ    MainchainAddressType
+		err    error
+	}{
+		{"legacy before activation", legacy, false, P2PKH, nil},
+		{"legacy after activation", legacy, true, P2PKH, nil},
+		{"p2wsh before activation", wsh, false, 0, ErrWithdrawalDataLength},
+		{"p2wsh after activation", wsh, true, P2WSH, nil},
+		{"p2wpkh after activation", wpkh, true, P2WPKH, nil},
+		{"p2wsh truncated", wsh[:len(wsh)-12], true, 0, ErrWithdrawalDataLength},
+		{"unknown type", unknown, true, 0, ErrUnknownMainchainAddressType},
+	}
+	for _, tt := range tests {
+		withdrawal, err := DecodeWithdrawal(value, tt.data, WithdrawalRules{Strict: true, Tagged: tt.tagged})
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: error mismatch: have %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if withdrawal.Address.Type != tt.typ {
+			t.Errorf("%s: address type mismatch: have %v, want %v", tt.name, withdrawal.Address.Type, tt.typ)
+		}
+		if withdrawal.Fee.Uint64() != 10000 {
+			t.Errorf("%s: fee mismatch: have %v, want %d", tt.name, withdrawal.Fee, 10000)
+		}
+		// The unversioned encoding must reproduce the data.
+		if data := encodeUnversionedWithdrawalData(10000, withdrawal.Address); !bytes.Equal(data, tt.data) {
+			t.Errorf("%s: encoding mismatch: have %x, want %x", tt.name, data, tt.data)
+		}
+	}
+}
+
+// Tests the versioned withdrawal data format, which is only accepted after its
+// activation, that legacy data keeps decoding as version 0 and that unknown
+// versions are reported distinctly.
+func TestDecodeVersionedWithdrawal(t *testing.T) {
+	var (
+		value   = new(big.Int).Mul(big.NewInt(50000000), Satoshi)
+		legacy  = common.FromHex("0x00000000000027104d6c3e9a58f1b0e2c7a3d5f6e8b9a0c1d2e3f4a5")
+		tagged  = common.FromHex("0x00000000000027100211223344556677889900aabbccddeeff00112233445566778899aabbccddeeff")
+		v1      = common.FromHex("0x0100000000000027100211223344556677889900aabbccddeeff00112233445566778899aabbccddeeff")
+		v1pkh   = common.FromHex("0x010000000000002710004d6c3e9a58f1b0e2c7a3d5f6e8b9a0c1d2e3f4a5")
+		v0      = common.FromHex("0x0000000000000027104d6c3e9a58f1b0e2c7a3d5f6e8b9a0c1d2e3f4a5")
+		v2      = common.FromHex("0x0200000000000027100211223344556677889900aabbccddeeff00112233445566778899aabbccddeeff")
+		v1short = v1[:len(v1)-1]
+	)
+	tests := []struct {
+		name      string
+		data      []byte
+		tagged    bool
+		versioned bool
+		typ       MainchainAddressType
+		err       error
+	}{
+		{"legacy before activation", legacy, true, false, P2PKH, nil},
+		{"legacy after activation", legacy, true, true, P2PKH, nil},
+		{"tagged after activation", tagged, true, true, 0, ErrWithdrawalDataLength},
+		{"v1 before activation", v1, true, false, 0, ErrUnknownMainchainAddressType},
+		{"v1 after activation", v1, true, true, P2WSH, nil},
+		{"v1 p2pkh", v1pkh, true, true, P2PKH, nil},
+		{"v1 truncated", v1short, true, true, 0, ErrWithdrawalDataLength},
+		{"v0 with version byte", v0, true, true, 0, ErrWithdrawalDataLength},
+		{"unknown version", v2, true, true, 0, ErrUnknownWithdrawalVersion},
+		{"empty", nil, true, true, 0, ErrWithdrawalDataLength},
+	}
+	for _, tt := range tests {
+		withdrawal, err := DecodeWithdrawal(value, tt.data, WithdrawalRules{Strict: true, Tagged: tt.tagged, Versioned: tt.versioned})
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: error mismatch: have %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if withdrawal.Address.Type != tt.typ {
+			t.Errorf("%s: address type mismatch: have %v, want %v", tt.name, withdrawal.Address.Type, tt.typ)
+		}
+		if withdrawal.Fee.Uint64() != 10000 {
+			t.Errorf("%s: fee mismatch: have %v, want %d", tt.name, withdrawal.Fee, 10000)
+		}
+	}
+	// Freshly encoded data is version 1.
+	for _, data := range [][]byte{v1, v1pkh} {
+		withdrawal, err := DecodeWithdrawal(value, data, WithdrawalRules{Strict: true, Tagged: true, Versioned: true})
+		if err != nil {
+			t.Fatalf("failed to decode %x: %v", data, err)
+		}
+		if enc := encodeVersionedWithdrawalData(10000, withdrawal.Address); !bytes.Equal(enc, data) {
+			t.Errorf("encoding mismatch: have %x, want %x", enc, data)
+		}
+	}
+}
+
+// Tests that an empty mainchain wallet is reported distinctly, but still as
+// insufficient funds.
+func TestBmmNoFunds(t *testing.T) {
+	err := fmt.Errorf("bmm attempt failed: %w: %s", ErrBmmNoFunds, "no utxos")
+	if !errors.Is(err, ErrBmmNoFunds) || !errors.Is(err, ErrBmmInsufficientFunds) {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrBmmNoFunds)
+	}
+	if errors.Is(ErrBmmInsufficientFunds, ErrBmmNoFunds) {
+		t.Error("insufficient funds matches no funds")
+	}
+}
+
+// Tests that BMM requests for headers without a previous mainchain block hash
+// are refused before reaching the engine.
+func TestAttemptBmmNoPrevMainBlockHash(t *testing.T) {
+	initTestEngine(t, nil)
+	header := &types.Header{Number: big.NewInt(1)}
+	if _, err := AttemptBmm(context.Background(), header, 1000); !errors.Is(err, ErrNoPrevMainBlockHash) {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrNoPrevMainBlockHash)
+	}
+}
+
+// Tests that the engine refuses to be used while it isn't running instead of
+// calling into the C layer.
+func TestNotInitialized(t *testing.T) {
+	if IsInitialized() {
+		t.Fatal("engine reported as initialized")
+	}
+	calls := map[string]func() error{
+		"GetMainchainTip":   func() error { _, err := GetMainchainTip(); return err },
+		"CreateDeposit":     func() error { _, err := CreateDeposit(common.Address{1}, 10000, 10); return err },
+		"GetWithdrawalData": func() error { _, err := GetWithdrawalData(1); return err },
+		"FormatMainchainAddress": func() error {
+			_, err := FormatMainchainAddress(MainchainAddress{})
+			return err
+		},
+		"VerifyBmm": func() error {
+			_, err := VerifyBmm(common.Hash{1}, common.Hash{2})
+			return err
+		},
+		"IsWithdrawalSpent":    func() error { _, err := IsWithdrawalSpent(common.Hash{1}); return err },
+		"GetWithdrawalHistory": func() error { _, err := GetWithdrawalHistory(common.Hash{1}); return err },
+		"GetSpentWithdrawals":  func() error { _, err := GetSpentWithdrawals(); return err },
+		"GetBmmHistory":        func() error { _, err := GetBmmHistory(0); return err },
+	}
+	for name, call := range calls {
+		if err := call(); !errors.Is(err, ErrNotInitialized) {
+			t.Errorf("%s: error mismatch: have %v, want %v", name, err, ErrNotInitialized)
+		}
+	}
+	if _, err := GetDepositOutputs(); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("GetDepositOutputs: error mismatch: have %v, want %v", err, ErrNotInitialized)
+	}
+	if err := ConnectBlock(common.Hash{}, nil, nil, nil, true); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("ConnectBlock: error mismatch: have %v, want %v", err, ErrNotInitialized)
+	}
+	if err := ConnectBlockUnchecked(nil, nil, nil, true); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("ConnectBlockUnchecked: error mismatch: have %v, want %v", err, ErrNotInitialized)
+	}
+	if _, err := BatchConnectBlocks(make([]BlockData, 1), true); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("BatchConnectBlocks: error mismatch: have %v, want %v", err, ErrNotInitialized)
+	}
+	if _, err := ConnectBlocks(make([]BlockConnectData, 1), true); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("ConnectBlocks: error mismatch: have %v, want %v", err, ErrNotInitialized)
+	}
+	if _, err := DisconnectBlocks(make([]BlockDisconnectData, 1), true); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("DisconnectBlocks: error mismatch: have %v, want %v", err, ErrNotInitialized)
+	}
+	if _, err := AreWithdrawalsSpent([]common.Hash{{1}}); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("AreWithdrawalsSpent: error mismatch: have %v, want %v", err, ErrNotInitialized)
+	}
+	if _, _, err := ConfirmBmm(context.Background()); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("ConfirmBmm: error mismatch: have %v, want %v", err, ErrNotInitialized)
+	}
+	if err := CancelBmm(); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("CancelBmm: error mismatch: have %v, want %v", err, ErrNotInitialized)
+	}
+	if _, err := GetPendingDeposits(); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("GetPendingDeposits: error mismatch: have %v, want %v", err, ErrNotInitialized)
+	}
+	if _, err := GetRefundableWithdrawals(); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("GetRefundableWithdrawals: error mismatch: have %v, want %v", err, ErrNotInitialized)
+	}
+	if _, err := GetCurrentBundle(); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("GetCurrentBundle: error mismatch: have %v, want %v", err, ErrNotInitialized)
+	}
+	if _, err := GetPendingBundle(); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("GetPendingBundle: error mismatch: have %v, want %v", err, ErrNotInitialized)
+	}
+	if _, err := GetBmmAmount(); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("GetBmmAmount: error mismatch: have %v, want %v", err, ErrNotInitialized)
+	}
+	if err := SetBmmAmount(1); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("SetBmmAmount: error mismatch: have %v, want %v", err, ErrNotInitialized)
+	}
+	if err := PrefetchBmmCommitments(common.Hash{1}, common.Hash{2}); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("PrefetchBmmCommitments: error mismatch: have %v, want %v", err, ErrNotInitialized)
+	}
+	if _, err := GetMainchainTipInfo(); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("GetMainchainTipInfo: error mismatch: have %v, want %v", err, ErrNotInitialized)
+	}
+	if err := <-SubscribeDeposits(make(chan Deposit)).Err(); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("SubscribeDeposits: error mismatch: have %v, want %v", err, ErrNotInitialized)
+	}
+	if err := <-SubscribeMainchainTip(make(chan MainchainTipEvent)).Err(); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("SubscribeMainchainTip: error mismatch: have %v, want %v", err, ErrNotInitialized)
+	}
+	if _, err := DepositStream(context.Background()); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("DepositStream: error mismatch: have %v, want %v", err, ErrNotInitialized)
+	}
+	if _, err := ExpectedTreasuryBalance(); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("ExpectedTreasuryBalance: error mismatch: have %v, want %v", err, ErrNotInitialized)
+	}
+	if err := CheckTreasuryInvariant(new(big.Int)); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("CheckTreasuryInvariant: error mismatch: have %v, want %v", err, ErrNotInitialized)
+	}
+	if _, err := GetBlockSidechainActivity(common.Hash{1}); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("GetBlockSidechainActivity: error mismatch: have %v, want %v", err, ErrNotInitialized)
+	}
+	if err := ConnectBlock(common.Hash{1}, nil, nil, nil, true); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("ConnectBlock with block hash: error mismatch: have %v, want %v", err, ErrNotInitialized)
+	}
+	if _, err := ReplayDeposits(1, 2); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("ReplayDeposits: error mismatch: have %v, want %v", err, ErrNotInitialized)
+	}
+	if err := ValidateBlock(nil, nil, nil); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("ValidateBlock: error mismatch: have %v, want %v", err, ErrNotInitialized)
+	}
+	key, _ := crypto.GenerateKey()
+	if _, err := CreateWithdrawal(context.Background(), types.HomesteadSigner{}, key, big.NewInt(10000), 1, 0); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("CreateWithdrawal: error mismatch: have %v, want %v", err, ErrNotInitialized)
+	}
+	if _, err := FormatDepositAddress(common.HexToAddress("0x62e907b15cbf27d5425399ebf6f0fb50ebb88f18")); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("FormatDepositAddress: error mismatch: have %v, want %v", err, ErrNotInitialized)
+	}
+	if err := VerifyDeposit(Deposit{Amount: big.NewInt(1)}); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("VerifyDeposit: error mismatch: have %v, want %v", err, ErrNotInitialized)
+	}
+	if err := Shutdown(); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("Shutdown: error mismatch: have %v, want %v", err, ErrNotInitialized)
+	}
+}
+
+func TestProbeMainchain(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "user" || password != "password" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"result": {}, "error": null, "id": 1}`))
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	port, _ := strconv.Atoi(u.Port())
+	cfg := Config{Host: u.Hostname(), Port: uint16(port), RPCUser: "user", RPCPassword: "password"}
+	if err := probeMainchain(context.Background(), &cfg); err != nil {
+		t.Fatalf("probe failed: %v", err)
+	}
+	cfg.RPCPassword = "wrong"
+	if err := probeMainchain(context.Background(), &cfg); err == nil {
+		t.Fatalf("probe succeeded with wrong credentials")
+	}
+}
+
+// Tests that the credentials are read from the cookie file, and read again when
+// the mainchain node rotated the cookie.
+func TestProbeMainchainCookie(t *testing.T) {
+	var token atomic.Value
+	token.Store("first")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "__cookie__" || password != token.Load().(string) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"result": {}, "error": null, "id": 1}`))
+	}))
+	defer srv.Close()
+
+	cookie := filepath.Join(t.TempDir(), ".cookie")
+	if err := os.WriteFile(cookie, []byte("__cookie__:first"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(srv.URL)
+	port, _ := strconv.Atoi(u.Port())
+	cfg := Config{Host: u.Hostname(), Port: uint16(port), CookieFile: cookie}
+	if err := probeMainchain(context.Background(), &cfg); err != nil {
+		t.Fatalf("probe failed: %v", err)
+	}
+	token.Store("second")
+	if err := os.WriteFile(cookie, []byte("__cookie__:second\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := probeMainchain(context.Background(), &cfg); err != nil {
+		t.Fatalf("probe failed after cookie rotation: %v", err)
+	}
+	if cfg.RPCPassword != "second" {
+		t.Errorf("password mismatch: have %q, want %q", cfg.RPCPassword, "second")
+	}
+	if err := os.WriteFile(cookie, []byte("garbage"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := probeMainchain(context.Background(), &cfg); err == nil {
+		t.Fatal("probe succeeded with malformed cookie")
+	}
+}
+
+func TestInitWithRetryGivesUp(t *testing.T) {
+	var attempts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		http.Error(w, "loading block index", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	port, _ := strconv.Atoi(u.Port())
+	if err := InitWithRetry(context.Background(), t.TempDir(), u.Hostname(), uint16(port), "user", "password", 2); err == nil {
+		t.Fatal("init succeeded with unreachable mainchain")
+	}
+	if n := atomic.LoadInt32(&attempts); n != 2 {
+		t.Errorf("attempt count mismatch: have %d, want 2", n)
+	}
+	if initialized {
+		t.Error("engine initialized with unreachable mainchain")
+	}
+}
+
+func TestBmmStateText(t *testing.T) {
+	for _, state := range []BmmState{Succeeded, Failed, Pending, Unknown, Cancelled, Idle} {
+		text, err := state.MarshalText()
+		if err != nil {
+			t.Fatalf("%v: marshal failed: %v", state, err)
+		}
+		var decoded BmmState
+		if err := decoded.UnmarshalText(text); err != nil {
+			t.Fatalf("%v: unmarshal failed: %v", state, err)
+		}
+		if decoded != state {
+			t.Errorf("round trip mismatch: have %v, want %v", decoded, state)
+		}
+	}
+	if s := fmt.Sprint(Succeeded); s != "succeeded" {
+		t.Errorf("string mismatch: have %q, want %q", s, "succeeded")
+	}
+	if enc, err := json.Marshal(map[string]BmmState{"state": Failed}); err != nil || string(enc) != `{"state":"failed"}` {
+		t.Errorf("json marshal mismatch: have %s (%v), want %s", enc, err, `{"state":"failed"}`)
+	}
+	var state BmmState
+	if err := json.Unmarshal([]byte(`"Pending"`), &state); err != nil || state != Pending {
+		t.Errorf("json unmarshal mismatch: have %v (%v), want %v", state, err, Pending)
+	}
+	if err := state.UnmarshalText([]byte("Succeded")); err == nil {
+		t.Error("unmarshal succeeded with invalid name")
+	}
+	if _, err := BmmState(Idle + 1).MarshalText(); err == nil {
+		t.Error("marshal succeeded with invalid state")
+	}
+}
+
+func TestRegisterMetrics(t *testing.T) {
+	reg := metrics.NewRegistry()
+	if err := RegisterMetrics(reg); err != nil {
+		t.Fatalf("failed to register metrics: %v", err)
+	}
+	if reg.Get("drivechain/connectblock/duration") == nil {
+		t.Error("connect block timer not registered")
+	}
+	if err := RegisterMetrics(reg); err == nil {
+		t.Error("registered metrics twice")
+	}
+}
+
+// initTestEngine initializes the engine against a fake mainchain node served
+// by handler, shutting it down again once the test is done. A nil handler
+// answers every call with an empty result.
+func initTestEngine(t *testing.T, handler http.HandlerFunc) {
+	t.Helper()
+	if handler == nil {
+		handler = testMainchainHandler
+	}
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	u, _ := url.Parse(srv.URL)
+	port, _ := strconv.Atoi(u.Port())
+	cfg := Config{DBPath: t.TempDir(), Host: u.Hostname(), Port: uint16(port), RPCUser: "user", RPCPassword: "password"}
+	if err := InitWithContext(context.Background(), cfg); err != nil {
+		t.Fatalf("failed to initialize: %v", err)
+	}
+	if !IsInitialized() {
+		t.Fatal("engine not reported as initialized")
+	}
+	t.Cleanup(func() { Shutdown() })
+}
+
+// testMainchainHandler is a mainchain node whose tip is the one of the test
+// engine, block 1 with hash 0x01.
+func testMainchainHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Method string `json:"method"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	switch req.Method {
+	case "getbestblockhash":
+		w.Write([]byte(`{"result": "` + common.HexToHash("01").Hex()[2:] + `", "error": null, "id": 1}`))
+	case "getblockheader":
+		w.Write([]byte(`{"result": {"hash": "` + common.HexToHash("01").Hex()[2:] + `", "height": 1, "time": 1600000000, "previousblockhash": "00"}, "error": null, "id": 1}`))
+	default:
+		w.Write([]byte(`{"result": {}, "error": null, "id": 1}`))
+	}
+}
+
+// Tests that Init registers the metrics with the default registry when metrics
+// collection is enabled.
+func TestInitRegistersMetrics(t *testing.T) {
+	enabled := metrics.Enabled
+	metrics.Enabled = true
+	defer func() { metrics.Enabled = enabled }()
+
+	initTestEngine(t, nil)
+
+	for _, name := range []string{
+		"drivechain/bmm/attempts/total",
+		"drivechain/bmm/succeeded/total",
+		"drivechain/bmm/failed/total",
+		"drivechain/bmm/verify/duration",
+		"drivechain/bmm/verify/cachehits",
+		"drivechain/deposits/seen/total",
+		"drivechain/depositoutputs/duration",
+		"drivechain/withdrawals/created/total",
+		"drivechain/bundle/broadcasts/total",
+		"drivechain/rpc/retries/total",
+		"drivechain/rpc/ratelimit/wait",
+		"drivechain/connectblock/duration",
+	} {
+		if metrics.DefaultRegistry.Get(name) == nil {
+			t.Errorf("metric %s not registered", name)
+		}
+	}
+}
+
+// Tests that a call whose context is cancelled while it waits for the engine
+// lock doesn't run once it gets the lock.
+func TestCallEngineCancelledWhileLocked(t *testing.T) {
+	initTestEngine(t, nil)
+
+	for name, call := range map[string]func(context.Context, func()) error{
+		"callEngine":          callEngine,
+		"callEngineExclusive": callEngineExclusive,
+	} {
+		var ran int32
+		ctx, cancel := context.WithCancel(context.Background())
+		engineMu.Lock()
+		errc := make(chan error, 1)
+		go func() { errc <- call(ctx, func() { atomic.StoreInt32(&ran, 1) }) }()
+		// Let the call block on the lock before giving up on it.
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+		err := <-errc
+		engineMu.Unlock()
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("%s: error mismatch: have %v, want %v", name, err, context.Canceled)
+		}
+		// Give the abandoned call time to get the lock.
+		time.Sleep(50 * time.Millisecond)
+		if atomic.LoadInt32(&ran) != 0 {
+			t.Errorf("%s: cancelled call ran", name)
+		}
+	}
+}
+
+// Tests that ConfirmBmm, which settles the BMM attempt, waits for readers of
+// the engine to finish.
+func TestConfirmBmmExclusive(t *testing.T) {
+	initTestEngine(t, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	engineMu.RLock()
+	_, _, err := (CGO{}).ConfirmBmm(ctx)
+	engineMu.RUnlock()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error mismatch: have %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+// Tests that the engine can be used from many goroutines at once, mixing calls
+// changing its state with readers. Run with -race.
+func TestConcurrentCalls(t *testing.T) {
+	initTestEngine(t, nil)
+
+	var (
+		ctx    = context.Background()
+		header = &types.Header{Number: big.NewInt(1), PrevMainBlockHash: common.Hash{1}}
+		wg     sync.WaitGroup
+	)
+	calls := []func(){
+		func() { ConnectBlock(common.Hash{}, nil, nil, nil, true) },
+		func() { DisconnectBlock(common.Hash{}, nil, nil, nil, true) },
+		func() { AttemptBmm(ctx, header, 1000) },
+		func() { CreateDeposit(common.Address{1}, 10000, 10) },
+		func() { AttemptBundleBroadcast(ctx) },
+		func() { GetMainchainTip() },
+		func() { GetUnspentWithdrawals(ctx) },
+		func() { IsWithdrawalSpent(common.Hash{1}) },
+		func() { VerifyBmm(common.Hash{1}, common.Hash{2}) },
+		func() { ConfirmBmm(ctx) },
+	}
+	for _, call := range calls {
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func(call func()) {
+				defer wg.Done()
+				for j := 0; j < 50; j++ {
+					call()
+				}
+			}(call)
+		}
+	}
+	wg.Wait()
+}
+
+// Tests that an idle engine reports that there is nothing to broadcast rather
+// than a failure.
+func TestAttemptBundleBroadcastIdle(t *testing.T) {
+	initTestEngine(t, nil)
+
+	result, err := AttemptBundleBroadcast(context.Background())
+	if err != nil {
+		t.Fatalf("broadcast failed: %v", err)
+	}
+	if result.Outcome != NothingToDo || result.BundleHash != (common.Hash{}) {
+		t.Errorf("result mismatch: have %+v, want %v", result, NothingToDo)
+	}
+}
+
+// Tests that GetPendingBundleHash reports the bundle broadcast by
+// AttemptBundleBroadcast, and no bundle before or while the engine isn't
+// running.
+func TestGetPendingBundleHash(t *testing.T) {
+	if hash, ok := GetPendingBundleHash(); ok {
+		t.Fatalf("engine not running, but bundle %x reported", hash)
+	}
+	initTestEngine(t, nil)
+	if hash, ok := GetPendingBundleHash(); ok {
+		t.Fatalf("nothing broadcast, but bundle %x reported", hash)
+	}
+
+	address, _ := NewMainchainAddress(P2PKH, common.FromHex("0x0102030405060708090a0b0c0d0e0f1011121314"))
+	withdrawals := map[common.Hash]Withdrawal{
+		{1}: {Address: address, Amount: big.NewInt(100000), Fee: big.NewInt(1000)},
+	}
+	if err := ConnectBlock(common.Hash{}, nil, withdrawals, nil, false); err != nil {
+		t.Fatalf("failed to connect block: %v", err)
+	}
+	result, err := AttemptBundleBroadcast(context.Background())
+	if err != nil {
+		t.Fatalf("broadcast failed: %v", err)
+	}
+	if result.Outcome != Broadcast {
+		t.Fatalf("outcome mismatch: have %v, want %v", result.Outcome, Broadcast)
+	}
+	if hash, ok := GetPendingBundleHash(); !ok || hash != result.BundleHash {
+		t.Errorf("pending bundle mismatch: have %x, %v, want %x", hash, ok, result.BundleHash)
+	}
+}
+
+func TestBmmAmountOverride(t *testing.T) {
+	initTestEngine(t, nil)
+
+	recommended, err := GetBmmAmount()
+	if err != nil {
+		t.Fatalf("failed to get bmm amount: %v", err)
+	}
+	if err := SetBmmAmount(recommended + 500); err != nil {
+		t.Fatalf("failed to set bmm amount: %v", err)
+	}
+	if amount, _ := GetBmmAmount(); amount != recommended+500 {
+		t.Errorf("amount mismatch: have %d, want %d", amount, recommended+500)
+	}
+	if err := SetBmmAmount(0); err != nil {
+		t.Fatalf("failed to drop override: %v", err)
+	}
+	if amount, _ := GetBmmAmount(); amount != recommended {
+		t.Errorf("amount mismatch after dropping override: have %d, want %d", amount, recommended)
+	}
+}
+
+func TestCreateDeposit(t *testing.T) {
+	tests := []struct {
+		amount, fee uint64
+		err         error
+	}{
+		{0, 10, ErrZeroDepositAmount},
+		{1000, 0, ErrZeroDepositFee},
+		{1000, 1001, ErrDepositFeeExceedsAmount},
+	}
+	for _, tt := range tests {
+		// Insane deposits are rejected before the engine is even consulted.
+		if _, err := CreateDeposit(common.Address{1}, tt.amount, tt.fee); !errors.Is(err, tt.err) {
+			t.Errorf("%d/%d: error mismatch: have %v, want %v", tt.amount, tt.fee, err, tt.err)
+		}
+	}
+	initTestEngine(t, nil)
+	txid, err := CreateDeposit(common.Address{1}, 10000, 1000)
+	if err != nil {
+		t.Fatalf("failed to create deposit: %v", err)
+	}
+	if txid == (common.Hash{}) {
+		t.Error("no deposit txid")
+	}
+}
+
+func TestStateSnapshot(t *testing.T) {
+	if _, err := ExportState(); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("ExportState: error mismatch: have %v, want %v", err, ErrNotInitialized)
+	}
+	if err := ImportState([]byte("DCST\x00\x00\x00\x01")); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("ImportState: error mismatch: have %v, want %v", err, ErrNotInitialized)
+	}
+	initTestEngine(t, nil)
+
+	snapshot, err := ExportState()
+	if err != nil {
+		t.Fatalf("failed to export state: %v", err)
+	}
+	if err := ImportState(snapshot); err != nil {
+		t.Fatalf("failed to import exported state: %v", err)
+	}
+	if again, _ := ExportState(); !bytes.Equal(again, snapshot) {
+		t.Errorf("state changed by round trip: have %x, want %x", again, snapshot)
+	}
+	for _, bad := range [][]byte{nil, []byte("DCST"), append([]byte("XXXX"), snapshot[4:]...)} {
+		if err := ImportState(bad); !errors.Is(err, ErrInvalidStateSnapshot) {
+			t.Errorf("%x: error mismatch: have %v, want %v", bad, err, ErrInvalidStateSnapshot)
+		}
+	}
+	newer := common.CopyBytes(snapshot)
+	binary.BigEndian.PutUint32(newer[4:], math.MaxUint32)
+	if err := ImportState(newer); !errors.Is(err, ErrUnsupportedStateVersion) {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrUnsupportedStateVersion)
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	var herr *HealthError
+	if err := HealthCheck(); !errors.As(err, &herr) || herr.Component != "engine" || !errors.Is(err, ErrNotInitialized) {
+		t.Fatalf("error mismatch: have %v, want engine %v", err, ErrNotInitialized)
+	}
+
+	var mainchainDown int32
+	initTestEngine(t, func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string `json:"method"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		switch {
+		case req.Method != "getblockcount":
+			w.Write([]byte(`{"result": {}, "error": null, "id": 1}`))
+		case atomic.LoadInt32(&mainchainDown) != 0:
+			http.Error(w, "loading block index", http.StatusServiceUnavailable)
+		default:
+			w.Write([]byte(`{"result": 812345, "error": null, "id": 1}`))
+		}
+	})
+	if err := HealthCheck(); err != nil {
+		t.Fatalf("health check failed: %v", err)
+	}
+	atomic.StoreInt32(&mainchainDown, 1)
+	if err := HealthCheck(); !errors.As(err, &herr) || herr.Component != "mainchain" {
+		t.Errorf("error mismatch: have %v, want mainchain failure", err)
+	}
+}
+
+func TestParseDepositAddress(t *testing.T) {
+	want := common.HexToAddress("0x62e907b15cbf27d5425399ebf6f0fb50ebb88f18")
+	tests := []struct {
+		deposit string
+		number  byte
+		err     error
+	}{
+		{"s7_0x62e907b15CbF27d5425399EbF6f0fB50EbB88f18_08d67f", 7, nil},
+		{"s255_62e907b15cbf27d5425399ebf6f0fb50ebb88f18_58acba", 255, nil},
+		{"s255_62e907b15cbf27d5425399ebf6f0fb50ebb88f18_58ACBA", 255, nil},
+		// Checksum of a different address.
+		{"s7_0x62e907b15CbF27d5425399EbF6f0fB50EbB88f19_08d67f", 0, ErrInvalidDepositAddress},
+		{"s8_0x62e907b15CbF27d5425399EbF6f0fB50EbB88f18_08d67f", 0, ErrInvalidDepositAddress},
+		{"s256_62e907b15cbf27d5425399ebf6f0fb50ebb88f18_58acba", 0, ErrInvalidDepositAddress},
+		{"s7_0x62e907b15CbF27d5425399EbF6f0fB50EbB88f1_08d67f", 0, ErrInvalidDepositAddress},
+		{"s7_62e907b15cbf27d5425399ebf6f0fb50ebb88f18", 0, ErrInvalidDepositAddress},
+		{"7_0x62e907b15CbF27d5425399EbF6f0fB50EbB88f18_08d67f", 0, ErrInvalidDepositAddress},
+		{"0x62e907b15cbf27d5425399ebf6f0fb50ebb88f18", 0, ErrInvalidDepositAddress},
+		{"", 0, ErrInvalidDepositAddress},
+	}
+	for _, tt := range tests {
+		deposit, err := ParseDepositAddress(tt.deposit)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%q: error mismatch: have %v, want %v", tt.deposit, err, tt.err)
+			continue
+		}
+		if err == nil && (deposit.Address != want || deposit.Sidechain != tt.number) {
+			t.Errorf("%q: have %x in sidechain %d, want %x in %d", tt.deposit, deposit.Address, deposit.Sidechain, want, tt.number)
+		}
+	}
+}
+
+func TestDepositAddressEncode(t *testing.T) {
+	address := common.HexToAddress("0x62e907b15cbf27d5425399ebf6f0fb50ebb88f18")
+	if have, want := (DepositAddress{Address: address, Sidechain: 255}).Encode(), "s255_62e907b15cbf27d5425399ebf6f0fb50ebb88f18_58acba"; have != want {
+		t.Errorf("encoding mismatch: have %q, want %q", have, want)
+	}
+	for _, sidechain := range []uint8{0, 7, 255} {
+		deposit := DepositAddress{Address: address, Sidechain: sidechain}
+		parsed, err := ParseDepositAddress(deposit.Encode())
+		if err != nil {
+			t.Errorf("sidechain %d: failed to parse %q: %v", sidechain, deposit.Encode(), err)
+			continue
+		}
+		if parsed != deposit {
+			t.Errorf("sidechain %d: round trip mismatch: have %+v, want %+v", sidechain, parsed, deposit)
+		}
+	}
+}
+
+func TestGetWithdrawalHistoryUnknown(t *testing.T) {
+	initTestEngine(t, nil)
+
+	if _, err := GetWithdrawalHistory(common.Hash{1}); !errors.Is(err, ErrUnknownWithdrawal) {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrUnknownWithdrawal)
+	}
+}
+
+func TestVerifyBmmCache(t *testing.T) {
+	initTestEngine(t, nil)
+
+	tip, err := GetMainchainTipInfo()
+	if err != nil {
+		t.Fatalf("failed to get tip: %v", err)
+	}
+	// Commitments in blocks below the tip are cached, those in the tip and in
+	// blocks not mined yet aren't.
+	for _, prev := range []common.Hash{{1}, tip.Hash, tip.PrevHash} {
+		if _, err := VerifyBmm(prev, common.Hash{2}); err != nil {
+			t.Fatalf("failed to verify bmm: %v", err)
+		}
+	}
+	if _, ok := bmmCache.Peek(bmmCacheKey{common.Hash{1}, common.Hash{2}}); !ok || bmmCache.Len() != 1 {
+		t.Errorf("cache mismatch: have %v", bmmCache.Keys())
+	}
+	if err := PrefetchBmmCommitments(common.Hash{3}, common.Hash{4}); err != nil {
+		t.Fatalf("failed to prefetch: %v", err)
+	}
+	if _, ok := bmmCache.Peek(bmmCacheKey{common.HexToHash("0x05"), common.HexToHash("0x07")}); !ok {
+		t.Errorf("prefetched commitment not cached")
+	}
+	// The tip passed in decides what is below it.
+	bmmCache.Purge()
+	cacheBmmCommitment(bmmCache, tip, tip.PrevHash, common.Hash{2})
+	cacheBmmCommitment(bmmCache, tip, common.Hash{1}, common.Hash{2})
+	if bmmCache.Len() != 1 {
+		t.Errorf("cache mismatch: have %v", bmmCache.Keys())
+	}
+	Shutdown()
+	if bmmCache != nil {
+		t.Error("cache kept after shutdown")
+	}
+}
+
+func TestDedupeRawDeposits(t *testing.T) {
+	const address = "0x62e907b15cbf27d5425399ebf6f0fb50ebb88f18"
+	raw := []RawDeposit{
+		{address: address, amount: 1000, txid: "01", Index: 0},
+		{address: address, amount: 1000, txid: "02", Index: 1},
+		// Reported again during a reorg, possibly with another outpoint.
+		{address: strings.ToUpper(address[2:]), amount: 1000, txid: "03", Index: 1},
+		{address: address, amount: 2000, txid: "04", Index: 1},
+		{address: address, amount: 1000, txid: "01", Index: 0},
+	}
+	deduped := dedupeRawDeposits(raw)
+	var txids []string
+	for _, deposit := range deduped {
+		txids = append(txids, deposit.txid)
+	}
+	if want := []string{"01", "02", "04"}; !reflect.DeepEqual(txids, want) {
+		t.Errorf("deduplicated deposits mismatch: have %v, want %v", txids, want)
+	}
+	if len(raw) != 5 || raw[2].txid != "03" {
+		t.Error("input modified")
+	}
+}
+
+func TestSplitDepositsByDepth(t *testing.T) {
+	raw := []RawDeposit{
+		{address: "0x01", amount: 1000, txid: "01", height: 95},
+		{address: "0x02", amount: 1000, txid: "02", height: 98},
+		{address: "0x03", amount: 1000, txid: "03", height: 100},
+		{address: "0x04", amount: 1000, txid: "04"},
+	}
+	confirmed, pending := splitDepositsByDepth(raw, 100, 3)
+	if len(confirmed) != 2 || confirmed[0].txid != "01" || confirmed[1].txid != "02" {
+		t.Errorf("confirmed deposits mismatch: have %+v", confirmed)
+	}
+	want := []struct {
+		address       common.Address
+		confirmations uint64
+	}{{common.HexToAddress("0x03"), 1}, {common.HexToAddress("0x04"), 0}}
+	if len(pending) != len(want) {
+		t.Fatalf("pending deposits mismatch: have %+v", pending)
+	}
+	for i, p := range pending {
+		if p.Address != want[i].address || p.Confirmations != want[i].confirmations || p.Required != 3 {
+			t.Errorf("pending deposit %d mismatch: have %+v, want %d confirmations", i, p, want[i].confirmations)
+		}
+	}
+	// The same deposits are confirmed once the tip moved on.
+	if confirmed, pending := splitDepositsByDepth(raw, 102, 3); len(confirmed) != 3 || len(pending) != 1 {
+		t.Errorf("deposits mismatch at later tip: have %d confirmed, %d pending", len(confirmed), len(pending))
+	}
+}
+
+// Tests that deposits replayed from a range of mainchain blocks are only
+// returned once. The test engine reports the same deposit twice for any range.
+func TestReplayDeposits(t *testing.T) {
+	initTestEngine(t, nil)
+
+	if _, err := ReplayDeposits(10, 9); !errors.Is(err, ErrInvalidHeightRange) {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrInvalidHeightRange)
+	}
+	deposits, err := ReplayDeposits(5, 10)
+	if err != nil {
+		t.Fatalf("failed to replay deposits: %v", err)
+	}
+	if len(deposits) != 1 || deposits[0].Amount.Uint64() != 1000 || deposits[0].Address != TreasuryAddress() {
+		t.Errorf("deposits mismatch: have %v", deposits)
+	}
+}
+
+// Tests that GetSidechainID reports the slot the engine is running with,
+// rather than the default one.
+func TestGetSidechainID(t *testing.T) {
+	if id := GetSidechainID(); id != defaultSidechainNumber {
+		t.Errorf("sidechain id before init mismatch: have %d, want %d", id, defaultSidechainNumber)
+	}
+	initTestEngine(t, nil)
+	if id := GetSidechainID(); id != 0 {
+		t.Errorf("sidechain id mismatch: have %d, want %d", id, 0)
+	}
+}
+
+// Tests that the deprecated THIS_SIDECHAIN constant isn't used outside of this
+// package, the slot is configurable and must be read with GetSidechainID.
+func TestNoSidechainConstantUses(t *testing.T) {
+	root, err := filepath.Abs("..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkgDir, err := filepath.Abs(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if name := info.Name(); path != root && (strings.HasPrefix(name, ".") || name == "vendor" || name == "testdata") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(path) != ".go" || filepath.Dir(path) == pkgDir {
+			return nil
+		}
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if !bytes.Contains(src, []byte("THIS_SIDECHAIN")) {
+			return nil
+		}
+		file, err := parser.ParseFile(token.NewFileSet(), path, src, 0)
+		if err != nil {
+			return err
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			if sel, ok := n.(*ast.SelectorExpr); ok && sel.Sel.Name == "THIS_SIDECHAIN" {
+				rel, _ := filepath.Rel(root, path)
+				t.Errorf("%s: uses deprecated THIS_SIDECHAIN, use GetSidechainID", rel)
+			}
+			return true
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Tests paging through the spent withdrawals. The test engine knows three,
+// with ids 0x01 to 0x03.
+func TestGetSpentWithdrawals(t *testing.T) {
+	initTestEngine(t, nil)
+
+	if _, err := GetSpentWithdrawalsPage(common.Hash{}, 0); !errors.Is(err, ErrInvalidPageLimit) {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrInvalidPageLimit)
+	}
+	var (
+		ids   []common.Hash
+		after common.Hash
+	)
+	for {
+		page, err := GetSpentWithdrawalsPage(after, 2)
+		if err != nil {
+			t.Fatalf("failed to get page after %x: %v", after, err)
+		}
+		for _, w := range page {
+			ids = append(ids, w.Id)
+		}
+		if len(page) < 2 {
+			break
+		}
+		after = page[len(page)-1].Id
+	}
+	want := []common.Hash{common.HexToHash("0x01"), common.HexToHash("0x02"), common.HexToHash("0x03")}
+	if !reflect.DeepEqual(ids, want) {
+		t.Errorf("paged ids mismatch: have %x, want %x", ids, want)
+	}
+	spent, err := GetSpentWithdrawals()
+	if err != nil {
+		t.Fatalf("failed to get spent withdrawals: %v", err)
+	}
+	if len(spent) != len(want) {
+		t.Fatalf("spent withdrawals mismatch: have %d, want %d", len(spent), len(want))
+	}
+	w := spent[common.HexToHash("0x03")]
+	if w.BundleTxid != common.HexToHash("0xb2") || w.MainchainBlockHash != common.HexToHash("0xc2") || w.MainchainHeight != 101 ||
+		w.Amount != 3000 || w.Fee != 20 {
+		t.Errorf("spent withdrawal mismatch: have %+v", w)
+	}
+}
+
+// Tests that GetBmmHistory returns the most recent attempts, oldest first. The
+// test engine recorded a failed, a cancelled and a successful attempt.
+func TestGetBmmHistory(t *testing.T) {
+	initTestEngine(t, nil)
+
+	if _, err := GetBmmHistory(-1); !errors.Is(err, ErrInvalidPageLimit) {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrInvalidPageLimit)
+	}
+	all, err := GetBmmHistory(0)
+	if err != nil {
+		t.Fatalf("failed to get bmm history: %v", err)
+	}
+	wantStates := []BmmState{Failed, Cancelled, Succeeded}
+	if len(all) != len(wantStates) {
+		t.Fatalf("history length mismatch: have %d, want %d", len(all), len(wantStates))
+	}
+	for i, record := range all {
+		if record.State != wantStates[i] || record.Amount != uint64(1000*(i+1)) {
+			t.Errorf("record %d mismatch: have %+v", i, record)
+		}
+		if (record.MainchainBlockHash != common.Hash{}) != (record.State == Succeeded) {
+			t.Errorf("record %d: mainchain block hash %x in state %v", i, record.MainchainBlockHash, record.State)
+		}
+	}
+	if all[2].SidechainBlockHash != common.HexToHash("0xa3") || all[2].MainchainBlockHash != common.HexToHash("0xb3") {
+		t.Errorf("successful record mismatch: have %+v", all[2])
+	}
+	recent, err := GetBmmHistory(2)
+	if err != nil {
+		t.Fatalf("failed to get recent bmm history: %v", err)
+	}
+	if !reflect.DeepEqual(recent, all[1:]) {
+		t.Errorf("recent history mismatch: have %+v, want %+v", recent, all[1:])
+	}
+}
+
+// testEthClient is an EthClient recording the transactions sent through it.
+type testEthClient struct {
+	calls int
+	sent  []*types.Transaction
+}
+
+func (c *testEthClient) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	c.calls++
+	return 7, nil
+}
+
+func (c *testEthClient) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	c.calls++
+	return big.NewInt(1e9), nil
+}
+
+func (c *testEthClient) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	c.calls++
+	c.sent = append(c.sent, tx)
+	return nil
+}
+
+func TestWithdraw(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		client = new(testEthClient)
+		signer = types.HomesteadSigner{}
+		amount = SatoshiToWei(DefaultMinWithdrawalSats)
+	)
+	key, _ := crypto.GenerateKey()
+
+	// Bad arguments are rejected before the network is touched.
+	tests := []struct {
+		name   string
+		amount *big.Int
+		fee    uint64
+		err    error
+	}{
+		{"fractional", new(big.Int).Add(amount, common.Big1), 1, ErrFractionalSatoshi},
+		{"zero", new(big.Int), 1, ErrZeroWithdrawalAmount},
+		{"dust", SatoshiToWei(1), 1, ErrBelowDustThreshold},
+		{"zero fee", amount, 0, ErrZeroWithdrawalFee},
+	}
+	for _, tt := range tests {
+		if _, err := Withdraw(ctx, client, signer, key, tt.amount, tt.fee); !errors.Is(err, tt.err) {
+			t.Errorf("%s: error mismatch: have %v, want %v", tt.name, err, tt.err)
+		}
+	}
+	if client.calls != 0 {
+		t.Fatalf("client called %d times for rejected withdrawals", client.calls)
+	}
+	if _, err := Withdraw(ctx, client, signer, key, amount, 1); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrNotInitialized)
+	}
+
+	initTestEngine(t, nil)
+	client = new(testEthClient)
+	hash, err := Withdraw(ctx, client, signer, key, amount, 1)
+	if err != nil {
+		t.Fatalf("failed to withdraw: %v", err)
+	}
+	if len(client.sent) != 1 || client.sent[0].Hash() != hash {
+		t.Fatalf("sent transactions mismatch: have %v, want %x", client.sent, hash)
+	}
+	tx := client.sent[0]
+	if tx.Nonce() != 7 || tx.GasPrice().Cmp(big.NewInt(1e9)) != 0 || *tx.To() != TreasuryAddress() {
+		t.Errorf("transaction mismatch: nonce %d, gas price %v, to %x", tx.Nonce(), tx.GasPrice(), tx.To())
+	}
+	withdrawal, err := DecodeWithdrawal(tx.Value(), tx.Data(), WithdrawalRules{Strict: true, Tagged: true, Versioned: true})
+	if err != nil {
+		t.Fatalf("failed to decode withdrawal: %v", err)
+	}
+	if withdrawal.Amount.Uint64() != DefaultMinWithdrawalSats || withdrawal.Fee.Uint64() != 1 {
+		t.Errorf("withdrawal mismatch: have %+v", withdrawal)
+	}
+}
+
+// Tests that batches of blocks are applied up to the failing block, whose
+// index is reported. The test engine rejects blocks with zero deposits.
+func TestConnectBlocks(t *testing.T) {
+	initTestEngine(t, nil)
+
+	deposit := func(amount int64) []Deposit {
+		return []Deposit{{Address: common.Address{1}, Amount: big.NewInt(amount)}}
+	}
+	connect := []BlockConnectData{{Deposits: deposit(1000)}, {}, {Deposits: deposit(0)}, {Deposits: deposit(1000)}}
+	disconnect := []BlockDisconnectData{{Deposits: deposit(1000)}, {}, {Deposits: deposit(0)}, {Deposits: deposit(1000)}}
+	for _, batch := range []struct {
+		name string
+		call func(n int) (int, error)
+	}{
+		{"connect", func(n int) (int, error) { return ConnectBlocks(connect[:n], false) }},
+		{"disconnect", func(n int) (int, error) { return DisconnectBlocks(disconnect[:n], false) }},
+	} {
+		if n, err := batch.call(2); n != 2 || err != nil {
+			t.Errorf("%s: valid batch: have %d, %v, want 2 blocks", batch.name, n, err)
+		}
+		if n, err := batch.call(0); n != 0 || err != nil {
+			t.Errorf("%s: empty batch: have %d, %v, want no blocks", batch.name, n, err)
+		}
+		n, err := batch.call(len(connect))
+		if n != 2 {
+			t.Errorf("%s: applied blocks mismatch: have %d, want 2", batch.name, n)
+		}
+		var batchErr *BatchBlockError
+		if !errors.As(err, &batchErr) || batchErr.Index != 2 {
+			t.Fatalf("%s: error mismatch: have %v, want failure of block 2", batch.name, err)
+		}
+		var blockErr *BlockError
+		if !errors.As(err, &blockErr) || blockErr.Kind != DepositMismatch {
+			t.Errorf("%s: block error mismatch: have %v, want %v", batch.name, err, DepositMismatch)
+		}
+	}
+}
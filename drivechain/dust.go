@@ -0,0 +1,85 @@
+package drivechain
+
+/*
+#include "./bindings.h"
+*/
+import "C"
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/params"
+)
+
+const (
+	// DefaultMinDepositSats is the smallest deposit in satoshis CreateDeposit
+	// makes when Config.MinDepositSats isn't set.
+	DefaultMinDepositSats = 10_000
+
+	// DefaultMinWithdrawalSats is the smallest withdrawal in satoshis the
+	// engine bundles when Config.MinWithdrawalSats isn't set, the same as the
+	// default of the chain config.
+	DefaultMinWithdrawalSats = params.DefaultMinWithdrawalSats
+)
+
+// The dust thresholds of the engine started by Init, accessed atomically as
+// they're read by the validation functions of CreateDeposit and
+// CreateWithdrawal, which don't take engineMu.
+var (
+	minDepositSats    uint64 = DefaultMinDepositSats
+	minWithdrawalSats uint64 = DefaultMinWithdrawalSats
+)
+
+// WithDustThresholds makes Init refuse deposits below minDeposit satoshis and
+// withdrawals below minWithdrawal satoshis, e.g. 1 on regtest.
+func WithDustThresholds(minDeposit, minWithdrawal uint64) Option {
+	return func(cfg *Config) {
+		cfg.MinDepositSats = minDeposit
+		cfg.MinWithdrawalSats = minWithdrawal
+	}
+}
+
+func (c *Config) minDepositSats() uint64 {
+	if c.MinDepositSats == 0 {
+		return DefaultMinDepositSats
+	}
+	return c.MinDepositSats
+}
+
+func (c *Config) minWithdrawalSats() uint64 {
+	if c.MinWithdrawalSats == 0 {
+		return DefaultMinWithdrawalSats
+	}
+	return c.MinWithdrawalSats
+}
+
+// setDustThresholds makes the engine leave withdrawals below the thresholds
+// of cfg out of the bundles it assembles, and the validation functions of the
+// package apply them. The caller must hold engineMu.
+func setDustThresholds(cfg *Config) error {
+	if !bool(C.set_dust_thresholds(C.uint64_t(cfg.minDepositSats()), C.uint64_t(cfg.minWithdrawalSats()))) {
+		if msg := getLastError(); msg != "" {
+			return fmt.Errorf("failed to set dust thresholds of the engine: %s", msg)
+		}
+		return errors.New("failed to set dust thresholds of the engine")
+	}
+	atomic.StoreUint64(&minDepositSats, cfg.minDepositSats())
+	atomic.StoreUint64(&minWithdrawalSats, cfg.minWithdrawalSats())
+	return nil
+}
+
+// resetDustThresholds goes back to the default thresholds once the engine is
+// shut down.
+func resetDustThresholds() {
+	atomic.StoreUint64(&minDepositSats, DefaultMinDepositSats)
+	atomic.StoreUint64(&minWithdrawalSats, DefaultMinWithdrawalSats)
+}
+
+// checkDust returns ErrBelowDustThreshold if amount satoshis are below min.
+func checkDust(what string, amount, min uint64) error {
+	if amount < min {
+		return fmt.Errorf("%w: %s of %d satoshis, minimum %d", ErrBelowDustThreshold, what, amount, min)
+	}
+	return nil
+}
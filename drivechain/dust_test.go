@@ -0,0 +1,105 @@
+package drivechain
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// checkDustThresholds checks deposits and withdrawals about to be made exactly
+// at, below and above the given thresholds.
+func checkDustThresholds(t *testing.T, minDeposit, minWithdrawal uint64) {
+	t.Helper()
+	tests := []struct {
+		name  string
+		delta int64
+		err   error
+	}{
+		{"below", -1, ErrBelowDustThreshold},
+		{"at", 0, nil},
+		{"above", 1, nil},
+	}
+	for _, tt := range tests {
+		deposit := uint64(int64(minDeposit) + tt.delta)
+		withdrawal := uint64(int64(minWithdrawal) + tt.delta)
+		wantDeposit, wantWithdrawal := tt.err, tt.err
+		// A zero amount is reported as such rather than as dust.
+		if deposit == 0 {
+			wantDeposit = ErrZeroDepositAmount
+		}
+		if withdrawal == 0 {
+			wantWithdrawal = ErrZeroWithdrawalAmount
+		}
+		if err := ValidateDeposit(deposit, 1); !errors.Is(err, wantDeposit) {
+			t.Errorf("deposit %s threshold: error mismatch: have %v, want %v", tt.name, err, wantDeposit)
+		}
+		if err := validateWithdrawal(new(big.Int).SetUint64(withdrawal), 1); !errors.Is(err, wantWithdrawal) {
+			t.Errorf("withdrawal %s threshold: error mismatch: have %v, want %v", tt.name, err, wantWithdrawal)
+		}
+	}
+}
+
+func TestDustThresholds(t *testing.T) {
+	checkDustThresholds(t, DefaultMinDepositSats, DefaultMinWithdrawalSats)
+
+	srv := httptest.NewServer(http.HandlerFunc(testMainchainHandler))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	port, _ := strconv.Atoi(u.Port())
+	cfg := Config{DBPath: t.TempDir(), Host: u.Hostname(), Port: uint16(port), RPCUser: "user", RPCPassword: "password"}
+	WithDustThresholds(1, 2)(&cfg)
+	if err := InitWithContext(context.Background(), cfg); err != nil {
+		t.Fatalf("failed to initialize: %v", err)
+	}
+	checkDustThresholds(t, 1, 2)
+
+	// The engine's thresholds don't change which withdrawals are valid.
+	address, _ := MainchainAddressFromBytes(common.Address{1}.Bytes())
+	data := encodeUnversionedWithdrawalData(1, address)
+	if _, err := DecodeWithdrawal(new(big.Int).Mul(big.NewInt(2), Satoshi), data, WithdrawalRules{Strict: true, MinWithdrawalSats: 3}); !errors.Is(err, ErrBelowDustThreshold) {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrBelowDustThreshold)
+	}
+
+	// The defaults apply again once the engine is shut down.
+	Shutdown()
+	checkDustThresholds(t, DefaultMinDepositSats, DefaultMinWithdrawalSats)
+}
+
+func TestMinWithdrawalRules(t *testing.T) {
+	config := *params.MainnetChainConfig
+	config.StrictWithdrawalBlock, config.MinWithdrawalBlock, config.MinWithdrawalSats = big.NewInt(5), big.NewInt(10), 500
+	address, _ := MainchainAddressFromBytes(common.Address{1}.Bytes())
+	data := encodeUnversionedWithdrawalData(1, address)
+
+	tests := []struct {
+		number int64
+		sats   int64
+		err    error
+	}{
+		// Before the fork dust is valid, also in strict mode.
+		{number: 1, sats: 1},
+		{number: 9, sats: 1},
+		{number: 10, sats: 499, err: ErrBelowDustThreshold},
+		{number: 10, sats: 500},
+	}
+	for _, tt := range tests {
+		rules := WithdrawalRulesAt(&config, big.NewInt(tt.number))
+		value := new(big.Int).Mul(big.NewInt(tt.sats), Satoshi)
+		if _, err := DecodeWithdrawal(value, data, rules); !errors.Is(err, tt.err) {
+			t.Errorf("block %d, %d satoshis: error mismatch: have %v, want %v", tt.number, tt.sats, err, tt.err)
+		}
+	}
+	// Without an amount set the default applies.
+	config.MinWithdrawalSats = 0
+	if have := WithdrawalRulesAt(&config, big.NewInt(10)).MinWithdrawalSats; have != params.DefaultMinWithdrawalSats {
+		t.Errorf("default minimum mismatch: have %d, want %d", have, params.DefaultMinWithdrawalSats)
+	}
+}
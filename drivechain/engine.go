@@ -0,0 +1,151 @@
+package drivechain
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Drivechain is the API of the drivechain engine used by the sidechain node.
+// CGO implements it with the engine started by Init. Tests of code using the
+// engine can swap in another implementation, like the in-memory one in the
+// fakes package, with SetDefault.
+type Drivechain interface {
+	Ping(ctx context.Context) error
+	GetMainchainTip() (common.Hash, error)
+
+	GetDepositOutputs() ([]Deposit, error)
+	CreateDeposit(address common.Address, amount uint64, fee uint64) (common.Hash, error)
+	ConnectBlock(sideBlockHash common.Hash, deposits []Deposit, withdrawals map[common.Hash]Withdrawal, refunds []Refund, justChecking bool) error
+	DisconnectBlock(sideBlockHash common.Hash, deposits []Deposit, withdrawals []common.Hash, refunds []common.Hash, justChecking bool) error
+	GetBlockSidechainActivity(sideBlockHash common.Hash) (BlockActivity, error)
+
+	GetWithdrawalData(fee uint64) ([]byte, error)
+	GetUnspentWithdrawals(ctx context.Context) (map[common.Hash]WithdrawalInfo, error)
+	GetWithdrawalStatusInfo(id common.Hash) (WithdrawalStatusInfo, error)
+	IsWithdrawalSpent(id common.Hash) (bool, error)
+	AttemptBundleBroadcast(ctx context.Context) (BroadcastResult, error)
+	FormatMainchainAddress(dest MainchainAddress) (string, error)
+
+	AttemptBmm(ctx context.Context, header *types.Header, amount uint64) (common.Hash, error)
+	ConfirmBmm(ctx context.Context) (BmmState, common.Hash, error)
+	CancelBmm() error
+	VerifyBmm(prevMainBlockHash common.Hash, criticalHash common.Hash) (bool, error)
+}
+
+// CGO is the Drivechain backed by the engine of the C library, which is
+// started with Init and stopped with Shutdown. Its methods fail with
+// ErrNotInitialized while the engine isn't running.
+type CGO struct{}
+
+var _ Drivechain = CGO{}
+
+var (
+	defaultMu         sync.RWMutex
+	defaultDrivechain Drivechain = CGO{}
+)
+
+// Default returns the Drivechain used by the package level functions.
+func Default() Drivechain {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultDrivechain
+}
+
+// SetDefault makes the package level functions use d, returning the
+// Drivechain used before. It is meant for tests, production code uses CGO.
+func SetDefault(d Drivechain) Drivechain {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	prev := defaultDrivechain
+	defaultDrivechain = d
+	return prev
+}
+
+// Ping is a wrapper around Default().Ping.
+func Ping(ctx context.Context) error {
+	return Default().Ping(ctx)
+}
+
+// GetMainchainTip is a wrapper around Default().GetMainchainTip.
+func GetMainchainTip() (common.Hash, error) {
+	return Default().GetMainchainTip()
+}
+
+// GetDepositOutputs is a wrapper around Default().GetDepositOutputs.
+func GetDepositOutputs() ([]Deposit, error) {
+	return Default().GetDepositOutputs()
+}
+
+// CreateDeposit is a wrapper around Default().CreateDeposit.
+func CreateDeposit(address common.Address, amount uint64, fee uint64) (common.Hash, error) {
+	return Default().CreateDeposit(address, amount, fee)
+}
+
+// ConnectBlock is a wrapper around Default().ConnectBlock.
+func ConnectBlock(sideBlockHash common.Hash, deposits []Deposit, withdrawals map[common.Hash]Withdrawal, refunds []Refund, just_checking bool) error {
+	return Default().ConnectBlock(sideBlockHash, deposits, withdrawals, refunds, just_checking)
+}
+
+// DisconnectBlock is a wrapper around Default().DisconnectBlock.
+func DisconnectBlock(sideBlockHash common.Hash, deposits []Deposit, withdrawals []common.Hash, refunds []common.Hash, just_checking bool) error {
+	return Default().DisconnectBlock(sideBlockHash, deposits, withdrawals, refunds, just_checking)
+}
+
+// GetBlockSidechainActivity is a wrapper around
+// Default().GetBlockSidechainActivity.
+func GetBlockSidechainActivity(sideBlockHash common.Hash) (BlockActivity, error) {
+	return Default().GetBlockSidechainActivity(sideBlockHash)
+}
+
+// GetWithdrawalData is a wrapper around Default().GetWithdrawalData.
+func GetWithdrawalData(fee uint64) ([]byte, error) {
+	return Default().GetWithdrawalData(fee)
+}
+
+// GetUnspentWithdrawals is a wrapper around Default().GetUnspentWithdrawals.
+func GetUnspentWithdrawals(ctx context.Context) (map[common.Hash]WithdrawalInfo, error) {
+	return Default().GetUnspentWithdrawals(ctx)
+}
+
+// GetWithdrawalStatusInfo is a wrapper around Default().GetWithdrawalStatusInfo.
+func GetWithdrawalStatusInfo(id common.Hash) (WithdrawalStatusInfo, error) {
+	return Default().GetWithdrawalStatusInfo(id)
+}
+
+// IsWithdrawalSpent is a wrapper around Default().IsWithdrawalSpent.
+func IsWithdrawalSpent(id common.Hash) (bool, error) {
+	return Default().IsWithdrawalSpent(id)
+}
+
+// AttemptBundleBroadcast is a wrapper around Default().AttemptBundleBroadcast.
+func AttemptBundleBroadcast(ctx context.Context) (BroadcastResult, error) {
+	return Default().AttemptBundleBroadcast(ctx)
+}
+
+// FormatMainchainAddress is a wrapper around Default().FormatMainchainAddress.
+func FormatMainchainAddress(dest MainchainAddress) (string, error) {
+	return Default().FormatMainchainAddress(dest)
+}
+
+// AttemptBmm is a wrapper around Default().AttemptBmm.
+func AttemptBmm(ctx context.Context, header *types.Header, amount uint64) (common.Hash, error) {
+	return Default().AttemptBmm(ctx, header, amount)
+}
+
+// ConfirmBmm is a wrapper around Default().ConfirmBmm.
+func ConfirmBmm(ctx context.Context) (BmmState, common.Hash, error) {
+	return Default().ConfirmBmm(ctx)
+}
+
+// CancelBmm is a wrapper around Default().CancelBmm.
+func CancelBmm() error {
+	return Default().CancelBmm()
+}
+
+// VerifyBmm is a wrapper around Default().VerifyBmm.
+func VerifyBmm(prevMainBlockHash common.Hash, criticalHash common.Hash) (bool, error) {
+	return Default().VerifyBmm(prevMainBlockHash, criticalHash)
+}
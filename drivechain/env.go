@@ -0,0 +1,36 @@
+package drivechain
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// Environment variables read by InitFromEnv.
+const (
+	EnvDBPath      = "DRIVECHAIN_DB_PATH"
+	EnvHost        = "DRIVECHAIN_HOST"
+	EnvPort        = "DRIVECHAIN_PORT"
+	EnvRPCUser     = "DRIVECHAIN_RPC_USER"
+	EnvRPCPassword = "DRIVECHAIN_RPC_PASSWORD"
+)
+
+// InitFromEnv is like Init, but takes its arguments from the environment
+// variables EnvDBPath, EnvHost, EnvPort, EnvRPCUser and EnvRPCPassword, for
+// deployments passing their configuration as container environment or
+// secrets. All of them are required.
+func InitFromEnv(opts ...Option) error {
+	var values [5]string
+	for i, name := range []string{EnvDBPath, EnvHost, EnvPort, EnvRPCUser, EnvRPCPassword} {
+		value, ok := os.LookupEnv(name)
+		if !ok || value == "" {
+			return fmt.Errorf("missing environment variable %s", name)
+		}
+		values[i] = value
+	}
+	port, err := strconv.ParseUint(values[2], 10, 16)
+	if err != nil || port == 0 {
+		return fmt.Errorf("invalid environment variable %s=%q, must be a port number", EnvPort, values[2])
+	}
+	return Init(values[0], values[1], uint16(port), values[3], values[4], opts...)
+}
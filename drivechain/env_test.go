@@ -0,0 +1,53 @@
+package drivechain
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestInitFromEnv(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(testMainchainHandler))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+
+	env := map[string]string{
+		EnvDBPath:      t.TempDir(),
+		EnvHost:        u.Hostname(),
+		EnvPort:        u.Port(),
+		EnvRPCUser:     "user",
+		EnvRPCPassword: "password",
+	}
+	// Every variable is required, and named when missing.
+	for missing := range env {
+		for name, value := range env {
+			if name == missing {
+				value = ""
+			}
+			t.Setenv(name, value)
+		}
+		err := InitFromEnv()
+		if err == nil || !strings.Contains(err.Error(), missing) {
+			t.Errorf("error without %s mismatch: have %v", missing, err)
+		}
+	}
+	for name, value := range env {
+		t.Setenv(name, value)
+	}
+	for _, port := range []string{"0", "65536", "port"} {
+		t.Setenv(EnvPort, port)
+		if err := InitFromEnv(); err == nil || !strings.Contains(err.Error(), EnvPort) {
+			t.Errorf("port %s: error mismatch: have %v", port, err)
+		}
+	}
+	t.Setenv(EnvPort, u.Port())
+	if err := InitFromEnv(); err != nil {
+		t.Fatalf("failed to initialize: %v", err)
+	}
+	defer Shutdown()
+	if !IsInitialized() {
+		t.Error("engine not reported as initialized")
+	}
+}
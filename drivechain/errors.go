@@ -0,0 +1,204 @@
+package drivechain
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrNotInitialized is returned when the engine is used before Init or
+	// after Shutdown.
+	ErrNotInitialized = errors.New("drivechain engine not initialized")
+	// ErrAlreadyInitialized is returned by Init if the engine is running.
+	ErrAlreadyInitialized = errors.New("drivechain engine already initialized")
+	// ErrSidechainInUse is returned by Init and NewClient if another engine
+	// of the process is running for the same sidechain number.
+	ErrSidechainInUse = errors.New("sidechain number already in use")
+
+	// ErrDepositMismatch is returned when the deposits paid out by a block don't
+	// match the deposit outputs known to the engine.
+	ErrDepositMismatch = errors.New("deposits don't match mainchain deposit outputs")
+	// ErrInvalidWithdrawal is returned when a block contains withdrawal data
+	// rejected by the engine.
+	ErrInvalidWithdrawal = errors.New("invalid withdrawal data")
+	// ErrWithdrawalSpent is returned when a block refunds or disconnects a
+	// withdrawal that was already paid out on mainchain.
+	ErrWithdrawalSpent = errors.New("withdrawal already spent")
+	// ErrRefundNotFound is returned when a block refunds a withdrawal the engine
+	// doesn't know about.
+	ErrRefundNotFound = errors.New("refunded withdrawal not found")
+	// ErrEngineFailure is returned when the engine itself failed, as opposed
+	// to the block being invalid.
+	ErrEngineFailure = errors.New("drivechain engine failure")
+
+	// ErrMainchainUnreachable is returned when the engine can't get data it
+	// needs from the mainchain node.
+	ErrMainchainUnreachable = errors.New("mainchain unreachable")
+
+	// ErrMainchainBlockNotFound is returned by GetMainchainBlock if the
+	// mainchain node doesn't know the block.
+	ErrMainchainBlockNotFound = errors.New("mainchain block not found")
+
+	// ErrMainchainTxNotFound is returned by GetMainchainConfirmations if the
+	// mainchain wallet doesn't know the transaction.
+	ErrMainchainTxNotFound = errors.New("mainchain transaction not found")
+
+	// ErrInvalidDepositAddress is returned by ParseDepositAddress for
+	// malformed deposit addresses.
+	ErrInvalidDepositAddress = errors.New("invalid deposit address")
+
+	// ErrBmmInsufficientFunds is returned by AttemptBmm when the mainchain
+	// wallet can't pay the BMM bribe.
+	ErrBmmInsufficientFunds = errors.New("insufficient mainchain funds for bmm")
+	// ErrBmmNoFunds is returned by AttemptBmm when the mainchain wallet has
+	// no spendable outputs to fund the BMM request at all. It matches
+	// ErrBmmInsufficientFunds with errors.Is.
+	ErrBmmNoFunds = fmt.Errorf("%w: no spendable outputs", ErrBmmInsufficientFunds)
+	// ErrBmmAlreadyPending is returned by AttemptBmm when an earlier BMM
+	// request is still pending.
+	ErrBmmAlreadyPending = errors.New("bmm request already pending")
+	// ErrNoPrevMainBlockHash is returned by AttemptBmm for headers without
+	// a PrevMainBlockHash, which mainchain would never accept.
+	ErrNoPrevMainBlockHash = errors.New("header has no previous mainchain block hash")
+	// ErrNoBmmPending is returned by WaitForBmm when there is no BMM attempt
+	// to wait for.
+	ErrNoBmmPending = errors.New("no bmm request pending")
+
+	// ErrFeeEstimateUnavailable is returned by EstimateWithdrawalFee if the
+	// mainchain node has no fee estimate, e.g. because it's still syncing.
+	ErrFeeEstimateUnavailable = errors.New("mainchain fee estimate unavailable")
+
+	// ErrUnknownDeposit is returned by VerifyDeposit when the engine doesn't
+	// know about the deposit.
+	ErrUnknownDeposit = errors.New("unknown deposit")
+
+	// ErrUnknownWithdrawal is returned when the engine doesn't know about the
+	// requested withdrawal.
+	ErrUnknownWithdrawal = errors.New("unknown withdrawal")
+
+	// ErrNoBundle is returned by GetCurrentBundle and GetPendingBundle if
+	// there is no withdrawal bundle.
+	ErrNoBundle = errors.New("no withdrawal bundle")
+
+	// Errors returned by WeiToSatoshi.
+	ErrFractionalSatoshi = errors.New("amount not a whole number of satoshis")
+	ErrSatoshiRange      = errors.New("amount out of satoshi range")
+
+	// Errors returned by ValidateWithdrawalData.
+	ErrWithdrawalDataLength = errors.New("wrong withdrawal data length")
+	ErrZeroWithdrawalFee    = errors.New("zero withdrawal fee")
+	ErrZeroMainchainAddress = errors.New("zero mainchain address")
+
+	// ErrUnknownWithdrawalVersion is returned by ValidateWithdrawalData and
+	// DecodeWithdrawal for versioned withdrawal data of a version this
+	// package doesn't know, e.g. written by a newer node.
+	ErrUnknownWithdrawalVersion = errors.New("unknown withdrawal data version")
+
+	// ErrWithdrawalAddressInactive is returned by GetWithdrawalData and
+	// CreateWithdrawal when the engine's wallet hands out a mainchain address
+	// of a type the chain doesn't accept in withdrawals yet, see
+	// params.ChainConfig.TaggedWithdrawalBlock.
+	ErrWithdrawalAddressInactive = errors.New("mainchain address type not active for withdrawals")
+
+	// Errors returned by DecodeWithdrawal in strict mode.
+	ErrWithdrawalAmountFraction   = errors.New("withdrawal amount not a whole number of satoshis")
+	ErrZeroWithdrawalAmount       = errors.New("zero withdrawal amount")
+	ErrBelowDustThreshold         = errors.New("amount below dust threshold")
+	ErrWithdrawalFeeOverflow      = errors.New("withdrawal fee overflows int64")
+	ErrWithdrawalFeeExceedsAmount = errors.New("withdrawal fee exceeds amount")
+
+	// Errors returned by ValidateDeposit.
+	ErrZeroDepositAmount       = errors.New("zero deposit amount")
+	ErrZeroDepositFee          = errors.New("zero deposit fee")
+	ErrDepositFeeExceedsAmount = errors.New("deposit fee exceeds amount")
+
+	// Errors returned by CreateDeposit when the mainchain wallet refuses to
+	// make the deposit.
+	ErrDepositInsufficientFunds = errors.New("insufficient mainchain funds for deposit")
+	ErrDepositFeeTooLow         = errors.New("deposit fee too low")
+	ErrMainchainWalletLocked    = errors.New("mainchain wallet locked")
+
+	// Errors returned by ImportState.
+	ErrInvalidStateSnapshot    = errors.New("invalid state snapshot")
+	ErrUnsupportedStateVersion = errors.New("unsupported state snapshot version")
+
+	// ErrInvalidHeightRange is returned by ReplayDeposits if the range of
+	// mainchain heights is empty.
+	ErrInvalidHeightRange = errors.New("invalid mainchain height range")
+
+	// ErrInvalidPageLimit is returned by GetSpentWithdrawalsPage if the limit
+	// isn't positive, and by GetBmmHistory if it's negative.
+	ErrInvalidPageLimit = errors.New("invalid page limit")
+
+	// Errors returned by DecodeRefund.
+	ErrRefundDataLength = errors.New("wrong refund data length")
+	ErrZeroRefundId     = errors.New("zero refund id")
+)
+
+// ErrorKind classifies why the engine refused to connect or disconnect a block.
+type ErrorKind uint32
+
+const (
+	DepositMismatch ErrorKind = iota + 1
+	InvalidWithdrawal
+	WithdrawalSpent
+	RefundNotFound
+	EngineFailure
+)
+
+func (k ErrorKind) err() error {
+	switch k {
+	case DepositMismatch:
+		return ErrDepositMismatch
+	case InvalidWithdrawal:
+		return ErrInvalidWithdrawal
+	case WithdrawalSpent:
+		return ErrWithdrawalSpent
+	case RefundNotFound:
+		return ErrRefundNotFound
+	default:
+		return ErrEngineFailure
+	}
+}
+
+// String implements fmt.Stringer.
+func (k ErrorKind) String() string {
+	return k.err().Error()
+}
+
+// BlockError is returned by ConnectBlock and DisconnectBlock. It unwraps to the
+// sentinel error matching its Kind, so callers can use errors.Is.
+type BlockError struct {
+	Kind ErrorKind
+	Msg  string // Message reported by the engine, may be empty
+}
+
+// Error implements error.
+func (e *BlockError) Error() string {
+	if e.Msg == "" {
+		return e.Kind.String()
+	}
+	return fmt.Sprintf("%v: %s", e.Kind, e.Msg)
+}
+
+// Unwrap returns the sentinel error of the error kind.
+func (e *BlockError) Unwrap() error {
+	return e.Kind.err()
+}
+
+// HealthError is returned by HealthCheck, naming the component that isn't
+// healthy.
+type HealthError struct {
+	Component string // "engine" or "mainchain"
+	Cause     error
+}
+
+// Error implements error.
+func (e *HealthError) Error() string {
+	return fmt.Sprintf("%s unhealthy: %v", e.Component, e.Cause)
+}
+
+// Unwrap returns the cause of the failed check.
+func (e *HealthError) Unwrap() error {
+	return e.Cause
+}
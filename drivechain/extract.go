@@ -0,0 +1,153 @@
+package drivechain
+
+import (
+	"errors"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// WithdrawalRules are the withdrawal rules in force in a block, selecting the
+// modes of DecodeWithdrawal.
+type WithdrawalRules struct {
+	Strict    bool // See params.ChainConfig.IsStrictWithdrawal
+	Tagged    bool // See params.ChainConfig.IsTaggedWithdrawal
+	Versioned bool // See params.ChainConfig.IsVersionedWithdrawal
+
+	// MinWithdrawalSats is the smallest valid withdrawal in satoshis, zero for
+	// no minimum. See params.ChainConfig.MinWithdrawalSatsAt.
+	MinWithdrawalSats uint64
+}
+
+// WithdrawalRulesAt returns the withdrawal rules of config for the block with
+// the given number.
+func WithdrawalRulesAt(config *params.ChainConfig, number *big.Int) WithdrawalRules {
+	return WithdrawalRules{
+		Strict:    config.IsStrictWithdrawal(number),
+		Tagged:    config.IsTaggedWithdrawal(number),
+		Versioned: config.IsVersionedWithdrawal(number),
+
+		MinWithdrawalSats: config.MinWithdrawalSatsAt(number),
+	}
+}
+
+// ChainHeadReader is the part of the sidechain read to find the withdrawal
+// rules of the next block. It's implemented by core.BlockChain.
+type ChainHeadReader interface {
+	Config() *params.ChainConfig
+	CurrentHeader() *types.Header
+}
+
+var (
+	chainMu sync.RWMutex
+	chain   ChainHeadReader // Set by SetChain, nil until then
+)
+
+// SetChain sets the chain whose head selects the withdrawal rules new
+// withdrawal data is built for, see CurrentWithdrawalRules. The node sets it
+// once its chain is loaded.
+func SetChain(c ChainHeadReader) {
+	chainMu.Lock()
+	defer chainMu.Unlock()
+	chain = c
+}
+
+// CurrentWithdrawalRules returns the withdrawal rules of the block following
+// the head of the chain set with SetChain, the earliest block a withdrawal
+// made now can be in. Without a chain no fork is taken as active, so that
+// only data valid under all rules is built.
+func CurrentWithdrawalRules() WithdrawalRules {
+	chainMu.RLock()
+	defer chainMu.RUnlock()
+	return nextWithdrawalRules(chain)
+}
+
+// nextWithdrawalRules returns the withdrawal rules of the block following the
+// head of c, or no rules if c is nil.
+func nextWithdrawalRules(c ChainHeadReader) WithdrawalRules {
+	if c == nil {
+		return WithdrawalRules{}
+	}
+	next := new(big.Int).Add(c.CurrentHeader().Number, common.Big1)
+	return WithdrawalRulesAt(c.Config(), next)
+}
+
+// ExtractWithdrawal returns the withdrawal made by tx, a transaction of a
+// block with the given rules, and its id, the transaction hash. Transactions
+// that aren't withdrawals, i.e. not sent to TreasuryAddress or refund
+// requests, give a nil withdrawal and no error. Withdrawals whose data
+// doesn't decode give the error of DecodeWithdrawal, the value they carry is
+// burned. The signer is used to check that tx is signed.
+func ExtractWithdrawal(tx *types.Transaction, signer types.Signer, rules WithdrawalRules) (common.Hash, *Withdrawal, error) {
+	if tx.To() == nil || !IsTreasury(*tx.To()) {
+		return common.Hash{}, nil, nil
+	}
+	from, err := types.Sender(signer, tx)
+	if err != nil {
+		return common.Hash{}, nil, err
+	}
+	if isRefundRequest(from, tx.Value(), tx.Data()) {
+		return common.Hash{}, nil, nil
+	}
+	withdrawal, err := DecodeWithdrawal(tx.Value(), tx.Data(), rules)
+	if err != nil {
+		return common.Hash{}, nil, err
+	}
+	return tx.Hash(), &withdrawal, nil
+}
+
+// ValidateWithdrawal checks a transaction sent by from to the address to,
+// carrying value and data, in a block with the rules. Under strict rules a
+// withdrawal whose value or data fails DecodeWithdrawal is invalid, so that
+// it's rejected instead of the value being burned. Data of an unknown version
+// is still accepted and burned, leaving room for later formats. Under lenient
+// rules only withdrawals below r.MinWithdrawalSats are invalid. Transactions
+// that aren't withdrawals are valid.
+func (r WithdrawalRules) ValidateWithdrawal(from common.Address, to *common.Address, value *big.Int, data []byte) error {
+	if to == nil || !IsTreasury(*to) || isRefundRequest(from, value, data) {
+		return nil
+	}
+	_, err := DecodeWithdrawal(value, data, r)
+	switch {
+	case err == nil || errors.Is(err, ErrUnknownWithdrawalVersion):
+		return nil
+	case r.Strict || errors.Is(err, ErrBelowDustThreshold):
+		return err
+	default:
+		return nil
+	}
+}
+
+// ExtractRefund returns the refund requested by tx: a transaction to
+// TreasuryAddress not sent by the treasury, without value and with the id of
+// the refunded withdrawal as data, see GetRefundData. Other transactions give
+// a nil refund and no error. The refund's Amount is left nil, it's the value
+// of the withdrawal transaction, which only the caller can look up. Whether
+// the withdrawal can be refunded is checked by ConnectBlock.
+func ExtractRefund(tx *types.Transaction, signer types.Signer) (*Refund, error) {
+	if tx.To() == nil || !IsTreasury(*tx.To()) {
+		return nil, nil
+	}
+	from, err := types.Sender(signer, tx)
+	if err != nil {
+		return nil, err
+	}
+	if !isRefundRequest(from, tx.Value(), tx.Data()) {
+		return nil, nil
+	}
+	id := common.BytesToHash(tx.Data())
+	if id == (common.Hash{}) {
+		return nil, ErrZeroRefundId
+	}
+	return &Refund{Id: id}, nil
+}
+
+// isRefundRequest reports whether a transaction to the treasury sent by from
+// with value and data is shaped like a refund request. Refund request data
+// never decodes as a withdrawal.
+func isRefundRequest(from common.Address, value *big.Int, data []byte) bool {
+	return !IsTreasury(from) && len(data) == RefundDataLength && value.Sign() == 0
+}
@@ -0,0 +1,181 @@
+package drivechain
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestExtractWithdrawalAndRefund(t *testing.T) {
+	signer := types.LatestSignerForChainID(big.NewInt(1))
+	userKey, _ := crypto.GenerateKey()
+	treasuryKey := TreasuryKey()
+	treasury, other := TreasuryAddress(), common.HexToAddress("0x01")
+	// sign returns a transaction sending sats satoshis, signed with key
+	// unless it's nil.
+	sign := func(key *ecdsa.PrivateKey, to *common.Address, sats int64, data []byte) *types.Transaction {
+		tx := types.NewTx(&types.LegacyTx{To: to, Value: new(big.Int).Mul(big.NewInt(sats), Satoshi), Gas: WithdrawalGas, Data: data})
+		if key == nil {
+			return tx
+		}
+		signed, err := types.SignTx(tx, signer, key)
+		if err != nil {
+			t.Fatalf("failed to sign: %v", err)
+		}
+		return signed
+	}
+	rules := WithdrawalRules{Strict: true, Tagged: true, Versioned: true, MinWithdrawalSats: DefaultMinWithdrawalSats}
+	address, _ := NewMainchainAddress(P2PKH, common.FromHex("62e907b15cbf27d5425399ebf6f0fb50ebb88f18"))
+	id := common.HexToHash("0xfeed")
+
+	tests := []struct {
+		name          string
+		tx            *types.Transaction
+		withdrawal    bool  // Whether the tx is a withdrawal
+		withdrawalErr error // Error of ExtractWithdrawal
+		refund        bool  // Whether the tx is a refund request
+		refundErr     error // Error of ExtractRefund
+	}{
+		{name: "transfer", tx: sign(userKey, &other, 10000, nil)},
+		{name: "contract creation", tx: sign(userKey, nil, 0, encodeVersionedWithdrawalData(10, address))},
+		{name: "withdrawal", tx: sign(userKey, &treasury, 10000, encodeVersionedWithdrawalData(10, address)), withdrawal: true},
+		{name: "withdrawal below dust", tx: sign(userKey, &treasury, 100, encodeVersionedWithdrawalData(10, address)), withdrawalErr: ErrBelowDustThreshold},
+		{name: "withdrawal with bad data", tx: sign(userKey, &treasury, 10000, []byte{1, 2, 3}), withdrawalErr: ErrWithdrawalDataLength},
+		{name: "refund request", tx: sign(userKey, &treasury, 0, GetRefundData(id)), refund: true},
+		{name: "refund of zero id", tx: sign(userKey, &treasury, 0, GetRefundData(common.Hash{})), refundErr: ErrZeroRefundId},
+		// With value the data is taken as a withdrawal's, which it can't be.
+		{name: "refund with value", tx: sign(userKey, &treasury, 10000, GetRefundData(id)), withdrawalErr: ErrWithdrawalDataLength},
+		// Payouts of the treasury are deposits or refunds, not requests.
+		{name: "refund from treasury", tx: sign(treasuryKey, &treasury, 0, GetRefundData(id)), withdrawalErr: ErrWithdrawalDataLength},
+		{name: "unsigned refund", tx: sign(nil, &treasury, 0, GetRefundData(id)), withdrawalErr: types.ErrInvalidSig, refundErr: types.ErrInvalidSig},
+	}
+	for _, tt := range tests {
+		wid, withdrawal, err := ExtractWithdrawal(tt.tx, signer, rules)
+		switch {
+		case !errors.Is(err, tt.withdrawalErr) || (tt.withdrawalErr != nil && withdrawal != nil):
+			t.Errorf("%s: withdrawal error mismatch: have %v, want %v", tt.name, err, tt.withdrawalErr)
+		case tt.withdrawal && (withdrawal == nil || wid != tt.tx.Hash() || withdrawal.Amount.Int64() != 10000 || withdrawal.Fee.Int64() != 10):
+			t.Errorf("%s: withdrawal mismatch: have %x %+v", tt.name, wid, withdrawal)
+		case !tt.withdrawal && withdrawal != nil:
+			t.Errorf("%s: unexpected withdrawal %+v", tt.name, withdrawal)
+		}
+		refund, err := ExtractRefund(tt.tx, signer)
+		switch {
+		case !errors.Is(err, tt.refundErr) || (tt.refundErr != nil && refund != nil):
+			t.Errorf("%s: refund error mismatch: have %v, want %v", tt.name, err, tt.refundErr)
+		case tt.refund && (refund == nil || refund.Id != id):
+			t.Errorf("%s: refund mismatch: have %+v, want id %x", tt.name, refund, id)
+		case !tt.refund && refund != nil:
+			t.Errorf("%s: unexpected refund %+v", tt.name, refund)
+		}
+	}
+}
+
+// testChain is a ChainHeadReader with a fixed head.
+type testChain struct {
+	config *params.ChainConfig
+	head   *types.Header
+}
+
+func (c testChain) Config() *params.ChainConfig  { return c.config }
+func (c testChain) CurrentHeader() *types.Header { return c.head }
+
+// setTestChain sets a chain of config with its head at number until the test
+// is done.
+func setTestChain(t *testing.T, config *params.ChainConfig, number int64) {
+	SetChain(testChain{config: config, head: &types.Header{Number: big.NewInt(number)}})
+	t.Cleanup(func() { SetChain(nil) })
+}
+
+// Tests that withdrawal data is built in a format the chain accepts at its
+// head, so that the value sent along isn't burned.
+func TestValidateWithdrawal(t *testing.T) {
+	user, treasury, other := common.HexToAddress("0x02"), TreasuryAddress(), common.HexToAddress("0x01")
+	address, _ := NewMainchainAddress(P2PKH, common.FromHex("62e907b15cbf27d5425399ebf6f0fb50ebb88f18"))
+	data := encodeVersionedWithdrawalData(10, address)
+	sats := func(n int64) *big.Int { return new(big.Int).Mul(big.NewInt(n), Satoshi) }
+	strict := WithdrawalRules{Strict: true, Tagged: true, Versioned: true}
+	lenient := WithdrawalRules{Tagged: true, Versioned: true}
+
+	tests := []struct {
+		name  string
+		rules WithdrawalRules
+		to    *common.Address
+		value *big.Int
+		data  []byte
+		err   error
+	}{
+		{name: "exact multiple", rules: strict, to: &treasury, value: sats(10000), data: data},
+		{name: "one wei more", rules: strict, to: &treasury, value: new(big.Int).Add(sats(10000), common.Big1), data: data, err: ErrWithdrawalAmountFraction},
+		{name: "one wei less", rules: strict, to: &treasury, value: new(big.Int).Sub(sats(10000), common.Big1), data: data, err: ErrWithdrawalAmountFraction},
+		{name: "zero value", rules: strict, to: &treasury, value: new(big.Int), data: data, err: ErrZeroWithdrawalAmount},
+		{name: "bad data", rules: strict, to: &treasury, value: sats(10000), data: []byte{1, 2, 3}, err: ErrWithdrawalDataLength},
+		// Unknown versions are burned, not rejected.
+		{name: "unknown version", rules: strict, to: &treasury, value: sats(10000), data: []byte{0xff, 1, 2, 3}},
+		{name: "refund request", rules: strict, to: &treasury, value: new(big.Int), data: GetRefundData(common.HexToHash("0xfeed"))},
+		{name: "transfer", rules: strict, to: &other, value: big.NewInt(1)},
+		{name: "contract creation", rules: strict, value: new(big.Int), data: data},
+		{name: "lenient fraction", rules: lenient, to: &treasury, value: big.NewInt(1), data: data},
+		// The minimum applies in either mode once its fork is active.
+		{name: "lenient dust", rules: WithdrawalRules{MinWithdrawalSats: 10}, to: &treasury, value: sats(9), data: encodeUnversionedWithdrawalData(1, address), err: ErrBelowDustThreshold},
+		{name: "lenient bad data with minimum", rules: WithdrawalRules{MinWithdrawalSats: 10}, to: &treasury, value: sats(9), data: []byte{1, 2, 3}},
+	}
+	for _, tt := range tests {
+		if err := tt.rules.ValidateWithdrawal(user, tt.to, tt.value, tt.data); !errors.Is(err, tt.err) {
+			t.Errorf("%s: error mismatch: have %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestWithdrawalDataFollowsChain(t *testing.T) {
+	initTestEngine(t, nil)
+	signer := types.LatestSignerForChainID(big.NewInt(1))
+	key, _ := crypto.GenerateKey()
+	treasury := TreasuryAddress()
+
+	forked := *params.MainnetChainConfig
+	forked.TaggedWithdrawalBlock, forked.VersionedWithdrawalBlock = big.NewInt(10), big.NewInt(20)
+	tests := []struct {
+		name   string
+		config *params.ChainConfig
+		head   int64
+		length int
+	}{
+		{"mainnet", params.MainnetChainConfig, 100, legacyWithdrawalDataLength},
+		{"before tagged fork", &forked, 8, legacyWithdrawalDataLength},
+		{"before versioned fork", &forked, 18, legacyWithdrawalDataLength},
+		{"at versioned fork", &forked, 19, 1 + legacyWithdrawalDataLength + 1},
+	}
+	for _, tt := range tests {
+		setTestChain(t, tt.config, tt.head)
+		data, err := GetWithdrawalData(10)
+		if err != nil {
+			t.Fatalf("%s: failed to get withdrawal data: %v", tt.name, err)
+		}
+		if len(data) != tt.length {
+			t.Errorf("%s: data length mismatch: have %d, want %d", tt.name, len(data), tt.length)
+		}
+		tx, _ := types.SignTx(types.NewTransaction(0, treasury, new(big.Int).Mul(big.NewInt(10000), Satoshi), WithdrawalGas, nil, data), signer, key)
+		rules := WithdrawalRulesAt(tt.config, big.NewInt(tt.head+1))
+		if _, withdrawal, err := ExtractWithdrawal(tx, signer, rules); err != nil || withdrawal == nil {
+			t.Errorf("%s: withdrawal not extracted: %v", tt.name, err)
+		}
+	}
+
+	// Addresses other than P2PKH need the tagged format.
+	address, _ := NewMainchainAddress(P2WSH, make([]byte, MaxMainchainAddressLength))
+	if _, err := encodeWithdrawalData(10, address, WithdrawalRules{Strict: true}); !errors.Is(err, ErrWithdrawalAddressInactive) {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrWithdrawalAddressInactive)
+	}
+	data, err := encodeWithdrawalData(10, address, WithdrawalRules{Tagged: true})
+	if err != nil || !bytes.Equal(data, encodeUnversionedWithdrawalData(10, address)) {
+		t.Errorf("tagged encoding mismatch: have %x, %v", data, err)
+	}
+}
@@ -0,0 +1,367 @@
+// Package fakes provides an in-memory implementation of drivechain.Drivechain
+// for testing code that uses the drivechain engine without the C library or a
+// mainchain node.
+package fakes
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/drivechain"
+)
+
+var _ drivechain.Drivechain = (*Fake)(nil)
+
+// bmmCommitment is a sidechain block merge mined in a mainchain block.
+type bmmCommitment struct {
+	prevMainBlockHash common.Hash
+	criticalHash      common.Hash
+}
+
+// Fake is an in-memory drivechain.Drivechain. Deposit outputs and withdrawals
+// are kept in maps, and the outcome of BMM attempts is scripted with
+// ScriptBmm. The zero value isn't usable, create one with New. It is safe for
+// concurrent use.
+type Fake struct {
+	mu sync.Mutex
+
+	tip         common.Hash
+	deposits    drivechain.DepositSet // Deposit outputs on mainchain
+	withdrawals map[common.Hash]drivechain.WithdrawalInfo
+	refunds     map[common.Hash]bool // Refunded withdrawals
+	activity    map[common.Hash]drivechain.BlockActivity
+
+	bmmStates   []drivechain.BmmState // Scripted ConfirmBmm results
+	bmmAttempt  *bmmCommitment        // Pending BMM attempt
+	cancelled   bool                  // Whether the last attempt was cancelled
+	commitments map[bmmCommitment]bool
+	mainBlocks  uint64 // Number of mainchain blocks mined by ConfirmBmm
+
+	// PingErr is returned by Ping.
+	PingErr error
+	// BmmErr, if set, is returned by AttemptBmm instead of making an attempt.
+	BmmErr error
+	// Broadcast is the result of AttemptBundleBroadcast.
+	Broadcast drivechain.BroadcastResult
+	// BroadcastErr, if set, is returned by AttemptBundleBroadcast.
+	BroadcastErr error
+}
+
+// New creates an empty fake engine.
+func New() *Fake {
+	return &Fake{
+		deposits:    drivechain.NewDepositSet(),
+		withdrawals: make(map[common.Hash]drivechain.WithdrawalInfo),
+		refunds:     make(map[common.Hash]bool),
+		activity:    make(map[common.Hash]drivechain.BlockActivity),
+		commitments: make(map[bmmCommitment]bool),
+	}
+}
+
+// SetMainchainTip sets the hash returned by GetMainchainTip.
+func (f *Fake) SetMainchainTip(hash common.Hash) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tip = hash
+}
+
+// AddDeposit adds a mainchain deposit output, as if a deposit was made on
+// mainchain.
+func (f *Fake) AddDeposit(d drivechain.Deposit) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.deposits.Add(d)
+}
+
+// SetWithdrawalStatus changes the status of a withdrawal connected to the
+// fake, e.g. to WithdrawalStatusSpent once it was paid out.
+func (f *Fake) SetWithdrawalStatus(id common.Hash, status drivechain.WithdrawalStatus) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	info, ok := f.withdrawals[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", drivechain.ErrUnknownWithdrawal, id.Hex())
+	}
+	info.Status = status
+	f.withdrawals[id] = info
+	return nil
+}
+
+// ScriptBmm queues the states returned by the following ConfirmBmm calls.
+// Once the queue is empty ConfirmBmm reports Pending.
+func (f *Fake) ScriptBmm(states ...drivechain.BmmState) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.bmmStates = append(f.bmmStates, states...)
+}
+
+// AddBmmCommitment makes VerifyBmm accept the sidechain block criticalHash as
+// merge mined after the mainchain block prevMainBlockHash.
+func (f *Fake) AddBmmCommitment(prevMainBlockHash, criticalHash common.Hash) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.commitments[bmmCommitment{prevMainBlockHash, criticalHash}] = true
+}
+
+// Ping implements drivechain.Drivechain.
+func (f *Fake) Ping(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.PingErr
+}
+
+// GetMainchainTip implements drivechain.Drivechain.
+func (f *Fake) GetMainchainTip() (common.Hash, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.tip == (common.Hash{}) {
+		return common.Hash{}, drivechain.ErrMainchainUnreachable
+	}
+	return f.tip, nil
+}
+
+// GetDepositOutputs implements drivechain.Drivechain.
+func (f *Fake) GetDepositOutputs() ([]drivechain.Deposit, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.deposits.List(), nil
+}
+
+// CreateDeposit implements drivechain.Drivechain. The deposit output shows up
+// immediately, with a made up mainchain outpoint.
+func (f *Fake) CreateDeposit(address common.Address, amount uint64, fee uint64) (common.Hash, error) {
+	if err := drivechain.ValidateDeposit(amount, fee); err != nil {
+		return common.Hash{}, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	txid := crypto.Keccak256Hash(address[:], big.NewInt(int64(len(f.deposits))).Bytes())
+	f.deposits.Add(drivechain.Deposit{
+		Address:  address,
+		Amount:   new(big.Int).SetUint64(amount),
+		MainTxid: txid,
+	})
+	return txid, nil
+}
+
+// ConnectBlock implements drivechain.Drivechain. Deposits must match a
+// deposit output by address and amount, refunds must name a known withdrawal
+// that wasn't refunded yet. Unless sideBlockHash is zero the activity of the
+// block is recorded like the engine does.
+func (f *Fake) ConnectBlock(sideBlockHash common.Hash, deposits []drivechain.Deposit, withdrawals map[common.Hash]drivechain.Withdrawal, refunds []drivechain.Refund, justChecking bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, d := range deposits {
+		if !f.hasDepositOutput(d) {
+			return &drivechain.BlockError{Kind: drivechain.DepositMismatch, Msg: fmt.Sprintf("%v to %s", d.Amount, d.Address)}
+		}
+	}
+	for _, r := range refunds {
+		if _, ok := f.withdrawals[r.Id]; !ok || f.refunds[r.Id] {
+			return &drivechain.BlockError{Kind: drivechain.RefundNotFound, Msg: r.Id.Hex()}
+		}
+	}
+	if justChecking {
+		return nil
+	}
+	for id, w := range withdrawals {
+		// Like the engine, report amounts in wei.
+		f.withdrawals[id] = drivechain.WithdrawalInfo{
+			Withdrawal: drivechain.Withdrawal{
+				Address: w.Address,
+				Amount:  new(big.Int).Mul(w.Amount, drivechain.Satoshi),
+				Fee:     new(big.Int).Mul(w.Fee, drivechain.Satoshi),
+			},
+			Status: drivechain.WithdrawalStatusPending,
+		}
+	}
+	for _, r := range refunds {
+		f.refunds[r.Id] = true
+		info := f.withdrawals[r.Id]
+		info.Status = drivechain.WithdrawalStatusRefunded
+		f.withdrawals[r.Id] = info
+	}
+	if sideBlockHash != (common.Hash{}) {
+		activity := drivechain.BlockActivity{Deposits: deposits}
+		for id := range withdrawals {
+			activity.Withdrawals = append(activity.Withdrawals, id)
+		}
+		for _, r := range refunds {
+			activity.Refunds = append(activity.Refunds, r.Id)
+		}
+		f.activity[sideBlockHash] = activity
+	}
+	return nil
+}
+
+func (f *Fake) hasDepositOutput(d drivechain.Deposit) bool {
+	for _, output := range f.deposits {
+		if output.Address == d.Address && output.Amount.Cmp(d.Amount) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// DisconnectBlock implements drivechain.Drivechain, undoing ConnectBlock.
+func (f *Fake) DisconnectBlock(sideBlockHash common.Hash, deposits []drivechain.Deposit, withdrawals []common.Hash, refunds []common.Hash, justChecking bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if justChecking {
+		return nil
+	}
+	delete(f.activity, sideBlockHash)
+	for _, id := range withdrawals {
+		delete(f.withdrawals, id)
+	}
+	for _, id := range refunds {
+		delete(f.refunds, id)
+		if info, ok := f.withdrawals[id]; ok {
+			info.Status = drivechain.WithdrawalStatusFailed
+			f.withdrawals[id] = info
+		}
+	}
+	return nil
+}
+
+// GetBlockSidechainActivity implements drivechain.Drivechain.
+func (f *Fake) GetBlockSidechainActivity(sideBlockHash common.Hash) (drivechain.BlockActivity, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	activity, ok := f.activity[sideBlockHash]
+	if !ok {
+		return drivechain.BlockActivity{}, fmt.Errorf("%w: %s", drivechain.ErrNotIndexed, sideBlockHash.Hex())
+	}
+	return activity, nil
+}
+
+// GetWithdrawalData implements drivechain.Drivechain, withdrawing to a fixed
+// mainchain address.
+func (f *Fake) GetWithdrawalData(fee uint64) ([]byte, error) {
+	data := make([]byte, drivechain.FeeLength+drivechain.MainchainAddressLength)
+	binary.BigEndian.PutUint64(data, fee)
+	for i := drivechain.FeeLength; i < len(data); i++ {
+		data[i] = 0x01
+	}
+	return data, nil
+}
+
+// GetUnspentWithdrawals implements drivechain.Drivechain.
+func (f *Fake) GetUnspentWithdrawals(ctx context.Context) (map[common.Hash]drivechain.WithdrawalInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	unspent := make(map[common.Hash]drivechain.WithdrawalInfo)
+	for id, info := range f.withdrawals {
+		if info.Status != drivechain.WithdrawalStatusSpent && !f.refunds[id] {
+			unspent[id] = info
+		}
+	}
+	return unspent, nil
+}
+
+// GetWithdrawalStatusInfo implements drivechain.Drivechain.
+func (f *Fake) GetWithdrawalStatusInfo(id common.Hash) (drivechain.WithdrawalStatusInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	info, ok := f.withdrawals[id]
+	if !ok {
+		return drivechain.WithdrawalStatusInfo{}, fmt.Errorf("%w: %s", drivechain.ErrUnknownWithdrawal, id.Hex())
+	}
+	return drivechain.WithdrawalStatusInfo{Status: info.Status}, nil
+}
+
+// IsWithdrawalSpent implements drivechain.Drivechain. Withdrawals are spent
+// once paid out or refunded.
+func (f *Fake) IsWithdrawalSpent(id common.Hash) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.withdrawals[id].Status == drivechain.WithdrawalStatusSpent || f.refunds[id], nil
+}
+
+// AttemptBundleBroadcast implements drivechain.Drivechain.
+func (f *Fake) AttemptBundleBroadcast(ctx context.Context) (drivechain.BroadcastResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.BroadcastErr != nil {
+		return drivechain.BroadcastResult{}, f.BroadcastErr
+	}
+	return f.Broadcast, nil
+}
+
+// FormatMainchainAddress implements drivechain.Drivechain, formatting the
+// address as its type followed by the hex encoded payload.
+func (f *Fake) FormatMainchainAddress(dest drivechain.MainchainAddress) (string, error) {
+	return dest.Type.String() + ":" + common.Bytes2Hex(dest.Bytes()), nil
+}
+
+// AttemptBmm implements drivechain.Drivechain. The returned txid is derived
+// from the header.
+func (f *Fake) AttemptBmm(ctx context.Context, header *types.Header, amount uint64) (common.Hash, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.BmmErr != nil {
+		return common.Hash{}, f.BmmErr
+	}
+	if f.bmmAttempt != nil {
+		return common.Hash{}, drivechain.ErrBmmAlreadyPending
+	}
+	f.bmmAttempt = &bmmCommitment{header.PrevMainBlockHash, header.Hash()}
+	f.cancelled = false
+	return crypto.Keccak256Hash(header.Hash().Bytes()), nil
+}
+
+// ConfirmBmm implements drivechain.Drivechain, reporting the next scripted
+// state. A successful attempt is recorded for VerifyBmm and mined in a new
+// mainchain block, which becomes the tip.
+func (f *Fake) ConfirmBmm(ctx context.Context) (drivechain.BmmState, common.Hash, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.cancelled {
+		return drivechain.Cancelled, common.Hash{}, nil
+	}
+	if f.bmmAttempt == nil {
+		return drivechain.Idle, common.Hash{}, nil
+	}
+	if len(f.bmmStates) == 0 {
+		return drivechain.Pending, common.Hash{}, nil
+	}
+	state := f.bmmStates[0]
+	f.bmmStates = f.bmmStates[1:]
+	switch state {
+	case drivechain.Succeeded:
+		f.commitments[*f.bmmAttempt] = true
+		f.mainBlocks++
+		var number [8]byte
+		binary.BigEndian.PutUint64(number[:], f.mainBlocks)
+		f.tip = crypto.Keccak256Hash(f.bmmAttempt.prevMainBlockHash[:], number[:])
+		f.bmmAttempt = nil
+		return state, f.tip, nil
+	case drivechain.Failed:
+		f.bmmAttempt = nil
+	}
+	return state, common.Hash{}, nil
+}
+
+// CancelBmm implements drivechain.Drivechain.
+func (f *Fake) CancelBmm() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.bmmAttempt != nil {
+		f.bmmAttempt = nil
+		f.cancelled = true
+	}
+	return nil
+}
+
+// VerifyBmm implements drivechain.Drivechain.
+func (f *Fake) VerifyBmm(prevMainBlockHash common.Hash, criticalHash common.Hash) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.commitments[bmmCommitment{prevMainBlockHash, criticalHash}], nil
+}
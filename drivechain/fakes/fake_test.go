@@ -0,0 +1,207 @@
+package fakes
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/drivechain"
+)
+
+func TestFakeBlocks(t *testing.T) {
+	f := New()
+	deposit := drivechain.Deposit{Address: common.Address{1}, Amount: big.NewInt(1000)}
+	if err := f.ConnectBlock(common.Hash{}, []drivechain.Deposit{deposit}, nil, nil, true); !errors.Is(err, drivechain.ErrDepositMismatch) {
+		t.Fatalf("error mismatch: have %v, want %v", err, drivechain.ErrDepositMismatch)
+	}
+	f.AddDeposit(deposit)
+
+	id := common.Hash{2}
+	address, _ := drivechain.MainchainAddressFromBytes(common.Address{3}.Bytes())
+	withdrawals := map[common.Hash]drivechain.Withdrawal{
+		id: {Address: address, Amount: big.NewInt(500), Fee: big.NewInt(10)},
+	}
+	if err := f.ConnectBlock(common.Hash{}, []drivechain.Deposit{deposit}, withdrawals, nil, false); err != nil {
+		t.Fatalf("failed to connect block: %v", err)
+	}
+	unspent, _ := f.GetUnspentWithdrawals(context.Background())
+	if len(unspent) != 1 || unspent[id].Status != drivechain.WithdrawalStatusPending {
+		t.Fatalf("unexpected unspent withdrawals: %v", unspent)
+	}
+
+	refund := []drivechain.Refund{{Id: id, Amount: big.NewInt(500)}}
+	if err := f.ConnectBlock(common.Hash{}, nil, nil, refund, false); err != nil {
+		t.Fatalf("failed to connect refund: %v", err)
+	}
+	if spent, _ := f.IsWithdrawalSpent(id); !spent {
+		t.Error("refunded withdrawal not spent")
+	}
+	if err := f.ConnectBlock(common.Hash{}, nil, nil, refund, true); !errors.Is(err, drivechain.ErrRefundNotFound) {
+		t.Errorf("error mismatch: have %v, want %v", err, drivechain.ErrRefundNotFound)
+	}
+	if err := f.DisconnectBlock(common.Hash{}, nil, nil, []common.Hash{id}, false); err != nil {
+		t.Fatalf("failed to disconnect refund: %v", err)
+	}
+	if spent, _ := f.IsWithdrawalSpent(id); spent {
+		t.Error("withdrawal still spent after disconnecting its refund")
+	}
+}
+
+func TestFakeBlockActivity(t *testing.T) {
+	f := New()
+	deposit := drivechain.Deposit{Address: common.Address{1}, Amount: big.NewInt(1000)}
+	f.AddDeposit(deposit)
+	hash := common.Hash{1}
+	if err := f.ConnectBlock(hash, []drivechain.Deposit{deposit}, nil, nil, true); err != nil {
+		t.Fatalf("failed to check block: %v", err)
+	}
+	if _, err := f.GetBlockSidechainActivity(hash); !errors.Is(err, drivechain.ErrNotIndexed) {
+		t.Fatalf("checked block indexed: have %v, want %v", err, drivechain.ErrNotIndexed)
+	}
+	if err := f.ConnectBlock(hash, []drivechain.Deposit{deposit}, nil, nil, false); err != nil {
+		t.Fatalf("failed to connect block: %v", err)
+	}
+	activity, err := f.GetBlockSidechainActivity(hash)
+	if err != nil {
+		t.Fatalf("failed to get block activity: %v", err)
+	}
+	if len(activity.Deposits) != 1 || activity.Deposits[0].Address != deposit.Address {
+		t.Errorf("deposits mismatch: have %v, want %v", activity.Deposits, []drivechain.Deposit{deposit})
+	}
+	if err := f.DisconnectBlock(hash, []drivechain.Deposit{deposit}, nil, nil, false); err != nil {
+		t.Fatalf("failed to disconnect block: %v", err)
+	}
+	if _, err := f.GetBlockSidechainActivity(hash); !errors.Is(err, drivechain.ErrNotIndexed) {
+		t.Errorf("disconnected block indexed: have %v, want %v", err, drivechain.ErrNotIndexed)
+	}
+}
+
+func TestFakeBmm(t *testing.T) {
+	f := New()
+	ctx := context.Background()
+	header := &types.Header{Number: big.NewInt(1), PrevMainBlockHash: common.Hash{1}}
+
+	f.ScriptBmm(drivechain.Failed, drivechain.Succeeded)
+	if _, err := f.AttemptBmm(ctx, header, 1000); err != nil {
+		t.Fatalf("failed to attempt bmm: %v", err)
+	}
+	if _, err := f.AttemptBmm(ctx, header, 1000); !errors.Is(err, drivechain.ErrBmmAlreadyPending) {
+		t.Errorf("error mismatch: have %v, want %v", err, drivechain.ErrBmmAlreadyPending)
+	}
+	if state, _, _ := f.ConfirmBmm(ctx); state != drivechain.Failed {
+		t.Fatalf("state mismatch: have %v, want %v", state, drivechain.Failed)
+	}
+	f.AttemptBmm(ctx, header, 1000)
+	state, mainBlock, _ := f.ConfirmBmm(ctx)
+	if state != drivechain.Succeeded {
+		t.Fatalf("state mismatch: have %v, want %v", state, drivechain.Succeeded)
+	}
+	if tip, _ := f.GetMainchainTip(); tip != mainBlock {
+		t.Errorf("tip mismatch: have %x, want %x", tip, mainBlock)
+	}
+	if ok, _ := f.VerifyBmm(header.PrevMainBlockHash, header.Hash()); !ok {
+		t.Error("merge mined block not verified")
+	}
+
+	f.AttemptBmm(ctx, header, 1000)
+	f.CancelBmm()
+	if state, _, _ := f.ConfirmBmm(ctx); state != drivechain.Cancelled {
+		t.Errorf("state mismatch: have %v, want %v", state, drivechain.Cancelled)
+	}
+}
+
+// Tests that the package level functions of drivechain can be pointed at the
+// fake.
+func TestFakeAsDefault(t *testing.T) {
+	f := New()
+	f.SetMainchainTip(common.Hash{7})
+	prev := drivechain.SetDefault(f)
+	defer drivechain.SetDefault(prev)
+
+	if tip, err := drivechain.GetMainchainTip(); err != nil || tip != (common.Hash{7}) {
+		t.Errorf("tip mismatch: have %x (%v), want %x", tip, err, common.Hash{7})
+	}
+	if _, err := drivechain.CreateDeposit(common.Address{1}, 10000, 10); err != nil {
+		t.Fatalf("failed to create deposit: %v", err)
+	}
+	if deposits, _ := drivechain.GetDepositOutputs(); len(deposits) != 1 {
+		t.Errorf("deposit count mismatch: have %d, want 1", len(deposits))
+	}
+}
+
+func TestListenForBmmConfirmation(t *testing.T) {
+	f := New()
+	prev := drivechain.SetDefault(f)
+	defer drivechain.SetDefault(prev)
+	ctx := context.Background()
+	header := &types.Header{Number: big.NewInt(1), PrevMainBlockHash: common.Hash{1}}
+
+	f.ScriptBmm(drivechain.Pending, drivechain.Pending, drivechain.Succeeded)
+	f.AttemptBmm(ctx, header, 1000)
+	results, err := drivechain.ListenForBmmConfirmation(ctx, time.Millisecond)
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	result := <-results
+	if result.Err != nil || result.State != drivechain.Succeeded || result.MainBlockHash == (common.Hash{}) {
+		t.Errorf("result mismatch: have %+v, want success", result)
+	}
+	if _, ok := <-results; ok {
+		t.Error("results channel not closed")
+	}
+
+	// Nothing is scripted, so the attempt stays pending until ctx is done.
+	f.AttemptBmm(ctx, header, 1000)
+	cctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	results, err = drivechain.ListenForBmmConfirmation(cctx, time.Millisecond)
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	if result := <-results; !errors.Is(result.Err, context.DeadlineExceeded) || result.State != drivechain.Pending {
+		t.Errorf("result mismatch: have %+v, want %v", result, context.DeadlineExceeded)
+	}
+}
+
+func TestWaitForBmm(t *testing.T) {
+	f := New()
+	prev := drivechain.SetDefault(f)
+	defer drivechain.SetDefault(prev)
+	ctx := context.Background()
+	header := &types.Header{Number: big.NewInt(1), PrevMainBlockHash: common.Hash{1}}
+
+	if _, _, err := drivechain.WaitForBmm(ctx); !errors.Is(err, drivechain.ErrNoBmmPending) {
+		t.Errorf("error mismatch: have %v, want %v", err, drivechain.ErrNoBmmPending)
+	}
+	f.ScriptBmm(drivechain.Pending, drivechain.Failed)
+	f.AttemptBmm(ctx, header, 1000)
+	if state, _, err := drivechain.WaitForBmm(ctx); err != nil || state != drivechain.Failed {
+		t.Errorf("result mismatch: have %v (%v), want %v", state, err, drivechain.Failed)
+	}
+	f.ScriptBmm(drivechain.Succeeded)
+	f.AttemptBmm(ctx, header, 1000)
+	state, mainBlockHash, err := drivechain.WaitForBmm(ctx)
+	if err != nil || state != drivechain.Succeeded || mainBlockHash == (common.Hash{}) {
+		t.Errorf("result mismatch: have %v %x (%v), want success", state, mainBlockHash, err)
+	}
+
+	// Nothing is scripted, so the attempt stays pending until ctx is done.
+	f.AttemptBmm(ctx, header, 1000)
+	cctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if state, _, err := drivechain.WaitForBmm(cctx); !errors.Is(err, context.DeadlineExceeded) || state != drivechain.Pending {
+		t.Errorf("result mismatch: have %v (%v), want %v", state, err, context.DeadlineExceeded)
+	}
+	// Cancelling the attempt ends the wait too.
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		f.CancelBmm()
+	}()
+	if _, _, err := drivechain.WaitForBmm(ctx); !errors.Is(err, drivechain.ErrNoBmmPending) {
+		t.Errorf("error mismatch: have %v, want %v", err, drivechain.ErrNoBmmPending)
+	}
+}
@@ -0,0 +1,85 @@
+package drivechain
+
+import (
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// WithdrawalFilter reports whether a withdrawal should be kept by
+// FilterWithdrawals. Filters are combined with And, Or and Not.
+type WithdrawalFilter func(id common.Hash, w Withdrawal) bool
+
+// FilterWithdrawals returns the withdrawals f keeps, in a new map. A nil f
+// keeps all withdrawals.
+func FilterWithdrawals(withdrawals map[common.Hash]Withdrawal, f WithdrawalFilter) map[common.Hash]Withdrawal {
+	filtered := make(map[common.Hash]Withdrawal)
+	for id, w := range withdrawals {
+		if f == nil || f(id, w) {
+			filtered[id] = w
+		}
+	}
+	return filtered
+}
+
+// MinAmount keeps withdrawals of at least amount, in the unit of the
+// withdrawals' Amount.
+func MinAmount(amount *big.Int) WithdrawalFilter {
+	amount = new(big.Int).Set(amount)
+	return func(_ common.Hash, w Withdrawal) bool {
+		return w.Amount != nil && w.Amount.Cmp(amount) >= 0
+	}
+}
+
+// MaxFee keeps withdrawals paying at most fee, in the unit of the
+// withdrawals' Fee.
+func MaxFee(fee *big.Int) WithdrawalFilter {
+	fee = new(big.Int).Set(fee)
+	return func(_ common.Hash, w Withdrawal) bool {
+		return w.Fee != nil && w.Fee.Cmp(fee) <= 0
+	}
+}
+
+// AddressPrefix keeps withdrawals to mainchain addresses starting with prefix,
+// e.g. "bc1q". Addresses are formatted by the engine, so nothing is kept
+// while it isn't running.
+func AddressPrefix(prefix string) WithdrawalFilter {
+	return func(_ common.Hash, w Withdrawal) bool {
+		s, err := FormatMainchainAddress(w.Address)
+		return err == nil && strings.HasPrefix(s, prefix)
+	}
+}
+
+// And keeps withdrawals kept by all filters. Without filters it keeps all
+// withdrawals.
+func And(filters ...WithdrawalFilter) WithdrawalFilter {
+	return func(id common.Hash, w Withdrawal) bool {
+		for _, f := range filters {
+			if !f(id, w) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or keeps withdrawals kept by any of filters. Without filters it keeps
+// nothing.
+func Or(filters ...WithdrawalFilter) WithdrawalFilter {
+	return func(id common.Hash, w Withdrawal) bool {
+		for _, f := range filters {
+			if f(id, w) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not keeps the withdrawals f drops.
+func Not(f WithdrawalFilter) WithdrawalFilter {
+	return func(id common.Hash, w Withdrawal) bool {
+		return !f(id, w)
+	}
+}
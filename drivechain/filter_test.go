@@ -0,0 +1,65 @@
+package drivechain
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestFilterWithdrawals(t *testing.T) {
+	withdrawal := func(typ MainchainAddressType, amount, fee int64) Withdrawal {
+		return Withdrawal{Address: MainchainAddress{Type: typ}, Amount: big.NewInt(amount), Fee: big.NewInt(fee)}
+	}
+	small, large, expensive := common.Hash{1}, common.Hash{2}, common.Hash{3}
+	withdrawals := map[common.Hash]Withdrawal{
+		small:     withdrawal(P2PKH, 1000, 10),
+		large:     withdrawal(P2WPKH, 100000, 10),
+		expensive: withdrawal(P2PKH, 100000, 5000),
+	}
+	tests := []struct {
+		name   string
+		filter WithdrawalFilter
+		want   []common.Hash
+	}{
+		{"nil", nil, []common.Hash{small, large, expensive}},
+		{"min amount", MinAmount(big.NewInt(100000)), []common.Hash{large, expensive}},
+		{"max fee", MaxFee(big.NewInt(10)), []common.Hash{small, large}},
+		{"and", And(MinAmount(big.NewInt(100000)), MaxFee(big.NewInt(10))), []common.Hash{large}},
+		{"or", Or(Not(MinAmount(big.NewInt(100000))), Not(MaxFee(big.NewInt(10)))), []common.Hash{small, expensive}},
+		{"empty and", And(), []common.Hash{small, large, expensive}},
+		{"empty or", Or(), nil},
+		// Addresses can't be formatted without the engine.
+		{"address prefix", AddressPrefix(""), nil},
+	}
+	for _, tt := range tests {
+		want := make(map[common.Hash]Withdrawal)
+		for _, id := range tt.want {
+			want[id] = withdrawals[id]
+		}
+		if have := FilterWithdrawals(withdrawals, tt.filter); !reflect.DeepEqual(have, want) {
+			t.Errorf("%s: have %v, want %v", tt.name, have, want)
+		}
+	}
+
+	defer SetDefault(SetDefault(typeFormatter{}))
+	for prefix, want := range map[string]int{"1": 2, "bc1q": 1, "bc1p": 0} {
+		if have := FilterWithdrawals(withdrawals, AddressPrefix(prefix)); len(have) != want {
+			t.Errorf("prefix %q: kept %d withdrawals, want %d", prefix, len(have), want)
+		}
+	}
+}
+
+// typeFormatter is a Drivechain formatting mainchain addresses by their type
+// alone.
+type typeFormatter struct {
+	CGO
+}
+
+func (typeFormatter) FormatMainchainAddress(dest MainchainAddress) (string, error) {
+	if dest.Type == P2WPKH {
+		return "bc1qstub", nil
+	}
+	return "1stub", nil
+}
@@ -0,0 +1,137 @@
+package drivechain
+
+import (
+	"encoding/json"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+)
+
+// The JSON encodings of the data types below write *big.Int amounts as
+// decimal strings, so they survive decoders that parse numbers as floats.
+
+type depositJSON struct {
+	Address  common.Address   `json:"address"`
+	Amount   *math.Decimal256 `json:"amount"`
+	MainTxid common.Hash      `json:"mainTxid"`
+	Vout     uint32           `json:"vout"`
+}
+
+func (d *Deposit) toJSON() depositJSON {
+	return depositJSON{Address: d.Address, Amount: (*math.Decimal256)(d.Amount), MainTxid: d.MainTxid, Vout: d.Vout}
+}
+
+func (dec *depositJSON) deposit() Deposit {
+	return Deposit{Address: dec.Address, Amount: (*big.Int)(dec.Amount), MainTxid: dec.MainTxid, Vout: dec.Vout}
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d Deposit) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.toJSON())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Deposit) UnmarshalJSON(input []byte) error {
+	var dec depositJSON
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	*d = dec.deposit()
+	return nil
+}
+
+// pendingDepositJSON is the JSON encoding of a PendingDeposit. PendingDeposit
+// needs methods of its own, the ones promoted from Deposit would drop the
+// confirmations.
+type pendingDepositJSON struct {
+	depositJSON
+	Confirmations uint64 `json:"confirmations"`
+	Required      uint64 `json:"required"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d PendingDeposit) MarshalJSON() ([]byte, error) {
+	return json.Marshal(pendingDepositJSON{depositJSON: d.toJSON(), Confirmations: d.Confirmations, Required: d.Required})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *PendingDeposit) UnmarshalJSON(input []byte) error {
+	var dec pendingDepositJSON
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	*d = PendingDeposit{Deposit: dec.deposit(), Confirmations: dec.Confirmations, Required: dec.Required}
+	return nil
+}
+
+type withdrawalJSON struct {
+	Address MainchainAddress `json:"address"`
+	Amount  *math.Decimal256 `json:"amount"`
+	Fee     *math.Decimal256 `json:"fee"`
+}
+
+func (w *Withdrawal) toJSON() withdrawalJSON {
+	return withdrawalJSON{Address: w.Address, Amount: (*math.Decimal256)(w.Amount), Fee: (*math.Decimal256)(w.Fee)}
+}
+
+func (dec *withdrawalJSON) withdrawal() Withdrawal {
+	return Withdrawal{Address: dec.Address, Amount: (*big.Int)(dec.Amount), Fee: (*big.Int)(dec.Fee)}
+}
+
+// MarshalJSON implements json.Marshaler.
+func (w Withdrawal) MarshalJSON() ([]byte, error) {
+	return json.Marshal(w.toJSON())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (w *Withdrawal) UnmarshalJSON(input []byte) error {
+	var dec withdrawalJSON
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	*w = dec.withdrawal()
+	return nil
+}
+
+// withdrawalInfoJSON is the JSON encoding of a WithdrawalInfo, see
+// pendingDepositJSON.
+type withdrawalInfoJSON struct {
+	withdrawalJSON
+	Status WithdrawalStatus `json:"status"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (w WithdrawalInfo) MarshalJSON() ([]byte, error) {
+	return json.Marshal(withdrawalInfoJSON{withdrawalJSON: w.toJSON(), Status: w.Status})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (w *WithdrawalInfo) UnmarshalJSON(input []byte) error {
+	var dec withdrawalInfoJSON
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	*w = WithdrawalInfo{Withdrawal: dec.withdrawal(), Status: dec.Status}
+	return nil
+}
+
+type refundJSON struct {
+	Id     common.Hash      `json:"id"`
+	Amount *math.Decimal256 `json:"amount"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (r Refund) MarshalJSON() ([]byte, error) {
+	return json.Marshal(refundJSON{Id: r.Id, Amount: (*math.Decimal256)(r.Amount)})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (r *Refund) UnmarshalJSON(input []byte) error {
+	var dec refundJSON
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	*r = Refund{Id: dec.Id, Amount: (*big.Int)(dec.Amount)}
+	return nil
+}
@@ -0,0 +1,97 @@
+package drivechain
+
+import (
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// roundTrip encodes v to JSON and decodes it into a new value of the same
+// type, reporting whether that gives v back.
+func roundTrip(t *testing.T, v interface{}) bool {
+	t.Helper()
+	enc, err := json.Marshal(v)
+	if err != nil {
+		t.Errorf("failed to encode %+v: %v", v, err)
+		return false
+	}
+	dec := reflect.New(reflect.TypeOf(v))
+	if err := json.Unmarshal(enc, dec.Interface()); err != nil {
+		t.Errorf("failed to decode %s: %v", enc, err)
+		return false
+	}
+	if !reflect.DeepEqual(dec.Elem().Interface(), v) {
+		t.Errorf("round trip of %+v gave %+v via %s", v, dec.Elem().Interface(), enc)
+		return false
+	}
+	return true
+}
+
+func TestDepositJSONRoundTrip(t *testing.T) {
+	f := func(address common.Address, amount [32]byte, txid common.Hash, vout uint32, confirmations, required uint64) bool {
+		d := Deposit{Address: address, Amount: new(big.Int).SetBytes(amount[:]), MainTxid: txid, Vout: vout}
+		return roundTrip(t, d) && roundTrip(t, PendingDeposit{Deposit: d, Confirmations: confirmations, Required: required})
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWithdrawalJSONRoundTrip(t *testing.T) {
+	f := func(typ uint8, payload [MaxMainchainAddressLength]byte, amount, fee [32]byte, status uint8) bool {
+		addressType := MainchainAddressType(typ % uint8(len(mainchainAddressTypeNames)))
+		address, err := NewMainchainAddress(addressType, payload[:addressType.Length()])
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := Withdrawal{Address: address, Amount: new(big.Int).SetBytes(amount[:]), Fee: new(big.Int).SetBytes(fee[:])}
+		info := WithdrawalInfo{Withdrawal: w, Status: WithdrawalStatus(1 + status%uint8(WithdrawalStatusRefunded))}
+		return roundTrip(t, w) && roundTrip(t, info)
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRefundJSONRoundTrip(t *testing.T) {
+	f := func(id common.Hash, amount [32]byte) bool {
+		return roundTrip(t, Refund{Id: id, Amount: new(big.Int).SetBytes(amount[:])})
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBmmStateJSONRoundTrip(t *testing.T) {
+	for state := range bmmStateNames {
+		roundTrip(t, BmmState(state))
+	}
+	if _, err := json.Marshal(BmmState(len(bmmStateNames))); err == nil {
+		t.Error("encoded invalid bmm state")
+	}
+}
+
+// Tests that amounts are encoded as decimal strings, keeping their precision.
+func TestJSONAmountsAreDecimal(t *testing.T) {
+	amount, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	enc, err := json.Marshal(Refund{Amount: amount})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `"amount":"123456789012345678901234567890"`; !strings.Contains(string(enc), want) {
+		t.Errorf("encoded refund %s, want it to contain %s", enc, want)
+	}
+	enc, err = json.Marshal(Withdrawal{Amount: big.NewInt(1), Fee: big.NewInt(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"address":{"type":"p2pkh","payload":"0x0000000000000000000000000000000000000000"},"amount":"1","fee":"2"}`
+	if string(enc) != want {
+		t.Errorf("encoded withdrawal %s, want %s", enc, want)
+	}
+}
@@ -0,0 +1,134 @@
+//go:build leak && linux
+// +build leak,linux
+
+package drivechain
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// leakIterations is how often each call is made by TestNoLeaks.
+const leakIterations = 100_000
+
+// maxRSSGrowth is how much the resident set may grow while calling an API
+// leakIterations times. Leaking a single id string per call already exceeds
+// it.
+const maxRSSGrowth = 4 << 20
+
+// rss returns the resident set size of the process, after returning all the
+// memory the Go runtime can give back.
+func rss(t *testing.T) int64 {
+	runtime.GC()
+	debug.FreeOSMemory()
+	statm, err := os.ReadFile("/proc/self/statm")
+	if err != nil {
+		t.Fatalf("failed to read statm: %v", err)
+	}
+	pages, err := strconv.ParseInt(strings.Fields(string(statm))[1], 10, 64)
+	if err != nil {
+		t.Fatalf("failed to parse statm: %v", err)
+	}
+	return pages * int64(os.Getpagesize())
+}
+
+// Tests that calling each API of the engine many times doesn't grow the
+// process, i.e. that all C memory passed to or returned by the engine is
+// freed. Run with:
+//
+//	go test -tags leak -run TestNoLeaks ./drivechain
+func TestNoLeaks(t *testing.T) {
+	initTestEngine(t, nil)
+
+	ctx := context.Background()
+	address := common.HexToAddress("0xc96aaa54e2d44c299564da76e1cd3184a2386b8d")
+	id := common.HexToHash("0x01")
+	deposits := []Deposit{{Address: address, Amount: big.NewInt(1000)}}
+	withdrawals := map[common.Hash]Withdrawal{
+		id: {Address: MainchainAddress{Type: P2PKH}, Amount: big.NewInt(1000), Fee: big.NewInt(10)},
+	}
+	refunds := []Refund{{Id: id, Amount: big.NewInt(1000)}}
+	header := &types.Header{Number: big.NewInt(1), PrevMainBlockHash: common.Hash{1}}
+	if err := ConnectBlock(id, deposits, nil, nil, false); err != nil {
+		t.Fatalf("failed to connect block: %v", err)
+	}
+
+	calls := []struct {
+		name string
+		call func() error
+	}{
+		{"GetMainchainTip", func() error { _, err := GetMainchainTip(); return err }},
+		{"GetDepositOutputs", func() error { _, err := GetDepositOutputs(); return err }},
+		{"ReplayDeposits", func() error { _, err := ReplayDeposits(1, 2); return err }},
+		{"FormatDepositAddress", func() error { _, err := FormatDepositAddress(address); return err }},
+		{"CreateDeposit", func() error { _, err := CreateDeposit(address, 10000, 10); return err }},
+		{"ConnectBlock", func() error { return ConnectBlock(common.Hash{}, deposits, withdrawals, nil, true) }},
+		{"ConnectBlockUnchecked", func() error { return ConnectBlockUnchecked(deposits, withdrawals, refunds, true) }},
+		{"ConnectBlockRefundCheck", func() error {
+			// The refund isn't of an unspent withdrawal of the test engine.
+			if err := ConnectBlock(common.Hash{}, nil, nil, refunds, true); !errors.Is(err, ErrRefundNotFound) {
+				return err
+			}
+			return nil
+		}},
+		{"ConnectBlock with block hash", func() error { return ConnectBlock(id, deposits, withdrawals, nil, true) }},
+		{"ConnectBlocks", func() error {
+			_, err := ConnectBlocks([]BlockConnectData{{Deposits: deposits, Withdrawals: withdrawals, Refunds: refunds}}, true)
+			return err
+		}},
+		{"DisconnectBlocks", func() error {
+			_, err := DisconnectBlocks([]BlockDisconnectData{{Deposits: deposits, Withdrawals: []common.Hash{id}, Refunds: []common.Hash{id}}}, true)
+			return err
+		}},
+		{"DisconnectBlock", func() error {
+			return DisconnectBlock(common.Hash{}, deposits, []common.Hash{id}, []common.Hash{id}, true)
+		}},
+		{"ValidateBlock", func() error {
+			if err := ValidateBlock(deposits, withdrawals, refunds); err != nil {
+				if _, ok := err.(*BlockValidationError); !ok {
+					return err
+				}
+			}
+			return nil
+		}},
+		{"GetUnspentWithdrawals", func() error { _, err := GetUnspentWithdrawals(ctx); return err }},
+		{"GetWithdrawalStatusInfo", func() error { _, err := GetWithdrawalStatusInfo(id); return err }},
+		{"IsWithdrawalSpent", func() error { _, err := IsWithdrawalSpent(id); return err }},
+		{"GetSpentWithdrawals", func() error { _, err := GetSpentWithdrawalsPage(id, 2); return err }},
+		{"AreWithdrawalsSpent", func() error { _, err := AreWithdrawalsSpent([]common.Hash{id, id}); return err }},
+		{"AttemptBundleBroadcast", func() error { _, err := AttemptBundleBroadcast(ctx); return err }},
+		{"AttemptBmm", func() error { _, err := AttemptBmm(ctx, header, 1000); return err }},
+		{"ConfirmBmm", func() error { _, _, err := ConfirmBmm(ctx); return err }},
+		{"GetBmmHistory", func() error { _, err := GetBmmHistory(0); return err }},
+		{"GetBlockSidechainActivity", func() error { _, err := GetBlockSidechainActivity(id); return err }},
+	}
+	for _, c := range calls {
+		t.Run(c.name, func(t *testing.T) {
+			// Warm up, so that caches and pools are filled before measuring.
+			for i := 0; i < 1000; i++ {
+				if err := c.call(); err != nil {
+					t.Fatalf("call failed: %v", err)
+				}
+			}
+			before := rss(t)
+			for i := 0; i < leakIterations; i++ {
+				if err := c.call(); err != nil {
+					t.Fatalf("call %d failed: %v", i, err)
+				}
+			}
+			if growth := rss(t) - before; growth > maxRSSGrowth {
+				t.Errorf("resident set grew by %d bytes over %d calls", growth, leakIterations)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package drivechain
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// rpcErrorInvalidAddressOrKey is the error code of mainchain RPC calls asking
+// for a block or transaction the node doesn't have.
+const rpcErrorInvalidAddressOrKey = -5
+
+// mainchainConfirmationsTTL is how long GetMainchainConfirmations reuses the
+// confirmation count of a transaction.
+const mainchainConfirmationsTTL = 10 * time.Second
+
+// confirmationsCache holds the confirmation counts fetched by
+// GetMainchainConfirmations.
+var confirmationsCache = struct {
+	lock    sync.Mutex
+	entries map[common.Hash]confirmationsEntry
+}{entries: make(map[common.Hash]confirmationsEntry)}
+
+type confirmationsEntry struct {
+	confirmations uint64
+	expires       time.Time
+}
+
+// MainchainBlock describes a mainchain block, as returned by
+// GetMainchainBlock.
+type MainchainBlock struct {
+	Hash      common.Hash
+	Height    uint64
+	Timestamp time.Time // Block header timestamp
+	TxCount   int       // Number of transactions in the block
+}
+
+// GetMainchainBlock fetches the mainchain block with the given hash from the
+// mainchain node, using the RPC credentials the engine was initialized with.
+// It fails with ErrMainchainBlockNotFound if the node doesn't know the block.
+func GetMainchainBlock(hash common.Hash) (MainchainBlock, error) {
+	if err := rlockEngine(); err != nil {
+		return MainchainBlock{}, err
+	}
+	cfg := engineConfig
+	engineMu.RUnlock()
+	return fetchMainchainBlock(&cfg, hash)
+}
+
+// fetchMainchainBlock is GetMainchainBlock for the node of cfg.
+func fetchMainchainBlock(cfg *Config, hash common.Hash) (MainchainBlock, error) {
+	var block MainchainBlock
+	err := withRetry(context.Background(), cfg.retryPolicy(), func() (err error) {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.rpcTimeout())
+		defer cancel()
+		block, err = getMainchainBlock(ctx, cfg, hash)
+		return err
+	})
+	return block, err
+}
+
+func getMainchainBlock(ctx context.Context, cfg *Config, hash common.Hash) (MainchainBlock, error) {
+	var block struct {
+		Hash   string `json:"hash"`
+		Height uint64 `json:"height"`
+		Time   int64  `json:"time"`
+		NTx    int    `json:"nTx"`
+	}
+	// Verbosity 1 returns the block header fields and the txids.
+	if err := newMainchainClient(cfg).call(ctx, &block, "getblock", hash.Hex()[2:], 1); err != nil {
+		if rpcErr, ok := err.(*mainchainRPCError); ok && rpcErr.Code == rpcErrorInvalidAddressOrKey {
+			return MainchainBlock{}, fmt.Errorf("%w: %s", ErrMainchainBlockNotFound, hash.Hex()[2:])
+		}
+		return MainchainBlock{}, err
+	}
+	return MainchainBlock{
+		Hash:      common.HexToHash(block.Hash),
+		Height:    block.Height,
+		Timestamp: time.Unix(block.Time, 0),
+		TxCount:   block.NTx,
+	}, nil
+}
+
+// DefaultFeeEstimateConf is the confirmation target of EstimateWithdrawalFee
+// when Config.FeeEstimateConf isn't set.
+const DefaultFeeEstimateConf = 6
+
+// WithdrawalVSize is the virtual size a withdrawal adds to its bundle
+// transaction: one output with the largest script, a P2WSH or taproot one.
+const WithdrawalVSize = 8 + 1 + 34
+
+// EstimateWithdrawalFee recommends the mainchain fee, in satoshis, for
+// withdrawing amount satoshis. It asks the mainchain node's estimatesmartfee
+// for the fee rate needed to confirm within Config.FeeEstimateConf blocks and
+// charges it for WithdrawalVSize bytes. It fails with ErrFeeEstimateUnavailable
+// if the node has no estimate and with ErrWithdrawalFeeExceedsAmount if the
+// fee would eat the whole amount.
+func EstimateWithdrawalFee(amount *big.Int) (uint64, error) {
+	if amount == nil || amount.Sign() <= 0 {
+		return 0, ErrZeroWithdrawalAmount
+	}
+	if err := rlockEngine(); err != nil {
+		return 0, err
+	}
+	cfg := engineConfig
+	engineMu.RUnlock()
+	return recommendWithdrawalFee(&cfg, amount)
+}
+
+// recommendWithdrawalFee is EstimateWithdrawalFee for the node of cfg, once
+// amount is known to be positive.
+func recommendWithdrawalFee(cfg *Config, amount *big.Int) (uint64, error) {
+	var fee uint64
+	err := withRetry(context.Background(), cfg.retryPolicy(), func() (err error) {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.rpcTimeout())
+		defer cancel()
+		fee, err = estimateWithdrawalFee(ctx, cfg)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	if new(big.Int).SetUint64(fee).Cmp(amount) >= 0 {
+		return 0, fmt.Errorf("%w: fee %d, amount %v", ErrWithdrawalFeeExceedsAmount, fee, amount)
+	}
+	return fee, nil
+}
+
+func estimateWithdrawalFee(ctx context.Context, cfg *Config) (uint64, error) {
+	var estimate struct {
+		FeeRate *float64 `json:"feerate"` // BTC per kvB
+		Errors  []string `json:"errors"`
+	}
+	if err := newMainchainClient(cfg).call(ctx, &estimate, "estimatesmartfee", cfg.feeEstimateConf()); err != nil {
+		return 0, err
+	}
+	if estimate.FeeRate == nil || *estimate.FeeRate <= 0 {
+		return 0, fmt.Errorf("%w: %s", ErrFeeEstimateUnavailable, strings.Join(estimate.Errors, ", "))
+	}
+	// Satoshis per 1000 virtual bytes, rounded to get rid of float noise.
+	perKvB := uint64(math.Round(*estimate.FeeRate * 1e8))
+	return (perKvB*WithdrawalVSize + 999) / 1000, nil
+}
+
+// GetMainchainConfirmations returns the number of mainchain confirmations of
+// the mainchain wallet transaction txHash, e.g. a withdrawal bundle. Results
+// are cached for 10 seconds, so it can be called while building every block.
+// It fails with ErrMainchainTxNotFound if the wallet doesn't know the
+// transaction.
+func GetMainchainConfirmations(txHash common.Hash) (uint64, error) {
+	if err := rlockEngine(); err != nil {
+		return 0, err
+	}
+	cfg := engineConfig
+	engineMu.RUnlock()
+	return fetchMainchainConfirmations(&cfg, txHash)
+}
+
+// fetchMainchainConfirmations is GetMainchainConfirmations for the node of
+// cfg.
+func fetchMainchainConfirmations(cfg *Config, txHash common.Hash) (uint64, error) {
+	var confirmations uint64
+	err := withRetry(context.Background(), cfg.retryPolicy(), func() (err error) {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.rpcTimeout())
+		defer cancel()
+		confirmations, err = getMainchainConfirmations(ctx, cfg, txHash)
+		return err
+	})
+	return confirmations, err
+}
+
+func getMainchainConfirmations(ctx context.Context, cfg *Config, txHash common.Hash) (uint64, error) {
+	now := time.Now()
+	confirmationsCache.lock.Lock()
+	entry, ok := confirmationsCache.entries[txHash]
+	confirmationsCache.lock.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.confirmations, nil
+	}
+
+	var tx struct {
+		Confirmations int64 `json:"confirmations"`
+	}
+	if err := newMainchainClient(cfg).call(ctx, &tx, "gettransaction", txHash.Hex()[2:]); err != nil {
+		if rpcErr, ok := err.(*mainchainRPCError); ok && rpcErr.Code == rpcErrorInvalidAddressOrKey {
+			return 0, fmt.Errorf("%w: %s", ErrMainchainTxNotFound, txHash.Hex()[2:])
+		}
+		return 0, err
+	}
+	// Transactions conflicting with the main chain have negative confirmations.
+	var confirmations uint64
+	if tx.Confirmations > 0 {
+		confirmations = uint64(tx.Confirmations)
+	}
+
+	confirmationsCache.lock.Lock()
+	defer confirmationsCache.lock.Unlock()
+	for hash, entry := range confirmationsCache.entries {
+		if now.After(entry.expires) {
+			delete(confirmationsCache.entries, hash)
+		}
+	}
+	confirmationsCache.entries[txHash] = confirmationsEntry{confirmations, now.Add(mainchainConfirmationsTTL)}
+	return confirmations, nil
+}
@@ -0,0 +1,147 @@
+package drivechain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestGetMainchainBlock(t *testing.T) {
+	known := common.HexToHash("0x0000000000000000000a1b2c3d4e5f60718293a4b5c6d7e8f90123456789abcd")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string        `json:"method"`
+			Params []interface{} `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Method != "getblock" || len(req.Params) != 2 {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if req.Params[0] != known.Hex()[2:] {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"result": null, "error": {"code": -5, "message": "Block not found"}, "id": 1}`))
+			return
+		}
+		w.Write([]byte(`{"result": {"hash": "` + known.Hex()[2:] + `", "height": 812345, "time": 1697000000, "nTx": 3, "tx": ["a", "b", "c"]}, "error": null, "id": 1}`))
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	port, _ := strconv.Atoi(u.Port())
+	cfg := Config{Host: u.Hostname(), Port: uint16(port), RPCUser: "user", RPCPassword: "password"}
+
+	block, err := getMainchainBlock(context.Background(), &cfg, known)
+	if err != nil {
+		t.Fatalf("failed to get block: %v", err)
+	}
+	want := MainchainBlock{Hash: known, Height: 812345, Timestamp: time.Unix(1697000000, 0), TxCount: 3}
+	if block != want {
+		t.Errorf("block mismatch: have %+v, want %+v", block, want)
+	}
+	if _, err := getMainchainBlock(context.Background(), &cfg, common.Hash{1}); !errors.Is(err, ErrMainchainBlockNotFound) {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrMainchainBlockNotFound)
+	}
+	if _, err := GetMainchainBlock(known); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrNotInitialized)
+	}
+}
+
+func TestGetMainchainConfirmations(t *testing.T) {
+	var (
+		known         = common.HexToHash("0x5e1fc6d3c3a5b8f7e4d2a1b0c9f8e7d6c5b4a39281706f5e4d3c2b1a09f8e7d6")
+		conflicted    = common.HexToHash("0x01")
+		confirmations int64
+		calls         int32
+	)
+	atomic.StoreInt64(&confirmations, 3)
+	confirmationsCache.lock.Lock()
+	confirmationsCache.entries = make(map[common.Hash]confirmationsEntry)
+	confirmationsCache.lock.Unlock()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		var req struct {
+			Method string        `json:"method"`
+			Params []interface{} `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Method != "gettransaction" || len(req.Params) != 1 {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		switch req.Params[0] {
+		case known.Hex()[2:]:
+			w.Write([]byte(`{"result": {"confirmations": ` + strconv.FormatInt(atomic.LoadInt64(&confirmations), 10) + `}, "error": null, "id": 1}`))
+		case conflicted.Hex()[2:]:
+			w.Write([]byte(`{"result": {"confirmations": -1}, "error": null, "id": 1}`))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"result": null, "error": {"code": -5, "message": "Invalid or non-wallet transaction id"}, "id": 1}`))
+		}
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	port, _ := strconv.Atoi(u.Port())
+	cfg := Config{Host: u.Hostname(), Port: uint16(port), RPCUser: "user", RPCPassword: "password"}
+
+	for i := 0; i < 2; i++ {
+		n, err := getMainchainConfirmations(context.Background(), &cfg, known)
+		if err != nil || n != 3 {
+			t.Fatalf("confirmations mismatch: have %d (%v), want 3", n, err)
+		}
+		// The second call must be served from the cache.
+		atomic.StoreInt64(&confirmations, 4)
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("rpc call count mismatch: have %d, want 1", n)
+	}
+	if n, err := getMainchainConfirmations(context.Background(), &cfg, conflicted); err != nil || n != 0 {
+		t.Errorf("conflicted confirmations mismatch: have %d (%v), want 0", n, err)
+	}
+	if _, err := getMainchainConfirmations(context.Background(), &cfg, common.Hash{2}); !errors.Is(err, ErrMainchainTxNotFound) {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrMainchainTxNotFound)
+	}
+}
+
+func TestEstimateWithdrawalFee(t *testing.T) {
+	var feeRate atomic.Value
+	feeRate.Store(`"feerate": 0.00012,`)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string        `json:"method"`
+			Params []interface{} `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Method != "estimatesmartfee" || len(req.Params) != 1 || req.Params[0] != 3.0 {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"result": {` + feeRate.Load().(string) + ` "blocks": 3}, "error": null, "id": 1}`))
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	port, _ := strconv.Atoi(u.Port())
+	cfg := Config{Host: u.Hostname(), Port: uint16(port), RPCUser: "user", RPCPassword: "password", FeeEstimateConf: 3}
+
+	// 12000 sat/kvB for 43 vbytes.
+	if fee, err := estimateWithdrawalFee(context.Background(), &cfg); err != nil || fee != 516 {
+		t.Errorf("fee mismatch: have %d (%v), want 516", fee, err)
+	}
+	feeRate.Store(`"errors": ["Insufficient data or no feerate found"],`)
+	if _, err := estimateWithdrawalFee(context.Background(), &cfg); !errors.Is(err, ErrFeeEstimateUnavailable) {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrFeeEstimateUnavailable)
+	}
+	if _, err := EstimateWithdrawalFee(big.NewInt(100000)); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrNotInitialized)
+	}
+}
@@ -0,0 +1,155 @@
+package drivechain
+
+/*
+#include "./bindings.h"
+*/
+import "C"
+import (
+	"bytes"
+	"sort"
+	"unsafe"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Ids and addresses cross the FFI as fixed-size byte arrays, so marshaling a
+// block copies them in place instead of allocating a hex string for each.
+
+// cHash converts a hash to be passed to the engine.
+func cHash(h common.Hash) [common.HashLength]C.uint8_t {
+	return *(*[common.HashLength]C.uint8_t)(unsafe.Pointer(&h))
+}
+
+// goHash converts a hash returned by the engine.
+func goHash(h [common.HashLength]C.uint8_t) common.Hash {
+	return *(*common.Hash)(unsafe.Pointer(&h))
+}
+
+// cAddress converts an address to be passed to the engine.
+func cAddress(a common.Address) [common.AddressLength]C.uint8_t {
+	return *(*[common.AddressLength]C.uint8_t)(unsafe.Pointer(&a))
+}
+
+// goAddress converts an address returned by the engine.
+func goAddress(a [common.AddressLength]C.uint8_t) common.Address {
+	return *(*common.Address)(unsafe.Pointer(&a))
+}
+
+// newBlockData copies the content of a block into C memory. Deposits and
+// refunds keep their order, withdrawals are sorted by id.
+func newBlockData(args *cArgs, block *BlockData) C.BlockData {
+	return C.BlockData{
+		deposits:    newDeposits(args, block.Deposits),
+		withdrawals: newWithdrawals(args, block.Withdrawals),
+		refunds:     newRefunds(args, block.Refunds),
+	}
+}
+
+// newBlockDataBatch copies a batch of blocks into C memory, see newBlockData.
+// The batch must not be empty.
+func newBlockDataBatch(args *cArgs, blocks []BlockData) []C.BlockData {
+	cBlocks := unsafe.Slice((*C.BlockData)(args.malloc(C.size_t(len(blocks))*C.size_t(unsafe.Sizeof(C.BlockData{})))), len(blocks))
+	for i := range blocks {
+		cBlocks[i] = newBlockData(args, &blocks[i])
+	}
+	return cBlocks
+}
+
+// newBlockDisconnectDataBatch copies a batch of blocks to disconnect into C
+// memory. The batch must not be empty.
+func newBlockDisconnectDataBatch(args *cArgs, blocks []BlockDisconnectData) []C.BlockData {
+	cBlocks := unsafe.Slice((*C.BlockData)(args.malloc(C.size_t(len(blocks))*C.size_t(unsafe.Sizeof(C.BlockData{})))), len(blocks))
+	for i, block := range blocks {
+		cBlocks[i] = C.BlockData{
+			deposits:    newDeposits(args, block.Deposits),
+			withdrawals: newWithdrawalsFromHash(args, block.Withdrawals),
+			refunds:     newRefundsFromHash(args, block.Refunds),
+		}
+	}
+	return cBlocks
+}
+
+func newDeposits(args *cArgs, deposits []Deposit) C.Deposits {
+	ptr := (*C.Deposit)(args.malloc(C.size_t(len(deposits)) * C.size_t(unsafe.Sizeof(C.Deposit{}))))
+	cDeposits := unsafe.Slice(ptr, len(deposits))
+	for i, deposit := range deposits {
+		cDeposits[i] = C.Deposit{
+			address: cAddress(deposit.Address),
+			amount:  C.uint64_t(deposit.Amount.Uint64()),
+		}
+	}
+	return C.Deposits{
+		ptr: ptr,
+		len: C.uintptr_t(len(deposits)),
+	}
+}
+
+func newWithdrawals(args *cArgs, withdrawals map[common.Hash]Withdrawal) C.Withdrawals {
+	ptr := (*C.Withdrawal)(args.malloc(C.size_t(len(withdrawals)) * C.size_t(unsafe.Sizeof(C.Withdrawal{}))))
+	cWithdrawals := unsafe.Slice(ptr, len(withdrawals))
+	i := 0
+	for id, w := range withdrawals {
+		cWithdrawals[i] = C.Withdrawal{
+			id:      cHash(id),
+			address: cMainchainAddress(w.Address),
+			amount:  C.uint64_t(w.Amount.Uint64()),
+			fee:     C.uint64_t(w.Fee.Uint64()),
+		}
+		i++
+	}
+	// Map order is random, the engine gets the canonical order.
+	sort.Sort(cWithdrawalsById(cWithdrawals))
+	return C.Withdrawals{
+		ptr: ptr,
+		len: C.uintptr_t(len(withdrawals)),
+	}
+}
+
+// cWithdrawalsById sorts withdrawals by id, in ascending byte order.
+type cWithdrawalsById []C.Withdrawal
+
+func (s cWithdrawalsById) Len() int      { return len(s) }
+func (s cWithdrawalsById) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s cWithdrawalsById) Less(i, j int) bool {
+	a, b := goHash(s[i].id), goHash(s[j].id)
+	return bytes.Compare(a[:], b[:]) < 0
+}
+
+func newWithdrawalsFromHash(args *cArgs, withdrawals []common.Hash) C.Withdrawals {
+	ptr := (*C.Withdrawal)(args.malloc(C.size_t(len(withdrawals)) * C.size_t(unsafe.Sizeof(C.Withdrawal{}))))
+	cWithdrawals := unsafe.Slice(ptr, len(withdrawals))
+	for i, id := range withdrawals {
+		cWithdrawals[i] = C.Withdrawal{id: cHash(id)}
+	}
+	return C.Withdrawals{
+		ptr: ptr,
+		len: C.uintptr_t(len(withdrawals)),
+	}
+}
+
+func newRefunds(args *cArgs, refunds []Refund) C.Refunds {
+	ptr := (*C.Refund)(args.malloc(C.size_t(len(refunds)) * C.size_t(unsafe.Sizeof(C.Refund{}))))
+	cRefunds := unsafe.Slice(ptr, len(refunds))
+	for i, r := range refunds {
+		cRefunds[i] = C.Refund{
+			id:     cHash(r.Id),
+			amount: C.uint64_t(r.Amount.Uint64()),
+		}
+	}
+	return C.Refunds{
+		ptr: ptr,
+		len: C.uintptr_t(len(refunds)),
+	}
+}
+
+func newRefundsFromHash(args *cArgs, refunds []common.Hash) C.Refunds {
+	ptr := (*C.Refund)(args.malloc(C.size_t(len(refunds)) * C.size_t(unsafe.Sizeof(C.Refund{}))))
+	cRefunds := unsafe.Slice(ptr, len(refunds))
+	for i, id := range refunds {
+		cRefunds[i] = C.Refund{id: cHash(id)}
+	}
+	return C.Refunds{
+		ptr: ptr,
+		len: C.uintptr_t(len(refunds)),
+	}
+}
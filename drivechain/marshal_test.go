@@ -0,0 +1,126 @@
+package drivechain
+
+import (
+	"bytes"
+	"math/big"
+	"reflect"
+	"testing"
+	"unsafe"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// testBlockData returns a block with n deposits, withdrawals and refunds.
+func testBlockData(n int) *BlockData {
+	block := &BlockData{Withdrawals: make(map[common.Hash]Withdrawal)}
+	for i := 0; i < n; i++ {
+		id := common.BigToHash(big.NewInt(int64(i + 1)))
+		block.Deposits = append(block.Deposits, Deposit{Address: common.BigToAddress(big.NewInt(int64(i + 1))), Amount: big.NewInt(10000)})
+		block.Withdrawals[id] = Withdrawal{Address: MainchainAddress{Type: P2PKH}, Amount: big.NewInt(10000), Fee: big.NewInt(10)}
+		block.Refunds = append(block.Refunds, Refund{Id: id, Amount: big.NewInt(10000)})
+	}
+	return block
+}
+
+// Tests that block content is copied in place into the arena, without any
+// allocation of its own.
+func TestBlockDataMarshal(t *testing.T) {
+	block := testBlockData(3)
+	args := marshalArgs()
+	defer args.free()
+	cBlock := newBlockData(&args, block)
+	if len(args.ptrs) != 0 {
+		t.Errorf("marshaling allocated %d times outside the arena", len(args.ptrs))
+	}
+	for i, cDeposit := range unsafe.Slice(cBlock.deposits.ptr, cBlock.deposits.len) {
+		if have := goAddress(cDeposit.address); have != block.Deposits[i].Address {
+			t.Errorf("deposit %d: address mismatch: have %v, want %v", i, have, block.Deposits[i].Address)
+		}
+	}
+	for _, cWithdrawal := range unsafe.Slice(cBlock.withdrawals.ptr, cBlock.withdrawals.len) {
+		if _, ok := block.Withdrawals[goHash(cWithdrawal.id)]; !ok {
+			t.Errorf("unknown withdrawal %x", goHash(cWithdrawal.id))
+		}
+	}
+	for i, cRefund := range unsafe.Slice(cBlock.refunds.ptr, cBlock.refunds.len) {
+		if have := goHash(cRefund.id); have != block.Refunds[i].Id {
+			t.Errorf("refund %d: id mismatch: have %x, want %x", i, have, block.Refunds[i].Id)
+		}
+	}
+}
+
+// Tests that the arena keeps a single chunk, large enough for the largest
+// call, across resets.
+func TestArenaReuse(t *testing.T) {
+	var a cArena
+	defer a.free()
+	for i := 0; i < 3; i++ {
+		a.alloc(minArenaChunk / 2)
+		a.alloc(minArenaChunk)
+		a.reset()
+	}
+	if len(a.chunks) != 1 || a.size < minArenaChunk+minArenaChunk/2 {
+		t.Errorf("arena holds %d chunks, last of %d bytes", len(a.chunks), a.size)
+	}
+	if ptr := a.alloc(8); ptr != a.chunks[0] {
+		t.Error("memory not reused after reset")
+	}
+}
+
+// BenchmarkConnectBlockMarshal measures marshaling a block for ConnectBlock.
+// The cmallocs/op metric counts C allocations, which are zero once the arena
+// has grown to fit the block.
+func BenchmarkConnectBlockMarshal(b *testing.B) {
+	block := testBlockData(100)
+	b.ReportAllocs()
+	var cMallocs int
+	for i := 0; i < b.N; i++ {
+		args := marshalArgs()
+		newBlockData(&args, block)
+		cMallocs += len(args.ptrs)
+		args.free()
+	}
+	b.ReportMetric(float64(cMallocs)/float64(b.N), "cmallocs/op")
+}
+
+// Tests that withdrawals reach the engine in the same order however their
+// map was built.
+func TestWithdrawalOrder(t *testing.T) {
+	initTestEngine(t, nil)
+
+	var ids []common.Hash
+	for i := 0; i < 64; i++ {
+		ids = append(ids, common.BigToHash(big.NewInt(int64(i*7919%64+1))))
+	}
+	withdrawal := Withdrawal{Address: MainchainAddress{Type: P2PKH}, Amount: big.NewInt(10000), Fee: big.NewInt(10)}
+	forward, backward := make(map[common.Hash]Withdrawal), make(map[common.Hash]Withdrawal)
+	for i := range ids {
+		forward[ids[i]] = withdrawal
+		backward[ids[len(ids)-1-i]] = withdrawal
+	}
+	marshaled := func(withdrawals map[common.Hash]Withdrawal) []common.Hash {
+		args := marshalArgs()
+		defer args.free()
+		cWithdrawals := newWithdrawals(&args, withdrawals)
+		var order []common.Hash
+		for _, cWithdrawal := range unsafe.Slice(cWithdrawals.ptr, cWithdrawals.len) {
+			order = append(order, goHash(cWithdrawal.id))
+		}
+		return order
+	}
+	want := marshaled(forward)
+	for i := 1; i < len(want); i++ {
+		if bytes.Compare(want[i-1][:], want[i][:]) >= 0 {
+			t.Fatalf("withdrawal %d out of order: %x after %x", i, want[i], want[i-1])
+		}
+	}
+	for i := 0; i < 10; i++ {
+		if have := marshaled(backward); !reflect.DeepEqual(have, want) {
+			t.Fatalf("order mismatch: have %x, want %x", have, want)
+		}
+	}
+	errForward := ConnectBlock(common.Hash{}, nil, forward, nil, true)
+	if errBackward := ConnectBlock(common.Hash{}, nil, backward, nil, true); !reflect.DeepEqual(errForward, errBackward) {
+		t.Errorf("result mismatch: have %v and %v", errForward, errBackward)
+	}
+}
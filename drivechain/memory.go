@@ -0,0 +1,144 @@
+package drivechain
+
+/*
+#include "./bindings.h"
+*/
+import "C"
+import (
+	"sync"
+	"unsafe"
+)
+
+// cArgs owns the C memory allocated for the arguments of engine calls, so
+// that a single deferred free releases all of it on every return path:
+//
+//	var args cArgs
+//	defer args.free()
+//	cId := args.cString(id.Hex())
+//
+// The engine only borrows its arguments, nothing passed to it may be freed
+// by anyone else.
+//
+// Calls marshaling whole blocks use the package's arena instead, see
+// marshalArgs.
+type cArgs struct {
+	ptrs  []unsafe.Pointer
+	arena *cArena // Serves malloc if set, held until free
+}
+
+// marshalArgs returns arguments allocating from the package's arena, reused
+// across calls so that marshaling blocks doesn't allocate in the common
+// case. Only one call can hold the arena, it is released by free.
+func marshalArgs() cArgs {
+	arenaMu.Lock()
+	return cArgs{arena: &arena}
+}
+
+// cString copies s into C memory owned by a.
+func (a *cArgs) cString(s string) *C.char {
+	cString := C.CString(s)
+	a.ptrs = append(a.ptrs, unsafe.Pointer(cString))
+	return cString
+}
+
+// cBytes copies b into C memory owned by a. An empty b gives nil.
+func (a *cArgs) cBytes(b []byte) *C.uint8_t {
+	if len(b) == 0 {
+		return nil
+	}
+	ptr := C.CBytes(b)
+	a.ptrs = append(a.ptrs, ptr)
+	return (*C.uint8_t)(ptr)
+}
+
+// malloc allocates size bytes of C memory owned by a.
+func (a *cArgs) malloc(size C.size_t) unsafe.Pointer {
+	if a.arena != nil {
+		return a.arena.alloc(size)
+	}
+	ptr := C.malloc(size)
+	a.ptrs = append(a.ptrs, ptr)
+	return ptr
+}
+
+// free releases everything allocated through a. It can be called more than
+// once.
+func (a *cArgs) free() {
+	for _, ptr := range a.ptrs {
+		C.free(ptr)
+	}
+	a.ptrs = a.ptrs[:0]
+	if a.arena != nil {
+		a.arena.reset()
+		a.arena = nil
+		arenaMu.Unlock()
+	}
+}
+
+// minArenaChunk is the size of the first chunk allocated by an arena.
+const minArenaChunk = 64 << 10
+
+var (
+	arenaMu sync.Mutex
+	arena   cArena // Used through marshalArgs
+)
+
+// cArena hands out C memory from chunks that are kept across resets. When a
+// chunk is exhausted a new one of at least twice the size is allocated, the
+// old ones are freed on the next reset, so the arena settles on a single
+// chunk large enough for the biggest call.
+type cArena struct {
+	chunks []unsafe.Pointer
+	size   C.size_t // Size of the last chunk
+	used   C.size_t // Bytes handed out from the last chunk
+}
+
+// alloc returns size bytes of C memory, aligned for any of the engine's
+// types. The memory is valid until the next reset.
+func (a *cArena) alloc(size C.size_t) unsafe.Pointer {
+	size = (size + 7) &^ 7
+	if len(a.chunks) == 0 || a.used+size > a.size {
+		chunk := 2 * a.size
+		if chunk < minArenaChunk {
+			chunk = minArenaChunk
+		}
+		for chunk < size {
+			chunk *= 2
+		}
+		a.chunks = append(a.chunks, C.malloc(chunk))
+		a.size, a.used = chunk, 0
+	}
+	ptr := unsafe.Add(a.chunks[len(a.chunks)-1], a.used)
+	a.used += size
+	return ptr
+}
+
+// reset makes all memory of a available again, keeping only its largest
+// chunk allocated.
+func (a *cArena) reset() {
+	if n := len(a.chunks); n > 1 {
+		for _, chunk := range a.chunks[:n-1] {
+			C.free(chunk)
+		}
+		a.chunks = append(a.chunks[:0], a.chunks[n-1])
+	}
+	a.used = 0
+}
+
+// goString copies a string returned by the engine into Go memory and frees it
+// with free_string. A nil string gives "".
+func goString(cString *C.char) string {
+	if cString == nil {
+		return ""
+	}
+	defer C.free_string(cString)
+	return C.GoString(cString)
+}
+
+// free releases all memory of a.
+func (a *cArena) free() {
+	for _, chunk := range a.chunks {
+		C.free(chunk)
+	}
+	*a = cArena{}
+}
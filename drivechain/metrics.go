@@ -0,0 +1,79 @@
+package drivechain
+
+import (
+	"sync"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// Metrics of the engine. They are no-ops unless metrics collection is enabled
+// (metrics.Enabled) and are only exported once registered with
+// RegisterMetrics, which Init does with the default registry. With the
+// Prometheus exporter the slashes in the names become underscores, e.g.
+// drivechain_deposits_total. Seen deposits are counted by the deposit poller,
+// so only while there are deposit subscribers.
+var (
+	depositsCounter            = metrics.NewCounter()
+	withdrawalsCounter         = metrics.NewCounter()
+	withdrawalsPendingGauge    = metrics.NewGauge()
+	bmmAttemptsCounter         = metrics.NewCounter()
+	bmmAttemptTimer            = metrics.NewTimer()
+	connectBlockTimer          = metrics.NewTimer()
+	connectBlockFailureCounter = metrics.NewCounter()
+	bmmSucceededCounter        = metrics.NewCounter()
+	bmmFailedCounter           = metrics.NewCounter()
+	bmmVerifyTimer             = metrics.NewTimer()
+	bmmVerifyCacheHitsCounter  = metrics.NewCounter()
+	depositsSeenCounter        = metrics.NewCounter()
+	depositOutputsTimer        = metrics.NewTimer()
+	withdrawalsCreatedCounter  = metrics.NewCounter()
+	bundleBroadcastsCounter    = metrics.NewCounter()
+	rpcRetriesCounter          = metrics.NewCounter()
+	rpcRateLimitTimer          = metrics.NewTimer()
+
+	registerMetricsOnce sync.Once
+)
+
+// RegisterMetrics registers the engine's metrics with reg, usually
+// metrics.DefaultRegistry.
+func RegisterMetrics(reg metrics.Registry) error {
+	for name, metric := range map[string]interface{}{
+		"drivechain/deposits/total":            depositsCounter,
+		"drivechain/withdrawals/total":         withdrawalsCounter,
+		"drivechain/withdrawals/pending":       withdrawalsPendingGauge,
+		"drivechain/bmm/attempts/total":        bmmAttemptsCounter,
+		"drivechain/bmm/attempts/duration":     bmmAttemptTimer,
+		"drivechain/connectblock/duration":     connectBlockTimer,
+		"drivechain/connectblock/failures":     connectBlockFailureCounter,
+		"drivechain/bmm/succeeded/total":       bmmSucceededCounter,
+		"drivechain/bmm/failed/total":          bmmFailedCounter,
+		"drivechain/bmm/verify/duration":       bmmVerifyTimer,
+		"drivechain/bmm/verify/cachehits":      bmmVerifyCacheHitsCounter,
+		"drivechain/deposits/seen/total":       depositsSeenCounter,
+		"drivechain/depositoutputs/duration":   depositOutputsTimer,
+		"drivechain/withdrawals/created/total": withdrawalsCreatedCounter,
+		"drivechain/bundle/broadcasts/total":   bundleBroadcastsCounter,
+		"drivechain/rpc/retries/total":         rpcRetriesCounter,
+		"drivechain/rpc/ratelimit/wait":        rpcRateLimitTimer,
+	} {
+		if err := reg.Register(name, metric); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// registerDefaultMetrics registers the metrics with metrics.DefaultRegistry if
+// metrics collection is enabled. It only does so once, so the engine can be
+// initialized again after a shutdown.
+func registerDefaultMetrics() {
+	if !metrics.Enabled {
+		return
+	}
+	registerMetricsOnce.Do(func() {
+		if err := RegisterMetrics(metrics.DefaultRegistry); err != nil {
+			log.Warn("Failed to register drivechain metrics", "err", err)
+		}
+	})
+}
@@ -0,0 +1,174 @@
+package drivechain
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// DefaultWithdrawalPollInterval is how often the withdrawals are polled for
+// status changes when Config.WithdrawalPollInterval isn't set.
+const DefaultWithdrawalPollInterval = 10 * time.Second
+
+// WithdrawalNotifier is told about withdrawals reaching a final stage on
+// mainchain, e.g. to call a webhook. Withdrawal amounts are in Wei, as
+// returned by GetUnspentWithdrawals.
+type WithdrawalNotifier interface {
+	// OnWithdrawalSpent is called once the withdrawal is paid out on
+	// mainchain.
+	OnWithdrawalSpent(id common.Hash, w Withdrawal)
+	// OnWithdrawalFailed is called once the bundle of the withdrawal failed,
+	// so that it can be refunded.
+	OnWithdrawalFailed(id common.Hash, w Withdrawal)
+}
+
+// withdrawalNotifiers are the withdrawal notifiers of an engine together
+// with the poller watching the withdrawals for them.
+type withdrawalNotifiers struct {
+	// mu protects the notifiers and the poller. When both are needed, the
+	// engine's lock must be acquired before mu.
+	mu   sync.Mutex
+	list []WithdrawalNotifier
+	quit chan struct{} // Closed to stop the poller, nil if it's not running
+}
+
+// notifiers are the withdrawal notifiers of the engine started by Init.
+var notifiers = new(withdrawalNotifiers)
+
+// RegisterWithdrawalNotifier adds n to the notifiers told about withdrawals
+// being spent or failing. Notifiers stay registered across engine restarts.
+// While the engine runs and there are notifiers, the withdrawals are polled
+// every Config.WithdrawalPollInterval, or on every new block with
+// Config.ZMQEndpoint. Only changes seen while polling are reported, not the
+// state found by the first poll. Notifiers are called one after another from
+// the polling goroutine, so they shouldn't block.
+func RegisterWithdrawalNotifier(n WithdrawalNotifier) {
+	running := rlockEngine() == nil
+	if running {
+		defer engineMu.RUnlock()
+	}
+	notifiers.register(n)
+	if running {
+		startWithdrawalPoller()
+	}
+}
+
+// startWithdrawalPoller starts the withdrawal poller of the engine started by
+// Init if there are notifiers and it isn't running yet. The caller must hold
+// the engine lock.
+func startWithdrawalPoller() {
+	notifiers.start(engineConfig.withdrawalPollInterval(), CGO{}.GetUnspentWithdrawals, GetWithdrawalStatus)
+}
+
+// register adds n to the notifiers.
+func (ns *withdrawalNotifiers) register(n WithdrawalNotifier) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	ns.list = append(ns.list, n)
+}
+
+// start starts the poller if there are notifiers and it isn't running yet.
+// Every interval it asks unspent for the unspent withdrawals and status for
+// the status of those no longer reported. The caller must hold the engine's
+// lock.
+func (ns *withdrawalNotifiers) start(interval time.Duration, unspent func(context.Context) (map[common.Hash]WithdrawalInfo, error), status func(common.Hash) (WithdrawalStatus, error)) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	if len(ns.list) > 0 && ns.quit == nil {
+		ns.quit = make(chan struct{})
+		go ns.poll(interval, ns.quit, unspent, status)
+	}
+}
+
+// stop stops the poller. The caller must hold the engine's exclusive lock.
+func (ns *withdrawalNotifiers) stop() {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	if ns.quit != nil {
+		close(ns.quit)
+		ns.quit = nil
+	}
+}
+
+func (ns *withdrawalNotifiers) poll(interval time.Duration, quit chan struct{}, unspent func(context.Context) (map[common.Hash]WithdrawalInfo, error), status func(common.Hash) (WithdrawalStatus, error)) {
+	var tracker withdrawalTracker
+	for {
+		withdrawals, err := unspent(context.Background())
+		if err != nil {
+			log.Debug("Failed to poll withdrawals", "err", err)
+		} else {
+			for _, ev := range tracker.update(withdrawals, status) {
+				ns.notify(ev)
+			}
+		}
+		if !waitMainchainBlock(interval, quit) {
+			return
+		}
+	}
+}
+
+// notify passes ev to all notifiers.
+func (ns *withdrawalNotifiers) notify(ev withdrawalEvent) {
+	ns.mu.Lock()
+	current := ns.list
+	ns.mu.Unlock()
+	for _, n := range current {
+		if ev.status == WithdrawalStatusSpent {
+			n.OnWithdrawalSpent(ev.id, ev.withdrawal)
+		} else {
+			n.OnWithdrawalFailed(ev.id, ev.withdrawal)
+		}
+	}
+}
+
+// withdrawalEvent is a withdrawal that became spent or failed.
+type withdrawalEvent struct {
+	id         common.Hash
+	withdrawal Withdrawal
+	status     WithdrawalStatus // WithdrawalStatusSpent or WithdrawalStatusFailed
+}
+
+// withdrawalTracker follows the withdrawals known to the engine between
+// polls.
+type withdrawalTracker struct {
+	known map[common.Hash]WithdrawalInfo // Nil before the first update
+}
+
+// update moves the tracker to the unspent withdrawals reported by the engine,
+// looking up the status of withdrawals that are no longer reported with
+// status. It returns the withdrawals that became spent or failed, nothing on
+// the first update.
+func (t *withdrawalTracker) update(unspent map[common.Hash]WithdrawalInfo, status func(common.Hash) (WithdrawalStatus, error)) []withdrawalEvent {
+	first := t.known == nil
+	var events []withdrawalEvent
+	report := func(id common.Hash, info WithdrawalInfo) {
+		if first || (info.Status != WithdrawalStatusSpent && info.Status != WithdrawalStatusFailed) {
+			return
+		}
+		if old, ok := t.known[id]; ok && old.Status == info.Status {
+			return
+		}
+		events = append(events, withdrawalEvent{id: id, withdrawal: info.Withdrawal, status: info.Status})
+	}
+	for id, info := range unspent {
+		report(id, info)
+	}
+	for id, old := range t.known {
+		if _, ok := unspent[id]; ok {
+			continue
+		}
+		s, err := status(id)
+		if err != nil {
+			// Keep it, to look it up again on the next update.
+			log.Debug("Failed to get withdrawal status", "id", id, "err", err)
+			unspent[id] = old
+			continue
+		}
+		report(id, WithdrawalInfo{Withdrawal: old.Withdrawal, Status: s})
+	}
+	t.known = unspent
+	return events
+}
@@ -0,0 +1,75 @@
+package drivechain
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestWithdrawalTracker(t *testing.T) {
+	a, b, c := common.Hash{1}, common.Hash{2}, common.Hash{3}
+	info := func(status WithdrawalStatus) WithdrawalInfo {
+		return WithdrawalInfo{Withdrawal: Withdrawal{Address: MainchainAddress{Type: P2PKH}}, Status: status}
+	}
+	// Status of the withdrawals that aren't reported as unspent anymore.
+	statuses := make(map[common.Hash]WithdrawalStatus)
+	status := func(id common.Hash) (WithdrawalStatus, error) {
+		if s, ok := statuses[id]; ok {
+			return s, nil
+		}
+		return 0, ErrUnknownWithdrawal
+	}
+
+	var tracker withdrawalTracker
+	steps := []struct {
+		unspent  map[common.Hash]WithdrawalInfo
+		statuses map[common.Hash]WithdrawalStatus
+		events   map[common.Hash]WithdrawalStatus
+	}{
+		// The first update is only the baseline, even with a failed
+		// withdrawal.
+		{unspent: map[common.Hash]WithdrawalInfo{a: info(WithdrawalStatusPending), b: info(WithdrawalStatusFailed)}},
+		{unspent: map[common.Hash]WithdrawalInfo{a: info(WithdrawalStatusBundleBroadcast), b: info(WithdrawalStatusFailed)}},
+		// a is paid out and gone from the unspent withdrawals, c shows up.
+		{
+			unspent:  map[common.Hash]WithdrawalInfo{b: info(WithdrawalStatusFailed), c: info(WithdrawalStatusInBundle)},
+			statuses: map[common.Hash]WithdrawalStatus{a: WithdrawalStatusSpent},
+			events:   map[common.Hash]WithdrawalStatus{a: WithdrawalStatusSpent},
+		},
+		{
+			unspent: map[common.Hash]WithdrawalInfo{b: info(WithdrawalStatusFailed), c: info(WithdrawalStatusFailed)},
+			events:  map[common.Hash]WithdrawalStatus{c: WithdrawalStatusFailed},
+		},
+		// Refunds aren't reported.
+		{
+			unspent:  map[common.Hash]WithdrawalInfo{},
+			statuses: map[common.Hash]WithdrawalStatus{b: WithdrawalStatusRefunded, c: WithdrawalStatusRefunded},
+		},
+		{unspent: map[common.Hash]WithdrawalInfo{}},
+	}
+	for i, step := range steps {
+		for id, s := range step.statuses {
+			statuses[id] = s
+		}
+		events := tracker.update(step.unspent, status)
+		if len(events) != len(step.events) {
+			t.Fatalf("step %d: have %d events, want %d", i, len(events), len(step.events))
+		}
+		for _, ev := range events {
+			if want, ok := step.events[ev.id]; !ok || ev.status != want || ev.withdrawal.Address.Type != P2PKH {
+				t.Errorf("step %d: unexpected event %+v", i, ev)
+			}
+		}
+	}
+	// A failed status lookup keeps the withdrawal, to report it later.
+	tracker.update(map[common.Hash]WithdrawalInfo{a: info(WithdrawalStatusPending)}, status)
+	lookupErr := errors.New("lookup failed")
+	if events := tracker.update(map[common.Hash]WithdrawalInfo{}, func(common.Hash) (WithdrawalStatus, error) { return 0, lookupErr }); len(events) != 0 {
+		t.Errorf("events after failed lookup: %+v", events)
+	}
+	statuses[a] = WithdrawalStatusSpent
+	if events := tracker.update(map[common.Hash]WithdrawalInfo{}, status); len(events) != 1 || events[0].id != a || events[0].status != WithdrawalStatusSpent {
+		t.Errorf("events after retried lookup: have %+v", events)
+	}
+}
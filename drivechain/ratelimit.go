@@ -0,0 +1,89 @@
+package drivechain
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// rpcLimiter throttles the mainchain RPC calls made by the engine and from Go.
+// It's nil unless Config.RateLimit is set, and is guarded by engineMu.
+var rpcLimiter *rate.Limiter
+
+// WithRateLimit makes Init throttle mainchain RPC calls to rps calls per
+// second, so a busy node doesn't overwhelm a shared mainchain node.
+func WithRateLimit(rps float64) Option {
+	return func(cfg *Config) {
+		cfg.RateLimit = rps
+	}
+}
+
+// newRPCLimiter returns the limiter for cfg, or nil if calls aren't limited.
+// Bursts are limited to one second worth of calls.
+func newRPCLimiter(cfg *Config) *rate.Limiter {
+	if cfg.RateLimit <= 0 {
+		return nil
+	}
+	burst := int(cfg.RateLimit)
+	if burst < 1 {
+		burst = 1
+	}
+	return rate.NewLimiter(rate.Limit(cfg.RateLimit), burst)
+}
+
+// waitRateLimit blocks until the rate limit allows another mainchain RPC call.
+// The caller must hold engineMu.
+func waitRateLimit() {
+	if rpcLimiter == nil {
+		return
+	}
+	defer rpcRateLimitTimer.UpdateSince(time.Now())
+	// Waiting for a single token can't fail without a deadline.
+	rpcLimiter.Wait(context.Background())
+}
+
+// RPCLimiterStats returns a reservation of no calls made now, which doesn't
+// take from the limit. Its Delay is how far the calls already admitted are
+// ahead of the rate limit. It's not OK if calls aren't rate limited or the
+// engine isn't running.
+func RPCLimiterStats() rate.Reservation {
+	if err := rlockEngine(); err != nil {
+		return rate.Reservation{}
+	}
+	defer engineMu.RUnlock()
+	if rpcLimiter == nil {
+		return rate.Reservation{}
+	}
+	return *rpcLimiter.ReserveN(time.Now(), 0)
+}
+
+// rateLimitedTransport is an http.RoundTripper waiting for the rate limiter
+// before every request.
+type rateLimitedTransport struct {
+	limiter *rate.Limiter
+	base    http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	if err := t.limiter.Wait(req.Context()); err != nil {
+		return nil, err
+	}
+	rpcRateLimitTimer.UpdateSince(start)
+	return t.base.RoundTrip(req)
+}
+
+// rateLimitedClient returns a copy of client sending its requests through
+// limiter.
+func rateLimitedClient(client *http.Client, limiter *rate.Limiter) *http.Client {
+	limited := *client
+	base := limited.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	limited.Transport = &rateLimitedTransport{limiter: limiter, base: base}
+	return &limited
+}
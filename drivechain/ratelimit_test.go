@@ -0,0 +1,46 @@
+package drivechain
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestRateLimitedClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	limiter := newRPCLimiter(&Config{RateLimit: 20})
+	if limiter.Burst() != 20 {
+		t.Errorf("burst mismatch: have %d, want 20", limiter.Burst())
+	}
+	limiter = rate.NewLimiter(20, 1)
+	client := rateLimitedClient(http.DefaultClient, limiter)
+	if http.DefaultClient.Transport != nil {
+		t.Fatal("default client modified")
+	}
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		res, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		res.Body.Close()
+	}
+	// The first request is let through right away, the others 50ms apart.
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("requests not throttled, took %v", elapsed)
+	}
+	if newRPCLimiter(&Config{}) != nil {
+		t.Error("limiter created without rate limit")
+	}
+	if newRPCLimiter(&Config{RateLimit: 0.5}).Burst() != 1 {
+		t.Error("burst below one call")
+	}
+	if stats := RPCLimiterStats(); stats.OK() {
+		t.Error("limiter stats reported without a running engine")
+	}
+}
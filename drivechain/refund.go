@@ -0,0 +1,45 @@
+package drivechain
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// RefundDataLength is the length of the data of refund requests.
+const RefundDataLength = common.HashLength
+
+// GetRefundData returns the data of a transaction to the treasury requesting
+// a refund of the failed withdrawal made in transaction id. Refund requests
+// don't carry any value, the withdrawn value is paid back by the treasury.
+func GetRefundData(id common.Hash) []byte {
+	return id.Bytes()
+}
+
+// DecodeRefund decodes the refund request data, as produced by GetRefundData,
+// into the refund of value Wei. Value is the value of the refunded withdrawal
+// transaction, it must be a whole, non-zero number of satoshis as required of
+// withdrawals in strict mode. Whether the withdrawal exists and can be refunded
+// is checked by the engine in ConnectBlock.
+func DecodeRefund(value *big.Int, data []byte) (Refund, error) {
+	if len(data) != RefundDataLength {
+		return Refund{}, fmt.Errorf("%w: have %d bytes, want %d", ErrRefundDataLength, len(data), RefundDataLength)
+	}
+	id := common.BytesToHash(data)
+	if id == (common.Hash{}) {
+		return Refund{}, ErrZeroRefundId
+	}
+	var amount, rem big.Int
+	amount.DivMod(value, Satoshi, &rem)
+	if rem.Sign() != 0 {
+		return Refund{}, ErrWithdrawalAmountFraction
+	}
+	if amount.Sign() == 0 {
+		return Refund{}, ErrZeroWithdrawalAmount
+	}
+	return Refund{
+		Id:     id,
+		Amount: &amount,
+	}, nil
+}
@@ -0,0 +1,80 @@
+package drivechain
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestRefundRoundTrip(t *testing.T) {
+	id := common.HexToHash("0x6a1f8c8e9a7bdbc31b2cd3e1d6e5e8b0c0ef4bcd3d1e7b0b5c12d1f0c4ae6b1d")
+	value := new(big.Int).Mul(big.NewInt(150000), Satoshi)
+
+	refund, err := DecodeRefund(value, GetRefundData(id))
+	if err != nil {
+		t.Fatalf("failed to decode refund: %v", err)
+	}
+	if refund.Id != id {
+		t.Errorf("id mismatch: have %x, want %x", refund.Id, id)
+	}
+	if refund.Amount.Cmp(big.NewInt(150000)) != 0 {
+		t.Errorf("amount mismatch: have %v, want %v", refund.Amount, 150000)
+	}
+}
+
+func TestDecodeRefund(t *testing.T) {
+	data := GetRefundData(common.Hash{1})
+	tests := []struct {
+		name  string
+		value *big.Int
+		data  []byte
+		err   error
+	}{
+		{"valid", Satoshi, data, nil},
+		{"empty", Satoshi, nil, ErrRefundDataLength},
+		{"short", Satoshi, data[1:], ErrRefundDataLength},
+		{"long", Satoshi, append(common.CopyBytes(data), 0), ErrRefundDataLength},
+		{"withdrawal data", Satoshi, make([]byte, FeeLength+MainchainAddressLength), ErrRefundDataLength},
+		{"zero id", Satoshi, make([]byte, RefundDataLength), ErrZeroRefundId},
+		{"fraction", new(big.Int).Add(Satoshi, common.Big1), data, ErrWithdrawalAmountFraction},
+		{"zero value", new(big.Int), data, ErrZeroWithdrawalAmount},
+	}
+	for _, tt := range tests {
+		if _, err := DecodeRefund(tt.value, tt.data); !errors.Is(err, tt.err) {
+			t.Errorf("%s: error mismatch: have %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+// Tests that ConnectBlock rejects refunds of withdrawals that aren't
+// unspent, unless unchecked.
+func TestConnectBlockRefundCheck(t *testing.T) {
+	initTestEngine(t, nil)
+
+	known := Refund{Id: common.HexToHash("0x01"), Amount: big.NewInt(10000)}
+	unknown := Refund{Id: common.HexToHash("0x02"), Amount: big.NewInt(10000)}
+	unspent := map[common.Hash]struct{}{known.Id: {}}
+	if err := checkRefundIds([]Refund{known}, unspent); err != nil {
+		t.Errorf("known refund rejected: %v", err)
+	}
+	err := checkRefundIds([]Refund{known, unknown}, unspent)
+	if !errors.Is(err, ErrRefundNotFound) {
+		t.Fatalf("error mismatch: have %v, want %v", err, ErrRefundNotFound)
+	}
+	if msg := err.Error(); !strings.Contains(msg, unknown.Id.Hex()) || strings.Contains(msg, known.Id.Hex()) {
+		t.Errorf("error %q doesn't list exactly the unknown id", msg)
+	}
+	// The test engine has no unspent withdrawals.
+	if err := ConnectBlock(common.Hash{}, nil, nil, []Refund{known}, true); !errors.Is(err, ErrRefundNotFound) {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrRefundNotFound)
+	}
+	if err := ConnectBlock(common.Hash{1}, nil, nil, []Refund{unknown}, true); !errors.Is(err, ErrRefundNotFound) {
+		t.Errorf("side block error mismatch: have %v, want %v", err, ErrRefundNotFound)
+	}
+	if err := ConnectBlockUnchecked(nil, nil, []Refund{unknown}, true); err != nil {
+		t.Errorf("failed to connect unchecked: %v", err)
+	}
+}
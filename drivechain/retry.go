@@ -0,0 +1,113 @@
+package drivechain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// rpcErrorInWarmup is the error code of mainchain RPC calls made while the
+// node is still loading its block index.
+const rpcErrorInWarmup = -28
+
+// RetryPolicy describes how calls failing because the mainchain node can't be
+// reached are retried.
+type RetryPolicy struct {
+	Attempts  int           // Number of attempts, including the first one
+	BaseDelay time.Duration // Delay before the first retry, doubled for every further one
+	MaxDelay  time.Duration // Cap of the retry delay
+
+	// Jitter is the fraction, between 0 and 1, of every delay that is
+	// randomized, so that nodes restarted together don't retry in lockstep.
+	Jitter float64
+}
+
+// DefaultRetryPolicy is the RetryPolicy used when Config.Retry isn't set.
+var DefaultRetryPolicy = RetryPolicy{
+	Attempts:  4,
+	BaseDelay: 250 * time.Millisecond,
+	MaxDelay:  5 * time.Second,
+	Jitter:    0.2,
+}
+
+// delay returns how long to wait before the given retry, counting from 1.
+func (p RetryPolicy) delay(retry int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < retry && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if p.Jitter > 0 {
+		delay -= time.Duration(rand.Float64() * p.Jitter * float64(delay))
+	}
+	return delay
+}
+
+// IsTransient reports whether err is a failure to reach the mainchain node
+// that may go away by itself, so that the call failing with it is worth
+// retrying.
+func IsTransient(err error) bool {
+	if errors.Is(err, ErrMainchainUnreachable) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var rpcErr *mainchainRPCError
+	return errors.As(err, &rpcErr) && rpcErr.Code == rpcErrorInWarmup
+}
+
+// WithRetry calls fn until it succeeds or fails with an error that isn't
+// transient, following the retry policy the engine was initialized with. If
+// the policy is exhausted the last error is returned, wrapped with the number
+// of attempts. The read-only engine calls already retry on their own, WithRetry
+// is for the others, e.g. a deposit the caller knows is safe to repeat.
+func WithRetry(ctx context.Context, fn func() error) error {
+	return withRetry(ctx, currentRetryPolicy(), fn)
+}
+
+// currentRetryPolicy returns the retry policy of the running engine, or
+// DefaultRetryPolicy if it isn't running.
+func currentRetryPolicy() RetryPolicy {
+	engineMu.RLock()
+	defer engineMu.RUnlock()
+	if !initialized {
+		return DefaultRetryPolicy
+	}
+	return engineConfig.retryPolicy()
+}
+
+func withRetry(ctx context.Context, policy RetryPolicy, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || !IsTransient(err) {
+			return err
+		}
+		if attempt >= policy.Attempts {
+			if attempt > 1 {
+				log.Warn("Mainchain call failed, giving up", "attempts", attempt, "err", err)
+				return fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+			}
+			return err
+		}
+		delay := policy.delay(attempt)
+		log.Debug("Mainchain call failed, retrying", "attempt", attempt, "delay", delay, "err", err)
+		rpcRetriesCounter.Inc(1)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("drivechain call aborted: %w", ctx.Err())
+		}
+	}
+}
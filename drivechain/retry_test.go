@@ -0,0 +1,71 @@
+package drivechain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestWithRetry(t *testing.T) {
+	policy := RetryPolicy{Attempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	transient := fmt.Errorf("%w: connection refused", ErrMainchainUnreachable)
+
+	// Transient failures are retried until the call succeeds.
+	calls := 0
+	err := withRetry(context.Background(), policy, func() error {
+		if calls++; calls < 3 {
+			return transient
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("have %d calls, err %v, want 3 calls, no error", calls, err)
+	}
+	// Other errors aren't.
+	calls = 0
+	err = withRetry(context.Background(), policy, func() error {
+		calls++
+		return ErrUnknownWithdrawal
+	})
+	if err != ErrUnknownWithdrawal || calls != 1 {
+		t.Errorf("have %d calls, err %v, want 1 call, error %v", calls, err, ErrUnknownWithdrawal)
+	}
+	// The last error is returned once the policy is exhausted.
+	calls = 0
+	err = withRetry(context.Background(), policy, func() error {
+		calls++
+		return transient
+	})
+	if !errors.Is(err, ErrMainchainUnreachable) || calls != 3 {
+		t.Errorf("have %d calls, err %v, want 3 calls, error %v", calls, err, ErrMainchainUnreachable)
+	}
+	// Waiting for a retry stops when the context is done.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = withRetry(ctx, RetryPolicy{Attempts: 2, BaseDelay: time.Hour, MaxDelay: time.Hour}, func() error {
+		return transient
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("error mismatch: have %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{Attempts: 10, BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+	for retry, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		if have := policy.delay(retry + 1); have != want {
+			t.Errorf("retry %d: delay mismatch: have %v, want %v", retry+1, have, want)
+		}
+	}
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if have := policy.delay(1); have <= time.Second/2 || have > time.Second {
+			t.Fatalf("jittered delay %v out of range", have)
+		}
+	}
+	if have := (&Config{}).retryPolicy(); have != DefaultRetryPolicy {
+		t.Errorf("default policy mismatch: have %+v, want %+v", have, DefaultRetryPolicy)
+	}
+}
@@ -0,0 +1,187 @@
+package drivechain
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// mainchainRPCID is the id of the last JSON-RPC request sent to a mainchain
+// node, incremented atomically.
+var mainchainRPCID uint64
+
+// mainchainClient is a JSON-RPC client of the mainchain node, for the read-only
+// calls that don't need to go through the engine. Timeouts are left to the
+// contexts passed to its methods.
+type mainchainClient struct {
+	cfg *Config // Node address and credentials
+}
+
+func newMainchainClient(cfg *Config) *mainchainClient {
+	return &mainchainClient{cfg: cfg}
+}
+
+// mainchainRPCError is an error returned by the mainchain node for an RPC call.
+type mainchainRPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error implements error.
+func (e *mainchainRPCError) Error() string {
+	return fmt.Sprintf("mainchain rpc error %d: %s", e.Code, e.Message)
+}
+
+// mainchainHTTPError is returned for responses without a JSON-RPC envelope,
+// e.g. when the node rejects the credentials.
+type mainchainHTTPError struct {
+	Method     string
+	StatusCode int
+	Status     string
+	Body       string
+}
+
+// Error implements error.
+func (e *mainchainHTTPError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("mainchain %s failed: %s", e.Method, e.Status)
+	}
+	return fmt.Sprintf("mainchain %s failed: %s: %s", e.Method, e.Status, e.Body)
+}
+
+// call calls method on the mainchain node and decodes the result into result.
+// Errors reported by the node are returned as *mainchainRPCError, responses
+// that aren't JSON-RPC as *mainchainHTTPError.
+func (c *mainchainClient) call(ctx context.Context, result interface{}, method string, params ...interface{}) error {
+	res, err := c.post(ctx, method, params...)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read mainchain %s response: %w", method, err)
+	}
+	var resp struct {
+		Result json.RawMessage    `json:"result"`
+		Error  *mainchainRPCError `json:"error"`
+	}
+	// Mainchain nodes report RPC errors with a non 200 status too, so the body
+	// is decoded first.
+	if err := json.Unmarshal(body, &resp); err != nil {
+		if res.StatusCode != http.StatusOK {
+			return &mainchainHTTPError{Method: method, StatusCode: res.StatusCode, Status: res.Status, Body: string(body)}
+		}
+		return fmt.Errorf("failed to decode mainchain %s response: %w", method, err)
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if res.StatusCode != http.StatusOK {
+		return &mainchainHTTPError{Method: method, StatusCode: res.StatusCode, Status: res.Status}
+	}
+	return json.Unmarshal(resp.Result, result)
+}
+
+// post sends a JSON-RPC request for method to the mainchain node,
+// authenticated with the credentials of the client.
+func (c *mainchainClient) post(ctx context.Context, method string, params ...interface{}) (*http.Response, error) {
+	if params == nil {
+		params = []interface{}{}
+	}
+	reqBody, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  method,
+		"params":  params,
+		"id":      atomic.AddUint64(&mainchainRPCID, 1),
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		c.cfg.rpcURL(),
+		bytes.NewReader(reqBody),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	req.SetBasicAuth(c.cfg.RPCUser, c.cfg.RPCPassword)
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := c.cfg.httpClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to establish RPC connection with mainchain: %w", err)
+	}
+	return res, nil
+}
+
+// blockchainInfo is the part of the getblockchaininfo result used here.
+type blockchainInfo struct {
+	Chain                string `json:"chain"`
+	Blocks               uint64 `json:"blocks"`
+	Headers              uint64 `json:"headers"`
+	BestBlockHash        string `json:"bestblockhash"`
+	InitialBlockDownload bool   `json:"initialblockdownload"`
+}
+
+// getBlockchainInfo calls getblockchaininfo.
+func (c *mainchainClient) getBlockchainInfo(ctx context.Context) (*blockchainInfo, error) {
+	var info blockchainInfo
+	if err := c.call(ctx, &info, "getblockchaininfo"); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// getBestBlockHash returns the hash of the tip of the mainchain node.
+func (c *mainchainClient) getBestBlockHash(ctx context.Context) (common.Hash, error) {
+	var hash string
+	if err := c.call(ctx, &hash, "getbestblockhash"); err != nil {
+		return common.Hash{}, err
+	}
+	return common.HexToHash(hash), nil
+}
+
+// getBlockHeader fetches the header of the mainchain block with the given
+// hash. It fails with ErrMainchainBlockNotFound if the node doesn't know the
+// block.
+func (c *mainchainClient) getBlockHeader(ctx context.Context, hash common.Hash) (MainchainTipInfo, error) {
+	var header struct {
+		Hash              string `json:"hash"`
+		Height            uint64 `json:"height"`
+		Time              int64  `json:"time"`
+		PreviousBlockHash string `json:"previousblockhash"`
+	}
+	if err := c.call(ctx, &header, "getblockheader", hash.Hex()[2:]); err != nil {
+		if rpcErr, ok := err.(*mainchainRPCError); ok && rpcErr.Code == rpcErrorInvalidAddressOrKey {
+			return MainchainTipInfo{}, fmt.Errorf("%w: %s", ErrMainchainBlockNotFound, hash.Hex()[2:])
+		}
+		return MainchainTipInfo{}, err
+	}
+	return MainchainTipInfo{
+		Hash:     common.HexToHash(header.Hash),
+		Height:   header.Height,
+		Time:     time.Unix(header.Time, 0),
+		PrevHash: common.HexToHash(header.PreviousBlockHash),
+	}, nil
+}
+
+// getTipInfo returns the header of the tip of the mainchain node. The tip can
+// change between the two calls it takes, in which case the header of the old
+// tip is returned.
+func (c *mainchainClient) getTipInfo(ctx context.Context) (MainchainTipInfo, error) {
+	hash, err := c.getBestBlockHash(ctx)
+	if err != nil {
+		return MainchainTipInfo{}, err
+	}
+	return c.getBlockHeader(ctx, hash)
+}
@@ -0,0 +1,107 @@
+package drivechain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestMainchainClient(t *testing.T) {
+	var (
+		tip    = common.HexToHash("0x00000000000000000002a7c4c1e48d76c5a37902165a270156b7a8d72728a054")
+		parent = common.HexToHash("0x00000000000000000001f1a8b4e8e0d6f3c7b1b9e8ad3e0f2a3f1b0c5d6e7f80")
+
+		mu  sync.Mutex
+		ids []uint64
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "user" || password != "password" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var req struct {
+			Method string        `json:"method"`
+			Params []interface{} `json:"params"`
+			ID     uint64        `json:"id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		ids = append(ids, req.ID)
+		mu.Unlock()
+		switch {
+		case req.Method == "getblockchaininfo":
+			w.Write([]byte(`{"result": {"chain": "regtest", "blocks": 812345, "headers": 812345, "bestblockhash": "` + tip.Hex()[2:] + `"}, "error": null, "id": 1}`))
+		case req.Method == "getbestblockhash":
+			w.Write([]byte(`{"result": "` + tip.Hex()[2:] + `", "error": null, "id": 1}`))
+		case req.Method == "getblockheader" && req.Params[0] == tip.Hex()[2:]:
+			w.Write([]byte(`{"result": {"hash": "` + tip.Hex()[2:] + `", "height": 812345, "time": 1697000000, "previousblockhash": "` + parent.Hex()[2:] + `"}, "error": null, "id": 1}`))
+		case req.Method == "getblockheader":
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"result": null, "error": {"code": -5, "message": "Block not found"}, "id": 1}`))
+		case req.Method == "slow":
+			<-r.Context().Done()
+		}
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	port, _ := strconv.Atoi(u.Port())
+	cfg := Config{Host: u.Hostname(), Port: uint16(port), RPCUser: "user", RPCPassword: "password"}
+	client := newMainchainClient(&cfg)
+	ctx := context.Background()
+
+	info, err := client.getBlockchainInfo(ctx)
+	if err != nil {
+		t.Fatalf("failed to get blockchain info: %v", err)
+	}
+	if info.Chain != "regtest" || info.Blocks != 812345 || common.HexToHash(info.BestBlockHash) != tip {
+		t.Errorf("blockchain info mismatch: have %+v", info)
+	}
+	header, err := client.getTipInfo(ctx)
+	if err != nil {
+		t.Fatalf("failed to get tip: %v", err)
+	}
+	want := MainchainTipInfo{Hash: tip, Height: 812345, Time: time.Unix(1697000000, 0), PrevHash: parent}
+	if header != want {
+		t.Errorf("tip mismatch: have %+v, want %+v", header, want)
+	}
+	_, err = client.getBlockHeader(ctx, common.Hash{1})
+	if !errors.Is(err, ErrMainchainBlockNotFound) {
+		t.Errorf("unknown block: error mismatch: have %v, want %v", err, ErrMainchainBlockNotFound)
+	}
+
+	// Every request gets its own id.
+	mu.Lock()
+	for i := 1; i < len(ids); i++ {
+		if ids[i] <= ids[i-1] {
+			t.Errorf("request ids not increasing: %v", ids)
+			break
+		}
+	}
+	mu.Unlock()
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err := client.call(timeoutCtx, nil, "slow"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("timeout: error mismatch: have %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	badCfg := cfg
+	badCfg.RPCPassword = "wrong"
+	var httpErr *mainchainHTTPError
+	if _, err := newMainchainClient(&badCfg).getBestBlockHash(ctx); !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("bad credentials: have %v, want status %d", err, http.StatusUnauthorized)
+	}
+}
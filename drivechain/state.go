@@ -0,0 +1,108 @@
+package drivechain
+
+/*
+#include "./bindings.h"
+*/
+import "C"
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"unsafe"
+)
+
+// stateMagic starts every state snapshot made by ExportState.
+var stateMagic = []byte("DCST")
+
+// stateHeaderLength is the length of the snapshot header: the magic followed
+// by the format version as a 4 byte big endian integer.
+const stateHeaderLength = 4 + 4
+
+// ExportState snapshots the engine's state, its pending withdrawals, BMM
+// requests and known deposits, so it can be restored on another machine with
+// ImportState. The snapshot is written in the newest format the engine
+// supports, which is recorded in its header.
+func ExportState() ([]byte, error) {
+	if err := rlockEngine(); err != nil {
+		return nil, err
+	}
+	defer engineMu.RUnlock()
+	_, version, err := stateFormatVersions()
+	if err != nil {
+		return nil, err
+	}
+	return newStateSnapshot(version, C.export_state(C.uint32_t(version)))
+}
+
+// newStateSnapshot prefixes the state exported by the engine in the given
+// format version with the snapshot header, freeing the state.
+func newStateSnapshot(version uint32, cState C.StateBlob) ([]byte, error) {
+	defer C.free_state(cState)
+	if !bool(cState.valid) {
+		return nil, fmt.Errorf("failed to export state: %w: %s", ErrEngineFailure, getLastError())
+	}
+	snapshot := make([]byte, stateHeaderLength, stateHeaderLength+int(cState.len))
+	copy(snapshot, stateMagic)
+	binary.BigEndian.PutUint32(snapshot[len(stateMagic):], version)
+	if cState.len > 0 {
+		snapshot = append(snapshot, unsafe.Slice((*byte)(unsafe.Pointer(cState.ptr)), cState.len)...)
+	}
+	return snapshot, nil
+}
+
+// ImportState replaces the engine's state with a snapshot made by
+// ExportState. Snapshots in a format the engine doesn't support, e.g. made by
+// a newer engine, are rejected with ErrUnsupportedStateVersion.
+func ImportState(snapshot []byte) error {
+	version, payload, err := splitStateSnapshot(snapshot)
+	if err != nil {
+		return err
+	}
+	if err := lockEngine(); err != nil {
+		return err
+	}
+	defer engineMu.Unlock()
+	if err := checkStateVersion(version); err != nil {
+		return err
+	}
+	defer depositCache.invalidate()
+	var args cArgs
+	defer args.free()
+	if !bool(C.import_state(args.cBytes(payload), C.uintptr_t(len(payload)), C.uint32_t(version))) {
+		return fmt.Errorf("failed to import state: %w: %s", ErrEngineFailure, getLastError())
+	}
+	return nil
+}
+
+// splitStateSnapshot checks the header of a snapshot and returns its format
+// version and the engine's serialized state.
+func splitStateSnapshot(snapshot []byte) (uint32, []byte, error) {
+	if len(snapshot) < stateHeaderLength || !bytes.Equal(snapshot[:len(stateMagic)], stateMagic) {
+		return 0, nil, ErrInvalidStateSnapshot
+	}
+	return binary.BigEndian.Uint32(snapshot[len(stateMagic):]), snapshot[stateHeaderLength:], nil
+}
+
+// checkStateVersion rejects snapshots in a format version the engine can't
+// read with ErrUnsupportedStateVersion. The caller must hold the engine's
+// lock.
+func checkStateVersion(version uint32) error {
+	min, max, err := stateFormatVersions()
+	if err != nil {
+		return err
+	}
+	if version < min || version > max {
+		return fmt.Errorf("%w: version %d, engine supports %d to %d", ErrUnsupportedStateVersion, version, min, max)
+	}
+	return nil
+}
+
+// stateFormatVersions returns the range of snapshot formats the engine can
+// read and write. The caller must hold the engine lock.
+func stateFormatVersions() (uint32, uint32, error) {
+	var min, max C.uint32_t
+	if !bool(C.state_format_versions(&min, &max)) {
+		return 0, 0, fmt.Errorf("failed to get state format versions: %w: %s", ErrEngineFailure, getLastError())
+	}
+	return uint32(min), uint32(max), nil
+}
@@ -0,0 +1,277 @@
+package drivechain
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// DefaultDepositPollInterval is how often the engine is polled for new deposits
+// when Config.DepositPollInterval isn't set.
+const DefaultDepositPollInterval = time.Second
+
+// depositSubscriptions are the deposit subscribers of an engine together with
+// the poller feeding them.
+type depositSubscriptions struct {
+	feed event.Feed
+
+	// mu protects the poller and subscription scope. When both are needed,
+	// the engine's lock must be acquired before mu.
+	mu    sync.Mutex
+	scope *event.SubscriptionScope
+	quit  chan struct{} // Closed to stop the poller, nil if it's not running
+}
+
+func newDepositSubscriptions() *depositSubscriptions {
+	return &depositSubscriptions{scope: new(event.SubscriptionScope)}
+}
+
+// depositSubs are the deposit subscriptions of the engine started by Init.
+var depositSubs = newDepositSubscriptions()
+
+// SubscribeDeposits sends deposit outputs to ch as the engine observes them on
+// mainchain. Every deposit output is sent once, to the subscribers present when
+// it first shows up. Deposits already pending when polling starts aren't sent.
+// The engine is polled every Config.DepositPollInterval from the first
+// subscription until the last one ends or the engine is shut down, which also
+// ends the subscriptions. If the engine isn't running the subscription fails
+// with ErrNotInitialized.
+func SubscribeDeposits(ch chan<- Deposit) event.Subscription {
+	if err := rlockEngine(); err != nil {
+		return event.NewSubscription(func(<-chan struct{}) error { return err })
+	}
+	defer engineMu.RUnlock()
+	return depositSubs.subscribe(ch, engineConfig.depositPollInterval(), GetDepositOutputs)
+}
+
+// subscribe adds a subscription, starting the poller calling get every
+// interval if it isn't running. The caller must hold the engine's lock.
+func (s *depositSubscriptions) subscribe(ch chan<- Deposit, interval time.Duration, get func() ([]Deposit, error)) event.Subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub := s.scope.Track(s.feed.Subscribe(ch))
+	if s.quit == nil {
+		s.quit = make(chan struct{})
+		go s.poll(interval, s.quit, get)
+	}
+	return sub
+}
+
+// stop stops the poller and ends all subscriptions. The caller must hold the
+// engine's exclusive lock.
+func (s *depositSubscriptions) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.quit != nil {
+		close(s.quit)
+		s.quit = nil
+	}
+	s.scope.Close()
+	s.scope = new(event.SubscriptionScope)
+}
+
+func (s *depositSubscriptions) poll(interval time.Duration, quit chan struct{}, get func() ([]Deposit, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var tracker depositTracker
+	poll := func() {
+		deposits, err := get()
+		if err != nil {
+			log.Debug("Failed to poll deposit outputs", "err", err)
+			return
+		}
+		for _, deposit := range tracker.update(deposits) {
+			depositsSeenCounter.Inc(1)
+			s.feed.Send(deposit)
+		}
+	}
+	poll()
+	for {
+		select {
+		case <-ticker.C:
+		case <-quit:
+			return
+		}
+		if !s.needed(quit) {
+			return
+		}
+		poll()
+	}
+}
+
+// needed reports whether the poller with the given quit channel still has
+// subscribers, marking it stopped if it hasn't.
+func (s *depositSubscriptions) needed(quit chan struct{}) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.quit != quit {
+		return false
+	}
+	if s.scope.Count() == 0 {
+		s.quit = nil
+		return false
+	}
+	return true
+}
+
+// depositTracker finds the deposit outputs new since the previous poll.
+type depositTracker struct {
+	pending map[common.Hash]struct{} // Nil before the first update
+}
+
+// update moves the tracker to the deposits pending now and returns those that
+// weren't pending at the previous update. The first update only sets the
+// baseline and returns nothing.
+func (t *depositTracker) update(deposits []Deposit) []Deposit {
+	var fresh []Deposit
+	// Only remember the deposits still pending, so the set doesn't grow with
+	// every deposit ever made.
+	current := make(map[common.Hash]struct{}, len(deposits))
+	for i := range deposits {
+		key := deposits[i].Hash()
+		current[key] = struct{}{}
+		if _, ok := t.pending[key]; !ok && t.pending != nil {
+			fresh = append(fresh, deposits[i])
+		}
+	}
+	t.pending = current
+	return fresh
+}
+
+// DepositStream is a channel based variant of SubscribeDeposits. The returned
+// channel is closed once ctx is done or the engine is shut down.
+func DepositStream(ctx context.Context) (<-chan Deposit, error) {
+	if err := rlockEngine(); err != nil {
+		return nil, err
+	}
+	engineMu.RUnlock()
+	return depositStream(ctx, SubscribeDeposits), nil
+}
+
+// depositStream forwards the deposits of a subscription made with subscribe
+// to the returned channel until ctx is done or the subscription ends.
+func depositStream(ctx context.Context, subscribe func(chan<- Deposit) event.Subscription) <-chan Deposit {
+	in := make(chan Deposit)
+	sub := subscribe(in)
+	out := make(chan Deposit)
+	go func() {
+		defer close(out)
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case deposit := <-in:
+				select {
+				case out <- deposit:
+				case <-ctx.Done():
+					return
+				}
+			case <-sub.Err():
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// BmmResult is the outcome of the BMM attempt ListenForBmmConfirmation waited
+// for.
+type BmmResult struct {
+	State BmmState
+	// MainBlockHash is the mainchain block the attempt was included in, zero
+	// unless State is Succeeded.
+	MainBlockHash common.Hash
+	// Err is set if ConfirmBmm failed or ctx was done before the attempt
+	// was decided. State is Pending then.
+	Err error
+}
+
+// ListenForBmmConfirmation polls ConfirmBmm every pollInterval until the last
+// BMM attempt is no longer pending. With Config.ZMQEndpoint it also polls as
+// soon as a new mainchain block arrives. The returned channel receives exactly one
+// result and is then closed. If the first poll fails, its error is returned
+// instead.
+func ListenForBmmConfirmation(ctx context.Context, pollInterval time.Duration) (<-chan BmmResult, error) {
+	return listenForBmmConfirmation(ctx, pollInterval, ConfirmBmm)
+}
+
+// listenForBmmConfirmation is ListenForBmmConfirmation polling confirm.
+func listenForBmmConfirmation(ctx context.Context, pollInterval time.Duration, confirm func(context.Context) (BmmState, common.Hash, error)) (<-chan BmmResult, error) {
+	if pollInterval <= 0 {
+		return nil, fmt.Errorf("invalid bmm poll interval %v", pollInterval)
+	}
+	state, mainBlockHash, err := confirm(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Buffered so the poller never blocks on a caller that stopped listening.
+	results := make(chan BmmResult, 1)
+	if state != Pending {
+		results <- BmmResult{State: state, MainBlockHash: mainBlockHash}
+		close(results)
+		return results, nil
+	}
+	go func() {
+		defer close(results)
+		for {
+			if !waitMainchainBlock(pollInterval, ctx.Done()) {
+				results <- BmmResult{State: Pending, Err: ctx.Err()}
+				return
+			}
+			state, mainBlockHash, err := confirm(ctx)
+			if err != nil {
+				results <- BmmResult{State: Pending, Err: err}
+				return
+			}
+			if state != Pending {
+				results <- BmmResult{State: state, MainBlockHash: mainBlockHash}
+				return
+			}
+		}
+	}()
+	return results, nil
+}
+
+// bmmPollInterval is how often WaitForBmm asks the engine about the pending
+// BMM attempt between mainchain block notifications.
+const bmmPollInterval = time.Second
+
+// WaitForBmm blocks until the pending BMM attempt is decided and returns its
+// outcome, Succeeded together with the mainchain block it was included in, or
+// Failed. The engine is asked on every new mainchain block with
+// Config.ZMQEndpoint, and every second otherwise. It fails with
+// ErrNoBmmPending if there is no attempt or it's cancelled while waiting, with
+// ErrEngineFailure if the engine reports a state this package doesn't know, and
+// with ctx.Err() once ctx is done.
+func WaitForBmm(ctx context.Context) (BmmState, common.Hash, error) {
+	return waitForBmm(ctx, ConfirmBmm)
+}
+
+// waitForBmm is WaitForBmm asking confirm.
+func waitForBmm(ctx context.Context, confirm func(context.Context) (BmmState, common.Hash, error)) (BmmState, common.Hash, error) {
+	for {
+		state, mainBlockHash, err := confirm(ctx)
+		if err != nil {
+			return Pending, common.Hash{}, err
+		}
+		switch state {
+		case Succeeded, Failed:
+			return state, mainBlockHash, nil
+		case Idle, Cancelled:
+			return state, common.Hash{}, fmt.Errorf("%w: %v", ErrNoBmmPending, state)
+		case Pending:
+		default:
+			return state, common.Hash{}, fmt.Errorf("%w: unexpected bmm state %v", ErrEngineFailure, state)
+		}
+		if !waitMainchainBlock(bmmPollInterval, ctx.Done()) {
+			return Pending, common.Hash{}, ctx.Err()
+		}
+	}
+}
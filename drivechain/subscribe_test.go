@@ -0,0 +1,98 @@
+package drivechain
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestDepositTracker(t *testing.T) {
+	deposit := func(n int64) Deposit {
+		return Deposit{Address: common.BigToAddress(big.NewInt(n)), Amount: big.NewInt(10000)}
+	}
+	a, b, c := deposit(1), deposit(2), deposit(3)
+
+	var tracker depositTracker
+	steps := []struct {
+		pending []Deposit
+		fresh   []Deposit
+	}{
+		// Deposits pending before the first update aren't new.
+		{pending: []Deposit{a, b}},
+		{pending: []Deposit{a, b}},
+		{pending: []Deposit{a, b, c}, fresh: []Deposit{c}},
+		// Paid out deposits are forgotten.
+		{pending: []Deposit{c}},
+		{pending: []Deposit{a, c}, fresh: []Deposit{a}},
+	}
+	for i, step := range steps {
+		fresh := tracker.update(step.pending)
+		if len(fresh) != len(step.fresh) {
+			t.Fatalf("step %d: have %d new deposits, want %d", i, len(fresh), len(step.fresh))
+		}
+		for j := range fresh {
+			if fresh[j].Hash() != step.fresh[j].Hash() {
+				t.Errorf("step %d: deposit %d mismatch: have %v, want %v", i, j, fresh[j], step.fresh[j])
+			}
+		}
+	}
+}
+
+// Tests that the deposit poller stops once the last subscriber is gone, and
+// starts again with the next subscription.
+func TestDepositPollerStops(t *testing.T) {
+	initTestEngine(t, nil)
+	engineMu.Lock()
+	engineConfig.DepositPollInterval = 10 * time.Millisecond
+	engineMu.Unlock()
+
+	running := func() bool {
+		depositSubs.mu.Lock()
+		defer depositSubs.mu.Unlock()
+		return depositSubs.quit != nil
+	}
+	for i := 0; i < 2; i++ {
+		sub := SubscribeDeposits(make(chan Deposit))
+		if !running() {
+			t.Fatalf("round %d: poller not started", i)
+		}
+		sub.Unsubscribe()
+		for deadline := time.Now().Add(time.Second); running(); time.Sleep(10 * time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatalf("round %d: poller still running without subscribers", i)
+			}
+		}
+	}
+}
+
+// Tests that WaitForBmm gives up on a state it doesn't know instead of waiting
+// for it to be decided.
+func TestWaitForBmmUnknownState(t *testing.T) {
+	defer SetDefault(SetDefault(bmmStateReporter{state: Unknown}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	state, _, err := WaitForBmm(ctx)
+	if !errors.Is(err, ErrEngineFailure) || !strings.Contains(err.Error(), Unknown.String()) {
+		t.Fatalf("error mismatch: have %v, want %v naming %v", err, ErrEngineFailure, Unknown)
+	}
+	if state != Unknown {
+		t.Errorf("state mismatch: have %v, want %v", state, Unknown)
+	}
+}
+
+// bmmStateReporter is a Drivechain whose BMM attempt is always in the same
+// state.
+type bmmStateReporter struct {
+	CGO
+	state BmmState
+}
+
+func (r bmmStateReporter) ConfirmBmm(ctx context.Context) (BmmState, common.Hash, error) {
+	return r.state, common.Hash{}, nil
+}
@@ -0,0 +1,210 @@
+package drivechain
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// DefaultTipPollInterval is how often the mainchain tip is polled when
+// Config.TipPollInterval isn't set.
+const DefaultTipPollInterval = 2 * time.Second
+
+// mainchainHeaderCacheSize is the number of mainchain headers below the tip
+// remembered to find the fork point of a reorg, and the most headers fetched
+// to link a new tip to them. Reorgs deeper than that are reported without a
+// fork point.
+const mainchainHeaderCacheSize = 64
+
+// MainchainTipEvent is sent by SubscribeMainchainTip when the mainchain tip
+// changes.
+type MainchainTipEvent struct {
+	NewTip MainchainTipInfo
+	OldTip MainchainTipInfo
+
+	// Reorg is set if the new tip doesn't extend the old one.
+	Reorg bool
+	// ForkPoint is the last block the old and the new chain have in common.
+	// It's nil unless Reorg is set, and also if the reorg is deeper than the
+	// headers remembered by the poller. A new tip further ahead than that
+	// can't be linked to the old one, so it's reported as a reorg without a
+	// fork point too.
+	ForkPoint *MainchainTipInfo
+}
+
+// tipSubscriptions are the mainchain tip subscribers of an engine together
+// with the poller feeding them.
+type tipSubscriptions struct {
+	feed event.Feed
+
+	// mu protects the poller and subscription scope. When both are needed,
+	// the engine's lock must be acquired before mu.
+	mu    sync.Mutex
+	scope *event.SubscriptionScope
+	quit  chan struct{} // Closed to stop the poller, nil if it's not running
+}
+
+func newTipSubscriptions() *tipSubscriptions {
+	return &tipSubscriptions{scope: new(event.SubscriptionScope)}
+}
+
+// tipSubs are the tip subscriptions of the engine started by Init.
+var tipSubs = newTipSubscriptions()
+
+// SubscribeMainchainTip sends an event to ch every time the mainchain tip
+// changes, flagging reorgs together with their fork point, so that sidechain
+// blocks merge mined in orphaned mainchain blocks can be unwound right away.
+// The tip is polled every Config.TipPollInterval, or on every new block with
+// Config.ZMQEndpoint, from the first subscription until the engine is shut
+// down, which also ends the subscriptions. If the engine isn't running the
+// subscription fails with ErrNotInitialized.
+func SubscribeMainchainTip(ch chan<- MainchainTipEvent) event.Subscription {
+	if err := rlockEngine(); err != nil {
+		return event.NewSubscription(func(<-chan struct{}) error { return err })
+	}
+	defer engineMu.RUnlock()
+	return tipSubs.subscribe(ch, engineConfig.tipPollInterval(), GetMainchainTipInfo, mainchainHeader)
+}
+
+// subscribe adds a subscription, starting the poller if it isn't running. The
+// poller follows the tip returned by tipInfo every interval, fetching the
+// headers in between with header. The caller must hold the engine's lock.
+func (s *tipSubscriptions) subscribe(ch chan<- MainchainTipEvent, interval time.Duration, tipInfo func() (MainchainTipInfo, error), header func(common.Hash) (MainchainTipInfo, error)) event.Subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub := s.scope.Track(s.feed.Subscribe(ch))
+	if s.quit == nil {
+		s.quit = make(chan struct{})
+		go s.poll(interval, s.quit, tipInfo, header)
+	}
+	return sub
+}
+
+// stop stops the poller and ends all subscriptions. The caller must hold the
+// engine's exclusive lock.
+func (s *tipSubscriptions) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.quit != nil {
+		close(s.quit)
+		s.quit = nil
+	}
+	s.scope.Close()
+	s.scope = new(event.SubscriptionScope)
+}
+
+// poll follows the mainchain tip until quit is closed. tipInfo and header are
+// expected to read the RPC credentials on every call, so that the poller
+// keeps working after Ping or Reconnect switched the engine over to new ones.
+func (s *tipSubscriptions) poll(interval time.Duration, quit chan struct{}, tipInfo func() (MainchainTipInfo, error), header func(common.Hash) (MainchainTipInfo, error)) {
+	var tracker tipTracker
+	for {
+		tip, err := tipInfo()
+		if err != nil {
+			log.Debug("Failed to poll mainchain tip", "err", err)
+		} else if ev, err := tracker.update(tip, header); err != nil {
+			log.Debug("Failed to follow mainchain tip", "err", err)
+		} else if ev != nil {
+			if ev.Reorg {
+				log.Warn("Mainchain reorg", "old", ev.OldTip.Hash, "new", ev.NewTip.Hash, "oldheight", ev.OldTip.Height, "newheight", ev.NewTip.Height)
+			}
+			s.feed.Send(*ev)
+		}
+		if !waitMainchainBlock(interval, quit) {
+			return
+		}
+	}
+}
+
+// mainchainHeader fetches the header of the mainchain block with the given
+// hash from the mainchain node, using the RPC credentials the engine is
+// currently using.
+func mainchainHeader(hash common.Hash) (MainchainTipInfo, error) {
+	if err := rlockEngine(); err != nil {
+		return MainchainTipInfo{}, err
+	}
+	cfg := engineConfig
+	engineMu.RUnlock()
+	return fetchMainchainHeader(&cfg, hash)
+}
+
+// fetchMainchainHeader fetches the header of the mainchain block with the
+// given hash from the node of cfg, retrying as its retry policy allows.
+func fetchMainchainHeader(cfg *Config, hash common.Hash) (MainchainTipInfo, error) {
+	var header MainchainTipInfo
+	err := withRetry(context.Background(), cfg.retryPolicy(), func() (err error) {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.rpcTimeout())
+		defer cancel()
+		header, err = newMainchainClient(cfg).getBlockHeader(ctx, hash)
+		return err
+	})
+	return header, err
+}
+
+// tipTracker follows the mainchain tip, remembering the most recent headers
+// of the chain it's on, oldest first.
+type tipTracker struct {
+	headers []MainchainTipInfo
+}
+
+// update moves the tracker to tip, fetching the headers between the old and
+// the new tip with header. It returns the event to send, nil if the tip didn't
+// change or on the first update. The new tip is reported as a reorg if its
+// chain doesn't link to the remembered headers within
+// mainchainHeaderCacheSize headers, without a fork point if no remembered
+// header was reached.
+func (t *tipTracker) update(tip MainchainTipInfo, header func(common.Hash) (MainchainTipInfo, error)) (*MainchainTipEvent, error) {
+	if len(t.headers) == 0 {
+		t.headers = append(t.headers, tip)
+		return nil, nil
+	}
+	old := t.headers[len(t.headers)-1]
+	if tip.Hash == old.Hash {
+		return nil, nil
+	}
+	// Walk back from the new tip until reaching a known header, until the
+	// parents are older than any known header, or until giving up after
+	// mainchainHeaderCacheSize headers.
+	var (
+		branch = []MainchainTipInfo{tip}
+		fork   = -1
+		oldest = t.headers[0].Height
+	)
+	for {
+		last := branch[len(branch)-1]
+		prev := last.PrevHash
+		for i := len(t.headers) - 1; i >= 0; i-- {
+			if t.headers[i].Hash == prev {
+				fork = i
+				break
+			}
+		}
+		if fork >= 0 || prev == (common.Hash{}) || last.Height <= oldest+1 || len(branch) >= mainchainHeaderCacheSize {
+			break
+		}
+		h, err := header(prev)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get mainchain header %x: %w", prev, err)
+		}
+		branch = append(branch, h)
+	}
+	ev := &MainchainTipEvent{NewTip: tip, OldTip: old, Reorg: fork != len(t.headers)-1}
+	if fork >= 0 && ev.Reorg {
+		forkPoint := t.headers[fork]
+		ev.ForkPoint = &forkPoint
+	}
+	// Replace everything above the fork point with the new branch.
+	t.headers = t.headers[:fork+1]
+	for i := len(branch) - 1; i >= 0; i-- {
+		t.headers = append(t.headers, branch[i])
+	}
+	if n := len(t.headers) - mainchainHeaderCacheSize; n > 0 {
+		t.headers = append(t.headers[:0], t.headers[n:]...)
+	}
+	return ev, nil
+}
@@ -0,0 +1,160 @@
+package drivechain
+
+import (
+	"errors"
+	"math/big"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestTipTracker(t *testing.T) {
+	// Two chains forking after block 2: 1-2-3-4 and 1-2-13-14-15.
+	headers := make(map[common.Hash]MainchainTipInfo)
+	block := func(n, parent byte, height uint64) MainchainTipInfo {
+		h := MainchainTipInfo{Hash: common.Hash{n}, Height: height, PrevHash: common.Hash{parent}}
+		headers[h.Hash] = h
+		return h
+	}
+	block(1, 0, 1)
+	b2, b3, b4 := block(2, 1, 2), block(3, 2, 3), block(4, 3, 4)
+	block(13, 2, 3)
+	b14, b15 := block(14, 13, 4), block(15, 14, 5)
+	lookup := func(hash common.Hash) (MainchainTipInfo, error) {
+		if h, ok := headers[hash]; ok {
+			return h, nil
+		}
+		return MainchainTipInfo{}, ErrMainchainBlockNotFound
+	}
+
+	var tracker tipTracker
+	steps := []struct {
+		tip       MainchainTipInfo
+		event     bool
+		reorg     bool
+		forkPoint *MainchainTipInfo
+	}{
+		// The first update has nothing to compare with.
+		{tip: b2},
+		{tip: b2},
+		// Extended by two blocks.
+		{tip: b4, event: true},
+		{tip: b15, event: true, reorg: true, forkPoint: &b2},
+		{tip: b15},
+		// And back.
+		{tip: b4, event: true, reorg: true, forkPoint: &b2},
+	}
+	for i, step := range steps {
+		ev, err := tracker.update(step.tip, lookup)
+		if err != nil {
+			t.Fatalf("step %d: update failed: %v", i, err)
+		}
+		if (ev != nil) != step.event {
+			t.Fatalf("step %d: have event %+v, want event %t", i, ev, step.event)
+		}
+		if ev == nil {
+			continue
+		}
+		if ev.NewTip != step.tip || ev.Reorg != step.reorg {
+			t.Errorf("step %d: event mismatch: have %+v", i, ev)
+		}
+		if (ev.ForkPoint == nil) != (step.forkPoint == nil) || (ev.ForkPoint != nil && *ev.ForkPoint != *step.forkPoint) {
+			t.Errorf("step %d: fork point mismatch: have %v, want %v", i, ev.ForkPoint, step.forkPoint)
+		}
+	}
+	if ev, _ := tracker.update(b14, lookup); ev == nil || !ev.Reorg || ev.OldTip != b4 || *ev.ForkPoint != b2 {
+		t.Errorf("reorg to a shorter chain: have %+v", ev)
+	}
+	if ev, _ := tracker.update(b3, lookup); ev == nil || !ev.Reorg || *ev.ForkPoint != b2 {
+		t.Errorf("reorg to a shorter chain: have %+v", ev)
+	}
+	// A header missing on mainchain fails the update without losing track.
+	orphan := MainchainTipInfo{Hash: common.Hash{99}, Height: 10, PrevHash: common.Hash{98}}
+	if _, err := tracker.update(orphan, lookup); !errors.Is(err, ErrMainchainBlockNotFound) {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrMainchainBlockNotFound)
+	}
+	if ev, _ := tracker.update(b4, lookup); ev == nil || ev.Reorg || ev.OldTip != b3 {
+		t.Errorf("extension after failed update: have %+v", ev)
+	}
+}
+
+func TestTipTrackerGap(t *testing.T) {
+	// A chain 1-...-200, and a branch forking off it after block 100.
+	headers := make(map[common.Hash]MainchainTipInfo)
+	hash := func(n uint64, fork bool) common.Hash {
+		h := common.BigToHash(new(big.Int).SetUint64(n))
+		if fork {
+			h[0] = 1
+		}
+		return h
+	}
+	for n := uint64(1); n <= 200; n++ {
+		headers[hash(n, false)] = MainchainTipInfo{Hash: hash(n, false), Height: n, PrevHash: hash(n-1, false)}
+		if n > 100 {
+			parent := hash(n-1, n > 101)
+			headers[hash(n, true)] = MainchainTipInfo{Hash: hash(n, true), Height: n, PrevHash: parent}
+		}
+	}
+	lookup := func(hash common.Hash) (MainchainTipInfo, error) {
+		if h, ok := headers[hash]; ok {
+			return h, nil
+		}
+		return MainchainTipInfo{}, ErrMainchainBlockNotFound
+	}
+
+	var tracker tipTracker
+	tracker.update(headers[hash(1, false)], lookup)
+	// Moving ahead by fewer blocks than are remembered extends the chain.
+	ev, err := tracker.update(headers[hash(50, false)], lookup)
+	if err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	if ev == nil || ev.Reorg || ev.ForkPoint != nil || ev.OldTip.Height != 1 {
+		t.Errorf("forward gap reported as reorg: have %+v", ev)
+	}
+	// Moving further ahead can't be linked to the old tip.
+	ev, err = tracker.update(headers[hash(200, false)], lookup)
+	if err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	if ev == nil || !ev.Reorg || ev.ForkPoint != nil || ev.OldTip.Height != 50 {
+		t.Errorf("unlinked forward gap mismatch: have %+v", ev)
+	}
+	if len(tracker.headers) != mainchainHeaderCacheSize {
+		t.Errorf("remembered %d headers, want %d", len(tracker.headers), mainchainHeaderCacheSize)
+	}
+	// A reorg deeper than the remembered headers has no fork point either.
+	ev, err = tracker.update(headers[hash(199, true)], lookup)
+	if err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	if ev == nil || !ev.Reorg || ev.ForkPoint != nil {
+		t.Errorf("deep reorg mismatch: have %+v", ev)
+	}
+}
+
+func TestMainchainHeaderAfterReconnect(t *testing.T) {
+	var user atomic.Value
+	user.Store("user")
+	initTestEngine(t, func(w http.ResponseWriter, r *http.Request) {
+		if u, _, _ := r.BasicAuth(); u != user.Load().(string) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		testMainchainHandler(w, r)
+	})
+	cfg := engineConfig
+	if _, err := mainchainHeader(common.Hash{1}); err != nil {
+		t.Fatalf("failed to get header: %v", err)
+	}
+	// The poller fetches headers with the credentials switched to last.
+	user.Store("rotated")
+	if err := Reconnect(cfg.Host, cfg.Port, "rotated", "password"); err != nil {
+		t.Fatalf("failed to reconnect: %v", err)
+	}
+	if _, err := mainchainHeader(common.Hash{1}); err != nil {
+		t.Errorf("failed to get header after reconnect: %v", err)
+	}
+}
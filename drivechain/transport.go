@@ -0,0 +1,117 @@
+package drivechain
+
+/*
+#include "./bindings.h"
+*/
+import "C"
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// TLSConfig configures TLS for connections to a mainchain node served over
+// https.
+type TLSConfig struct {
+	CAFile   string // PEM bundle of the CAs to trust instead of the system ones
+	CertFile string // PEM client certificate, for nodes requiring one
+	KeyFile  string // PEM key of the client certificate
+
+	// InsecureSkipVerify disables verification of the node's certificate. It's
+	// only meant for development setups.
+	InsecureSkipVerify bool
+}
+
+// rpcURL returns the URL of the mainchain node's RPC server.
+func (c *Config) rpcURL() string {
+	if c.URL != "" {
+		return c.URL
+	}
+	return fmt.Sprintf("http://%s:%d", c.Host, c.Port)
+}
+
+// setupTransport checks the URL and sets up HTTPClient with Transport and the
+// TLS settings, unless an HTTPClient is given.
+func (c *Config) setupTransport() error {
+	if c.URL != "" {
+		u, err := url.Parse(c.URL)
+		if err != nil {
+			return fmt.Errorf("invalid mainchain url: %w", err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("unsupported mainchain url scheme %q, must be http or https", u.Scheme)
+		}
+	}
+	if c.HTTPClient != nil || (c.Transport == nil && c.TLS == (TLSConfig{})) {
+		return nil
+	}
+	transport := c.Transport
+	if transport == nil {
+		tlsConfig, err := c.TLS.load()
+		if err != nil {
+			return err
+		}
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.TLSClientConfig = tlsConfig
+		transport = t
+	}
+	c.HTTPClient = &http.Client{Transport: transport}
+	return nil
+}
+
+// load reads the files of t.
+func (t *TLSConfig) load() (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: t.InsecureSkipVerify}
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read mainchain CA bundle: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in mainchain CA bundle %s", t.CAFile)
+		}
+	}
+	if t.CertFile != "" || t.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load mainchain client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// setEngineTransport passes the URL and TLS settings of cfg to the engine,
+// for the mainchain RPC calls it makes itself. The caller must hold the
+// exclusive engine lock.
+func setEngineTransport(cfg *Config) error {
+	return setMainchainTransport(cfg, func(url, caFile, certFile, keyFile *C.char, insecureSkipVerify C.bool) C.bool {
+		return C.set_mainchain_transport(url, caFile, certFile, keyFile, insecureSkipVerify)
+	})
+}
+
+// setMainchainTransport passes the URL and TLS settings of cfg to an engine
+// with set, unless there are none.
+func setMainchainTransport(cfg *Config, set func(url, caFile, certFile, keyFile *C.char, insecureSkipVerify C.bool) C.bool) error {
+	if cfg.URL == "" && cfg.TLS == (TLSConfig{}) {
+		return nil
+	}
+	var args cArgs
+	defer args.free()
+	cURL := args.cString(cfg.rpcURL())
+	cCAFile := args.cString(cfg.TLS.CAFile)
+	cCertFile := args.cString(cfg.TLS.CertFile)
+	cKeyFile := args.cString(cfg.TLS.KeyFile)
+	if !bool(set(cURL, cCAFile, cCertFile, cKeyFile, C.bool(cfg.TLS.InsecureSkipVerify))) {
+		if msg := getLastError(); msg != "" {
+			return fmt.Errorf("failed to set up mainchain transport of the engine: %s", msg)
+		}
+		return errors.New("failed to set up mainchain transport of the engine")
+	}
+	return nil
+}
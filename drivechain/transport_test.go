@@ -0,0 +1,87 @@
+package drivechain
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Tests that Init connects to a mainchain node served over https with a
+// certificate signed by the CA bundle from the config.
+func TestInitHTTPS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(testMainchainHandler))
+	defer srv.Close()
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caFile, ca, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without the CA the node's certificate is rejected.
+	cfg := Config{DBPath: t.TempDir(), URL: srv.URL, RPCUser: "user", RPCPassword: "password"}
+	if err := InitWithContext(context.Background(), cfg); err == nil {
+		Shutdown()
+		t.Fatal("connected to node with untrusted certificate")
+	}
+	cfg.TLS = TLSConfig{CAFile: filepath.Join(t.TempDir(), "missing.pem")}
+	if err := InitWithContext(context.Background(), cfg); err == nil {
+		Shutdown()
+		t.Fatal("initialized with missing CA bundle")
+	}
+
+	cfg.TLS = TLSConfig{CAFile: caFile}
+	if err := InitWithContext(context.Background(), cfg); err != nil {
+		t.Fatalf("failed to initialize: %v", err)
+	}
+	defer Shutdown()
+	if _, err := GetMainchainTipInfo(); err != nil {
+		t.Errorf("failed to get tip over https: %v", err)
+	}
+}
+
+// countingTransport is a RoundTripper counting the requests it carries.
+type countingTransport struct {
+	requests int
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.requests++
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// Tests that the mainchain RPC requests made from Go go through the transport
+// from the config.
+func TestInitCustomTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(testMainchainHandler))
+	defer srv.Close()
+
+	transport := new(countingTransport)
+	err := Init(t.TempDir(), "", 0, "user", "password", WithURL(srv.URL), WithTransport(transport))
+	if err != nil {
+		t.Fatalf("failed to initialize: %v", err)
+	}
+	defer Shutdown()
+	if transport.requests == 0 {
+		t.Fatal("probe didn't go through the transport")
+	}
+	probed := transport.requests
+	if _, err := GetMainchainTipInfo(); err != nil {
+		t.Fatalf("failed to get tip: %v", err)
+	}
+	if transport.requests == probed {
+		t.Error("tip request didn't go through the transport")
+	}
+}
+
+// Tests that only http and https URLs are accepted.
+func TestInitURLScheme(t *testing.T) {
+	cfg := Config{DBPath: t.TempDir(), URL: "ftp://mainchain.example.com:8332"}
+	if err := InitWithContext(context.Background(), cfg); err == nil {
+		Shutdown()
+		t.Fatal("initialized with ftp url")
+	}
+}
@@ -0,0 +1,150 @@
+package drivechain
+
+/*
+#include "./bindings.h"
+*/
+import "C"
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// MaxMoney is the number of satoshis there will ever be on mainchain, which
+// the treasury account holds in Wei at genesis.
+const MaxMoney = 21_000_000 * 100_000_000
+
+// treasuryAccount is the address TREASURY_PRIVATE_KEY must derive. The
+// treasury address is part of consensus, so a different key is a bug.
+const treasuryAccount = "0xc96aaa54e2d44c299564da76e1cd3184a2386b8d"
+
+var (
+	treasuryKey     = mustTreasuryKey()
+	treasuryAddress = crypto.PubkeyToAddress(treasuryKey.PublicKey)
+
+	// TREASURY_ACCOUNT is the address of the treasury account in lowercase
+	// hex. Compare addresses with IsTreasury instead of comparing strings,
+	// Address.Hex is checksummed.
+	TREASURY_ACCOUNT = strings.ToLower(treasuryAddress.Hex())
+)
+
+func init() {
+	if TREASURY_ACCOUNT != treasuryAccount {
+		panic(fmt.Sprintf("treasury account: %s != actual treasury account: %s", treasuryAccount, TREASURY_ACCOUNT))
+	}
+}
+
+func mustTreasuryKey() *ecdsa.PrivateKey {
+	key, err := crypto.HexToECDSA(TREASURY_PRIVATE_KEY)
+	if err != nil {
+		panic(fmt.Sprintf("can't get treasury private key: %s", err))
+	}
+	return key
+}
+
+// TreasuryKey returns the private key of the treasury account, used to sign
+// the transactions paying out deposits and refunds. The key is shared and
+// must not be modified.
+func TreasuryKey() *ecdsa.PrivateKey {
+	return treasuryKey
+}
+
+// TreasuryAddress returns the address of the treasury account.
+func TreasuryAddress() common.Address {
+	return treasuryAddress
+}
+
+// IsTreasury reports whether addr is the treasury account.
+func IsTreasury(addr common.Address) bool {
+	return addr == treasuryAddress
+}
+
+// ErrTreasuryMismatch is returned by CheckTreasuryInvariant if the treasury
+// balance isn't the one expected from the deposits and withdrawals.
+var ErrTreasuryMismatch = errors.New("treasury balance mismatch")
+
+// TreasuryMismatchError is returned by CheckTreasuryInvariant. It unwraps to
+// ErrTreasuryMismatch.
+type TreasuryMismatchError struct {
+	Expected  *big.Int // Balance expected from the engine's database, in Wei
+	Actual    *big.Int // Balance passed to CheckTreasuryInvariant, in Wei
+	Deposited uint64   // Satoshis credited by deposits
+	Withdrawn uint64   // Satoshis paid out by completed withdrawals
+}
+
+// Error implements error.
+func (e *TreasuryMismatchError) Error() string {
+	diff := new(big.Int).Sub(e.Actual, e.Expected)
+	return fmt.Sprintf("%v: have %v Wei, want %v Wei (off by %v Wei, deposited %d sat, withdrawn %d sat)",
+		ErrTreasuryMismatch, e.Actual, e.Expected, diff, e.Deposited, e.Withdrawn)
+}
+
+// Unwrap returns ErrTreasuryMismatch.
+func (e *TreasuryMismatchError) Unwrap() error {
+	return ErrTreasuryMismatch
+}
+
+// getTreasuryTotals returns the satoshis credited by deposits and paid out by
+// completed withdrawals according to the engine's database.
+func getTreasuryTotals() (deposited, withdrawn uint64, err error) {
+	if err := rlockEngine(); err != nil {
+		return 0, 0, err
+	}
+	defer engineMu.RUnlock()
+	var cDeposited, cWithdrawn C.uint64_t
+	if !bool(C.get_treasury_totals(&cDeposited, &cWithdrawn)) {
+		return 0, 0, fmt.Errorf("failed to get treasury totals: %w: %s", ErrEngineFailure, getLastError())
+	}
+	return uint64(cDeposited), uint64(cWithdrawn), nil
+}
+
+// expectedTreasuryBalance is the treasury balance in Wei after deposited
+// satoshis were credited and withdrawn satoshis paid out.
+func expectedTreasuryBalance(deposited, withdrawn uint64) *big.Int {
+	balance := SatoshiToWei(MaxMoney)
+	balance.Sub(balance, SatoshiToWei(deposited))
+	return balance.Add(balance, SatoshiToWei(withdrawn))
+}
+
+// ExpectedTreasuryBalance returns the balance in Wei the treasury account
+// should have according to the engine's database: MaxMoney, less the
+// deposits credited on the sidechain, plus the completed withdrawals.
+func ExpectedTreasuryBalance() (*big.Int, error) {
+	deposited, withdrawn, err := getTreasuryTotals()
+	if err != nil {
+		return nil, err
+	}
+	return expectedTreasuryBalance(deposited, withdrawn), nil
+}
+
+// CheckTreasuryInvariant compares actual, the balance of the treasury account
+// in the sidechain state, to ExpectedTreasuryBalance. A mismatch is reported
+// as a *TreasuryMismatchError. The check is cheap enough to run on every
+// block while debugging accounting issues.
+func CheckTreasuryInvariant(actual *big.Int) error {
+	return checkTreasuryInvariant(actual, getTreasuryTotals)
+}
+
+// checkTreasuryInvariant is CheckTreasuryInvariant with the treasury totals
+// returned by totals.
+func checkTreasuryInvariant(actual *big.Int, totals func() (deposited, withdrawn uint64, err error)) error {
+	deposited, withdrawn, err := totals()
+	if err != nil {
+		return err
+	}
+	expected := expectedTreasuryBalance(deposited, withdrawn)
+	if actual.Cmp(expected) != 0 {
+		return &TreasuryMismatchError{
+			Expected:  expected,
+			Actual:    new(big.Int).Set(actual),
+			Deposited: deposited,
+			Withdrawn: withdrawn,
+		}
+	}
+	return nil
+}
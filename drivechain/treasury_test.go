@@ -0,0 +1,58 @@
+package drivechain
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Tests that the treasury address is the one of the treasury key, whatever
+// the case of its hex form.
+func TestTreasuryAddress(t *testing.T) {
+	if have := crypto.PubkeyToAddress(TreasuryKey().PublicKey); have != TreasuryAddress() {
+		t.Errorf("address mismatch: key has %v, want %v", have, TreasuryAddress())
+	}
+	for _, s := range []string{TREASURY_ACCOUNT, TreasuryAddress().Hex()} {
+		if !IsTreasury(common.HexToAddress(s)) {
+			t.Errorf("%s not taken as the treasury", s)
+		}
+	}
+	if IsTreasury(common.Address{}) {
+		t.Error("zero address taken as the treasury")
+	}
+}
+
+// Tests that the treasury balance is checked against the deposit and
+// withdrawal totals of the engine, which are 500000 and 200000 satoshis in the
+// test engine.
+func TestCheckTreasuryInvariant(t *testing.T) {
+	initTestEngine(t, nil)
+
+	want := SatoshiToWei(MaxMoney - 500000 + 200000)
+	expected, err := ExpectedTreasuryBalance()
+	if err != nil {
+		t.Fatalf("failed to get expected balance: %v", err)
+	}
+	if expected.Cmp(want) != 0 {
+		t.Fatalf("expected balance mismatch: have %v, want %v", expected, want)
+	}
+	if err := CheckTreasuryInvariant(want); err != nil {
+		t.Errorf("matching balance rejected: %v", err)
+	}
+
+	actual := new(big.Int).Add(want, Satoshi)
+	err = CheckTreasuryInvariant(actual)
+	var mismatch *TreasuryMismatchError
+	if !errors.Is(err, ErrTreasuryMismatch) || !errors.As(err, &mismatch) {
+		t.Fatalf("error mismatch: have %v, want %v", err, ErrTreasuryMismatch)
+	}
+	if mismatch.Actual.Cmp(actual) != 0 || mismatch.Expected.Cmp(want) != 0 {
+		t.Errorf("balances mismatch: have %v/%v, want %v/%v", mismatch.Actual, mismatch.Expected, actual, want)
+	}
+	if mismatch.Deposited != 500000 || mismatch.Withdrawn != 200000 {
+		t.Errorf("totals mismatch: have %d/%d, want 500000/200000", mismatch.Deposited, mismatch.Withdrawn)
+	}
+}
@@ -0,0 +1,29 @@
+package drivechain
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// SatoshiToWei converts an amount of satoshis to Wei.
+func SatoshiToWei(sats uint64) *big.Int {
+	wei := new(big.Int).SetUint64(sats)
+	return wei.Mul(wei, Satoshi)
+}
+
+// WeiToSatoshi converts an amount of Wei to satoshis. The amount must be a
+// whole number of satoshis that fits an uint64.
+func WeiToSatoshi(wei *big.Int) (uint64, error) {
+	if wei.Sign() < 0 {
+		return 0, fmt.Errorf("%w: negative amount %v", ErrSatoshiRange, wei)
+	}
+	var sats, rem big.Int
+	sats.QuoRem(wei, Satoshi, &rem)
+	if rem.Sign() != 0 {
+		return 0, fmt.Errorf("%w: %v Wei", ErrFractionalSatoshi, wei)
+	}
+	if !sats.IsUint64() {
+		return 0, fmt.Errorf("%w: %v satoshis", ErrSatoshiRange, &sats)
+	}
+	return sats.Uint64(), nil
+}
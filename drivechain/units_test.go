@@ -0,0 +1,55 @@
+package drivechain
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestSatoshiToWei(t *testing.T) {
+	tests := []struct {
+		sats uint64
+		wei  string
+	}{
+		{0, "0"},
+		{1, "10000000000"},
+		{100_000_000, "1000000000000000000"},
+		{math.MaxInt64 + 1, "92233720368547758080000000000"},
+		{math.MaxUint64, "184467440737095516150000000000"},
+	}
+	for _, tt := range tests {
+		if wei := SatoshiToWei(tt.sats); wei.String() != tt.wei {
+			t.Errorf("%d: wei mismatch: have %v, want %s", tt.sats, wei, tt.wei)
+		}
+	}
+}
+
+func TestWeiToSatoshi(t *testing.T) {
+	maxWei := SatoshiToWei(math.MaxUint64)
+	tests := []struct {
+		name string
+		wei  *big.Int
+		sats uint64
+		err  error
+	}{
+		{"zero", new(big.Int), 0, nil},
+		{"one satoshi", new(big.Int).Set(Satoshi), 1, nil},
+		{"one wei", big.NewInt(1), 0, ErrFractionalSatoshi},
+		{"one wei less", new(big.Int).Sub(Satoshi, big.NewInt(1)), 0, ErrFractionalSatoshi},
+		{"one wei more", new(big.Int).Add(Satoshi, big.NewInt(1)), 0, ErrFractionalSatoshi},
+		{"max", maxWei, math.MaxUint64, nil},
+		{"max plus one satoshi", new(big.Int).Add(maxWei, Satoshi), 0, ErrSatoshiRange},
+		{"negative", new(big.Int).Neg(Satoshi), 0, ErrSatoshiRange},
+	}
+	for _, tt := range tests {
+		sats, err := WeiToSatoshi(tt.wei)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: error mismatch: have %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		if sats != tt.sats {
+			t.Errorf("%s: satoshi mismatch: have %d, want %d", tt.name, sats, tt.sats)
+		}
+	}
+}
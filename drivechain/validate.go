@@ -0,0 +1,102 @@
+package drivechain
+
+/*
+#include "./bindings.h"
+*/
+import "C"
+import (
+	"fmt"
+	"strings"
+	"unsafe"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// CheckResult is a validation check of the engine a block failed.
+type CheckResult struct {
+	Name string    // Name of the check, e.g. "withdrawal_amount"
+	Kind ErrorKind // How the block fails the check
+	Msg  string    // Details reported by the engine, may be empty
+}
+
+// String implements fmt.Stringer.
+func (r CheckResult) String() string {
+	if r.Msg == "" {
+		return fmt.Sprintf("%s: %v", r.Name, r.Kind)
+	}
+	return fmt.Sprintf("%s: %v: %s", r.Name, r.Kind, r.Msg)
+}
+
+// BlockValidationError is returned by ValidateBlock for invalid blocks,
+// listing every check the block failed. It matches the sentinel errors of
+// the kinds of all its checks with errors.Is.
+type BlockValidationError struct {
+	FailedChecks []CheckResult
+}
+
+// Error implements error.
+func (e *BlockValidationError) Error() string {
+	checks := make([]string, len(e.FailedChecks))
+	for i, check := range e.FailedChecks {
+		checks[i] = check.String()
+	}
+	return fmt.Sprintf("invalid block, %d checks failed: %s", len(checks), strings.Join(checks, "; "))
+}
+
+// Is reports whether any of the failed checks is of the kind of target.
+func (e *BlockValidationError) Is(target error) bool {
+	for _, check := range e.FailedChecks {
+		if check.Kind.err() == target {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateBlock checks the deposits, withdrawals and refunds of a block the
+// way ConnectBlock does, without changing any state of the engine, including
+// its statistics, so it can be used to check pending transactions. Unlike
+// ConnectBlock it runs every check instead of stopping at the first failure,
+// and returns a *BlockValidationError listing the failed ones. If the block
+// couldn't be checked the error is a *BlockError of kind EngineFailure.
+//
+// common.Hash here is for transaction hashes.
+func ValidateBlock(deposits []Deposit, withdrawals map[common.Hash]Withdrawal, refunds []Refund) error {
+	if err := rlockEngine(); err != nil {
+		return err
+	}
+	defer engineMu.RUnlock()
+	args := marshalArgs()
+	defer args.free()
+	cBlock := newBlockData(&args, &BlockData{Deposits: deposits, Withdrawals: withdrawals, Refunds: refunds})
+	return checkResultsError(C.validate_block(cBlock))
+}
+
+// checkResultsError converts the check results returned by validate_block into
+// the error of ValidateBlock, freeing them.
+func checkResultsError(cResults C.CheckResults) error {
+	defer C.free_check_results(cResults)
+	if !bool(cResults.valid) {
+		return &BlockError{Kind: EngineFailure, Msg: getLastError()}
+	}
+	var failed []CheckResult
+	for _, cResult := range unsafe.Slice(cResults.ptr, cResults.len) {
+		if cResult.error == C.BlockError_None {
+			continue
+		}
+		result := CheckResult{Name: C.GoString(cResult.name)}
+		kind, ok := errorKind(cResult.error)
+		if !ok {
+			return &BlockError{Kind: EngineFailure, Msg: fmt.Sprintf("unknown error code %d for check %s", cResult.error, result.Name)}
+		}
+		result.Kind = kind
+		if cResult.message != nil {
+			result.Msg = C.GoString(cResult.message)
+		}
+		failed = append(failed, result)
+	}
+	if len(failed) > 0 {
+		return &BlockValidationError{FailedChecks: failed}
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package drivechain
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Tests that ValidateBlock reports the failed checks of an invalid block and
+// nothing for a valid one.
+func TestValidateBlock(t *testing.T) {
+	initTestEngine(t, nil)
+
+	deposits := []Deposit{{Address: common.HexToAddress("0xc96aaa54e2d44c299564da76e1cd3184a2386b8d"), Amount: big.NewInt(1000)}}
+	if err := ValidateBlock(deposits, nil, nil); err != nil {
+		t.Fatalf("valid block: %v", err)
+	}
+
+	withdrawals := map[common.Hash]Withdrawal{
+		{1}: {Amount: big.NewInt(1), Fee: big.NewInt(0)},
+	}
+	err := ValidateBlock(deposits, withdrawals, nil)
+	var validationErr *BlockValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("invalid block: got %v, want *BlockValidationError", err)
+	}
+	want := []CheckResult{{Name: "withdrawal_amount", Kind: InvalidWithdrawal, Msg: "amount below dust"}}
+	if len(validationErr.FailedChecks) != len(want) || validationErr.FailedChecks[0] != want[0] {
+		t.Errorf("failed checks: got %v, want %v", validationErr.FailedChecks, want)
+	}
+	if !errors.Is(err, ErrInvalidWithdrawal) {
+		t.Errorf("error %v doesn't match %v", err, ErrInvalidWithdrawal)
+	}
+	if errors.Is(err, ErrDepositMismatch) {
+		t.Errorf("error %v matches %v", err, ErrDepositMismatch)
+	}
+}
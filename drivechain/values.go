@@ -0,0 +1,155 @@
+package drivechain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Deposit, Withdrawal and Refund hold their amounts as *big.Int, so == only
+// compares pointers. The methods below compare and hash them by value.
+
+// cmpBig compares two amounts, treating nil as zero.
+func cmpBig(a, b *big.Int) int {
+	if a == nil {
+		a = common.Big0
+	}
+	if b == nil {
+		b = common.Big0
+	}
+	return a.Cmp(b)
+}
+
+// bigBytes returns the 32 byte big endian encoding of an amount, nil being
+// zero.
+func bigBytes(x *big.Int) []byte {
+	if x == nil {
+		return make([]byte, common.HashLength)
+	}
+	return common.BigToHash(x).Bytes()
+}
+
+// Equal reports whether d and other are the same deposit.
+func (d Deposit) Equal(other Deposit) bool {
+	return d.Address == other.Address && cmpBig(d.Amount, other.Amount) == 0 &&
+		d.MainTxid == other.MainTxid && d.Vout == other.Vout
+}
+
+// Less orders deposits by mainchain outpoint, then address and amount.
+func (d Deposit) Less(other Deposit) bool {
+	if c := bytes.Compare(d.MainTxid[:], other.MainTxid[:]); c != 0 {
+		return c < 0
+	}
+	if d.Vout != other.Vout {
+		return d.Vout < other.Vout
+	}
+	if c := bytes.Compare(d.Address[:], other.Address[:]); c != 0 {
+		return c < 0
+	}
+	return cmpBig(d.Amount, other.Amount) < 0
+}
+
+// Hash returns a hash identifying the deposit, equal deposits have the same
+// hash.
+func (d Deposit) Hash() common.Hash {
+	var vout [4]byte
+	binary.BigEndian.PutUint32(vout[:], d.Vout)
+	return crypto.Keccak256Hash(d.Address[:], bigBytes(d.Amount), d.MainTxid[:], vout[:])
+}
+
+// Equal reports whether w and other are the same withdrawal.
+func (w Withdrawal) Equal(other Withdrawal) bool {
+	return w.Address == other.Address && cmpBig(w.Amount, other.Amount) == 0 &&
+		cmpBig(w.Fee, other.Fee) == 0
+}
+
+// Less orders withdrawals by mainchain address type and payload, then amount
+// and fee.
+func (w Withdrawal) Less(other Withdrawal) bool {
+	if c := bytes.Compare(canonicalAddress(w.Address), canonicalAddress(other.Address)); c != 0 {
+		return c < 0
+	}
+	if c := cmpBig(w.Amount, other.Amount); c != 0 {
+		return c < 0
+	}
+	return cmpBig(w.Fee, other.Fee) < 0
+}
+
+// Hash returns a hash identifying the withdrawal, equal withdrawals have the
+// same hash.
+func (w Withdrawal) Hash() common.Hash {
+	return crypto.Keccak256Hash(canonicalAddress(w.Address), bigBytes(w.Amount), bigBytes(w.Fee))
+}
+
+// canonicalAddress encodes a withdrawal's address for Less and Hash, always
+// as the type byte followed by the payload, so that both tell the same
+// addresses apart.
+func canonicalAddress(a MainchainAddress) []byte {
+	return a.encode(nil, true)
+}
+
+// Equal reports whether r and other are the same refund.
+func (r Refund) Equal(other Refund) bool {
+	return r.Id == other.Id && cmpBig(r.Amount, other.Amount) == 0
+}
+
+// Less orders refunds by withdrawal id, then amount.
+func (r Refund) Less(other Refund) bool {
+	if c := bytes.Compare(r.Id[:], other.Id[:]); c != 0 {
+		return c < 0
+	}
+	return cmpBig(r.Amount, other.Amount) < 0
+}
+
+// Hash returns a hash identifying the refund, equal refunds have the same
+// hash.
+func (r Refund) Hash() common.Hash {
+	return crypto.Keccak256Hash(r.Id[:], bigBytes(r.Amount))
+}
+
+// DepositSet is a set of deposits, compared by value.
+type DepositSet map[common.Hash]Deposit
+
+// NewDepositSet creates a set holding the given deposits.
+func NewDepositSet(deposits ...Deposit) DepositSet {
+	set := make(DepositSet, len(deposits))
+	for _, d := range deposits {
+		set.Add(d)
+	}
+	return set
+}
+
+// Add adds d to the set, returning false if it was already in it.
+func (s DepositSet) Add(d Deposit) bool {
+	hash := d.Hash()
+	if _, ok := s[hash]; ok {
+		return false
+	}
+	s[hash] = d
+	return true
+}
+
+// Contains reports whether d is in the set.
+func (s DepositSet) Contains(d Deposit) bool {
+	_, ok := s[d.Hash()]
+	return ok
+}
+
+// Remove removes d from the set.
+func (s DepositSet) Remove(d Deposit) {
+	delete(s, d.Hash())
+}
+
+// List returns the deposits in the set, sorted with Deposit.Less.
+func (s DepositSet) List() []Deposit {
+	deposits := make([]Deposit, 0, len(s))
+	for _, d := range s {
+		deposits = append(deposits, d)
+	}
+	sort.Slice(deposits, func(i, j int) bool { return deposits[i].Less(deposits[j]) })
+	return deposits
+}
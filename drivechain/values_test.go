@@ -0,0 +1,80 @@
+package drivechain
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestDepositValueSemantics(t *testing.T) {
+	a := Deposit{Address: common.Address{1}, Amount: big.NewInt(100), MainTxid: common.Hash{2}, Vout: 1}
+	b := Deposit{Address: common.Address{1}, Amount: big.NewInt(100), MainTxid: common.Hash{2}, Vout: 1}
+	c := Deposit{Address: common.Address{1}, Amount: big.NewInt(100), MainTxid: common.Hash{2}, Vout: 2}
+
+	if !a.Equal(b) || a.Hash() != b.Hash() {
+		t.Errorf("equal deposits compare or hash differently")
+	}
+	if a.Equal(c) || a.Hash() == c.Hash() {
+		t.Errorf("different deposits compare or hash equal")
+	}
+	if !a.Less(c) || c.Less(a) || a.Less(b) {
+		t.Errorf("wrong deposit ordering")
+	}
+	if !(Deposit{}).Equal(Deposit{Amount: new(big.Int)}) {
+		t.Errorf("nil amount should equal zero amount")
+	}
+
+	set := NewDepositSet(c, a)
+	if set.Add(b) {
+		t.Errorf("added duplicate deposit to set")
+	}
+	if len(set) != 2 || !set.Contains(b) {
+		t.Fatalf("unexpected set contents: %v", set)
+	}
+	if list := set.List(); !list[0].Equal(a) || !list[1].Equal(c) {
+		t.Errorf("set list not sorted: %v", list)
+	}
+	set.Remove(b)
+	if set.Contains(a) || len(set) != 1 {
+		t.Errorf("failed to remove deposit from set")
+	}
+}
+
+func TestWithdrawalRefundValueSemantics(t *testing.T) {
+	address, _ := MainchainAddressFromBytes(common.Address{1}.Bytes())
+	w1 := Withdrawal{Address: address, Amount: big.NewInt(10), Fee: big.NewInt(1)}
+	w2 := Withdrawal{Address: address, Amount: big.NewInt(10), Fee: big.NewInt(2)}
+	if w1.Equal(w2) || w1.Hash() == w2.Hash() || !w1.Less(w2) {
+		t.Errorf("wrong withdrawal comparison")
+	}
+	if !w1.Equal(Withdrawal{Address: address, Amount: big.NewInt(10), Fee: big.NewInt(1)}) {
+		t.Errorf("equal withdrawals compare differently")
+	}
+	wsh, _ := NewMainchainAddress(P2WSH, common.Hash{1}.Bytes())
+	w3 := Withdrawal{Address: wsh, Amount: big.NewInt(10), Fee: big.NewInt(1)}
+	if w1.Equal(w3) || w1.Hash() == w3.Hash() || !w1.Less(w3) {
+		t.Errorf("wrong comparison of withdrawals to different address types")
+	}
+	// Less and Hash tell the same withdrawals apart, whatever the address
+	// type.
+	pkh, _ := NewMainchainAddress(P2PKH, common.Address{1}.Bytes())
+	wpkh, _ := NewMainchainAddress(P2WPKH, common.Address{1}.Bytes())
+	withdrawals := []Withdrawal{w1, w2, w3}
+	for _, a := range []MainchainAddress{pkh, wpkh} {
+		withdrawals = append(withdrawals, Withdrawal{Address: a, Amount: big.NewInt(10), Fee: big.NewInt(1)})
+	}
+	for _, a := range withdrawals {
+		for _, b := range withdrawals {
+			if same := !a.Less(b) && !b.Less(a); same != (a.Hash() == b.Hash()) {
+				t.Errorf("%v and %v: ordered as same %t, hashes %x and %x", a.Address.Type, b.Address.Type, same, a.Hash(), b.Hash())
+			}
+		}
+	}
+
+	r1 := Refund{Id: common.Hash{1}, Amount: big.NewInt(5)}
+	r2 := Refund{Id: common.Hash{2}, Amount: big.NewInt(5)}
+	if r1.Equal(r2) || r1.Hash() == r2.Hash() || !r1.Less(r2) {
+		t.Errorf("wrong refund comparison")
+	}
+}
@@ -0,0 +1,239 @@
+package drivechain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// WithdrawalStatus is the stage of the drivechain withdrawal process a
+// withdrawal is in.
+type WithdrawalStatus uint32
+
+const (
+	WithdrawalStatusPending         WithdrawalStatus = iota + 1 // Waiting to be included in a bundle
+	WithdrawalStatusInBundle                                    // Included in a bundle that wasn't broadcast yet
+	WithdrawalStatusBundleBroadcast                             // Bundle broadcast, waiting for mainchain ACKs
+	WithdrawalStatusSpent                                       // Paid out on mainchain
+	WithdrawalStatusFailed                                      // Bundle failed, can be refunded
+	WithdrawalStatusRefunded                                    // Refunded on the sidechain
+)
+
+var withdrawalStatusNames = [...]string{
+	WithdrawalStatusPending:         "pending",
+	WithdrawalStatusInBundle:        "in-bundle",
+	WithdrawalStatusBundleBroadcast: "bundle-broadcast",
+	WithdrawalStatusSpent:           "spent",
+	WithdrawalStatusFailed:          "failed",
+	WithdrawalStatusRefunded:        "refunded",
+}
+
+// String implements fmt.Stringer.
+func (s WithdrawalStatus) String() string {
+	if s < WithdrawalStatus(len(withdrawalStatusNames)) && withdrawalStatusNames[s] != "" {
+		return withdrawalStatusNames[s]
+	}
+	return fmt.Sprintf("unknown(%d)", uint32(s))
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (s WithdrawalStatus) MarshalText() ([]byte, error) {
+	if s >= WithdrawalStatus(len(withdrawalStatusNames)) || withdrawalStatusNames[s] == "" {
+		return nil, fmt.Errorf("invalid withdrawal status %d", uint32(s))
+	}
+	return []byte(withdrawalStatusNames[s]), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (s *WithdrawalStatus) UnmarshalText(text []byte) error {
+	for status, name := range withdrawalStatusNames {
+		if name != "" && strings.EqualFold(name, string(text)) {
+			*s = WithdrawalStatus(status)
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid withdrawal status %q", text)
+}
+
+// WithdrawalStatusInfo is the status of a withdrawal together with the
+// mainchain bundle it's paid out in.
+type WithdrawalStatusInfo struct {
+	Status WithdrawalStatus `json:"status"`
+	// BundleHash is the hash of the bundle transaction, nil until the
+	// withdrawal is included in a bundle.
+	BundleHash *common.Hash `json:"bundleHash,omitempty"`
+	// Acks is the number of mainchain ACKs the bundle got so far.
+	Acks uint32 `json:"acks"`
+}
+
+// WithdrawalEventKind is a state transition of a withdrawal.
+type WithdrawalEventKind uint32
+
+const (
+	WithdrawalEventCreated   WithdrawalEventKind = iota + 1 // Withdrawal made on the sidechain
+	WithdrawalEventInBundle                                 // Included in a bundle
+	WithdrawalEventBroadcast                                // Bundle broadcast to mainchain
+	WithdrawalEventSpent                                    // Paid out on mainchain
+	WithdrawalEventFailed                                   // Bundle failed on mainchain
+)
+
+var withdrawalEventKindNames = [...]string{
+	WithdrawalEventCreated:   "created",
+	WithdrawalEventInBundle:  "in-bundle",
+	WithdrawalEventBroadcast: "broadcast",
+	WithdrawalEventSpent:     "spent",
+	WithdrawalEventFailed:    "failed",
+}
+
+// String implements fmt.Stringer.
+func (k WithdrawalEventKind) String() string {
+	if k < WithdrawalEventKind(len(withdrawalEventKindNames)) && withdrawalEventKindNames[k] != "" {
+		return withdrawalEventKindNames[k]
+	}
+	return fmt.Sprintf("unknown(%d)", uint32(k))
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (k WithdrawalEventKind) MarshalText() ([]byte, error) {
+	if k >= WithdrawalEventKind(len(withdrawalEventKindNames)) || withdrawalEventKindNames[k] == "" {
+		return nil, fmt.Errorf("invalid withdrawal event kind %d", uint32(k))
+	}
+	return []byte(withdrawalEventKindNames[k]), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (k *WithdrawalEventKind) UnmarshalText(text []byte) error {
+	for kind, name := range withdrawalEventKindNames {
+		if name != "" && strings.EqualFold(name, string(text)) {
+			*k = WithdrawalEventKind(kind)
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid withdrawal event kind %q", text)
+}
+
+// WithdrawalEvent is a state transition of a withdrawal, as returned by
+// GetWithdrawalHistory.
+type WithdrawalEvent struct {
+	Kind WithdrawalEventKind `json:"kind"`
+	// MainchainHeight is the height of the mainchain tip when the transition
+	// was recorded.
+	MainchainHeight uint64    `json:"mainchainHeight"`
+	Timestamp       time.Time `json:"timestamp"`
+}
+
+// SpentWithdrawal is a withdrawal the engine considers paid out on mainchain,
+// as returned by GetSpentWithdrawals.
+type SpentWithdrawal struct {
+	Id                 common.Hash `json:"id"`
+	BundleTxid         common.Hash `json:"bundleTxid"`         // Mainchain bundle transaction paying out the withdrawal
+	MainchainBlockHash common.Hash `json:"mainchainBlockHash"` // Mainchain block including the bundle
+	MainchainHeight    uint64      `json:"mainchainHeight"`    // Height of that block
+	Amount             uint64      `json:"amount"`             // Withdrawn amount in satoshis
+	Fee                uint64      `json:"fee"`                // Mainchain fee in satoshis
+}
+
+// WithdrawalInfo is a withdrawal together with its current status.
+type WithdrawalInfo struct {
+	Withdrawal
+	Status WithdrawalStatus
+}
+
+// WithdrawalBundle is a mainchain transaction paying out a batch of
+// withdrawals, as returned by GetCurrentBundle.
+type WithdrawalBundle struct {
+	BundleHash  common.Hash   `json:"bundleHash"`  // Hash of the mainchain bundle transaction
+	Members     []common.Hash `json:"withdrawals"` // Ids of the withdrawals paid out by the bundle, in bundle order
+	TotalAmount uint64        `json:"totalAmount"` // Sum of the withdrawn amounts in satoshis
+	TotalFee    uint64        `json:"totalFee"`    // Sum of the mainchain fees in satoshis
+}
+
+// BundleStatus is the stage of the payout process a withdrawal bundle is in.
+type BundleStatus uint32
+
+const (
+	BundleStatusBuilding  BundleStatus = iota + 1 // Collecting withdrawals, not broadcast yet
+	BundleStatusBroadcast                         // Broadcast, no mainchain ACKs yet
+	BundleStatusAcked                             // Collecting mainchain ACKs, see Bundle.Acks
+	BundleStatusFailed                            // Didn't get enough ACKs, withdrawals can be refunded
+	BundleStatusPaid                              // Paid out on mainchain
+)
+
+var bundleStatusNames = [...]string{
+	BundleStatusBuilding:  "building",
+	BundleStatusBroadcast: "broadcast",
+	BundleStatusAcked:     "acked",
+	BundleStatusFailed:    "failed",
+	BundleStatusPaid:      "paid",
+}
+
+// String implements fmt.Stringer.
+func (s BundleStatus) String() string {
+	if s < BundleStatus(len(bundleStatusNames)) && bundleStatusNames[s] != "" {
+		return bundleStatusNames[s]
+	}
+	return fmt.Sprintf("unknown(%d)", uint32(s))
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (s BundleStatus) MarshalText() ([]byte, error) {
+	if s >= BundleStatus(len(bundleStatusNames)) || bundleStatusNames[s] == "" {
+		return nil, fmt.Errorf("invalid bundle status %d", uint32(s))
+	}
+	return []byte(bundleStatusNames[s]), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (s *BundleStatus) UnmarshalText(text []byte) error {
+	for status, name := range bundleStatusNames {
+		if name != "" && strings.EqualFold(name, string(text)) {
+			*s = BundleStatus(status)
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid bundle status %q", text)
+}
+
+// BroadcastOutcome is what AttemptBundleBroadcast did.
+type BroadcastOutcome uint
+
+const (
+	NothingToDo    BroadcastOutcome = iota // There were no withdrawals to bundle
+	AlreadyPending                         // The previous bundle wasn't paid out or failed yet
+	Broadcast                              // A new bundle was broadcast
+)
+
+var broadcastOutcomeNames = [...]string{
+	NothingToDo:    "nothing-to-do",
+	AlreadyPending: "already-pending",
+	Broadcast:      "broadcast",
+}
+
+// String implements fmt.Stringer.
+func (o BroadcastOutcome) String() string {
+	if o < BroadcastOutcome(len(broadcastOutcomeNames)) {
+		return broadcastOutcomeNames[o]
+	}
+	return fmt.Sprintf("BroadcastOutcome(%d)", uint(o))
+}
+
+// BroadcastResult is the result of AttemptBundleBroadcast.
+type BroadcastResult struct {
+	Outcome BroadcastOutcome
+	// BundleHash is the hash of the bundle, set if Outcome is Broadcast.
+	BundleHash common.Hash
+}
+
+// Bundle is a withdrawal bundle together with how far along its payout is,
+// as returned by GetPendingBundle.
+type Bundle struct {
+	WithdrawalBundle
+	Status BundleStatus `json:"status"`
+	// Acks is the number of mainchain ACKs the bundle got so far.
+	Acks uint32 `json:"acks"`
+	// MainchainTxid is the id of the mainchain transaction paying out the
+	// bundle, nil until the bundle is broadcast.
+	MainchainTxid *common.Hash `json:"mainchainTxid,omitempty"`
+}
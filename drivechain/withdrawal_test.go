@@ -0,0 +1,103 @@
+package drivechain
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestWithdrawalStatusInfoJSON(t *testing.T) {
+	bundleHash := common.HexToHash("0x0102")
+	tests := []struct {
+		info WithdrawalStatusInfo
+		want string
+	}{
+		{
+			WithdrawalStatusInfo{Status: WithdrawalStatusPending},
+			`{"status":"pending","acks":0}`,
+		},
+		{
+			WithdrawalStatusInfo{Status: WithdrawalStatusBundleBroadcast, BundleHash: &bundleHash, Acks: 13},
+			`{"status":"bundle-broadcast","bundleHash":"0x0000000000000000000000000000000000000000000000000000000000000102","acks":13}`,
+		},
+	}
+	for _, tt := range tests {
+		enc, err := json.Marshal(tt.info)
+		if err != nil {
+			t.Fatalf("%v: failed to marshal: %v", tt.info.Status, err)
+		}
+		if string(enc) != tt.want {
+			t.Errorf("encoding mismatch:\nhave %s\nwant %s", enc, tt.want)
+		}
+		var dec WithdrawalStatusInfo
+		if err := json.Unmarshal(enc, &dec); err != nil {
+			t.Fatalf("%v: failed to unmarshal: %v", tt.info.Status, err)
+		}
+		if dec.Status != tt.info.Status || dec.Acks != tt.info.Acks || (dec.BundleHash == nil) != (tt.info.BundleHash == nil) {
+			t.Errorf("round trip mismatch: have %+v, want %+v", dec, tt.info)
+		}
+	}
+	if _, err := json.Marshal(WithdrawalStatusInfo{}); err == nil {
+		t.Error("marshaled unknown withdrawal status")
+	}
+}
+
+func TestBundleJSON(t *testing.T) {
+	txid := common.HexToHash("0x0304")
+	bundle := Bundle{
+		WithdrawalBundle: WithdrawalBundle{
+			BundleHash:  common.HexToHash("0x0102"),
+			Members:     []common.Hash{common.HexToHash("0x01"), common.HexToHash("0x02")},
+			TotalAmount: 150000,
+			TotalFee:    2000,
+		},
+		Status:        BundleStatusAcked,
+		Acks:          7,
+		MainchainTxid: &txid,
+	}
+	enc, err := json.Marshal(bundle)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	want := `{"bundleHash":"0x0000000000000000000000000000000000000000000000000000000000000102",` +
+		`"withdrawals":["0x0000000000000000000000000000000000000000000000000000000000000001","0x0000000000000000000000000000000000000000000000000000000000000002"],` +
+		`"totalAmount":150000,"totalFee":2000,"status":"acked","acks":7,` +
+		`"mainchainTxid":"0x0000000000000000000000000000000000000000000000000000000000000304"}`
+	if string(enc) != want {
+		t.Errorf("encoding mismatch:\nhave %s\nwant %s", enc, want)
+	}
+	var dec Bundle
+	if err := json.Unmarshal(enc, &dec); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	if dec.Status != bundle.Status || dec.Acks != bundle.Acks || len(dec.Members) != 2 || *dec.MainchainTxid != txid {
+		t.Errorf("round trip mismatch: have %+v, want %+v", dec, bundle)
+	}
+	if _, err := json.Marshal(Bundle{}); err == nil {
+		t.Error("marshaled unknown bundle status")
+	}
+}
+
+func TestWithdrawalEventJSON(t *testing.T) {
+	event := WithdrawalEvent{Kind: WithdrawalEventInBundle, MainchainHeight: 812345, Timestamp: time.Unix(1697000000, 0).UTC()}
+	enc, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	want := `{"kind":"in-bundle","mainchainHeight":812345,"timestamp":"2023-10-11T04:53:20Z"}`
+	if string(enc) != want {
+		t.Errorf("encoding mismatch:\nhave %s\nwant %s", enc, want)
+	}
+	var dec WithdrawalEvent
+	if err := json.Unmarshal(enc, &dec); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	if dec.Kind != event.Kind || dec.MainchainHeight != event.MainchainHeight || !dec.Timestamp.Equal(event.Timestamp) {
+		t.Errorf("round trip mismatch: have %+v, want %+v", dec, event)
+	}
+	if _, err := json.Marshal(WithdrawalEvent{}); err == nil {
+		t.Error("marshaled unknown withdrawal event kind")
+	}
+}
@@ -0,0 +1,305 @@
+package drivechain
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	// zmqPollInterval is how often the pollers still check the mainchain while
+	// the ZMQ listener is connected, in case a notification got lost.
+	zmqPollInterval = 30 * time.Second
+
+	zmqRetryDelay    = 500 * time.Millisecond // Delay before the first reconnect
+	maxZMQRetryDelay = 30 * time.Second       // Cap of the exponentially growing reconnect delay
+
+	zmqMaxFrameSize = 1 << 20 // Frames larger than this are rejected
+)
+
+// zmqHashBlockTopic is the topic of bitcoind's zmqpubhashblock notifications.
+const zmqHashBlockTopic = "hashblock"
+
+var (
+	// mainchainBlock is closed and replaced when a ZMQ listener is notified of
+	// a new mainchain block, waking everyone waiting for one.
+	mainchainBlockMu sync.Mutex
+	mainchainBlock   = make(chan struct{})
+
+	// zmqConnected is the number of ZMQ listeners, of the engine started by
+	// Init and of clients, subscribed to block notifications.
+	zmqConnected int32
+
+	// zmqSub is the running ZMQ listener, nil if there is none. It's guarded by
+	// engineMu.
+	zmqSub *zmqListener
+)
+
+// WithZMQ makes Init subscribe to the mainchain node's zmqpubhashblock
+// endpoint, e.g. "tcp://127.0.0.1:28332".
+func WithZMQ(endpoint string) Option {
+	return func(cfg *Config) {
+		cfg.ZMQEndpoint = endpoint
+	}
+}
+
+// notifyMainchainBlock wakes everyone waiting for a new mainchain block.
+func notifyMainchainBlock() {
+	mainchainBlockMu.Lock()
+	defer mainchainBlockMu.Unlock()
+	close(mainchainBlock)
+	mainchainBlock = make(chan struct{})
+}
+
+// waitMainchainBlock waits until it's time to poll the mainchain again, which
+// is after interval or, while a ZMQ listener is connected, when a new
+// mainchain block arrives. It returns false if quit was closed first.
+func waitMainchainBlock(interval time.Duration, quit <-chan struct{}) bool {
+	mainchainBlockMu.Lock()
+	block := mainchainBlock
+	mainchainBlockMu.Unlock()
+
+	if atomic.LoadInt32(&zmqConnected) > 0 && interval < zmqPollInterval {
+		interval = zmqPollInterval
+	}
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-block:
+	case <-quit:
+		return false
+	}
+	return true
+}
+
+// zmqListener subscribes to the block notifications of the mainchain node,
+// reconnecting with a backoff if the connection drops.
+type zmqListener struct {
+	addr string
+	quit chan struct{}
+	done chan struct{}
+
+	lock sync.Mutex
+	conn net.Conn // Current connection, nil while disconnected
+}
+
+// startZMQ starts listening to the zmqpubhashblock endpoint, a tcp://
+// address.
+func startZMQ(endpoint string) *zmqListener {
+	l := &zmqListener{
+		addr: strings.TrimPrefix(endpoint, "tcp://"),
+		quit: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go l.loop()
+	return l
+}
+
+// stop closes the connection and waits for the listener to exit.
+func (l *zmqListener) stop() {
+	close(l.quit)
+	l.lock.Lock()
+	if l.conn != nil {
+		l.conn.Close()
+	}
+	l.lock.Unlock()
+	<-l.done
+}
+
+func (l *zmqListener) loop() {
+	defer close(l.done)
+	delay := zmqRetryDelay
+	for {
+		subscribed, err := l.listen()
+		if subscribed {
+			atomic.AddInt32(&zmqConnected, -1)
+		}
+		select {
+		case <-l.quit:
+			return
+		default:
+		}
+		if subscribed {
+			// The subscription worked before dropping, start over with a
+			// short delay.
+			delay = zmqRetryDelay
+		}
+		log.Warn("Mainchain ZMQ connection lost, polling instead", "addr", l.addr, "delay", delay, "err", err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-l.quit:
+			timer.Stop()
+			return
+		}
+		if delay *= 2; delay > maxZMQRetryDelay {
+			delay = maxZMQRetryDelay
+		}
+	}
+}
+
+// listen connects to the endpoint and delivers its notifications until the
+// connection fails, reporting whether the subscription was set up before.
+func (l *zmqListener) listen() (bool, error) {
+	conn, err := net.DialTimeout("tcp", l.addr, DefaultRPCTimeout)
+	if err != nil {
+		return false, err
+	}
+	l.lock.Lock()
+	select {
+	case <-l.quit:
+		l.lock.Unlock()
+		conn.Close()
+		return false, nil
+	default:
+	}
+	l.conn = conn
+	l.lock.Unlock()
+	defer func() {
+		l.lock.Lock()
+		l.conn = nil
+		l.lock.Unlock()
+		conn.Close()
+	}()
+
+	r := bufio.NewReader(conn)
+	conn.SetDeadline(time.Now().Add(DefaultRPCTimeout))
+	if err := zmqHandshake(conn, r); err != nil {
+		return false, err
+	}
+	// ZMTP 3.0 subscriptions are messages starting with a 1 byte.
+	if err := writeZMQFrame(conn, 0, append([]byte{1}, zmqHashBlockTopic...)); err != nil {
+		return false, err
+	}
+	conn.SetDeadline(time.Time{})
+	atomic.AddInt32(&zmqConnected, 1)
+	log.Info("Subscribed to mainchain block notifications", "addr", l.addr)
+	// The mainchain may have moved while the pollers waited for notifications.
+	notifyMainchainBlock()
+
+	for {
+		msg, err := readZMQMessage(r)
+		if err != nil {
+			return true, err
+		}
+		if len(msg) < 2 || string(msg[0]) != zmqHashBlockTopic || len(msg[1]) != common.HashLength {
+			continue
+		}
+		// bitcoind sends the hash in the byte order used by its RPC.
+		log.Debug("New mainchain block", "hash", common.BytesToHash(msg[1]))
+		notifyMainchainBlock()
+	}
+}
+
+// ZMTP 3.0 frame flags.
+const (
+	zmqFlagMore    = 0x01
+	zmqFlagLong    = 0x02
+	zmqFlagCommand = 0x04
+)
+
+// zmqHandshake exchanges the ZMTP 3.0 greeting and READY commands of a SUB
+// socket using the NULL security mechanism.
+func zmqHandshake(w io.Writer, r *bufio.Reader) error {
+	greeting := make([]byte, 64)
+	greeting[0], greeting[9] = 0xff, 0x7f
+	greeting[10], greeting[11] = 3, 0
+	copy(greeting[12:], "NULL")
+	if _, err := w.Write(greeting); err != nil {
+		return err
+	}
+	peer := make([]byte, 64)
+	if _, err := io.ReadFull(r, peer); err != nil {
+		return err
+	}
+	if peer[0] != 0xff || peer[9] != 0x7f || peer[10] < 3 {
+		return errors.New("not a ZMTP 3 peer")
+	}
+	if mechanism := strings.TrimRight(string(peer[12:32]), "\x00"); mechanism != "NULL" {
+		return fmt.Errorf("unsupported zmq security mechanism %q", mechanism)
+	}
+	ready := []byte("\x05READY\x0bSocket-Type\x00\x00\x00\x03SUB")
+	if err := writeZMQFrame(w, zmqFlagCommand, ready); err != nil {
+		return err
+	}
+	flags, body, err := readZMQFrame(r)
+	if err != nil {
+		return err
+	}
+	if flags&zmqFlagCommand == 0 || len(body) < 6 || string(body[:6]) != "\x05READY" {
+		return errors.New("zmq peer didn't send READY")
+	}
+	return nil
+}
+
+func writeZMQFrame(w io.Writer, flags byte, body []byte) error {
+	var header []byte
+	if len(body) > 255 {
+		header = make([]byte, 9)
+		header[0] = flags | zmqFlagLong
+		binary.BigEndian.PutUint64(header[1:], uint64(len(body)))
+	} else {
+		header = []byte{flags, byte(len(body))}
+	}
+	_, err := w.Write(append(header, body...))
+	return err
+}
+
+func readZMQFrame(r *bufio.Reader) (byte, []byte, error) {
+	flags, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	var size uint64
+	if flags&zmqFlagLong != 0 {
+		var b [8]byte
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return 0, nil, err
+		}
+		size = binary.BigEndian.Uint64(b[:])
+	} else {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		size = uint64(b)
+	}
+	if size > zmqMaxFrameSize {
+		return 0, nil, fmt.Errorf("zmq frame of %d bytes too large", size)
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return flags, body, nil
+}
+
+// readZMQMessage reads the frames of the next message, skipping commands.
+func readZMQMessage(r *bufio.Reader) ([][]byte, error) {
+	var msg [][]byte
+	for {
+		flags, body, err := readZMQFrame(r)
+		if err != nil {
+			return nil, err
+		}
+		if flags&zmqFlagCommand != 0 {
+			continue
+		}
+		msg = append(msg, body)
+		if flags&zmqFlagMore == 0 {
+			return msg, nil
+		}
+	}
+}
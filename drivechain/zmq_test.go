@@ -0,0 +1,84 @@
+package drivechain
+
+import (
+	"bufio"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// zmqPublisher accepts a single ZMQ subscriber and sends it a hashblock
+// notification for every value sent on blocks.
+func zmqPublisher(t *testing.T, ln net.Listener, blocks <-chan []byte) {
+	conn, err := ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	if err := zmqHandshake(conn, r); err != nil {
+		t.Errorf("handshake failed: %v", err)
+		return
+	}
+	_, sub, err := readZMQFrame(r)
+	if err != nil || string(sub) != "\x01hashblock" {
+		t.Errorf("subscription mismatch: have %q, err %v", sub, err)
+		return
+	}
+	for hash := range blocks {
+		writeZMQFrame(conn, zmqFlagMore, []byte(zmqHashBlockTopic))
+		writeZMQFrame(conn, zmqFlagMore, hash)
+		writeZMQFrame(conn, 0, []byte{1, 0, 0, 0})
+	}
+}
+
+func TestZMQListener(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	blocks := make(chan []byte)
+	go zmqPublisher(t, ln, blocks)
+
+	l := startZMQ("tcp://" + ln.Addr().String())
+	defer l.stop()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&zmqConnected) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("listener didn't subscribe")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// A notification cuts the wait short, even with a long poll interval.
+	woken := make(chan bool)
+	go func() { woken <- waitMainchainBlock(time.Hour, nil) }()
+	timeout := time.After(time.Second)
+	for woke := false; !woke; {
+		select {
+		case ok := <-woken:
+			if !ok {
+				t.Error("wait reported quit")
+			}
+			woke = true
+		case blocks <- make([]byte, 32):
+		case <-timeout:
+			t.Fatal("wait not woken by block notification")
+		}
+	}
+	// Once the connection drops the pollers fall back to their interval.
+	close(blocks)
+	deadline = time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&zmqConnected) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("listener still reported as connected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	start := time.Now()
+	if !waitMainchainBlock(20*time.Millisecond, nil) || time.Since(start) > time.Second {
+		t.Error("poll interval not used after disconnecting")
+	}
+}
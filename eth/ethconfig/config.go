@@ -33,6 +33,7 @@ import (
 	"github.com/ethereum/go-ethereum/consensus/clique"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/drivechain"
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/eth/gasprice"
 	"github.com/ethereum/go-ethereum/ethdb"
@@ -218,7 +219,17 @@ type Config struct {
 func CreateConsensusEngine(stack *node.Node, chainConfig *params.ChainConfig, config *ethash.Config, notify []string, noverify bool, db ethdb.Database) consensus.Engine {
 	// If proof-of-authority is requested, set it up
 	var engine consensus.Engine
-	bmm, err := bmm.New(stack.Config().DataDir, stack.Config().MainHost, uint16(stack.Config().MainPort), stack.Config().MainUser, stack.Config().MainPassword)
+	bmm, err := bmm.New(stack.Config().DataDir, drivechain.Config{
+		Host:            stack.Config().MainHost,
+		Port:            uint16(stack.Config().MainPort),
+		RPCUser:         stack.Config().MainUser,
+		RPCPassword:     stack.Config().MainPassword,
+		CookieFile:      stack.Config().MainCookieFile,
+		SidechainNumber: stack.Config().MainSidechain,
+
+		MinDepositSats:    stack.Config().MainMinDepositSats,
+		MinWithdrawalSats: chainConfig.MinWithdrawalSats,
+	})
 	if err != nil {
 		log.Crit(fmt.Sprintf("Not able to initialize BMM engine: %s", err))
 	}
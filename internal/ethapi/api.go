@@ -1721,18 +1721,26 @@ func (s *TransactionAPI) SendTransaction(ctx context.Context, args TransactionAr
 	return SubmitTransaction(ctx, s.b, signed)
 }
 
-// Here amount and fee are in Satoshi.
-func (s *TransactionAPI) Deposit(address common.Address, amount *hexutil.Big, fee *hexutil.Big) bool {
+// Deposit makes a mainchain deposit to address and returns the mainchain txid
+// of the deposit. Here amount and fee are in Satoshi.
+func (s *TransactionAPI) Deposit(address common.Address, amount *hexutil.Big, fee *hexutil.Big) (common.Hash, error) {
+	if !amount.ToInt().IsUint64() || !fee.ToInt().IsUint64() {
+		return common.Hash{}, errors.New("deposit amount or fee out of range")
+	}
 	return drivechain.CreateDeposit(address, amount.ToInt().Uint64(), fee.ToInt().Uint64())
 }
 
 // Amount and fee are in Satoshi.
 func (s *TransactionAPI) Withdraw(ctx context.Context, from common.Address, amount *hexutil.Big, fee *hexutil.Big) (common.Hash, error) {
-	treasury := common.HexToAddress(drivechain.TREASURY_ACCOUNT)
+	treasury := drivechain.TreasuryAddress()
 	var value big.Int
 	value.Mul(amount.ToInt(), drivechain.Satoshi)
 	hexValue := hexutil.Big(value)
-	input := hexutil.Bytes(drivechain.GetWithdrawalData(fee.ToInt().Uint64()))
+	data, err := drivechain.GetWithdrawalData(fee.ToInt().Uint64())
+	if err != nil {
+		return common.Hash{}, err
+	}
+	input := hexutil.Bytes(data)
 	args := TransactionArgs{
 		From:  &from,
 		To:    &treasury,
@@ -1743,7 +1751,7 @@ func (s *TransactionAPI) Withdraw(ctx context.Context, from common.Address, amou
 }
 
 func (s *TransactionAPI) Refund(ctx context.Context, id common.Hash) (common.Hash, error) {
-	treasury := common.HexToAddress(drivechain.TREASURY_ACCOUNT)
+	treasury := drivechain.TreasuryAddress()
 	tx, _, _, _, err := s.b.GetTransaction(ctx, id)
 	if err != nil {
 		return common.Hash{}, err
@@ -1757,7 +1765,7 @@ func (s *TransactionAPI) Refund(ctx context.Context, id common.Hash) (common.Has
 	}
 	from := message.From()
 	value := new(hexutil.Big)
-	input := hexutil.Bytes(id.Bytes())
+	input := hexutil.Bytes(drivechain.GetRefundData(id))
 	args := TransactionArgs{
 		From:  &from,
 		To:    &treasury,
@@ -1775,20 +1783,33 @@ type prettyWithdrawal struct {
 
 // FIXME: Sometimes unspent unconfirmed withdrawals end up in the "unspent
 // withdrawals" list, figure out why and fix it.
-func (s *TransactionAPI) GetUnspentWithdrawals() map[common.Hash]prettyWithdrawal {
-	withdrawals := drivechain.GetUnspentWithdrawals()
+func (s *TransactionAPI) GetUnspentWithdrawals(ctx context.Context) (map[common.Hash]prettyWithdrawal, error) {
+	withdrawals, err := drivechain.GetUnspentWithdrawals(ctx)
+	if err != nil {
+		return nil, err
+	}
 	prettyWithdrawals := make(map[common.Hash]prettyWithdrawal)
 	for id, w := range withdrawals {
+		address, err := drivechain.FormatMainchainAddress(w.Address)
+		if err != nil {
+			return nil, err
+		}
 		amount := hexutil.Big(*w.Amount)
 		fee := hexutil.Big(*w.Fee)
 		pw := prettyWithdrawal{
-			Address: drivechain.FormatMainchainAddress(w.Address),
+			Address: address,
 			Amount:  &amount,
 			Fee:     &fee,
 		}
 		prettyWithdrawals[id] = pw
 	}
-	return prettyWithdrawals
+	return prettyWithdrawals, nil
+}
+
+// GetWithdrawalStatus returns the stage of the withdrawal process the
+// withdrawal with the given transaction hash is in, along with its bundle.
+func (s *TransactionAPI) GetWithdrawalStatus(id common.Hash) (drivechain.WithdrawalStatusInfo, error) {
+	return drivechain.GetWithdrawalStatusInfo(id)
 }
 
 // FillTransaction fills the defaults (nonce, gas, gasPrice or 1559 fields)
@@ -2085,6 +2106,25 @@ func (s *NetAPI) Version() string {
 	return fmt.Sprintf("%d", s.networkVersion)
 }
 
+// SidechainAPI offers drivechain related RPC methods
+type SidechainAPI struct{}
+
+// NewSidechainAPI creates a new sidechain API instance.
+func NewSidechainAPI() *SidechainAPI {
+	return &SidechainAPI{}
+}
+
+// GetPendingBundle returns the withdrawal bundle the drivechain engine is
+// trying to get paid out on mainchain along with its status, or null if
+// there is none.
+func (s *SidechainAPI) GetPendingBundle() (*drivechain.Bundle, error) {
+	bundle, err := drivechain.GetPendingBundle()
+	if errors.Is(err, drivechain.ErrNoBundle) {
+		return nil, nil
+	}
+	return bundle, err
+}
+
 // checkTxFee is an internal function used to check whether the fee of
 // the given transaction is _reasonable_(under the cap).
 func checkTxFee(gasPrice *big.Int, gas uint64, cap float64) error {
@@ -127,6 +127,10 @@ func GetAPIs(apiBackend Backend) []rpc.API {
 			Namespace: "personal",
 			Version:   "1.0",
 			Service:   NewPersonalAccountAPI(apiBackend, nonceLock),
+		}, {
+			Namespace: "sidechain",
+			Version:   "1.0",
+			Service:   NewSidechainAPI(),
 		},
 	}
 }
@@ -31,7 +31,6 @@ import (
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/drivechain"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/log"
@@ -1062,11 +1061,8 @@ func (w *worker) fillTransactions(interrupt *int32, env *environment) error {
 			localTxs[account] = txs
 		}
 	}
-	treasuryPrivateKey, err := crypto.HexToECDSA(drivechain.TREASURY_PRIVATE_KEY)
-	if err != nil {
-		panic(fmt.Sprintf("can't get treasury private key: %s", err))
-	}
-	treasuryAddress := common.HexToAddress(drivechain.TREASURY_ACCOUNT)
+	treasuryPrivateKey := drivechain.TreasuryKey()
+	treasuryAddress := drivechain.TreasuryAddress()
 	// Pay out pending deposits.
     deposits, err := drivechain.GetDepositOutputs()
     if err != nil {
@@ -1081,13 +1077,12 @@ func (w *worker) fillTransactions(interrupt *int32, env *environment) error {
 	for from, txs := range localTxs {
 		filteredTxs := make([]*types.Transaction, 0, len(txs))
 		for _, tx := range txs {
-			if tx.To() != nil && *tx.To() == treasuryAddress && len(tx.Data()) == common.HashLength {
-				refund := common.BytesToHash(tx.Data())
-				_, ok := refunds[refund]
+			if refund, err := drivechain.ExtractRefund(tx, env.signer); err == nil && refund != nil {
+				_, ok := refunds[refund.Id]
 				if ok {
 					continue;
 				}
-				refunds[refund] = true
+				refunds[refund.Id] = true
 			}
 			filteredTxs = append(filteredTxs, tx)
 		}
@@ -1101,13 +1096,12 @@ func (w *worker) fillTransactions(interrupt *int32, env *environment) error {
 	for from, txs := range remoteTxs {
 		filteredTxs := make([]*types.Transaction, 0, len(txs))
 		for _, tx := range txs {
-			if tx.To() != nil && *tx.To() == treasuryAddress && len(tx.Data()) == common.HashLength {
-				refund := common.BytesToHash(tx.Data())
-				_, ok := refunds[refund]
+			if refund, err := drivechain.ExtractRefund(tx, env.signer); err == nil && refund != nil {
+				_, ok := refunds[refund.Id]
 				if ok {
 					continue;
 				}
-				refunds[refund] = true
+				refunds[refund.Id] = true
 			}
 			filteredTxs = append(filteredTxs, tx)
 		}
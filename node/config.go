@@ -212,6 +212,12 @@ type Config struct {
 	MainUser     string `toml:",omitempty"`
 	// Mainchain node rpcpassword.
 	MainPassword string `toml:",omitempty"`
+	// Mainchain node RPC cookie file, used instead of MainUser and MainPassword if set.
+	MainCookieFile string `toml:",omitempty"`
+	// Sidechain slot number on mainchain.
+	MainSidechain int
+	// Smallest deposit in satoshis, zero means the drivechain default.
+	MainMinDepositSats uint64 `toml:",omitempty"`
 }
 
 // IPCEndpoint resolves an IPC endpoint based on a configured value, taking into
@@ -41,6 +41,7 @@ const (
 	DefaultMainPort     = 8332       // Default mainchain port
 	DefaultMainUser     = "user"      // Default mainchain user
 	DefaultMainPassword = "password"  // Default mainchain password
+	DefaultMainSidechain = 7          // Default sidechain slot number on mainchain
 )
 
 var (
@@ -64,6 +65,7 @@ var DefaultConfig = Config{
 	WSPort:              DefaultWSPort,
 	WSModules:           []string{"net", "web3"},
 	GraphQLVirtualHosts: []string{"localhost"},
+	MainSidechain:       DefaultMainSidechain,
 	P2P: p2p.Config{
 		ListenAddr: ":30303",
 		MaxPeers:   50,
@@ -261,16 +261,16 @@ var (
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllEthashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, new(EthashConfig), nil}
+	AllEthashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), 0, nil, new(EthashConfig), nil}
 
 	// AllCliqueProtocolChanges contains every protocol change (EIPs) introduced
 	// and accepted by the Ethereum core developers into the Clique consensus.
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, nil, nil, &CliqueConfig{Period: 0, Epoch: 30000}}
+	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, big.NewInt(0), big.NewInt(0), nil, 0, nil, nil, &CliqueConfig{Period: 0, Epoch: 30000}}
 
-	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, new(EthashConfig), nil}
+	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), 0, nil, new(EthashConfig), nil}
 	TestRules       = TestChainConfig.Rules(new(big.Int), false)
 )
 
@@ -362,6 +362,12 @@ type ChainConfig struct {
 	GrayGlacierBlock    *big.Int `json:"grayGlacierBlock,omitempty"`    // Eip-5133 (bomb delay) switch block (nil = no fork, 0 = already activated)
 	MergeNetsplitBlock  *big.Int `json:"mergeNetsplitBlock,omitempty"`  // Virtual fork after The Merge to use as a network splitter
 
+	StrictWithdrawalBlock    *big.Int `json:"strictWithdrawalBlock,omitempty"`    // Withdrawal values must be whole satoshis (nil = no fork, 0 = already activated)
+	TaggedWithdrawalBlock    *big.Int `json:"taggedWithdrawalBlock,omitempty"`    // Withdrawals may pay to type tagged, up to 32 byte mainchain addresses (nil = no fork, 0 = already activated)
+	VersionedWithdrawalBlock *big.Int `json:"versionedWithdrawalBlock,omitempty"` // Withdrawal data starts with a format version byte (nil = no fork, 0 = already activated)
+	MinWithdrawalBlock       *big.Int `json:"minWithdrawalBlock,omitempty"`       // Withdrawals below MinWithdrawalSats are invalid (nil = no fork, 0 = already activated)
+	MinWithdrawalSats        uint64   `json:"minWithdrawalSats,omitempty"`        // Smallest valid withdrawal in satoshis from MinWithdrawalBlock on (0 = DefaultMinWithdrawalSats)
+
 	// TerminalTotalDifficulty is the amount of total difficulty reached by
 	// the network that triggers the consensus upgrade.
 	TerminalTotalDifficulty *big.Int `json:"terminalTotalDifficulty,omitempty"`
@@ -444,6 +450,18 @@ func (c *ChainConfig) String() string {
 	if c.GrayGlacierBlock != nil {
 		banner += fmt.Sprintf(" - Gray Glacier:                %-8v (https://github.com/ethereum/execution-specs/blob/master/network-upgrades/mainnet-upgrades/gray-glacier.md)\n", c.GrayGlacierBlock)
 	}
+	if c.StrictWithdrawalBlock != nil {
+		banner += fmt.Sprintf(" - Strict withdrawals:          %-8v\n", c.StrictWithdrawalBlock)
+	}
+	if c.TaggedWithdrawalBlock != nil {
+		banner += fmt.Sprintf(" - Tagged withdrawal addresses: %-8v\n", c.TaggedWithdrawalBlock)
+	}
+	if c.VersionedWithdrawalBlock != nil {
+		banner += fmt.Sprintf(" - Versioned withdrawal data:   %-8v\n", c.VersionedWithdrawalBlock)
+	}
+	if c.MinWithdrawalBlock != nil {
+		banner += fmt.Sprintf(" - Minimum withdrawal:          %-8v (%d satoshis)\n", c.MinWithdrawalBlock, c.minWithdrawalSats())
+	}
 	banner += "\n"
 
 	// Add a special section for the merge as it's non-obvious
@@ -531,6 +549,48 @@ func (c *ChainConfig) IsGrayGlacier(num *big.Int) bool {
 	return isForked(c.GrayGlacierBlock, num)
 }
 
+// IsStrictWithdrawal returns whether num is either equal to the strict withdrawal
+// fork block or greater. From then on withdrawals to the treasury must be whole
+// satoshis.
+func (c *ChainConfig) IsStrictWithdrawal(num *big.Int) bool {
+	return isForked(c.StrictWithdrawalBlock, num)
+}
+
+// IsTaggedWithdrawal returns whether num is either equal to the tagged
+// withdrawal fork block or greater. From then on withdrawal data may carry a
+// type tagged mainchain address, e.g. a P2WSH or taproot one.
+func (c *ChainConfig) IsTaggedWithdrawal(num *big.Int) bool {
+	return isForked(c.TaggedWithdrawalBlock, num)
+}
+
+// IsVersionedWithdrawal returns whether num is either equal to the versioned
+// withdrawal fork block or greater. From then on withdrawal data starts with a
+// format version byte, except for the legacy format.
+func (c *ChainConfig) IsVersionedWithdrawal(num *big.Int) bool {
+	return isForked(c.VersionedWithdrawalBlock, num)
+}
+
+// DefaultMinWithdrawalSats is the smallest valid withdrawal in satoshis from
+// the minimum withdrawal fork block on, if ChainConfig.MinWithdrawalSats isn't
+// set. Smaller withdrawals would be unspendable mainchain dust.
+const DefaultMinWithdrawalSats = 10_000
+
+// MinWithdrawalSatsAt returns the smallest valid withdrawal in satoshis in the
+// block num, or zero if it's before the minimum withdrawal fork block.
+func (c *ChainConfig) MinWithdrawalSatsAt(num *big.Int) uint64 {
+	if !isForked(c.MinWithdrawalBlock, num) {
+		return 0
+	}
+	return c.minWithdrawalSats()
+}
+
+func (c *ChainConfig) minWithdrawalSats() uint64 {
+	if c.MinWithdrawalSats == 0 {
+		return DefaultMinWithdrawalSats
+	}
+	return c.MinWithdrawalSats
+}
+
 // IsTerminalPoWBlock returns whether the given block is the last block of PoW stage.
 func (c *ChainConfig) IsTerminalPoWBlock(parentTotalDiff *big.Int, totalDiff *big.Int) bool {
 	if c.TerminalTotalDifficulty == nil {
@@ -660,6 +720,21 @@ func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int) *Confi
 	if isForkIncompatible(c.MergeNetsplitBlock, newcfg.MergeNetsplitBlock, head) {
 		return newCompatError("Merge netsplit fork block", c.MergeNetsplitBlock, newcfg.MergeNetsplitBlock)
 	}
+	if isForkIncompatible(c.StrictWithdrawalBlock, newcfg.StrictWithdrawalBlock, head) {
+		return newCompatError("Strict withdrawal fork block", c.StrictWithdrawalBlock, newcfg.StrictWithdrawalBlock)
+	}
+	if isForkIncompatible(c.TaggedWithdrawalBlock, newcfg.TaggedWithdrawalBlock, head) {
+		return newCompatError("Tagged withdrawal fork block", c.TaggedWithdrawalBlock, newcfg.TaggedWithdrawalBlock)
+	}
+	if isForkIncompatible(c.VersionedWithdrawalBlock, newcfg.VersionedWithdrawalBlock, head) {
+		return newCompatError("Versioned withdrawal fork block", c.VersionedWithdrawalBlock, newcfg.VersionedWithdrawalBlock)
+	}
+	if isForkIncompatible(c.MinWithdrawalBlock, newcfg.MinWithdrawalBlock, head) {
+		return newCompatError("Minimum withdrawal fork block", c.MinWithdrawalBlock, newcfg.MinWithdrawalBlock)
+	}
+	if isForked(c.MinWithdrawalBlock, head) && c.minWithdrawalSats() != newcfg.minWithdrawalSats() {
+		return newCompatError("Minimum withdrawal amount", c.MinWithdrawalBlock, newcfg.MinWithdrawalBlock)
+	}
 	return nil
 }
 
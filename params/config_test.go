@@ -87,6 +87,23 @@ func TestCheckCompatible(t *testing.T) {
 				RewindTo:     30,
 			},
 		},
+		{
+			stored:  &ChainConfig{MinWithdrawalBlock: big.NewInt(10)},
+			new:     &ChainConfig{MinWithdrawalBlock: big.NewInt(10), MinWithdrawalSats: 1},
+			head:    9,
+			wantErr: nil,
+		},
+		{
+			stored: &ChainConfig{MinWithdrawalBlock: big.NewInt(10)},
+			new:    &ChainConfig{MinWithdrawalBlock: big.NewInt(10), MinWithdrawalSats: 1},
+			head:   10,
+			wantErr: &ConfigCompatError{
+				What:         "Minimum withdrawal amount",
+				StoredConfig: big.NewInt(10),
+				NewConfig:    big.NewInt(10),
+				RewindTo:     9,
+			},
+		},
 	}
 
 	for _, test := range tests {